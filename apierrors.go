@@ -0,0 +1,70 @@
+package metasql
+
+import (
+	stderrors "errors"
+	"net"
+	"strings"
+
+	"github.com/adarsh-jaiss/metasql/errors"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	"github.com/aws/smithy-go"
+)
+
+// credentialsErrorCodes are the error codes of the Data API rejecting the credentials of a request.
+var credentialsErrorCodes = []string{
+	"UnrecognizedClientException",
+	"InvalidClientTokenId",
+	"InvalidSignatureException",
+	"SignatureDoesNotMatch",
+	"ExpiredTokenException",
+	"AccessDeniedException",
+}
+
+// unreachableMessages are fragments of the messages of the validation errors returned when the cluster or
+// workgroup of a request does not exist or can not be used.
+var unreachableMessages = []string{
+	"not found",
+	"does not exist",
+	"not in available state",
+	"is not available",
+}
+
+// classifyAPIError wraps err into an errors.ConfigError when a Data API call failed because of the AWS setup:
+// missing or rejected credentials, or an unknown or unavailable cluster or workgroup. Other errors are
+// returned as they are.
+func classifyAPIError(err error) error {
+	if err == nil {
+		return nil
+	}
+	var signingErr *v4.SigningError
+	if stderrors.As(err, &signingErr) {
+		return errors.NewConfigError(errors.ErrCredentials, err)
+	}
+	var apiErr smithy.APIError
+	if stderrors.As(err, &apiErr) {
+		code := apiErr.ErrorCode()
+		for _, c := range credentialsErrorCodes {
+			if code == c {
+				return errors.NewConfigError(errors.ErrCredentials, err)
+			}
+		}
+		if code == "ResourceNotFoundException" {
+			return errors.NewConfigError(errors.ErrTargetUnreachable, err)
+		}
+		if code == "ValidationException" {
+			message := strings.ToLower(apiErr.ErrorMessage())
+			for _, m := range unreachableMessages {
+				if strings.Contains(message, m) {
+					return errors.NewConfigError(errors.ErrTargetUnreachable, err)
+				}
+			}
+		}
+		return err
+	}
+	// the Data API endpoint of the region could not be reached at all
+	var dnsErr *net.DNSError
+	if stderrors.As(err, &dnsErr) {
+		return errors.NewConfigError(errors.ErrTargetUnreachable, err)
+	}
+	return err
+}
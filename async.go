@@ -0,0 +1,196 @@
+package metasql
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"sync"
+	"time"
+
+	cfg "github.com/adarsh-jaiss/metasql/config"
+	"github.com/adarsh-jaiss/metasql/hooks"
+	"github.com/adarsh-jaiss/metasql/utils"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/redshiftdata"
+)
+
+// Async submits statements to the Redshift Data API without waiting for them to finish.
+// Callers poll Status and page through Result themselves, which suits request/response front ends such as gateways.
+type Async struct {
+	client RedshiftDataClient
+	cfg    *cfg.RedshiftDataConfig
+}
+
+// StatementStatus is a snapshot of a submitted statement as reported by DescribeStatement.
+type StatementStatus struct {
+	ID           string        `json:"id"`
+	Status       string        `json:"status"`
+	Error        string        `json:"error,omitempty"`
+	Query        string        `json:"query,omitempty"`
+	HasResultSet bool          `json:"has_result_set"`
+	ResultRows   int64         `json:"result_rows"`
+	ResultSize   int64         `json:"result_size"`
+	Duration     time.Duration `json:"duration"`
+	// QueueDuration and ExecDuration split the lifetime of a finished statement into the time it waited on the
+	// warehouse, queueing and cold starts included, and the time it executed. Both are zero until it finished.
+	QueueDuration time.Duration `json:"queue_duration"`
+	ExecDuration  time.Duration `json:"exec_duration"`
+	CreatedAt     time.Time     `json:"created_at"`
+	UpdatedAt     time.Time     `json:"updated_at"`
+}
+
+// Finished reports whether the statement reached a final status.
+func (s *StatementStatus) Finished() bool {
+	return s.Status == "FINISHED" || s.Status == "FAILED" || s.Status == "ABORTED"
+}
+
+// ResultPage is one page of records of a finished statement.
+type ResultPage struct {
+	Columns   []string `json:"columns"`
+	Rows      [][]any  `json:"rows"`
+	NextToken string   `json:"next_token,omitempty"`
+	TotalRows int64    `json:"total_rows"`
+}
+
+// NewAsync creates an Async using the client returned by NewRedshiftDataClient for the given config.
+func NewAsync(ctx context.Context, cfg *cfg.RedshiftDataConfig) (*Async, error) {
+	client, err := NewRedshiftDataClient(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	return NewAsyncWithClient(client, cfg), nil
+}
+
+// NewAsyncWithClient returns an Async using the provided RedshiftDataClient and RedshiftDataConfig.
+func NewAsyncWithClient(client RedshiftDataClient, cfg *cfg.RedshiftDataConfig) *Async {
+	return &Async{
+		client: client,
+		cfg:    cfg,
+	}
+}
+
+// submittedKey holds the function executeStatement reports the id of a submitted statement to.
+type submittedKey struct{}
+
+// Submit starts the statement and returns its id once the Data API accepted it.
+// Arguments follow the database/sql conventions, sql.Named values bind named parameters.
+//
+// The statement runs as the connections of the driver run statements, see Executor: placeholders, hooks, quotas,
+// the statement timeout and the cancel mode apply. It is waited for in the background, so the AfterStatement
+// hooks see it finish and it holds its active statement slot until then. Once submitted, it is not cancelled
+// when ctx is done.
+func (a *Async) Submit(ctx context.Context, query string, args ...any) (string, error) {
+	namedArgs, err := namedValues(args, a.cfg.ParameterEncoder)
+	if err != nil {
+		return "", err
+	}
+	runCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	// ctx is only followed until the statement was submitted, waiting for quota or submission retries
+	stop := context.AfterFunc(ctx, cancel)
+	submitted := make(chan string, 1)
+	done := make(chan error, 1)
+	var once sync.Once
+	runCtx = context.WithValue(runCtx, submittedKey{}, func(id string) {
+		once.Do(func() {
+			stop()
+			submitted <- id
+		})
+	})
+	go func() {
+		defer cancel()
+		conn := NewConnection(a.client, a.cfg)
+		defer conn.Close()
+		_, _, err := conn.runStatement(runCtx, hooks.KindExec, query, namedArgs)
+		done <- err
+	}()
+	select {
+	case id := <-submitted:
+		return id, nil
+	case err := <-done:
+		// a statement failing right away was submitted, its status reports the error
+		select {
+		case id := <-submitted:
+			return id, nil
+		default:
+			return "", err
+		}
+	}
+}
+
+// reportSubmitted reports the id of a submitted statement to Submit, when the statement is run by Submit.
+func reportSubmitted(ctx context.Context, id *string) {
+	if submitted, ok := ctx.Value(submittedKey{}).(func(string)); ok {
+		submitted(aws.ToString(id))
+	}
+}
+
+// Status describes the statement with the given id.
+func (a *Async) Status(ctx context.Context, id string) (*StatementStatus, error) {
+	desc, err := a.client.DescribeStatement(ctx, &redshiftdata.DescribeStatementInput{
+		Id: aws.String(id),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("describe statement error: %w", err)
+	}
+	return statementStatus(desc), nil
+}
+
+// Cancel cancels the statement with the given id.
+func (a *Async) Cancel(ctx context.Context, id string) error {
+	_, err := a.client.CancelStatement(ctx, &redshiftdata.CancelStatementInput{
+		Id: aws.String(id),
+	})
+	if err != nil {
+		return fmt.Errorf("cancel statement error: %w", err)
+	}
+	return nil
+}
+
+// Result fetches one page of the statement's result set, starting at nextToken or at the beginning when it is empty.
+func (a *Async) Result(ctx context.Context, id string, nextToken string) (*ResultPage, error) {
+	output, err := a.client.GetStatementResult(ctx, &redshiftdata.GetStatementResultInput{
+		Id:        aws.String(id),
+		NextToken: utils.Nullif(nextToken),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("get statement result error: %w", err)
+	}
+	page := &ResultPage{
+		Columns:   make([]string, 0, len(output.ColumnMetadata)),
+		Rows:      make([][]any, 0, len(output.Records)),
+		NextToken: utils.Coalesce(output.NextToken),
+		TotalRows: output.TotalNumRows,
+	}
+	for _, c := range output.ColumnMetadata {
+		page.Columns = append(page.Columns, utils.Coalesce(c.Name))
+	}
+	for _, record := range output.Records {
+		row := make([]any, len(record))
+		for i, field := range record {
+			row[i] = convertField(field)
+		}
+		page.Rows = append(page.Rows, row)
+	}
+	return page, nil
+}
+
+// namedValues converts database/sql style arguments into driver.NamedValue, checked as the driver checks them.
+func namedValues(args []any, encoder cfg.ParameterEncoder) ([]driver.NamedValue, error) {
+	if len(args) == 0 {
+		return nil, nil
+	}
+	values := make([]driver.NamedValue, 0, len(args))
+	for i, arg := range args {
+		nv := driver.NamedValue{Ordinal: i + 1, Value: arg}
+		if named, ok := arg.(sql.NamedArg); ok {
+			nv.Name = named.Name
+			nv.Value = named.Value
+		}
+		if err := checkNamedValue(&nv, encoder); err != nil {
+			return nil, err
+		}
+		values = append(values, nv)
+	}
+	return values, nil
+}
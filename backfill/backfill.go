@@ -0,0 +1,255 @@
+// Package backfill runs a templated INSERT or CREATE TABLE AS statement over a partition key range, one chunk
+// at a time. Finished chunks are recorded in a state table, so a job that failed or was interrupted resumes
+// where it stopped when it is run again.
+package backfill
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+)
+
+// DefaultStateTable is the table finished chunks are recorded in when Options.StateTable is empty.
+const DefaultStateTable = "public.metasql_backfill_state"
+
+// Chunk is one slice of the partition key range. Start is inclusive and End exclusive.
+type Chunk struct {
+	Index int    // Index is the position of the chunk in the job, it identifies the chunk in the state table.
+	Start string // Start is the first key of the chunk as a SQL literal, such as 100 or '2024-01-01 00:00:00'.
+	End   string // End is the key following the chunk as a SQL literal.
+}
+
+// IntChunks splits [start, end) into chunks of size keys.
+func IntChunks(start, end, size int64) []Chunk {
+	if size <= 0 {
+		size = end - start
+	}
+	var chunks []Chunk
+	for lo := start; lo < end; lo += size {
+		hi := min(lo+size, end)
+		chunks = append(chunks, Chunk{Index: len(chunks), Start: strconv.FormatInt(lo, 10), End: strconv.FormatInt(hi, 10)})
+	}
+	return chunks
+}
+
+// TimeChunks splits [start, end) into chunks of size, such as 24 * time.Hour for daily partitions.
+func TimeChunks(start, end time.Time, size time.Duration) []Chunk {
+	if size <= 0 {
+		size = end.Sub(start)
+	}
+	var chunks []Chunk
+	for lo := start; lo.Before(end); lo = lo.Add(size) {
+		hi := lo.Add(size)
+		if hi.After(end) {
+			hi = end
+		}
+		chunks = append(chunks, Chunk{Index: len(chunks), Start: timeLiteral(lo), End: timeLiteral(hi)})
+	}
+	return chunks
+}
+
+func timeLiteral(t time.Time) string {
+	return "'" + t.UTC().Format("2006-01-02 15:04:05.999999") + "'"
+}
+
+// Job is a backfill to run.
+type Job struct {
+	Name string // Name identifies the job in the state table, running a job with the same name again resumes it.
+	// Query is a text/template of the statement run for each chunk, executed with the Chunk as data, such as
+	// INSERT INTO sales SELECT * FROM staging.sales WHERE sold_at >= {{.Start}} AND sold_at < {{.End}}
+	Query  string
+	Chunks []Chunk
+}
+
+// Options controls how a job is run.
+type Options struct {
+	StateTable  string         // StateTable is the schema qualified table recording finished chunks, DefaultStateTable when empty.
+	Concurrency int            // Concurrency is the number of chunks run at once, chunks run sequentially when it is below 2.
+	Retries     int            // Retries is the number of times a failed chunk is run again before the job fails.
+	Backoff     time.Duration  // Backoff is the delay before the first retry of a chunk, doubled after each one. Defaults to one second.
+	Progress    func(Progress) // Progress, when set, is called after every chunk.
+}
+
+// Progress reports the state of a running job.
+type Progress struct {
+	Chunk    Chunk         // Chunk is the chunk that just finished or failed.
+	Err      error         // Err is the error of the chunk after its last attempt, nil when it succeeded.
+	Attempts int           // Attempts is the number of times the chunk was run.
+	Duration time.Duration // Duration is the time spent on the chunk, retries included.
+	Done     int           // Done is the number of chunks finished so far, skipped ones included.
+	Total    int           // Total is the number of chunks of the job.
+}
+
+// Report is the outcome of a job.
+type Report struct {
+	Done    int           // Done is the number of chunks run successfully.
+	Skipped int           // Skipped is the number of chunks recorded as finished by an earlier run.
+	Failed  []ChunkError  // Failed lists the chunks that still failed after their retries.
+	Elapsed time.Duration // Elapsed is the duration of the run.
+}
+
+// ChunkError is the error of a chunk that failed.
+type ChunkError struct {
+	Chunk Chunk
+	Err   error
+}
+
+func (e ChunkError) Error() string {
+	return fmt.Sprintf("chunk %d [%s, %s): %v", e.Chunk.Index, e.Chunk.Start, e.Chunk.End, e.Err)
+}
+
+func (e ChunkError) Unwrap() error {
+	return e.Err
+}
+
+// Run runs the chunks of the job not recorded as finished in the state table, creating the table when needed.
+// Once a chunk failed after its retries no further chunk is started, chunks already running are finished,
+// and the error of the first failed chunk is returned together with the report.
+func Run(ctx context.Context, db *sql.DB, job Job, opts Options) (*Report, error) {
+	start := time.Now()
+	if job.Name == "" {
+		return nil, fmt.Errorf("backfill: job name is required")
+	}
+	tmpl, err := template.New(job.Name).Option("missingkey=error").Parse(job.Query)
+	if err != nil {
+		return nil, fmt.Errorf("backfill %s: %w", job.Name, err)
+	}
+	state := opts.stateTable()
+	if _, err := db.ExecContext(ctx, "CREATE TABLE IF NOT EXISTS "+state+" (job VARCHAR(256) NOT NULL, chunk INTEGER NOT NULL, range_start VARCHAR(64), range_end VARCHAR(64), attempts INTEGER, finished_at TIMESTAMP)"); err != nil {
+		return nil, fmt.Errorf("backfill %s: create state table: %w", job.Name, err)
+	}
+	finished, err := finishedChunks(ctx, db, state, job.Name)
+	if err != nil {
+		return nil, fmt.Errorf("backfill %s: %w", job.Name, err)
+	}
+
+	report := &Report{}
+	var pending []Chunk
+	for _, c := range job.Chunks {
+		if finished[c.Index] {
+			report.Skipped++
+			continue
+		}
+		pending = append(pending, c)
+	}
+
+	var mu sync.Mutex
+	var firstErr error
+	work := make(chan Chunk)
+	var wg sync.WaitGroup
+	for range max(1, opts.Concurrency) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for c := range work {
+				chunkStart := time.Now()
+				attempts, err := runChunk(ctx, db, tmpl, c, opts)
+				if err == nil {
+					_, err = db.ExecContext(ctx, "INSERT INTO "+state+" (job, chunk, range_start, range_end, attempts, finished_at) VALUES (?, ?, ?, ?, ?, GETDATE())", job.Name, c.Index, c.Start, c.End, attempts)
+				}
+				mu.Lock()
+				if err != nil {
+					chunkErr := ChunkError{Chunk: c, Err: err}
+					report.Failed = append(report.Failed, chunkErr)
+					if firstErr == nil {
+						firstErr = fmt.Errorf("backfill %s: %w", job.Name, chunkErr)
+					}
+				} else {
+					report.Done++
+				}
+				if opts.Progress != nil {
+					opts.Progress(Progress{
+						Chunk:    c,
+						Err:      err,
+						Attempts: attempts,
+						Duration: time.Since(chunkStart),
+						Done:     report.Done + report.Skipped,
+						Total:    len(job.Chunks),
+					})
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+dispatch:
+	for _, c := range pending {
+		mu.Lock()
+		failed := firstErr != nil
+		mu.Unlock()
+		if failed {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			break dispatch
+		case work <- c:
+		}
+	}
+	close(work)
+	wg.Wait()
+	report.Elapsed = time.Since(start)
+	if firstErr == nil && ctx.Err() != nil {
+		firstErr = fmt.Errorf("backfill %s: %w", job.Name, ctx.Err())
+	}
+	return report, firstErr
+}
+
+// runChunk renders the statement of the chunk and executes it, retrying with backoff. It returns the number of attempts.
+func runChunk(ctx context.Context, db *sql.DB, tmpl *template.Template, c Chunk, opts Options) (int, error) {
+	var b strings.Builder
+	if err := tmpl.Execute(&b, c); err != nil {
+		return 0, err
+	}
+	backoff := opts.Backoff
+	if backoff <= 0 {
+		backoff = time.Second
+	}
+	for attempt := 1; ; attempt++ {
+		_, err := db.ExecContext(ctx, b.String())
+		if err == nil || attempt > opts.Retries || ctx.Err() != nil {
+			return attempt, err
+		}
+		select {
+		case <-ctx.Done():
+			return attempt, err
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+// finishedChunks returns the indexes of the chunks of the job recorded in the state table.
+func finishedChunks(ctx context.Context, db *sql.DB, state, job string) (map[int]bool, error) {
+	rows, err := db.QueryContext(ctx, "SELECT chunk FROM "+state+" WHERE job = ?", job)
+	if err != nil {
+		return nil, fmt.Errorf("read state table: %w", err)
+	}
+	defer rows.Close()
+	finished := map[int]bool{}
+	for rows.Next() {
+		var chunk int
+		if err := rows.Scan(&chunk); err != nil {
+			return nil, err
+		}
+		finished[chunk] = true
+	}
+	return finished, rows.Err()
+}
+
+// Reset forgets the finished chunks of the job, so the next run starts over.
+func Reset(ctx context.Context, db *sql.DB, job string, opts Options) error {
+	_, err := db.ExecContext(ctx, "DELETE FROM "+opts.stateTable()+" WHERE job = ?", job)
+	return err
+}
+
+func (opts Options) stateTable() string {
+	if opts.StateTable == "" {
+		return DefaultStateTable
+	}
+	return opts.StateTable
+}
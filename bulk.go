@@ -0,0 +1,77 @@
+package metasql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// BulkInsertMaxParams bounds the number of parameters of a single INSERT statement issued by BulkInsert.
+// Rows are split into as many statements as needed to stay below it.
+var BulkInsertMaxParams = 500
+
+// BulkInsert inserts rows into table with multi-row INSERT statements, which is far cheaper than one
+// Data API call per row. NULL values are rendered as literals since Data API parameters can not be null.
+// It returns the total number of inserted rows.
+func BulkInsert(ctx context.Context, db *sql.DB, table string, columns []string, rows [][]any) (int64, error) {
+	return bulkInsert(ctx, db, table, columns, rows)
+}
+
+// execer is a *sql.DB, *sql.Conn or *sql.Tx.
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+func bulkInsert(ctx context.Context, db execer, table string, columns []string, rows [][]any) (int64, error) {
+	if len(columns) == 0 {
+		return 0, fmt.Errorf("bulk insert into %s: no columns", table)
+	}
+	chunkSize := max(BulkInsertMaxParams/len(columns), 1)
+	var total int64
+	for start := 0; start < len(rows); start += chunkSize {
+		end := min(start+chunkSize, len(rows))
+		query, args, err := bulkInsertQuery(table, columns, rows[start:end])
+		if err != nil {
+			return total, fmt.Errorf("bulk insert into %s: %w", table, err)
+		}
+		res, err := db.ExecContext(withStatementToken(ctx, start/chunkSize), query, args...)
+		if err != nil {
+			return total, fmt.Errorf("bulk insert into %s: rows %d-%d: %w", table, start, end-1, err)
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			n = int64(end - start)
+		}
+		total += n
+	}
+	return total, nil
+}
+
+func bulkInsertQuery(table string, columns []string, rows [][]any) (string, []any, error) {
+	var b strings.Builder
+	fmt.Fprintf(&b, "INSERT INTO %s (%s) VALUES ", table, strings.Join(columns, ", "))
+	args := make([]any, 0, len(rows)*len(columns))
+	for i, row := range rows {
+		if len(row) != len(columns) {
+			return "", nil, fmt.Errorf("row %d has %d values, expected %d", i, len(row), len(columns))
+		}
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		b.WriteByte('(')
+		for j, v := range row {
+			if j > 0 {
+				b.WriteString(", ")
+			}
+			if v == nil {
+				b.WriteString("NULL")
+				continue
+			}
+			b.WriteByte('?')
+			args = append(args, v)
+		}
+		b.WriteByte(')')
+	}
+	return b.String(), args, nil
+}
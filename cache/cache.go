@@ -0,0 +1,102 @@
+// Package cache defines the storage of the query result cache. Results are stored as opaque bytes under
+// keys derived from the query, its arguments and its target, so any key-value store with expiration, such as
+// Redis or memcached, can share cached results between the replicas of a service by implementing Cache.
+// An in-memory LRU is used when the config has no Cache.
+package cache
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+)
+
+// DefaultSize is the number of results kept by the LRU used when the config has no Cache.
+const DefaultSize = 1000
+
+// Cache stores encoded results. Errors are not fatal to queries: a failing Get is treated as a miss and a
+// failing Set leaves the result uncached.
+type Cache interface {
+	// Get returns the value stored under key, ok is false when there is none or it expired.
+	Get(ctx context.Context, key string) (value []byte, ok bool, err error)
+	// Set stores value under key for ttl.
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	// Invalidate removes the value stored under key, if any.
+	Invalidate(ctx context.Context, key string) error
+}
+
+// LRU is an in-memory Cache holding a bounded number of values, evicting the least recently used one first.
+type LRU struct {
+	size int
+
+	mu      sync.Mutex
+	order   *list.List // order holds the *lruEntry values, most recently used first.
+	entries map[string]*list.Element
+}
+
+type lruEntry struct {
+	key     string
+	value   []byte
+	expires time.Time
+}
+
+// NewLRU returns an LRU holding up to size values, DefaultSize when size is not positive.
+func NewLRU(size int) *LRU {
+	if size <= 0 {
+		size = DefaultSize
+	}
+	return &LRU{size: size, order: list.New(), entries: map[string]*list.Element{}}
+}
+
+func (c *LRU) Get(_ context.Context, key string) ([]byte, bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[key]
+	if !ok {
+		return nil, false, nil
+	}
+	entry := e.Value.(*lruEntry)
+	if time.Now().After(entry.expires) {
+		c.remove(e)
+		return nil, false, nil
+	}
+	c.order.MoveToFront(e)
+	return entry.value, true, nil
+}
+
+func (c *LRU) Set(_ context.Context, key string, value []byte, ttl time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry := &lruEntry{key: key, value: value, expires: time.Now().Add(ttl)}
+	if e, ok := c.entries[key]; ok {
+		e.Value = entry
+		c.order.MoveToFront(e)
+		return nil
+	}
+	c.entries[key] = c.order.PushFront(entry)
+	for c.order.Len() > c.size {
+		c.remove(c.order.Back())
+	}
+	return nil
+}
+
+func (c *LRU) Invalidate(_ context.Context, key string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.entries[key]; ok {
+		c.remove(e)
+	}
+	return nil
+}
+
+// Len returns the number of values held, expired ones included until they are looked up or evicted.
+func (c *LRU) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
+
+func (c *LRU) remove(e *list.Element) {
+	c.order.Remove(e)
+	delete(c.entries, e.Value.(*lruEntry).key)
+}
@@ -0,0 +1,67 @@
+package metasql
+
+import (
+	"context"
+	"database/sql"
+	stderrors "errors"
+	"fmt"
+
+	"github.com/adarsh-jaiss/metasql/errors"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/redshiftdata"
+	awstypes "github.com/aws/aws-sdk-go-v2/service/redshiftdata/types"
+)
+
+// activeStatuses are the statuses of the statements that can still be cancelled.
+var activeStatuses = []awstypes.StatusString{awstypes.StatusStringSubmitted, awstypes.StatusStringPicked, awstypes.StatusStringStarted}
+
+// CancelByTag cancels the active statements submitted with tag as their statement name, see WithStatementName,
+// so a "stop query" button of a UI can cancel the statements of the request it started. It returns the number
+// of statements cancelled. Statements are found with the Data API ListStatements operation, filtered by name,
+// which only lists the statements of the caller's IAM identity.
+func CancelByTag(ctx context.Context, db *sql.DB, tag string) (int, error) {
+	name := truncateStatementName(tag)
+	if name == "" {
+		return 0, fmt.Errorf("cancel by tag: empty tag")
+	}
+	c, err := db.Conn(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer c.Close()
+	cancelled := 0
+	err = rawConn(c, func(conn *redshiftDataConn) error {
+		lister, ok := conn.client.(redshiftdata.ListStatementsAPIClient)
+		if !ok {
+			return errors.NewUnsupportedError(fmt.Sprintf("listing statements with %T", conn.client), errors.LimitationDriver, "use a client implementing ListStatements")
+		}
+		// every page is listed before cancelling, statements leaving the listed status would shift the pages
+		var ids []*string
+		for _, status := range activeStatuses {
+			p := redshiftdata.NewListStatementsPaginator(lister, &redshiftdata.ListStatementsInput{Status: status, StatementName: aws.String(name)})
+			for p.HasMorePages() {
+				page, err := p.NextPage(ctx, conn.cfg.RedshiftDataOptFns...)
+				if err != nil {
+					return fmt.Errorf("list statements error: %w", err)
+				}
+				for _, stmt := range page.Statements {
+					// the Data API matches the statement name as a prefix
+					if aws.ToString(stmt.StatementName) == name {
+						ids = append(ids, stmt.Id)
+					}
+				}
+			}
+		}
+		var errs []error
+		for _, id := range ids {
+			// a statement finishing meanwhile can no longer be cancelled, the others still are
+			if err := conn.cancelStatement(id); err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			cancelled++
+		}
+		return stderrors.Join(errs...)
+	})
+	return cancelled, err
+}
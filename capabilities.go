@@ -0,0 +1,136 @@
+package metasql
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"strconv"
+	"sync"
+	"time"
+
+	cfg "github.com/adarsh-jaiss/metasql/config"
+	"github.com/adarsh-jaiss/metasql/hooks"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/redshiftdata"
+)
+
+// WarehouseCapabilities describes what the cluster or workgroup of a connector supports.
+type WarehouseCapabilities struct {
+	Serverless     bool // Serverless reports whether the target is a serverless workgroup.
+	Sessions       bool // Sessions reports whether statements can be pinned to a Data API session, see PinSession.
+	ResultFormatV2 bool // ResultFormatV2 reports whether CSV results of GetStatementResultV2 are available, not used by the driver yet.
+	MaxConcurrency int  // MaxConcurrency is the number of query slots of the user queues of the cluster, 0 when unknown.
+
+	Features map[cfg.Feature]bool // Features holds the state of every feature of the driver on the config of the connector.
+}
+
+// capabilitiesCache holds the capabilities of a connector once probed. Failed probes are not cached.
+type capabilitiesCache struct {
+	mu   sync.Mutex
+	caps *WarehouseCapabilities
+}
+
+// peek returns the capabilities when they were probed already, nil otherwise.
+func (c *capabilitiesCache) peek() *WarehouseCapabilities {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.caps
+}
+
+// Capabilities probes the cluster or workgroup behind db, once per connector, and returns its capabilities.
+// Probing executes a couple of statements. Once known, the capabilities let the driver reject features the
+// target lacks upfront, such as pinning sessions. The state of the features of the config is reported along.
+func Capabilities(ctx context.Context, db *sql.DB) (*WarehouseCapabilities, error) {
+	c, err := db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer c.Close()
+	var caps *WarehouseCapabilities
+	err = rawConn(c, func(conn *redshiftDataConn) error {
+		probed, err := conn.capabilities(ctx)
+		if err != nil {
+			return err
+		}
+		withFeatures := *probed
+		withFeatures.Features = conn.cfg.Features.State()
+		caps = &withFeatures
+		return nil
+	})
+	return caps, err
+}
+
+// capabilities returns the cached capabilities of the connection, probing them when they are not known yet.
+func (conn *redshiftDataConn) capabilities(ctx context.Context) (*WarehouseCapabilities, error) {
+	conn.caps.mu.Lock()
+	defer conn.caps.mu.Unlock()
+	if conn.caps.caps != nil {
+		return conn.caps.caps, nil
+	}
+	caps := &WarehouseCapabilities{Serverless: conn.cfg.WorkgroupName != nil}
+	var err error
+	if caps.Sessions, err = conn.probeSessions(ctx); err != nil {
+		return nil, err
+	}
+	if !caps.Serverless {
+		// stv_wlm_service_class_config is not available on serverless workgroups, which scale by RPUs instead.
+		if caps.MaxConcurrency, err = conn.queryInt(ctx, "SELECT COALESCE(SUM(num_query_tasks), 0) FROM stv_wlm_service_class_config WHERE service_class >= 6"); err != nil {
+			caps.MaxConcurrency = 0
+		}
+	}
+	conn.caps.caps = caps
+	return caps, nil
+}
+
+// probeSessions starts a statement asking for a session with the shortest keep-alive and reports whether one was created.
+func (conn *redshiftDataConn) probeSessions(ctx context.Context) (bool, error) {
+	database, dbUser, secretArn, err := routeTarget(conn.withDatabase(ctx), conn.cfg)
+	if err != nil {
+		return false, err
+	}
+	output, err := conn.client.ExecuteStatement(ctx, &redshiftdata.ExecuteStatementInput{
+		Sql:                     aws.String("SELECT 1"),
+		ClusterIdentifier:       conn.cfg.ClusterIdentifier,
+		Database:                database,
+		DbUser:                  dbUser,
+		SecretArn:               secretArn,
+		WorkgroupName:           conn.cfg.WorkgroupName,
+		SessionKeepAliveSeconds: aws.Int32(1),
+	})
+	if err != nil {
+		return false, fmt.Errorf("probe sessions error (%s): %w", conn.info.ID, err)
+	}
+	if _, err := conn.waitWithCancel(ctx, output.Id, time.Now()); err != nil {
+		return false, err
+	}
+	return output.SessionId != nil, nil
+}
+
+// queryInt runs a query returning a single integer.
+func (conn *redshiftDataConn) queryInt(ctx context.Context, query string) (int, error) {
+	p, output, err := conn.runStatement(withoutClientToken(ctx), hooks.KindQuery, query, nil)
+	if err != nil {
+		return 0, err
+	}
+	rows, err := conn.newRows(ctx, aws.ToString(output.Id), p)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+	dest := make([]driver.Value, len(rows.columns))
+	if err := rows.Next(dest); err != nil {
+		if err == io.EOF {
+			return 0, fmt.Errorf("%s returned no rows", query)
+		}
+		return 0, err
+	}
+	switch v := dest[0].(type) {
+	case int64:
+		return int(v), nil
+	case string:
+		return strconv.Atoi(v)
+	}
+	return 0, fmt.Errorf("%s returned %T", query, dest[0])
+}
@@ -0,0 +1,38 @@
+package metasql
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/redshiftdata"
+)
+
+type resumeKey struct{}
+
+type resumePoint struct {
+	statementID string
+	nextToken   string
+}
+
+// WithResume returns a context whose queries do not execute anything but fetch the result set of the statement
+// statementID from nextToken on, as reported by a Checkpoint hook. The query text and arguments are ignored.
+// The Data API keeps results for 24 hours.
+func WithResume(ctx context.Context, statementID, nextToken string) context.Context {
+	return context.WithValue(ctx, resumeKey{}, resumePoint{statementID: statementID, nextToken: nextToken})
+}
+
+// resumeRows returns the rows resumed from the checkpoint of the context, ok is false when there is none.
+func (conn *redshiftDataConn) resumeRows(ctx context.Context) (rows *redshiftDataRows, ok bool, err error) {
+	point, ok := ctx.Value(resumeKey{}).(resumePoint)
+	if !ok {
+		return nil, false, nil
+	}
+	input := &redshiftdata.GetStatementResultInput{
+		Id: aws.String(point.statementID),
+	}
+	if point.nextToken != "" {
+		input.NextToken = aws.String(point.nextToken)
+	}
+	rows, err = conn.newRows(ctx, point.statementID, redshiftdata.NewGetStatementResultPaginator(conn.client, input))
+	return rows, true, err
+}
@@ -0,0 +1,237 @@
+// Package checks runs data quality expectations declared as YAML, such as row count ranges, null rates,
+// uniqueness and referential integrity. Every check is turned into a query executed through the driver,
+// and the outcomes are gathered in a report pipelines can fail on.
+package checks
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Check is one expectation on a table. Exactly one of RowCount, NullRate, Unique, References and SQL is set.
+type Check struct {
+	Name  string `yaml:"name"`  // Name identifies the check in the report, derived from the expectation when empty.
+	Table string `yaml:"table"` // Table is the [schema.]table the expectation is about.
+	Where string `yaml:"where"` // Where, when set, restricts the checked rows, such as loaded_at > GETDATE() - 1.
+
+	RowCount   *Range     `yaml:"row_count"`  // RowCount expects the number of rows within the range.
+	NullRate   *NullRate  `yaml:"null_rate"`  // NullRate expects at most a share of NULLs in a column.
+	Unique     []string   `yaml:"unique"`     // Unique expects no two rows with the same values of the columns.
+	References *Reference `yaml:"references"` // References expects every non-NULL value of a column in another table.
+	SQL        string     `yaml:"sql"`        // SQL is a custom query returning the number of offending rows, expected to be 0.
+}
+
+// Range bounds a value, a nil bound is not checked.
+type Range struct {
+	Min *float64 `yaml:"min"`
+	Max *float64 `yaml:"max"`
+}
+
+// NullRate is the largest share of NULLs allowed in a column, between 0 and 1.
+type NullRate struct {
+	Column string  `yaml:"column"`
+	Max    float64 `yaml:"max"`
+}
+
+// Reference is a foreign key checked by the References expectation.
+type Reference struct {
+	Column   string `yaml:"column"`    // Column is the referencing column of the checked table.
+	Table    string `yaml:"table"`     // Table is the referenced table.
+	ToColumn string `yaml:"to_column"` // ToColumn is the referenced column, Column when empty.
+}
+
+// Load reads a YAML list of checks.
+func Load(r io.Reader) ([]Check, error) {
+	var checks []Check
+	if err := yaml.NewDecoder(r).Decode(&checks); err != nil {
+		return nil, fmt.Errorf("load checks: %w", err)
+	}
+	for i := range checks {
+		if _, _, err := checks[i].Query(); err != nil {
+			return nil, fmt.Errorf("load checks: check %d: %w", i+1, err)
+		}
+	}
+	return checks, nil
+}
+
+// Query returns the query computing the observed value of the check and a description of the expected one.
+func (c Check) Query() (query string, expected string, err error) {
+	set := 0
+	for _, ok := range []bool{c.RowCount != nil, c.NullRate != nil, len(c.Unique) > 0, c.References != nil, c.SQL != ""} {
+		if ok {
+			set++
+		}
+	}
+	if set != 1 {
+		return "", "", fmt.Errorf("%s: exactly one of row_count, null_rate, unique, references and sql must be set", c.name())
+	}
+	if c.SQL != "" {
+		return c.SQL, "0 offending rows", nil
+	}
+	if c.Table == "" {
+		return "", "", fmt.Errorf("%s: table is required", c.name())
+	}
+	where := ""
+	if c.Where != "" {
+		where = " WHERE (" + c.Where + ")"
+	}
+	switch {
+	case c.RowCount != nil:
+		return "SELECT COUNT(*) FROM " + c.Table + where, c.RowCount.String() + " rows", nil
+	case c.NullRate != nil:
+		return fmt.Sprintf("SELECT COALESCE(SUM(CASE WHEN %s IS NULL THEN 1 ELSE 0 END)::FLOAT / NULLIF(COUNT(*), 0), 0) FROM %s%s", c.NullRate.Column, c.Table, where),
+			"null rate <= " + strconv.FormatFloat(c.NullRate.Max, 'f', -1, 64), nil
+	case len(c.Unique) > 0:
+		columns := strings.Join(c.Unique, ", ")
+		return fmt.Sprintf("SELECT COUNT(*) FROM (SELECT %s FROM %s%s GROUP BY %s HAVING COUNT(*) > 1)", columns, c.Table, where, columns), "0 duplicate keys", nil
+	}
+	ref := c.References
+	toColumn := ref.ToColumn
+	if toColumn == "" {
+		toColumn = ref.Column
+	}
+	if c.Where != "" {
+		where = " AND (" + c.Where + ")"
+	}
+	return fmt.Sprintf("SELECT COUNT(*) FROM %s c WHERE c.%s IS NOT NULL%s AND NOT EXISTS (SELECT 1 FROM %s p WHERE p.%s = c.%s)", c.Table, ref.Column, where, ref.Table, toColumn, ref.Column),
+		"0 orphaned rows", nil
+}
+
+// name returns the name of the check, derived from its expectation when it has none.
+func (c Check) name() string {
+	switch {
+	case c.Name != "":
+		return c.Name
+	case c.RowCount != nil:
+		return c.Table + " row_count"
+	case c.NullRate != nil:
+		return c.Table + "." + c.NullRate.Column + " null_rate"
+	case len(c.Unique) > 0:
+		return c.Table + " unique(" + strings.Join(c.Unique, ", ") + ")"
+	case c.References != nil:
+		return c.Table + "." + c.References.Column + " references " + c.References.Table
+	}
+	return "sql"
+}
+
+// pass reports whether the observed value meets the expectation.
+func (c Check) pass(observed float64) bool {
+	switch {
+	case c.RowCount != nil:
+		return c.RowCount.contains(observed)
+	case c.NullRate != nil:
+		return observed <= c.NullRate.Max
+	}
+	return observed == 0
+}
+
+func (r Range) contains(v float64) bool {
+	return (r.Min == nil || v >= *r.Min) && (r.Max == nil || v <= *r.Max)
+}
+
+func (r Range) String() string {
+	format := func(v float64) string { return strconv.FormatFloat(v, 'f', -1, 64) }
+	switch {
+	case r.Min != nil && r.Max != nil:
+		return "between " + format(*r.Min) + " and " + format(*r.Max)
+	case r.Min != nil:
+		return ">= " + format(*r.Min)
+	case r.Max != nil:
+		return "<= " + format(*r.Max)
+	}
+	return "any number of"
+}
+
+// Result is the outcome of a check.
+type Result struct {
+	Name     string        `json:"name"`
+	Query    string        `json:"query"`
+	Expected string        `json:"expected"`
+	Observed float64       `json:"observed"`
+	Passed   bool          `json:"passed"`
+	Error    string        `json:"error,omitempty"` // Error is the error of the query, the check did not pass when it is set.
+	Duration time.Duration `json:"duration"`
+}
+
+// Report gathers the results of the checks, in the order of the checks.
+type Report struct {
+	Results []Result `json:"results"`
+}
+
+// Passed reports whether every check passed.
+func (r *Report) Passed() bool {
+	return r.Failed() == 0
+}
+
+// Failed returns the number of checks that did not pass.
+func (r *Report) Failed() int {
+	n := 0
+	for _, res := range r.Results {
+		if !res.Passed {
+			n++
+		}
+	}
+	return n
+}
+
+// String renders one line per check followed by a summary.
+func (r *Report) String() string {
+	var b strings.Builder
+	for _, res := range r.Results {
+		switch {
+		case res.Error != "":
+			fmt.Fprintf(&b, "ERROR %s: %s\n", res.Name, res.Error)
+		case res.Passed:
+			fmt.Fprintf(&b, "PASS  %s: %s, observed %s\n", res.Name, res.Expected, strconv.FormatFloat(res.Observed, 'f', -1, 64))
+		default:
+			fmt.Fprintf(&b, "FAIL  %s: %s, observed %s\n", res.Name, res.Expected, strconv.FormatFloat(res.Observed, 'f', -1, 64))
+		}
+	}
+	fmt.Fprintf(&b, "%d checks, %d failed\n", len(r.Results), r.Failed())
+	return b.String()
+}
+
+// Run executes the checks, concurrency at a time, and returns their report. Failing checks and query errors
+// are reported in the results, an error is only returned for checks that are not valid.
+func Run(ctx context.Context, db *sql.DB, checks []Check, concurrency int) (*Report, error) {
+	queries := make([]string, len(checks))
+	expected := make([]string, len(checks))
+	for i, c := range checks {
+		var err error
+		if queries[i], expected[i], err = c.Query(); err != nil {
+			return nil, err
+		}
+	}
+	report := &Report{Results: make([]Result, len(checks))}
+	sem := make(chan struct{}, max(1, concurrency))
+	var wg sync.WaitGroup
+	for i, c := range checks {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			res := Result{Name: c.name(), Query: queries[i], Expected: expected[i]}
+			start := time.Now()
+			var observed sql.NullFloat64
+			if err := db.QueryRowContext(ctx, queries[i]).Scan(&observed); err != nil {
+				res.Error = err.Error()
+			} else {
+				res.Observed = observed.Float64
+				res.Passed = c.pass(res.Observed)
+			}
+			res.Duration = time.Since(start)
+			report.Results[i] = res
+		}()
+	}
+	wg.Wait()
+	return report, nil
+}
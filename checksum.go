@@ -0,0 +1,16 @@
+package metasql
+
+import (
+	"database/sql"
+
+	"github.com/adarsh-jaiss/metasql/resultdiff"
+)
+
+// Checksum reads all remaining rows and returns an order-insensitive hash of the result set. Values are
+// normalized as resultdiff does, so the same data read through different types or time locations hashes
+// the same, and floats are rounded to the decimals of resultdiff.DefaultOptions.
+// Comparing the checksums of a source query and of a backfilled table validates the copy without fetching
+// both result sets into memory.
+func Checksum(rows *sql.Rows) (string, error) {
+	return resultdiff.Checksum(rows, resultdiff.DefaultOptions)
+}
@@ -5,6 +5,7 @@ import (
 	"github.com/aws/aws-sdk-go-v2/config"
 
 	cfg "github.com/adarsh-jaiss/metasql/config"
+	"github.com/adarsh-jaiss/metasql/errors"
 	"github.com/aws/aws-sdk-go-v2/service/redshiftdata"
 )
 
@@ -35,13 +36,17 @@ func NewRedshiftDataClient(ctx context.Context, cfg *cfg.RedshiftDataConfig) (Re
 // DefaultRedshiftDataClientConstructor creates a new RedshiftDataClient using the default AWS SDK configuration
 // It uses the AWS SDK's LoadDefaultConfig function to load the default configuration
 // It then creates a new RedshiftDataClient using the configuration and the RedshiftDataOptFns passed in the cfg
-// It returns the RedshiftDataClient and an error
+// It returns the RedshiftDataClient and an error, matching errors.ErrCredentials when the AWS configuration can not be loaded
 func DefaultRedshiftDataClientConstructor(ctx context.Context, cfg *cfg.RedshiftDataConfig) (RedshiftDataClient, error) {
 	awsCfg, err := config.LoadDefaultConfig(ctx)
 	if err != nil {
-		return nil, err
+		return nil, errors.NewConfigError(errors.ErrCredentials, err)
 	}
 
-	client := redshiftdata.NewFromConfig(awsCfg, cfg.RedshiftDataOptFns...)
+	optFns := cfg.RedshiftDataOptFns
+	if cfg.APIMetrics {
+		optFns = append(optFns[:len(optFns):len(optFns)], WithAPIMetrics(cfg))
+	}
+	client := redshiftdata.NewFromConfig(awsCfg, optFns...)
 	return client, nil
 }
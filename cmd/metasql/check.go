@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/adarsh-jaiss/metasql/checks"
+)
+
+// runCheck runs the data quality checks of a YAML file and prints their report.
+// It exits with status 1 when a check did not pass, so pipelines can stop on bad data.
+func runCheck(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("check", flag.ExitOnError)
+	dsn := dsnFlag(fs)
+	file := fs.String("file", "checks.yaml", "YAML file listing the checks")
+	concurrency := fs.Int("concurrency", 4, "number of checks run at once")
+	asJSON := fs.Bool("json", false, "print the report as JSON")
+	fs.Parse(args)
+
+	f, err := os.Open(*file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	list, err := checks.Load(f)
+	if err != nil {
+		return err
+	}
+	db, err := openDB(*dsn)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	report, err := checks.Run(ctx, db, list, *concurrency)
+	if err != nil {
+		return err
+	}
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(report); err != nil {
+			return err
+		}
+	} else {
+		fmt.Print(report.String())
+	}
+	if !report.Passed() {
+		os.Exit(1)
+	}
+	return nil
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/adarsh-jaiss/metasql/resultdiff"
+)
+
+// runDiff compares the result of a query on two targets, or on one target against a golden file.
+// It exits with status 1 when the results differ.
+func runDiff(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("diff", flag.ExitOnError)
+	dsn := dsnFlag(fs)
+	otherDSN := fs.String("other-dsn", "", "data source name of the target to compare with")
+	golden := fs.String("golden", "", "golden CSV file to compare with instead of another target")
+	update := fs.Bool("update", false, "write the result to the golden file instead of comparing")
+	query := fs.String("query", "", "query to run")
+	ordered := fs.Bool("ordered", false, "compare rows by position instead of as a multiset")
+	precision := fs.Int("float-precision", resultdiff.DefaultOptions.FloatPrecision, "number of decimals floats are rounded to")
+	checksum := fs.Bool("checksum", false, "only compare the checksums of the results, without holding their rows in memory")
+	fs.Parse(args)
+
+	if *query == "" {
+		return fmt.Errorf("-query is required")
+	}
+	if (*otherDSN == "") == (*golden == "") {
+		return fmt.Errorf("exactly one of -other-dsn and -golden is required")
+	}
+	opts := resultdiff.Options{Ordered: *ordered, FloatPrecision: *precision}
+	if *checksum && !*update {
+		return diffChecksums(ctx, *dsn, *otherDSN, *golden, *query, opts)
+	}
+
+	left, err := queryResult(ctx, *dsn, *query, opts)
+	if err != nil {
+		return err
+	}
+	var right *resultdiff.Result
+	if *golden != "" {
+		if *update {
+			f, err := os.Create(*golden)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			return resultdiff.WriteGolden(f, left)
+		}
+		f, err := os.Open(*golden)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		if right, err = resultdiff.ReadGolden(f); err != nil {
+			return err
+		}
+	} else if right, err = queryResult(ctx, *otherDSN, *query, opts); err != nil {
+		return err
+	}
+
+	report := resultdiff.Compare(left, right, opts)
+	fmt.Print(report.String())
+	if !report.Equal() {
+		os.Exit(1)
+	}
+	return nil
+}
+
+func queryResult(ctx context.Context, dsn string, query string, opts resultdiff.Options) (*resultdiff.Result, error) {
+	db, err := openDB(dsn)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+	return resultdiff.Query(ctx, db, opts, query)
+}
+
+// diffChecksums compares the checksum of the query result with the one of the other target or of the golden file.
+func diffChecksums(ctx context.Context, dsn, otherDSN, golden, query string, opts resultdiff.Options) error {
+	left, err := queryChecksum(ctx, dsn, query, opts)
+	if err != nil {
+		return err
+	}
+	var right string
+	if golden != "" {
+		f, err := os.Open(golden)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		res, err := resultdiff.ReadGolden(f)
+		if err != nil {
+			return err
+		}
+		right = res.Checksum()
+	} else if right, err = queryChecksum(ctx, otherDSN, query, opts); err != nil {
+		return err
+	}
+	if left == right {
+		fmt.Printf("checksums are equal: %s\n", left)
+		return nil
+	}
+	fmt.Printf("- checksum %s\n+ checksum %s\n", left, right)
+	os.Exit(1)
+	return nil
+}
+
+func queryChecksum(ctx context.Context, dsn string, query string, opts resultdiff.Options) (string, error) {
+	db, err := openDB(dsn)
+	if err != nil {
+		return "", err
+	}
+	defer db.Close()
+	rows, err := db.QueryContext(ctx, query)
+	if err != nil {
+		return "", err
+	}
+	defer rows.Close()
+	return resultdiff.Checksum(rows, opts)
+}
@@ -0,0 +1,240 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/adarsh-jaiss/metasql"
+	"github.com/adarsh-jaiss/metasql/config"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// Export modes, auto picks one from the estimated size of the result.
+const (
+	exportAuto   = "auto"
+	exportClient = "client"
+	exportUnload = "unload"
+)
+
+// runExport writes the result of a query to a local file, stdout or S3. Small results are streamed through
+// the driver, large ones and Parquet exports are written by Redshift itself with UNLOAD.
+func runExport(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	dsn := dsnFlag(fs)
+	query := fs.String("query", "", "query to export")
+	to := fs.String("to", "-", "destination: s3://bucket/prefix, a file path or - for stdout")
+	format := fs.String("format", "csv", "output format: csv, json or parquet")
+	mode := fs.String("mode", exportAuto, "auto, client (stream through the driver) or unload (server-side UNLOAD)")
+	iamRole := fs.String("iam-role", "default", "IAM role ARN Redshift uses to write to S3 with UNLOAD")
+	maxClientBytes := fs.Int64("max-client-bytes", 256<<20, "estimated result size from which auto mode switches to UNLOAD")
+	fs.Parse(args)
+
+	if *query == "" {
+		return fmt.Errorf("-query is required")
+	}
+	switch *format {
+	case "csv", "json", "parquet":
+	default:
+		return fmt.Errorf("unknown format %q", *format)
+	}
+	cfg, err := config.ParseDSN(*dsn)
+	if err != nil {
+		return err
+	}
+	db := sql.OpenDB(metasql.NewConnector(cfg))
+	defer db.Close()
+
+	toS3 := strings.HasPrefix(*to, "s3://")
+	if *mode == exportAuto {
+		*mode = exportClient
+		if toS3 && *format == "parquet" {
+			*mode = exportUnload
+		} else if toS3 {
+			size, err := estimateSize(ctx, db, *query)
+			if err != nil {
+				return err
+			}
+			if size >= *maxClientBytes {
+				*mode = exportUnload
+			}
+		}
+	}
+
+	switch *mode {
+	case exportUnload:
+		if !toS3 {
+			return fmt.Errorf("unload requires an s3:// destination")
+		}
+		start := time.Now()
+		if _, err := db.ExecContext(ctx, unloadStatement(*query, *to, *format, *iamRole)); err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "unloaded to %s in %s\n", *to, time.Since(start).Round(time.Millisecond))
+		return nil
+	case exportClient:
+		if *format == "parquet" {
+			return fmt.Errorf("parquet is only written by unload")
+		}
+		if !toS3 {
+			w := os.Stdout
+			if *to != "-" {
+				if w, err = os.Create(*to); err != nil {
+					return err
+				}
+				defer w.Close()
+			}
+			_, err := exportRows(ctx, db, *query, *format, w)
+			return err
+		}
+		return exportToS3(ctx, db, cfg, *query, *format, *to)
+	}
+	return fmt.Errorf("unknown mode %q", *mode)
+}
+
+var planEstimate = regexp.MustCompile(`rows=(\d+) width=(\d+)`)
+
+// estimateSize returns the result size in bytes estimated by the planner for the query.
+func estimateSize(ctx context.Context, db *sql.DB, query string) (int64, error) {
+	var plan string
+	if err := db.QueryRowContext(ctx, "EXPLAIN "+query).Scan(&plan); err != nil {
+		return 0, fmt.Errorf("estimate result size: %w", err)
+	}
+	m := planEstimate.FindStringSubmatch(plan)
+	if m == nil {
+		return 0, fmt.Errorf("estimate result size: no estimate in plan %q", plan)
+	}
+	rows, _ := strconv.ParseInt(m[1], 10, 64)
+	width, _ := strconv.ParseInt(m[2], 10, 64)
+	return rows * width, nil
+}
+
+// unloadStatement returns the UNLOAD statement writing the result of the query under the S3 prefix.
+func unloadStatement(query, to, format, iamRole string) string {
+	role := "default"
+	if iamRole != "default" {
+		role = quoteLiteral(iamRole)
+	}
+	var options string
+	switch format {
+	case "parquet":
+		options = "FORMAT AS PARQUET"
+	case "json":
+		options = "FORMAT AS JSON"
+	default:
+		options = "FORMAT AS CSV HEADER"
+	}
+	return fmt.Sprintf("UNLOAD (%s) TO %s IAM_ROLE %s %s", quoteLiteral(query), quoteLiteral(to), role, options)
+}
+
+// exportToS3 streams the result into a temporary file and uploads it under the S3 prefix,
+// named like the first file of an UNLOAD.
+func exportToS3(ctx context.Context, db *sql.DB, cfg *config.RedshiftDataConfig, query, format, to string) error {
+	bucket, prefix, _ := strings.Cut(strings.TrimPrefix(to, "s3://"), "/")
+	f, err := os.CreateTemp("", "metasql-export-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+	n, err := exportRows(ctx, db, query, format, f)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	awsCfg, err := awsconfig.LoadDefaultConfig(ctx)
+	if err != nil {
+		return err
+	}
+	if region := cfg.Params.Get("region"); region != "" {
+		awsCfg.Region = region
+	}
+	key := prefix + "0000_part_00." + format
+	if _, err := s3.NewFromConfig(awsCfg).PutObject(ctx, &s3.PutObjectInput{
+		Bucket: &bucket,
+		Key:    &key,
+		Body:   f,
+	}); err != nil {
+		return fmt.Errorf("upload to s3://%s/%s: %w", bucket, key, err)
+	}
+	fmt.Fprintf(os.Stderr, "exported %d rows to s3://%s/%s\n", n, bucket, key)
+	return nil
+}
+
+// exportRows writes the result of the query to w as CSV with a header or as JSON lines and returns the number of rows.
+func exportRows(ctx context.Context, db *sql.DB, query, format string, w io.Writer) (int64, error) {
+	rows, err := db.QueryContext(ctx, query)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+	columns, err := rows.Columns()
+	if err != nil {
+		return 0, err
+	}
+	var n int64
+	if format == "json" {
+		enc := json.NewEncoder(w)
+		for rows.Next() {
+			row, err := metasql.ScanMap(rows)
+			if err != nil {
+				return n, err
+			}
+			if err := enc.Encode(row); err != nil {
+				return n, err
+			}
+			n++
+		}
+		return n, rows.Err()
+	}
+
+	cw := csv.NewWriter(w)
+	if err := cw.Write(columns); err != nil {
+		return 0, err
+	}
+	record := make([]string, len(columns))
+	for rows.Next() {
+		values, err := metasql.ScanSlice(rows)
+		if err != nil {
+			return n, err
+		}
+		for i, v := range values {
+			switch v := v.(type) {
+			case nil:
+				record[i] = ""
+			case []byte:
+				record[i] = hex.EncodeToString(v)
+			default:
+				record[i] = fmt.Sprint(v)
+			}
+		}
+		if err := cw.Write(record); err != nil {
+			return n, err
+		}
+		n++
+	}
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		return n, err
+	}
+	return n, rows.Err()
+}
+
+// quoteLiteral quotes a SQL string literal, doubling the quotes it contains.
+func quoteLiteral(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
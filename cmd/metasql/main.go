@@ -0,0 +1,87 @@
+// Command metasql is a command line companion for the metasql driver.
+//
+// Usage:
+//
+//	metasql <command> [flags]
+//
+// Every command connects with the DSN given by the -dsn flag or the METASQL_DSN environment variable.
+package main
+
+import (
+	"context"
+	"database/sql"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"sort"
+	"syscall"
+
+	"github.com/adarsh-jaiss/metasql"
+	"github.com/adarsh-jaiss/metasql/config"
+)
+
+// command is a subcommand of the metasql CLI.
+type command struct {
+	usage string                                         // usage is a one line description shown in the help output.
+	run   func(ctx context.Context, args []string) error // run executes the command with the arguments following its name.
+}
+
+var commands = map[string]*command{
+	"check":   {usage: "run the data quality checks of a YAML file", run: runCheck},
+	"columns": {usage: "list the columns of a table", run: runColumns},
+	"ddl":     {usage: "print a CREATE TABLE statement for a table", run: runDDL},
+	"diff":    {usage: "compare the result of a query on two targets or against a golden file", run: runDiff},
+	"export":  {usage: "export the result of a query to a file or S3, streaming it or with UNLOAD", run: runExport},
+	"serve":   {usage: "serve the driver over another protocol (pgwire, http, grpc)", run: runServe},
+	"tables":  {usage: "list the tables of the database", run: runTables},
+	"watch":   {usage: "show the running statements live and cancel them", run: runWatch},
+}
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+	cmd, ok := commands[os.Args[1]]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "metasql: unknown command %q\n", os.Args[1])
+		usage()
+		os.Exit(2)
+	}
+	if err := cmd.run(ctx, os.Args[2:]); err != nil {
+		fmt.Fprintf(os.Stderr, "metasql %s: %v\n", os.Args[1], err)
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: metasql <command> [flags]")
+	fmt.Fprintln(os.Stderr, "")
+	fmt.Fprintln(os.Stderr, "commands:")
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "  %-10s %s\n", name, commands[name].usage)
+	}
+}
+
+// dsnFlag registers the -dsn flag shared by all commands on fs.
+func dsnFlag(fs *flag.FlagSet) *string {
+	return fs.String("dsn", os.Getenv("METASQL_DSN"), "data source name, defaults to $METASQL_DSN")
+}
+
+// openDB parses dsn and opens a *sql.DB backed by the metasql driver.
+func openDB(dsn string) (*sql.DB, error) {
+	cfg, err := config.ParseDSN(dsn)
+	if err != nil {
+		return nil, err
+	}
+	return sql.OpenDB(metasql.NewConnector(cfg)), nil
+}
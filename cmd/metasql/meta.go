@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/adarsh-jaiss/metasql"
+	"github.com/adarsh-jaiss/metasql/config"
+	"github.com/adarsh-jaiss/metasql/meta"
+)
+
+// runTables lists the tables of the database.
+func runTables(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("tables", flag.ExitOnError)
+	dsn := dsnFlag(fs)
+	schema := fs.String("schema", "", "LIKE pattern the schema must match")
+	pattern := fs.String("pattern", "", "LIKE pattern the table name must match")
+	fs.Parse(args)
+
+	catalog, err := openCatalog(ctx, *dsn)
+	if err != nil {
+		return err
+	}
+	tables, err := catalog.Tables(ctx, *schema, *pattern)
+	if err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "SCHEMA\tNAME\tTYPE")
+	for _, t := range tables {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", t.Schema, t.Name, t.Type)
+	}
+	return w.Flush()
+}
+
+// runColumns lists the columns of a table.
+func runColumns(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("columns", flag.ExitOnError)
+	dsn := dsnFlag(fs)
+	fs.Parse(args)
+	schema, table, err := tableArg(fs)
+	if err != nil {
+		return err
+	}
+
+	catalog, err := openCatalog(ctx, *dsn)
+	if err != nil {
+		return err
+	}
+	columns, err := catalog.Columns(ctx, schema, table)
+	if err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tTYPE\tNULLABLE\tDEFAULT")
+	for _, c := range columns {
+		fmt.Fprintf(w, "%s\t%s\t%t\t%s\n", c.Name, c.TypeSQL(), c.Nullable, c.Default)
+	}
+	return w.Flush()
+}
+
+// runDDL prints a CREATE TABLE statement for a table.
+func runDDL(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("ddl", flag.ExitOnError)
+	dsn := dsnFlag(fs)
+	fs.Parse(args)
+	schema, table, err := tableArg(fs)
+	if err != nil {
+		return err
+	}
+
+	catalog, err := openCatalog(ctx, *dsn)
+	if err != nil {
+		return err
+	}
+	ddl, err := catalog.DDL(ctx, schema, table)
+	if err != nil {
+		return err
+	}
+	fmt.Print(ddl)
+	return nil
+}
+
+// tableArg returns the schema and name of the [schema.]table argument following the flags.
+func tableArg(fs *flag.FlagSet) (schema, table string, err error) {
+	if fs.NArg() != 1 {
+		return "", "", fmt.Errorf("usage: metasql %s [flags] [schema.]table", fs.Name())
+	}
+	if schema, table, ok := strings.Cut(fs.Arg(0), "."); ok {
+		return schema, table, nil
+	}
+	return "", fs.Arg(0), nil
+}
+
+// openCatalog parses dsn and returns a meta.Catalog reading its database.
+func openCatalog(ctx context.Context, dsn string) (*meta.Catalog, error) {
+	cfg, err := config.ParseDSN(dsn)
+	if err != nil {
+		return nil, err
+	}
+	client, err := metasql.NewRedshiftDataClient(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	metaClient, ok := client.(meta.Client)
+	if !ok {
+		return nil, fmt.Errorf("%T can not read the catalog", client)
+	}
+	return meta.NewCatalog(metaClient, cfg), nil
+}
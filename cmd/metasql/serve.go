@@ -0,0 +1,181 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"crypto/tls"
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/adarsh-jaiss/metasql"
+	"github.com/adarsh-jaiss/metasql/config"
+	"github.com/adarsh-jaiss/metasql/gateway"
+	"github.com/adarsh-jaiss/metasql/grpcserver"
+	"github.com/adarsh-jaiss/metasql/pgwire"
+	"google.golang.org/grpc"
+)
+
+var serveModes = map[string]func(ctx context.Context, args []string) error{
+	"pgwire": runServePgwire,
+	"http":   runServeHTTP,
+	"grpc":   runServeGRPC,
+}
+
+func runServe(ctx context.Context, args []string) error {
+	if len(args) < 1 {
+		return fmt.Errorf("usage: metasql serve <pgwire|http|grpc> [flags]")
+	}
+	run, ok := serveModes[args[0]]
+	if !ok {
+		return fmt.Errorf("unknown serve mode %q", args[0])
+	}
+	return run(ctx, args[1:])
+}
+
+func runServePgwire(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("serve pgwire", flag.ExitOnError)
+	dsn := dsnFlag(fs)
+	listen := fs.String("listen", "127.0.0.1:5432", "address to listen on")
+	password := fs.String("password", os.Getenv("METASQL_PGWIRE_PASSWORD"), "password clients must send, defaults to $METASQL_PGWIRE_PASSWORD (empty trusts every client, only on loopback addresses without -insecure)")
+	certFile := fs.String("tls-cert", "", "TLS certificate file, enables SSL for clients requesting it")
+	keyFile := fs.String("tls-key", "", "TLS key file")
+	insecure := fs.Bool("insecure", false, "allow serving without a password, or sending it without TLS, on an address that is not a loopback one")
+	fs.Parse(args)
+
+	// off loopback the password protects the proxy, and TLS the password
+	guarded := !*insecure && !isLoopback(*listen)
+	switch {
+	case guarded && *password == "":
+		return fmt.Errorf("serve pgwire: refusing to serve %s without a password, set -password or -insecure", *listen)
+	case guarded && *certFile == "":
+		return fmt.Errorf("serve pgwire: refusing to accept passwords on %s without TLS, set -tls-cert and -tls-key or -insecure", *listen)
+	}
+
+	db, err := openDB(*dsn)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	srv := pgwire.NewServer(db)
+	if *password != "" {
+		srv.Authenticate = func(user, pw string) bool {
+			return subtle.ConstantTimeCompare([]byte(pw), []byte(*password)) == 1
+		}
+	}
+	if *certFile != "" {
+		cert, err := tls.LoadX509KeyPair(*certFile, *keyFile)
+		if err != nil {
+			return fmt.Errorf("load tls key pair: %w", err)
+		}
+		srv.TLSConfig = &tls.Config{Certificates: []tls.Certificate{cert}}
+		srv.RequireTLS = guarded
+	}
+
+	go func() {
+		<-ctx.Done()
+		srv.Close()
+	}()
+	log.Printf("metasql: serving pgwire on %s", *listen)
+	err = srv.ListenAndServe(*listen)
+	if ctx.Err() != nil {
+		return nil
+	}
+	return err
+}
+
+func runServeHTTP(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("serve http", flag.ExitOnError)
+	dsn := dsnFlag(fs)
+	listen := fs.String("listen", "127.0.0.1:8080", "address to listen on")
+	token := fs.String("token", os.Getenv("METASQL_HTTP_TOKEN"), "bearer token clients must send, defaults to $METASQL_HTTP_TOKEN (empty disables authentication, only on loopback addresses without -insecure)")
+	insecure := fs.Bool("insecure", false, "allow serving without a token on an address that is not a loopback one")
+	fs.Parse(args)
+
+	if *token == "" && !*insecure && !isLoopback(*listen) {
+		return fmt.Errorf("serve http: refusing to serve %s without a token, set -token or -insecure", *listen)
+	}
+
+	cfg, err := config.ParseDSN(*dsn)
+	if err != nil {
+		return err
+	}
+	async, err := metasql.NewAsync(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	h := gateway.NewHandler(async)
+	h.Token = *token
+
+	srv := &http.Server{
+		Addr:              *listen,
+		Handler:           h,
+		ReadHeaderTimeout: 10 * time.Second,
+		ReadTimeout:       time.Minute,
+		WriteTimeout:      5 * time.Minute,
+		IdleTimeout:       2 * time.Minute,
+	}
+	go func() {
+		<-ctx.Done()
+		srv.Close()
+	}()
+	log.Printf("metasql: serving http on %s", *listen)
+	err = srv.ListenAndServe()
+	if ctx.Err() != nil {
+		return nil
+	}
+	return err
+}
+
+func runServeGRPC(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("serve grpc", flag.ExitOnError)
+	dsn := dsnFlag(fs)
+	listen := fs.String("listen", "127.0.0.1:9090", "address to listen on")
+	token := fs.String("token", os.Getenv("METASQL_GRPC_TOKEN"), "bearer token clients must send, defaults to $METASQL_GRPC_TOKEN")
+	insecure := fs.Bool("insecure", false, "allow serving without a token on an address that is not a loopback one")
+	fs.Parse(args)
+
+	var opts []grpc.ServerOption
+	if *token != "" {
+		opts = grpcserver.TokenAuth(*token)
+	} else if !*insecure && !isLoopback(*listen) {
+		return fmt.Errorf("serve grpc: refusing to serve %s without a token, set -token or -insecure", *listen)
+	}
+
+	db, err := openDB(*dsn)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	l, err := net.Listen("tcp", *listen)
+	if err != nil {
+		return err
+	}
+	s := grpc.NewServer(opts...)
+	grpcserver.NewServer(db).Register(s)
+	go func() {
+		<-ctx.Done()
+		s.GracefulStop()
+	}()
+	log.Printf("metasql: serving grpc on %s", *listen)
+	return s.Serve(l)
+}
+
+// isLoopback reports whether the address only listens on a loopback interface.
+func isLoopback(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
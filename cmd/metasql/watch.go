@@ -0,0 +1,150 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/adarsh-jaiss/metasql"
+	"github.com/adarsh-jaiss/metasql/config"
+	"github.com/adarsh-jaiss/metasql/utils"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/redshiftdata"
+	"github.com/aws/aws-sdk-go-v2/service/redshiftdata/types"
+)
+
+// watchClient is the part of the Data API client used by the watch command.
+type watchClient interface {
+	redshiftdata.ListStatementsAPIClient
+	CancelStatement(ctx context.Context, params *redshiftdata.CancelStatementInput, optFns ...func(*redshiftdata.Options)) (*redshiftdata.CancelStatementOutput, error)
+}
+
+// runWatch renders a live table of the submitted and running statements of the caller.
+// Entering a row number cancels the statement of the row, q quits.
+func runWatch(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("watch", flag.ExitOnError)
+	dsn := dsnFlag(fs)
+	interval := fs.Duration("interval", 2*time.Second, "refresh interval")
+	roleLevel := fs.Bool("role-level", true, "list the statements of every session of the IAM role, not only the caller's")
+	fs.Parse(args)
+
+	cfg, err := config.ParseDSN(*dsn)
+	if err != nil {
+		return err
+	}
+	client, err := metasql.NewRedshiftDataClient(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	wc, ok := client.(watchClient)
+	if !ok {
+		return fmt.Errorf("%T can not list statements", client)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var mu sync.Mutex
+	var shown []types.StatementData
+	message := ""
+	go func() {
+		defer cancel()
+		scanner := bufio.NewScanner(os.Stdin)
+		for scanner.Scan() {
+			input := strings.TrimSpace(scanner.Text())
+			if input == "q" {
+				return
+			}
+			n, err := strconv.Atoi(input)
+			mu.Lock()
+			if err != nil || n < 1 || n > len(shown) {
+				message = fmt.Sprintf("no row %q", input)
+				mu.Unlock()
+				continue
+			}
+			id := shown[n-1].Id
+			mu.Unlock()
+			_, err = wc.CancelStatement(ctx, &redshiftdata.CancelStatementInput{Id: id})
+			mu.Lock()
+			if err != nil {
+				message = fmt.Sprintf("cancel %s: %v", utils.Coalesce(id), err)
+			} else {
+				message = fmt.Sprintf("cancelled %s", utils.Coalesce(id))
+			}
+			mu.Unlock()
+		}
+	}()
+
+	ticker := time.NewTicker(*interval)
+	defer ticker.Stop()
+	for {
+		statements, err := inFlightStatements(ctx, wc, *roleLevel)
+		if err != nil && ctx.Err() == nil {
+			return err
+		}
+		mu.Lock()
+		shown = statements
+		renderWatch(os.Stdout, statements, message)
+		mu.Unlock()
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// inFlightStatements returns the statements that did not finish yet, oldest first.
+func inFlightStatements(ctx context.Context, client redshiftdata.ListStatementsAPIClient, roleLevel bool) ([]types.StatementData, error) {
+	var statements []types.StatementData
+	for _, status := range []types.StatusString{types.StatusStringSubmitted, types.StatusStringPicked, types.StatusStringStarted} {
+		p := redshiftdata.NewListStatementsPaginator(client, &redshiftdata.ListStatementsInput{
+			Status:    status,
+			RoleLevel: aws.Bool(roleLevel),
+		})
+		for p.HasMorePages() {
+			page, err := p.NextPage(ctx)
+			if err != nil {
+				return nil, fmt.Errorf("list statements error: %w", err)
+			}
+			statements = append(statements, page.Statements...)
+		}
+	}
+	sort.Slice(statements, func(i, j int) bool {
+		return aws.ToTime(statements[i].CreatedAt).Before(aws.ToTime(statements[j].CreatedAt))
+	})
+	return statements, nil
+}
+
+// renderWatch clears the terminal and writes the table of statements.
+func renderWatch(w io.Writer, statements []types.StatementData, message string) {
+	fmt.Fprint(w, "\033[H\033[2J")
+	fmt.Fprintf(w, "metasql watch  %s  %d in flight\n\n", time.Now().Format(time.TimeOnly), len(statements))
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "#\tID\tSTATUS\tAGE\tQUERY")
+	for i, s := range statements {
+		query := utils.Coalesce(s.QueryString)
+		if len(s.QueryStrings) > 0 {
+			query = strings.Join(s.QueryStrings, "; ")
+		}
+		query = strings.Join(strings.Fields(query), " ")
+		if len(query) > 80 {
+			query = query[:77] + "..."
+		}
+		age := time.Since(aws.ToTime(s.CreatedAt)).Round(time.Second)
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s\n", i+1, utils.Coalesce(s.Id), s.Status, age, query)
+	}
+	tw.Flush()
+	fmt.Fprintln(w, "\nenter a row number to cancel its statement, q to quit")
+	if message != "" {
+		fmt.Fprintln(w, message)
+	}
+}
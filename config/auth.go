@@ -0,0 +1,31 @@
+package config
+
+import (
+	"github.com/adarsh-jaiss/metasql/errors"
+)
+
+const (
+	AuthPrecedenceSecret = "secret"  // AuthPrecedenceSecret sends the secret ARN and drops the database user when both are set
+	AuthPrecedenceDBUser = "db_user" // AuthPrecedenceDBUser sends the database user and drops the secret ARN when both are set
+)
+
+// Credentials returns the credential fields to send to the Data API along with secretArn, the secret ARN of the
+// statement target. The Data API rejects requests setting both a secret ARN and a database user, AuthPrecedence
+// picks the one to keep, without it the combination is reported as ErrAuthConflict. Errors match ErrConfigInvalid.
+func (cfg *RedshiftDataConfig) Credentials(secretArn *string) (dbUser, secret *string, err error) {
+	dbUser, secret = cfg.DBUser, secretArn
+	if dbUser != nil && secret != nil {
+		switch cfg.AuthPrecedence {
+		case AuthPrecedenceSecret:
+			dbUser = nil
+		case AuthPrecedenceDBUser:
+			secret = nil
+		default:
+			return nil, nil, errors.NewConfigError(errors.ErrConfigInvalid, errors.ErrAuthConflict)
+		}
+	}
+	if dbUser != nil && cfg.WorkgroupName != nil {
+		return nil, nil, errors.NewConfigError(errors.ErrConfigInvalid, errors.ErrAuthDBUserServerless)
+	}
+	return dbUser, secret, nil
+}
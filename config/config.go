@@ -3,10 +3,17 @@ package config
 import (
 	"fmt"
 	"net/url"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/adarsh-jaiss/metasql/cache"
+	"github.com/adarsh-jaiss/metasql/dialect"
 	"github.com/adarsh-jaiss/metasql/errors"
+	"github.com/adarsh-jaiss/metasql/hooks"
+	"github.com/adarsh-jaiss/metasql/quota"
 	"github.com/adarsh-jaiss/metasql/utils"
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/redshiftdata"
@@ -15,18 +22,172 @@ import (
 // RedshiftDataConfig is a struct that contains the configuration the configuration needed to connect to a Redshift database using the Redshift Data API.
 // It includes the ClusterIdentifier, Database, DBUser, WorkgroupName, SecretArn, Timeout, Polling, Params, and RedshiftDataOptFns fields
 type RedshiftDataConfig struct {
-	ClusterIdentifier  *string                       `yaml:"" pflag:",cluster-identifier"`                   // ClusterIdentifier is the name of the Redshift cluster
-	Database           *string                       `yaml:"" pflag:",database"`                             // Database is the name of the database
-	DBUser             *string                       `yaml:"" pflag:",db-user"`                              // DBUser is the username for the database
-	WorkgroupName      *string                       `yaml:"" pflag:",workgroup-name"`                       // WorkgroupName is the name of the workgroup
-	SecretsArn         *string                       `yaml:"" pflag:",secret-arn"`                           // SecretArn is the ARN of the secret
-	Timeout            time.Duration                 `yaml:"timeout" pflag:",timeout"`                       // Timeout is the amount of time to wait for the query to complete
-	Polling            time.Duration                 `yaml:"polling" pflag:",polling"`                       // Polling is the amount of time to wait between polling for the query status
-	Params             url.Values                    `yaml:"params" pflag:",params"`                         // Params is a map of key value pairs to be used as parameters in the query
-	RedshiftDataOptFns []func(*redshiftdata.Options) `yaml:"redshiftdataoptfns" pflag:",redshiftdataoptfns"` // RedshiftDataOptFns is a slice of functions that modify the RedshiftDataClient options
+	ClusterIdentifier         *string                       `yaml:"" pflag:",cluster-identifier"`                                     // ClusterIdentifier is the name of the Redshift cluster
+	Database                  *string                       `yaml:"" pflag:",database"`                                               // Database is the name of the database
+	DBUser                    *string                       `yaml:"" pflag:",db-user"`                                                // DBUser is the username for the database
+	WorkgroupName             *string                       `yaml:"" pflag:",workgroup-name"`                                         // WorkgroupName is the name of the workgroup
+	SecretsArn                *string                       `yaml:"" pflag:",secret-arn"`                                             // SecretArn is the ARN of the secret
+	Timeout                   time.Duration                 `yaml:"timeout" pflag:",timeout"`                                         // Timeout is the amount of time to wait for the query to complete
+	Polling                   time.Duration                 `yaml:"polling" pflag:",polling"`                                         // Polling is the amount of time to wait between polling for the query status, the first interval when backing off
+	MaxPolling                time.Duration                 `yaml:"max_polling" pflag:",max-polling"`                                 // MaxPolling is the longest interval the polling backs off to, see GetPollingStrategy
+	Params                    url.Values                    `yaml:"params" pflag:",params"`                                           // Params is a map of key value pairs to be used as parameters in the query
+	AuthPrecedence            string                        `yaml:"auth_precedence" pflag:",auth-precedence"`                         // AuthPrecedence picks the credential sent when both SecretsArn and DBUser are set, see AuthPrecedenceSecret
+	Dialect                   string                        `yaml:"dialect" pflag:",dialect"`                                         // Dialect selects the SQL translation shims applied to queries, see the dialect package
+	DescribeTableCacheTTL     time.Duration                 `yaml:"describe_table_cache_ttl" pflag:",describe-table-cache-ttl"`       // DescribeTableCacheTTL enables resolving result columns with DescribeTable metadata cached for this long
+	ResultCacheTTL            time.Duration                 `yaml:"result_cache_ttl" pflag:",result-cache-ttl"`                       // ResultCacheTTL enables caching the results of queries for this long, see ResultCache
+	ColumnComments            bool                          `yaml:"column_comments" pflag:",column-comments"`                         // ColumnComments adds the comments of the table columns to the rows metadata, cached with DescribeTableCacheTTL
+	TimestampPrecision        string                        `yaml:"timestamp_precision" pflag:",timestamp-precision"`                 // TimestampPrecision is how sub-microsecond timestamps are handled, TimestampPrecisionTruncate by default
+	Decode                    DecodeOptions                 `yaml:"decode" pflag:"-"`                                                 // Decode controls how result sets are decoded
+	APIMetrics                bool                          `yaml:"api_metrics" pflag:",api-metrics"`                                 // APIMetrics reports every Data API operation to the AfterAPICall hooks
+	QueryLabels               bool                          `yaml:"query_labels" pflag:",query-labels"`                               // QueryLabels prefixes every statement with a /* metasql:<label> */ comment to find it in the query history
+	CastParameters            bool                          `yaml:"cast_parameters" pflag:",cast-parameters"`                         // CastParameters appends a ::type cast matching the Go type of the argument to each placeholder
+	ExecBatchWindow           time.Duration                 `yaml:"exec_batch_window" pflag:",exec-batch-window"`                     // ExecBatchWindow enables coalescing consecutive argument-less Execs submitted within this window
+	MultiStatements           bool                          `yaml:"multi_statements" pflag:",multi-statements"`                       // MultiStatements runs queries holding several semicolon-separated statements as one BatchExecuteStatement
+	CancelMode                string                        `yaml:"cancel_mode" pflag:",cancel-mode"`                                 // CancelMode is what happens to a statement whose context is done or whose timeout elapses, CancelModeCancel by default
+	TxMode                    string                        `yaml:"tx_mode" pflag:",tx-mode"`                                         // TxMode selects how transactions run, TxModeBatch by default
+	Placeholders              string                        `yaml:"placeholders" pflag:",placeholders"`                               // Placeholders restricts the placeholder styles rewritten into Data API parameters, all of them by default
+	SerializationRetries      int                           `yaml:"serialization_retries" pflag:",serialization-retries"`             // SerializationRetries is the number of times a transaction aborted by a serialization failure is retried
+	SerializationRetryBackoff time.Duration                 `yaml:"serialization_retry_backoff" pflag:",serialization-retry-backoff"` // SerializationRetryBackoff is the delay before the first retry, doubled after each one
+	RetryMinAttemptTimeout    time.Duration                 `yaml:"retry_min_attempt_timeout" pflag:",retry-min-attempt-timeout"`     // RetryMinAttemptTimeout is the least share of the context deadline given to an attempt when retries are enabled
+	WarehouseWaitThreshold    time.Duration                 `yaml:"warehouse_wait_threshold" pflag:",warehouse-wait-threshold"`       // WarehouseWaitThreshold is the warehouse-side wait from which a statement is reported to the WarehouseWait hook
+	PrefetchPages             int                           `yaml:"prefetch_pages" pflag:",prefetch-pages"`                           // PrefetchPages fetches up to this many result pages ahead in the background while rows are scanned, pages are fetched on demand when 0
+	ProgressInterval          time.Duration                 `yaml:"progress_interval" pflag:",progress-interval"`                     // ProgressInterval enables reporting the progress of COPY and UNLOAD statements to the Progress hook at this interval
+	Features                  Features                      `yaml:"features" pflag:"-"`                                               // Features enables or disables subsystems of the driver, see Feature
+	RedshiftDataOptFns        []func(*redshiftdata.Options) `yaml:"redshiftdataoptfns" pflag:",redshiftdataoptfns"`                   // RedshiftDataOptFns is a slice of functions that modify the RedshiftDataClient options
+	Hooks                     []*hooks.Hooks                `yaml:"-" pflag:"-"`                                                      // Hooks are called around every statement executed with this config
+	ParameterEncoder          ParameterEncoder              `yaml:"-" pflag:"-"`                                                      // ParameterEncoder renders arguments of custom types into parameter values
+	PollingStrategy           PollingStrategy               `yaml:"-" pflag:"-"`                                                      // PollingStrategy decides the interval between status polls instead of Polling and MaxPolling
+	Coordinator               *quota.Coordinator            `yaml:"-" pflag:"-"`                                                      // Coordinator shares the submission rate and active statement budget with other connectors, see the quota package
+	ResultCache               cache.Cache                   `yaml:"-" pflag:"-"`                                                      // ResultCache stores cached query results, an in-memory LRU of cache.DefaultSize results when nil
+	reloaded                  atomic.Pointer[Reloadable]    // reloaded holds the settings applied with Reload, nil until then
+	quotaOnce                 sync.Once                     // quotaOnce records quota before Reload first changes the limits of the Coordinator
+	quota                     quota.Limits                  // quota holds the limits of the Coordinator before Reload changed them
+	defaultCache              sync.Once                     // defaultCache creates lru when no ResultCache is set
+	lru                       *cache.LRU                    // lru is the result cache used when no ResultCache is set
 	// Region             *string                        `yaml:"region" pflag:",region"`                         // Region is the AWS region
 }
 
+const (
+	DefaultTimeout                   = 15 * time.Minute       // DefaultTimeout is used when no timeout is configured
+	DefaultPolling                   = 10 * time.Millisecond  // DefaultPolling is used when no polling interval is configured
+	DefaultMaxPolling                = 2 * time.Second        // DefaultMaxPolling is the longest interval the default polling strategy backs off to
+	DefaultPollingMultiplier         = 1.5                    // DefaultPollingMultiplier grows the interval of the default polling strategy after each poll
+	DefaultPollingJitter             = 0.2                    // DefaultPollingJitter spreads the intervals of the default polling strategy
+	DefaultSerializationRetryBackoff = 100 * time.Millisecond // DefaultSerializationRetryBackoff is used when no retry backoff is configured
+	DefaultWarehouseWaitThreshold    = 5 * time.Second        // DefaultWarehouseWaitThreshold is used when no warehouse wait threshold is configured
+	DefaultRetryMinAttemptTimeout    = 5 * time.Second        // DefaultRetryMinAttemptTimeout is used when no minimum attempt timeout is configured
+	DefaultPrefetchPages             = 2                      // DefaultPrefetchPages is used by the parallel_fetch feature when no PrefetchPages is configured
+	DefaultReloadInterval            = 30 * time.Second       // DefaultReloadInterval is used by Watch when no reload interval is given
+)
+
+const (
+	TimestampPrecisionTruncate = "truncate" // TimestampPrecisionTruncate silently truncates timestamps to microseconds
+	TimestampPrecisionError    = "error"    // TimestampPrecisionError rejects timestamps that would lose precision
+)
+
+const (
+	CancelModeCancel = "cancel" // CancelModeCancel cancels the statement with CancelStatement before returning
+	CancelModeLeave  = "leave"  // CancelModeLeave returns right away and leaves the statement running, for writes that must complete
+)
+
+const (
+	TxModeBatch   = "batch"   // TxModeBatch buffers the statements of a transaction and submits them as one batch at commit
+	TxModeSession = "session" // TxModeSession runs BEGIN, the statements and COMMIT in a Data API session as they come
+)
+
+const (
+	PlaceholdersQuestion = "question" // PlaceholdersQuestion rewrites ? placeholders only, $ is left as it is
+	PlaceholdersDollar   = "dollar"   // PlaceholdersDollar rewrites $N and $name placeholders only, ? is left as it is
+	PlaceholdersColon    = "colon"    // PlaceholdersColon rewrites nothing, :N and :name are the only placeholders
+	PlaceholdersNone     = "none"     // PlaceholdersNone sends queries as they are and binds arguments without checking them against the query
+)
+
+// GetTimeout returns the configured timeout, falling back to DefaultTimeout when it is not set.
+// Settings applied with Reload take precedence.
+func (cfg *RedshiftDataConfig) GetTimeout() time.Duration {
+	if r := cfg.reloaded.Load(); r != nil && r.Timeout > 0 {
+		return r.Timeout
+	}
+	if cfg.Timeout <= 0 {
+		return DefaultTimeout
+	}
+	return cfg.Timeout
+}
+
+// GetPolling returns the configured polling interval, falling back to DefaultPolling when it is not set.
+// Settings applied with Reload take precedence.
+func (cfg *RedshiftDataConfig) GetPolling() time.Duration {
+	if r := cfg.reloaded.Load(); r != nil && r.Polling > 0 {
+		return r.Polling
+	}
+	if cfg.Polling <= 0 {
+		return DefaultPolling
+	}
+	return cfg.Polling
+}
+
+// GetSerializationRetries returns the configured number of serialization retries.
+// Settings applied with Reload take precedence.
+func (cfg *RedshiftDataConfig) GetSerializationRetries() int {
+	if r := cfg.reloaded.Load(); r != nil && r.SerializationRetries != nil {
+		return *r.SerializationRetries
+	}
+	return cfg.SerializationRetries
+}
+
+// GetSerializationRetryBackoff returns the configured retry backoff, falling back to DefaultSerializationRetryBackoff when it is not set.
+// Settings applied with Reload take precedence.
+func (cfg *RedshiftDataConfig) GetSerializationRetryBackoff() time.Duration {
+	if r := cfg.reloaded.Load(); r != nil && r.SerializationRetryBackoff > 0 {
+		return r.SerializationRetryBackoff
+	}
+	if cfg.SerializationRetryBackoff <= 0 {
+		return DefaultSerializationRetryBackoff
+	}
+	return cfg.SerializationRetryBackoff
+}
+
+// GetResultCache returns the configured result cache, falling back to an in-memory LRU shared by the connections of the config.
+func (cfg *RedshiftDataConfig) GetResultCache() cache.Cache {
+	if cfg.ResultCache != nil {
+		return cfg.ResultCache
+	}
+	cfg.defaultCache.Do(func() { cfg.lru = cache.NewLRU(cache.DefaultSize) })
+	return cfg.lru
+}
+
+// GetRetryMinAttemptTimeout returns the configured minimum attempt timeout, falling back to DefaultRetryMinAttemptTimeout when it is not set.
+// Settings applied with Reload take precedence.
+func (cfg *RedshiftDataConfig) GetRetryMinAttemptTimeout() time.Duration {
+	if r := cfg.reloaded.Load(); r != nil && r.RetryMinAttemptTimeout > 0 {
+		return r.RetryMinAttemptTimeout
+	}
+	if cfg.RetryMinAttemptTimeout <= 0 {
+		return DefaultRetryMinAttemptTimeout
+	}
+	return cfg.RetryMinAttemptTimeout
+}
+
+// GetCancelMode returns the configured cancel mode, falling back to CancelModeCancel when it is not set.
+// Settings applied with Reload take precedence.
+func (cfg *RedshiftDataConfig) GetCancelMode() string {
+	if r := cfg.reloaded.Load(); r != nil && r.CancelMode != "" {
+		return r.CancelMode
+	}
+	if cfg.CancelMode == "" {
+		return CancelModeCancel
+	}
+	return cfg.CancelMode
+}
+
+// GetWarehouseWaitThreshold returns the configured warehouse wait threshold, falling back to DefaultWarehouseWaitThreshold when it is not set.
+func (cfg *RedshiftDataConfig) GetWarehouseWaitThreshold() time.Duration {
+	if cfg.WarehouseWaitThreshold <= 0 {
+		return DefaultWarehouseWaitThreshold
+	}
+	return cfg.WarehouseWaitThreshold
+}
+
 // addOrDeleteParam adds or deletes a parameter based on its value.
 func AddOrDeleteParam(params url.Values, key string, value fmt.Stringer) {
 	if value.String() != "0" { // Assuming String() returns "0" for zero values
@@ -46,6 +207,78 @@ func (cfg *RedshiftDataConfig) String() string {
 	params := url.Values{}
 	AddOrDeleteParam(params, "timeout", cfg.Timeout)
 	AddOrDeleteParam(params, "polling", cfg.Polling)
+	AddOrDeleteParam(params, "max_polling", cfg.MaxPolling)
+	if cfg.Dialect != "" {
+		params.Set("dialect", cfg.Dialect)
+	}
+	if cfg.AuthPrecedence != "" {
+		params.Set("auth_precedence", cfg.AuthPrecedence)
+	}
+	cfg.Decode.encodeParams(params)
+	if cfg.TimestampPrecision != "" {
+		params.Set("timestamp_precision", cfg.TimestampPrecision)
+	}
+	if cfg.CancelMode != "" {
+		params.Set("cancel_mode", cfg.CancelMode)
+	}
+	if cfg.APIMetrics {
+		params.Set("api_metrics", "true")
+	}
+	if cfg.QueryLabels {
+		params.Set("query_labels", "true")
+	}
+	if cfg.Coordinator != nil && cfg.Coordinator == quota.Global() {
+		params.Set("quota", "global")
+	}
+	if cfg.CastParameters {
+		params.Set("cast_parameters", "true")
+	}
+	if cfg.MultiStatements {
+		params.Set("multi_statements", "true")
+	}
+	if len(cfg.Features) > 0 {
+		params.Set("features", cfg.Features.String())
+	}
+	if cfg.TxMode != "" {
+		params.Set("tx_mode", cfg.TxMode)
+	}
+	if cfg.Placeholders != "" {
+		params.Set("placeholders", cfg.Placeholders)
+	}
+	if cfg.SerializationRetries > 0 {
+		params.Set("serialization_retries", strconv.Itoa(cfg.SerializationRetries))
+	}
+	if cfg.SerializationRetryBackoff > 0 {
+		params.Set("serialization_retry_backoff", cfg.SerializationRetryBackoff.String())
+	}
+	if cfg.RetryMinAttemptTimeout > 0 {
+		params.Set("retry_min_attempt_timeout", cfg.RetryMinAttemptTimeout.String())
+	}
+	if cfg.ExecBatchWindow > 0 {
+		params.Set("exec_batch_window", cfg.ExecBatchWindow.String())
+	}
+	if cfg.WarehouseWaitThreshold > 0 {
+		params.Set("warehouse_wait_threshold", cfg.WarehouseWaitThreshold.String())
+	}
+	if cfg.PrefetchPages > 0 {
+		params.Set("prefetch_pages", strconv.Itoa(cfg.PrefetchPages))
+	}
+	if cfg.ProgressInterval > 0 {
+		params.Set("progress_interval", cfg.ProgressInterval.String())
+	}
+	if cfg.DescribeTableCacheTTL > 0 {
+		params.Set("describe_table_cache_ttl", cfg.DescribeTableCacheTTL.String())
+	}
+	if cfg.ResultCacheTTL > 0 {
+		params.Set("result_cache_ttl", cfg.ResultCacheTTL.String())
+	}
+	if cfg.ColumnComments {
+		params.Set("column_comments", "true")
+	}
+
+	for name, value := range cfg.DefaultParameters() {
+		params.Set(DefaultParameterPrefix+name, value)
+	}
 
 	EncodedParams := params.Encode()
 	if EncodedParams != "" {
@@ -100,6 +333,207 @@ func (cfg *RedshiftDataConfig) SetParams(params url.Values) error {
 		}
 		cfg.Params.Del("polling")
 	}
+	if params.Has("max_polling") {
+		cfg.MaxPolling, err = time.ParseDuration(params.Get("max_polling"))
+		if err != nil {
+			return fmt.Errorf("error parsing max_polling: %w", err)
+		}
+		cfg.Params.Del("max_polling")
+	}
+
+	if params.Has("result_cache_ttl") {
+		cfg.ResultCacheTTL, err = time.ParseDuration(params.Get("result_cache_ttl"))
+		if err != nil {
+			return fmt.Errorf("error parsing result_cache_ttl: %w", err)
+		}
+		cfg.Params.Del("result_cache_ttl")
+	}
+
+	if params.Has("describe_table_cache_ttl") {
+		cfg.DescribeTableCacheTTL, err = time.ParseDuration(params.Get("describe_table_cache_ttl"))
+		if err != nil {
+			return fmt.Errorf("error parsing describe_table_cache_ttl: %w", err)
+		}
+		cfg.Params.Del("describe_table_cache_ttl")
+	}
+
+	if err := cfg.Decode.setParams(params); err != nil {
+		return err
+	}
+
+	if params.Has("timestamp_precision") {
+		switch p := params.Get("timestamp_precision"); p {
+		case TimestampPrecisionTruncate, TimestampPrecisionError:
+			cfg.TimestampPrecision = p
+		default:
+			return fmt.Errorf("unknown timestamp_precision: %q", p)
+		}
+		cfg.Params.Del("timestamp_precision")
+	}
+
+	if params.Has("cancel_mode") {
+		switch m := params.Get("cancel_mode"); m {
+		case CancelModeCancel, CancelModeLeave:
+			cfg.CancelMode = m
+		default:
+			return fmt.Errorf("unknown cancel_mode: %q", m)
+		}
+		cfg.Params.Del("cancel_mode")
+	}
+
+	if params.Has("retry_min_attempt_timeout") {
+		cfg.RetryMinAttemptTimeout, err = time.ParseDuration(params.Get("retry_min_attempt_timeout"))
+		if err != nil {
+			return fmt.Errorf("error parsing retry_min_attempt_timeout: %w", err)
+		}
+		cfg.Params.Del("retry_min_attempt_timeout")
+	}
+
+	if params.Has("serialization_retries") {
+		cfg.SerializationRetries, err = strconv.Atoi(params.Get("serialization_retries"))
+		if err != nil {
+			return fmt.Errorf("error parsing serialization_retries: %w", err)
+		}
+		cfg.Params.Del("serialization_retries")
+	}
+	if params.Has("serialization_retry_backoff") {
+		cfg.SerializationRetryBackoff, err = time.ParseDuration(params.Get("serialization_retry_backoff"))
+		if err != nil {
+			return fmt.Errorf("error parsing serialization_retry_backoff: %w", err)
+		}
+		cfg.Params.Del("serialization_retry_backoff")
+	}
+
+	if params.Has("exec_batch_window") {
+		cfg.ExecBatchWindow, err = time.ParseDuration(params.Get("exec_batch_window"))
+		if err != nil {
+			return fmt.Errorf("error parsing exec_batch_window: %w", err)
+		}
+		cfg.Params.Del("exec_batch_window")
+	}
+
+	if params.Has("warehouse_wait_threshold") {
+		cfg.WarehouseWaitThreshold, err = time.ParseDuration(params.Get("warehouse_wait_threshold"))
+		if err != nil {
+			return fmt.Errorf("error parsing warehouse_wait_threshold: %w", err)
+		}
+		cfg.Params.Del("warehouse_wait_threshold")
+	}
+
+	if params.Has("prefetch_pages") {
+		cfg.PrefetchPages, err = strconv.Atoi(params.Get("prefetch_pages"))
+		if err != nil {
+			return fmt.Errorf("error parsing prefetch_pages: %w", err)
+		}
+		cfg.Params.Del("prefetch_pages")
+	}
+	if params.Has("progress_interval") {
+		cfg.ProgressInterval, err = time.ParseDuration(params.Get("progress_interval"))
+		if err != nil {
+			return fmt.Errorf("error parsing progress_interval: %w", err)
+		}
+		cfg.Params.Del("progress_interval")
+	}
+
+	if params.Has("api_metrics") {
+		cfg.APIMetrics, err = strconv.ParseBool(params.Get("api_metrics"))
+		if err != nil {
+			return fmt.Errorf("error parsing api_metrics: %w", err)
+		}
+		cfg.Params.Del("api_metrics")
+	}
+
+	if params.Has("features") {
+		cfg.Features, err = ParseFeatures(params.Get("features"))
+		if err != nil {
+			return fmt.Errorf("error parsing features: %w", err)
+		}
+		cfg.Params.Del("features")
+	}
+
+	if params.Has("multi_statements") {
+		cfg.MultiStatements, err = strconv.ParseBool(params.Get("multi_statements"))
+		if err != nil {
+			return fmt.Errorf("error parsing multi_statements: %w", err)
+		}
+		cfg.Params.Del("multi_statements")
+	}
+
+	if params.Has("query_labels") {
+		cfg.QueryLabels, err = strconv.ParseBool(params.Get("query_labels"))
+		if err != nil {
+			return fmt.Errorf("error parsing query_labels: %w", err)
+		}
+		cfg.Params.Del("query_labels")
+	}
+
+	if params.Has("column_comments") {
+		cfg.ColumnComments, err = strconv.ParseBool(params.Get("column_comments"))
+		if err != nil {
+			return fmt.Errorf("error parsing column_comments: %w", err)
+		}
+		cfg.Params.Del("column_comments")
+	}
+
+	if params.Has("cast_parameters") {
+		cfg.CastParameters, err = strconv.ParseBool(params.Get("cast_parameters"))
+		if err != nil {
+			return fmt.Errorf("error parsing cast_parameters: %w", err)
+		}
+		cfg.Params.Del("cast_parameters")
+	}
+
+	if params.Has("quota") {
+		switch q := params.Get("quota"); q {
+		case "global":
+			cfg.Coordinator = quota.Global()
+		case "", "none":
+			cfg.Coordinator = nil
+		default:
+			return fmt.Errorf("unknown quota: %q", q)
+		}
+		cfg.Params.Del("quota")
+	}
+
+	if params.Has("dialect") {
+		switch d := params.Get("dialect"); d {
+		case dialect.Redshift, dialect.Postgres:
+			cfg.Dialect = d
+		default:
+			return fmt.Errorf("unknown dialect: %q", d)
+		}
+		cfg.Params.Del("dialect")
+	}
+
+	if params.Has("auth_precedence") {
+		switch p := params.Get("auth_precedence"); p {
+		case AuthPrecedenceSecret, AuthPrecedenceDBUser:
+			cfg.AuthPrecedence = p
+		default:
+			return fmt.Errorf("unknown auth_precedence: %q", p)
+		}
+		cfg.Params.Del("auth_precedence")
+	}
+
+	if params.Has("tx_mode") {
+		switch m := params.Get("tx_mode"); m {
+		case TxModeBatch, TxModeSession:
+			cfg.TxMode = m
+		default:
+			return fmt.Errorf("unknown tx_mode: %q", m)
+		}
+		cfg.Params.Del("tx_mode")
+	}
+
+	if params.Has("placeholders") {
+		switch p := params.Get("placeholders"); p {
+		case PlaceholdersQuestion, PlaceholdersDollar, PlaceholdersColon, PlaceholdersNone:
+			cfg.Placeholders = p
+		default:
+			return fmt.Errorf("unknown placeholders: %q", p)
+		}
+		cfg.Params.Del("placeholders")
+	}
 
 	if params.Has("region") {
 		cfg = cfg.WithRegion(params.Get("region"))
@@ -124,9 +558,71 @@ func (cfg *RedshiftDataConfig) WithRegion(region string) *RedshiftDataConfig {
 	return cfg
 }
 
-// ParseDSN Parses a Data Source Name (DSN) string into a RedshiftDataConfig object. 
+// DefaultParameterPrefix prefixes the DSN parameters holding default statement parameters, such as param.tenant_id=42.
+const DefaultParameterPrefix = "param."
+
+// DefaultParameters returns the default statement parameters of the config, by name. They are bound to the
+// :name placeholders of every statement that refers to them without an argument of that name, which lets a
+// connector scope its queries, for instance to a tenant, without passing the value at each call site.
+func (cfg *RedshiftDataConfig) DefaultParameters() map[string]string {
+	var defaults map[string]string
+	for key, values := range cfg.Params {
+		if name, ok := strings.CutPrefix(key, DefaultParameterPrefix); ok && name != "" && len(values) > 0 {
+			if defaults == nil {
+				defaults = map[string]string{}
+			}
+			defaults[name] = values[0]
+		}
+	}
+	return defaults
+}
+
+// WithDefaultParameter sets a default statement parameter, see DefaultParameters, and returns the updated configuration object.
+func (cfg *RedshiftDataConfig) WithDefaultParameter(name, value string) *RedshiftDataConfig {
+	if cfg.Params == nil {
+		cfg.Params = url.Values{}
+	}
+	cfg.Params.Set(DefaultParameterPrefix+name, value)
+	return cfg
+}
+
+// WithCredentialsProvider sets the credentials used by the RedshiftData API client and returns the updated configuration object.
+// Providers that do not cache their credentials are wrapped in an aws.CredentialsCache.
+func (cfg *RedshiftDataConfig) WithCredentialsProvider(p aws.CredentialsProvider) *RedshiftDataConfig {
+	if _, ok := p.(*aws.CredentialsCache); !ok {
+		p = aws.NewCredentialsCache(p)
+	}
+	cfg.RedshiftDataOptFns = append(cfg.RedshiftDataOptFns, func(o *redshiftdata.Options) {
+		o.Credentials = p
+	})
+	return cfg
+}
+
+// WithHooks registers hooks called around every statement and returns the updated configuration object.
+func (cfg *RedshiftDataConfig) WithHooks(h *hooks.Hooks) *RedshiftDataConfig {
+	cfg.Hooks = append(cfg.Hooks, h)
+	return cfg
+}
+
+// WithCoordinator shares the submission rate and active statement budget of c with the other connectors using it
+// and returns the updated configuration object.
+func (cfg *RedshiftDataConfig) WithCoordinator(c *quota.Coordinator) *RedshiftDataConfig {
+	cfg.Coordinator = c
+	return cfg
+}
+
+// ParseDSN Parses a Data Source Name (DSN) string into a RedshiftDataConfig object.
 // It Supports ARN-based connections, cluster-based connections, and workgroup-based connections.
+// Errors match errors.ErrConfigInvalid.
 func ParseDSN(dsn string) (*RedshiftDataConfig, error) {
+	cfg, err := parseDSN(dsn)
+	if err != nil {
+		return nil, errors.NewConfigError(errors.ErrConfigInvalid, err)
+	}
+	return cfg, nil
+}
+
+func parseDSN(dsn string) (*RedshiftDataConfig, error) {
 	if dsn == "" {
 		return nil, errors.ErrDSNEmpty
 	}
@@ -0,0 +1,236 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	IntegerWidthInt64 = "int64" // IntegerWidthInt64 returns every integer column as int64
+	IntegerWidthExact = "exact" // IntegerWidthExact returns SMALLINT, INTEGER and BIGINT as int16, int32 and int64, failing on overflow
+)
+
+const (
+	ColumnCaseLower = "lower" // ColumnCaseLower lower-cases the column names of result sets
+	ColumnCaseUpper = "upper" // ColumnCaseUpper upper-cases the column names of result sets
+)
+
+const (
+	SuperString = "string" // SuperString returns SUPER values as their JSON text
+	SuperJSON   = "json"   // SuperJSON returns SUPER values as json.RawMessage
+	SuperValue  = "value"  // SuperValue parses SUPER values into map[string]any, []any, string, float64, bool or nil
+)
+
+const (
+	NumericString  = "string"  // NumericString returns NUMERIC values as strings, as the Data API returns them
+	NumericDecimal = "decimal" // NumericDecimal returns NUMERIC values as metasql.Decimal
+)
+
+// DecodeOptions gathers the settings controlling how result sets are decoded.
+// They are set per connector on RedshiftDataConfig.Decode and can be replaced per query through the context.
+type DecodeOptions struct {
+	IntegerWidth string `yaml:"integer_width"` // IntegerWidth selects the Go type of integer columns, IntegerWidthInt64 by default
+	ColumnCase   string `yaml:"column_case"`   // ColumnCase folds the case of column names, they are kept as reported by default
+	StrictTypes  bool   `yaml:"strict_types"`  // StrictTypes fails on values whose Go type does not match the scan type of their column
+	Workers      int    `yaml:"workers"`       // Workers decodes each fetched page with this many goroutines, rows are decoded by the caller of Next when it is below 2
+	Super        string `yaml:"super"`         // Super selects the Go type of SUPER values, SuperString by default
+	Numeric      string `yaml:"numeric"`       // Numeric selects the Go type of NUMERIC values, NumericString by default
+	ParseTime    bool   `yaml:"parse_time"`    // ParseTime returns TIMESTAMP and TIMESTAMPTZ values as time.Time instead of strings
+	Location     string `yaml:"loc"`           // Location is the time zone of zone-less TIMESTAMP values and of parsed TIMESTAMPTZ values, UTC by default
+	// ColumnTypes overrides the type of result columns, keyed by column name or table.column, such as
+	// {"payload": "super", "amount": "decimal(18,4)"}, for the columns whose metadata does not tell their intent.
+	// Decoding, scan types and column metadata all follow the declared type.
+	ColumnTypes map[string]string `yaml:"column_types"`
+}
+
+// decodeParams lists the DSN parameters of the DecodeOptions.
+var decodeParams = []string{"integer_width", "column_case", "strict_types", "decode_workers", "super", "numeric", "parse_time", "loc", "column_types"}
+
+// setParams parses the DSN parameters of the options and removes them from params.
+func (o *DecodeOptions) setParams(params url.Values) error {
+	if params.Has("integer_width") {
+		switch w := params.Get("integer_width"); w {
+		case IntegerWidthInt64, IntegerWidthExact:
+			o.IntegerWidth = w
+		default:
+			return fmt.Errorf("unknown integer_width: %q", w)
+		}
+	}
+	if params.Has("column_case") {
+		switch c := params.Get("column_case"); c {
+		case ColumnCaseLower, ColumnCaseUpper:
+			o.ColumnCase = c
+		default:
+			return fmt.Errorf("unknown column_case: %q", c)
+		}
+	}
+	if params.Has("super") {
+		switch m := params.Get("super"); m {
+		case SuperString, SuperJSON, SuperValue:
+			o.Super = m
+		default:
+			return fmt.Errorf("unknown super: %q", m)
+		}
+	}
+	if params.Has("numeric") {
+		switch n := params.Get("numeric"); n {
+		case NumericString, NumericDecimal:
+			o.Numeric = n
+		default:
+			return fmt.Errorf("unknown numeric: %q", n)
+		}
+	}
+	if params.Has("parse_time") {
+		var err error
+		if o.ParseTime, err = strconv.ParseBool(params.Get("parse_time")); err != nil {
+			return fmt.Errorf("error parsing parse_time: %w", err)
+		}
+	}
+	if params.Has("loc") {
+		if _, err := time.LoadLocation(params.Get("loc")); err != nil {
+			return fmt.Errorf("error parsing loc: %w", err)
+		}
+		o.Location = params.Get("loc")
+	}
+	if params.Has("column_types") {
+		types, err := parseColumnTypes(params.Get("column_types"))
+		if err != nil {
+			return fmt.Errorf("error parsing column_types: %w", err)
+		}
+		o.ColumnTypes = types
+	}
+	if params.Has("strict_types") {
+		var err error
+		if o.StrictTypes, err = strconv.ParseBool(params.Get("strict_types")); err != nil {
+			return fmt.Errorf("error parsing strict_types: %w", err)
+		}
+	}
+	if params.Has("decode_workers") {
+		var err error
+		if o.Workers, err = strconv.Atoi(params.Get("decode_workers")); err != nil {
+			return fmt.Errorf("error parsing decode_workers: %w", err)
+		}
+	}
+	for _, p := range decodeParams {
+		params.Del(p)
+	}
+	return nil
+}
+
+// encodeParams adds the DSN parameters of the options that are set to params.
+func (o *DecodeOptions) encodeParams(params url.Values) {
+	if o.IntegerWidth != "" {
+		params.Set("integer_width", o.IntegerWidth)
+	}
+	if o.ColumnCase != "" {
+		params.Set("column_case", o.ColumnCase)
+	}
+	if o.Super != "" {
+		params.Set("super", o.Super)
+	}
+	if o.Numeric != "" {
+		params.Set("numeric", o.Numeric)
+	}
+	if o.ParseTime {
+		params.Set("parse_time", "true")
+	}
+	if o.Location != "" {
+		params.Set("loc", o.Location)
+	}
+	if len(o.ColumnTypes) > 0 {
+		params.Set("column_types", formatColumnTypes(o.ColumnTypes))
+	}
+	if o.StrictTypes {
+		params.Set("strict_types", "true")
+	}
+	if o.Workers > 0 {
+		params.Set("decode_workers", strconv.Itoa(o.Workers))
+	}
+}
+
+// TimeLocation returns the time zone named by Location, UTC when it is empty or can not be loaded.
+func (o *DecodeOptions) TimeLocation() *time.Location {
+	if o.Location == "" {
+		return time.UTC
+	}
+	loc, err := time.LoadLocation(o.Location)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+// parseColumnTypes parses column type overrides written column:type and separated by commas, such as
+// payload:super,amount:decimal(18,4).
+func parseColumnTypes(s string) (map[string]string, error) {
+	types := map[string]string{}
+	depth, start := 0, 0
+	for i := 0; i <= len(s); i++ {
+		if i < len(s) {
+			switch s[i] {
+			case '(':
+				depth++
+			case ')':
+				depth--
+			}
+			if s[i] != ',' || depth > 0 {
+				continue
+			}
+		}
+		if entry := strings.TrimSpace(s[start:i]); entry != "" {
+			column, spec, ok := strings.Cut(entry, ":")
+			if !ok {
+				return nil, fmt.Errorf("column type %q is not column:type", entry)
+			}
+			if _, _, _, err := ParseColumnType(spec); err != nil {
+				return nil, err
+			}
+			types[strings.TrimSpace(column)] = strings.TrimSpace(spec)
+		}
+		start = i + 1
+	}
+	return types, nil
+}
+
+func formatColumnTypes(types map[string]string) string {
+	entries := make([]string, 0, len(types))
+	for column, spec := range types {
+		entries = append(entries, column+":"+spec)
+	}
+	sort.Strings(entries)
+	return strings.Join(entries, ",")
+}
+
+// ParseColumnType parses a column type such as super, varchar(256) or decimal(18,4) into its lower-cased name
+// and its modifiers: the precision or length, and the scale.
+func ParseColumnType(spec string) (typeName string, precision, scale int32, err error) {
+	spec = strings.ToLower(strings.TrimSpace(spec))
+	name, modifiers, ok := strings.Cut(spec, "(")
+	typeName = strings.TrimSpace(name)
+	if typeName == "" {
+		return "", 0, 0, fmt.Errorf("invalid column type %q", spec)
+	}
+	if !ok {
+		return typeName, 0, 0, nil
+	}
+	modifiers, ok = strings.CutSuffix(strings.TrimSpace(modifiers), ")")
+	if !ok {
+		return "", 0, 0, fmt.Errorf("invalid column type %q", spec)
+	}
+	for i, m := range strings.Split(modifiers, ",") {
+		n, err := strconv.ParseInt(strings.TrimSpace(m), 10, 32)
+		if err != nil || i > 1 {
+			return "", 0, 0, fmt.Errorf("invalid column type %q", spec)
+		}
+		if i == 0 {
+			precision = int32(n)
+		} else {
+			scale = int32(n)
+		}
+	}
+	return typeName, precision, scale, nil
+}
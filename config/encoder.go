@@ -0,0 +1,22 @@
+package config
+
+// ParameterEncoder renders argument values into the string values of Data API parameters.
+// It lets custom Go types, such as money amounts, enums or domain ids, be passed as arguments as they are.
+type ParameterEncoder interface {
+	// EncodeParameter returns the parameter value for v. When ok is false the default encoding is used.
+	EncodeParameter(v any) (value string, ok bool, err error)
+}
+
+// ParameterEncoderFunc adapts a function to the ParameterEncoder interface.
+type ParameterEncoderFunc func(v any) (value string, ok bool, err error)
+
+// EncodeParameter calls f(v).
+func (f ParameterEncoderFunc) EncodeParameter(v any) (string, bool, error) {
+	return f(v)
+}
+
+// WithParameterEncoder sets the encoder applied to the arguments of every statement and returns the updated configuration object.
+func (cfg *RedshiftDataConfig) WithParameterEncoder(e ParameterEncoder) *RedshiftDataConfig {
+	cfg.ParameterEncoder = e
+	return cfg
+}
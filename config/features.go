@@ -0,0 +1,92 @@
+package config
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Feature names a subsystem of the driver gated by RedshiftDataConfig.Features.
+type Feature string
+
+const (
+	FeatureSessions      Feature = "sessions"       // FeatureSessions allows pinned sessions, TxModeSession and the helpers running in a session, enabled by default
+	FeatureResultCache   Feature = "result_cache"   // FeatureResultCache allows caching query results, see ResultCacheTTL, enabled by default
+	FeaturePlanCache     Feature = "plan_cache"     // FeaturePlanCache caches the rewritten placeholders and checked parameters of queries per connection, enabled by default
+	FeatureParallelFetch Feature = "parallel_fetch" // FeatureParallelFetch fetches and decodes the pages of result sets in the background, ahead of the rows scanned, disabled by default
+)
+
+// defaultFeatures holds the state of the features the Features of a config do not set.
+var defaultFeatures = map[Feature]bool{
+	FeatureSessions:      true,
+	FeatureResultCache:   true,
+	FeaturePlanCache:     true,
+	FeatureParallelFetch: false,
+}
+
+// KnownFeatures returns the names of the features of the driver, sorted.
+func KnownFeatures() []Feature {
+	return sortedFeatures(defaultFeatures)
+}
+
+func sortedFeatures(m map[Feature]bool) []Feature {
+	features := make([]Feature, 0, len(m))
+	for feature := range m {
+		features = append(features, feature)
+	}
+	slices.Sort(features)
+	return features
+}
+
+// Features enables or disables subsystems of the driver, so new and experimental ones can be adopted one at a time
+// and established ones turned off. Features missing from the map keep their default state.
+// In a DSN, features=result_cache,-sessions enables the listed features, and disables the ones prefixed with -.
+type Features map[Feature]bool
+
+// Enabled reports whether the feature is enabled, by the features or by default.
+func (f Features) Enabled(feature Feature) bool {
+	if enabled, ok := f[feature]; ok {
+		return enabled
+	}
+	return defaultFeatures[feature]
+}
+
+// State returns the state of every known feature.
+func (f Features) State() map[Feature]bool {
+	state := make(map[Feature]bool, len(defaultFeatures))
+	for feature := range defaultFeatures {
+		state[feature] = f.Enabled(feature)
+	}
+	return state
+}
+
+// ParseFeatures parses a comma-separated list of features, each one enabled unless it is prefixed with -.
+func ParseFeatures(s string) (Features, error) {
+	features := Features{}
+	for _, name := range strings.Split(s, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		enabled := !strings.HasPrefix(name, "-")
+		feature := Feature(strings.TrimPrefix(name, "-"))
+		if _, ok := defaultFeatures[feature]; !ok {
+			return nil, fmt.Errorf("unknown feature: %q", feature)
+		}
+		features[feature] = enabled
+	}
+	return features, nil
+}
+
+// String formats the features set explicitly as ParseFeatures reads them, sorted.
+func (f Features) String() string {
+	var names []string
+	for _, feature := range sortedFeatures(f) {
+		if f[feature] {
+			names = append(names, string(feature))
+		} else {
+			names = append(names, "-"+string(feature))
+		}
+	}
+	return strings.Join(names, ",")
+}
@@ -0,0 +1,100 @@
+package config
+
+import (
+	"cmp"
+	"math"
+	"math/rand/v2"
+	"time"
+)
+
+// PollingStrategy decides how long to wait between the DescribeStatement calls polling the status of a statement,
+// trading the number of Data API calls against the latency added to statements once they finish.
+type PollingStrategy interface {
+	// Interval returns the delay before the attempt-th poll of a statement, counted from 1, submitted elapsed ago.
+	Interval(attempt int, elapsed time.Duration) time.Duration
+}
+
+// PollingStrategyFunc adapts a function to the PollingStrategy interface.
+type PollingStrategyFunc func(attempt int, elapsed time.Duration) time.Duration
+
+// Interval calls f(attempt, elapsed).
+func (f PollingStrategyFunc) Interval(attempt int, elapsed time.Duration) time.Duration {
+	return f(attempt, elapsed)
+}
+
+// FixedPolling polls at a constant interval.
+type FixedPolling time.Duration
+
+// Interval returns the interval.
+func (p FixedPolling) Interval(int, time.Duration) time.Duration {
+	return time.Duration(p)
+}
+
+// BackoffPolling polls fast queries every Initial, then backs off exponentially up to Max for long-running ones,
+// so a statement running for minutes costs a few dozen DescribeStatement calls instead of thousands.
+type BackoffPolling struct {
+	Initial    time.Duration // Initial is the delay before the first poll.
+	Max        time.Duration // Max caps the delay between polls, Initial when lower.
+	Multiplier float64       // Multiplier grows the delay after each poll, 2 when not greater than 1.
+	Jitter     float64       // Jitter randomly spreads each delay by up to this fraction of it, so statements submitted together do not poll together.
+}
+
+// Interval returns Initial * Multiplier^(attempt-1), capped at Max and spread by Jitter.
+func (p BackoffPolling) Interval(attempt int, _ time.Duration) time.Duration {
+	multiplier := p.Multiplier
+	if multiplier <= 1 {
+		multiplier = 2
+	}
+	limit := max(p.Max, p.Initial)
+	d := float64(p.Initial) * math.Pow(multiplier, float64(max(attempt-1, 0)))
+	d = min(d, float64(limit))
+	if p.Jitter > 0 {
+		d += d * p.Jitter * (2*rand.Float64() - 1)
+	}
+	return time.Duration(max(d, 0))
+}
+
+// GetPollingStrategy returns the strategy statements are polled with: the configured PollingStrategy, a fixed
+// Polling interval when MaxPolling is not set, or else a BackoffPolling from the polling interval up to MaxPolling,
+// or DefaultMaxPolling. The Polling and MaxPolling applied with Reload are merged into the strategy: they replace
+// the interval of a FixedPolling and the Initial and Max of a BackoffPolling, other strategies are kept as they are.
+func (cfg *RedshiftDataConfig) GetPollingStrategy() PollingStrategy {
+	var r Reloadable
+	if reloaded := cfg.reloaded.Load(); reloaded != nil {
+		r = *reloaded
+	}
+	switch s := cfg.PollingStrategy.(type) {
+	case nil:
+	case FixedPolling:
+		return FixedPolling(cmp.Or(r.Polling, time.Duration(s)))
+	case BackoffPolling:
+		return s.reloaded(r)
+	case *BackoffPolling:
+		return s.reloaded(r)
+	default:
+		return s
+	}
+	maxPolling := cmp.Or(r.MaxPolling, cfg.MaxPolling)
+	if cfg.Polling > 0 && maxPolling <= 0 {
+		return FixedPolling(cfg.GetPolling())
+	}
+	return BackoffPolling{
+		Initial:    cfg.GetPolling(),
+		Max:        cmp.Or(maxPolling, DefaultMaxPolling),
+		Multiplier: DefaultPollingMultiplier,
+		Jitter:     DefaultPollingJitter,
+	}
+}
+
+// reloaded returns the strategy with the polling intervals applied with Reload.
+func (p BackoffPolling) reloaded(r Reloadable) BackoffPolling {
+	p.Initial = cmp.Or(r.Polling, p.Initial)
+	p.Max = cmp.Or(r.MaxPolling, p.Max)
+	return p
+}
+
+// WithPollingStrategy sets the strategy the status of statements is polled with and returns the updated configuration object.
+func (cfg *RedshiftDataConfig) WithPollingStrategy(s PollingStrategy) *RedshiftDataConfig {
+	cfg.PollingStrategy = s
+	return cfg
+}
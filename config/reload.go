@@ -0,0 +1,142 @@
+package config
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/adarsh-jaiss/metasql/quota"
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultAppConfigEndpoint is the address the AWS AppConfig Agent and the AppConfig Lambda extension serve
+// configurations on.
+const DefaultAppConfigEndpoint = "http://localhost:2772"
+
+// Reloadable holds the settings that can be changed at runtime without recreating connectors.
+// Zero values fall back to the settings the config was created with.
+type Reloadable struct {
+	Timeout    time.Duration `yaml:"timeout"`     // Timeout overrides RedshiftDataConfig.Timeout
+	Polling    time.Duration `yaml:"polling"`     // Polling overrides RedshiftDataConfig.Polling, the first interval of a backoff PollingStrategy
+	MaxPolling time.Duration `yaml:"max_polling"` // MaxPolling overrides RedshiftDataConfig.MaxPolling, the longest interval of a backoff PollingStrategy
+	CancelMode string        `yaml:"cancel_mode"` // CancelMode overrides RedshiftDataConfig.CancelMode
+
+	SerializationRetries      *int          `yaml:"serialization_retries"`       // SerializationRetries overrides RedshiftDataConfig.SerializationRetries when not nil, 0 disables retries
+	SerializationRetryBackoff time.Duration `yaml:"serialization_retry_backoff"` // SerializationRetryBackoff overrides RedshiftDataConfig.SerializationRetryBackoff
+	RetryMinAttemptTimeout    time.Duration `yaml:"retry_min_attempt_timeout"`   // RetryMinAttemptTimeout overrides RedshiftDataConfig.RetryMinAttemptTimeout
+
+	// Quota overrides the limits of the Coordinator of the config, field by field. The coordinator is shared with
+	// the other connectors using it, such as all the ones with quota=global, they are all affected.
+	Quota quota.Limits `yaml:"quota"`
+}
+
+// validate reports the settings Reload can not apply.
+func (r *Reloadable) validate() error {
+	switch r.CancelMode {
+	case "", CancelModeCancel, CancelModeLeave:
+	default:
+		return fmt.Errorf("unknown cancel_mode: %q", r.CancelMode)
+	}
+	if r.SerializationRetries != nil && *r.SerializationRetries < 0 {
+		return fmt.Errorf("negative serialization_retries: %d", *r.SerializationRetries)
+	}
+	if r.Quota.Rate < 0 || r.Quota.Burst < 0 || r.Quota.MaxActive < 0 {
+		return fmt.Errorf("negative quota limits: %+v", r.Quota)
+	}
+	return nil
+}
+
+// Source returns the current content of a YAML document holding Reloadable settings.
+// Other keys of the document are ignored, so the config file of an application can be used as is.
+type Source func(ctx context.Context) ([]byte, error)
+
+// FileSource reads the settings from a YAML file.
+func FileSource(path string) Source {
+	return func(ctx context.Context) ([]byte, error) {
+		return os.ReadFile(path)
+	}
+}
+
+// AppConfigSource reads the settings from the YAML configuration profile of an AWS AppConfig application and
+// environment, through the AppConfig Agent or the AppConfig Lambda extension listening at endpoint,
+// DefaultAppConfigEndpoint when empty. The agent polls AppConfig and caches the configuration, so reading it
+// does not call AppConfig nor need AWS credentials in the application.
+func AppConfigSource(endpoint, application, environment, profile string) Source {
+	u := cmp.Or(endpoint, DefaultAppConfigEndpoint) + "/applications/" + url.PathEscape(application) +
+		"/environments/" + url.PathEscape(environment) + "/configurations/" + url.PathEscape(profile)
+	return func(ctx context.Context) ([]byte, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		defer resp.Body.Close()
+		b, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("appconfig %s/%s/%s: %s: %s", application, environment, profile, resp.Status, bytes.TrimSpace(b))
+		}
+		return b, nil
+	}
+}
+
+// Reload applies the reloadable settings, they are picked up by the next statement of every connection.
+// The Quota limits are applied to the Coordinator of the config right away, the limits it had before the first
+// reload changing them are restored once a reload leaves them out.
+func (cfg *RedshiftDataConfig) Reload(r Reloadable) {
+	prev := cfg.reloaded.Swap(&r)
+	c := cfg.Coordinator
+	if c == nil || r.Quota == (quota.Limits{}) && (prev == nil || prev.Quota == (quota.Limits{})) {
+		return
+	}
+	cfg.quotaOnce.Do(func() { cfg.quota = c.Limits() })
+	c.SetLimits(quota.Limits{
+		Rate:      cmp.Or(r.Quota.Rate, cfg.quota.Rate),
+		Burst:     cmp.Or(r.Quota.Burst, cfg.quota.Burst),
+		MaxActive: cmp.Or(r.Quota.MaxActive, cfg.quota.MaxActive),
+	})
+}
+
+// Watch reloads the settings from source every interval, DefaultReloadInterval when not positive, until ctx is
+// done. Errors reading, parsing or validating the settings keep the current ones and are reported to onError,
+// which may be nil.
+func (cfg *RedshiftDataConfig) Watch(ctx context.Context, source Source, interval time.Duration, onError func(error)) {
+	if interval <= 0 {
+		interval = DefaultReloadInterval
+	}
+	var last []byte
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		b, err := source(ctx)
+		if err == nil && !bytes.Equal(b, last) {
+			var r Reloadable
+			if err = yaml.Unmarshal(b, &r); err == nil {
+				err = r.validate()
+			}
+			if err == nil {
+				cfg.Reload(r)
+				last = b
+			}
+		}
+		if err != nil && onError != nil {
+			onError(fmt.Errorf("reload config: %w", err))
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -4,11 +4,21 @@ import (
 	"context"
 	"database/sql"
 	"database/sql/driver"
+	"encoding/hex"
+	stderrors "errors"
 	"fmt"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"sync/atomic"
 	"time"
+	"unicode"
 
 	cfg "github.com/adarsh-jaiss/metasql/config"
+	"github.com/adarsh-jaiss/metasql/dialect"
 	"github.com/adarsh-jaiss/metasql/errors"
+	"github.com/adarsh-jaiss/metasql/hooks"
 	"github.com/adarsh-jaiss/metasql/types"
 	"github.com/adarsh-jaiss/metasql/utils"
 	"github.com/aws/aws-sdk-go-v2/aws"
@@ -23,30 +33,53 @@ type redshiftDataConn struct {
 	cfg      *cfg.RedshiftDataConfig // RedshiftDataConfig is a struct that holds the configuration details required to connect to an AWS Redshift database using the Redshift Data API.
 	aliveCh  chan struct{}           // aliveCh is a channel that is closed when the connection is closed.
 	isClosed bool                    // isClosed is a flag that indicates whether the connection is closed.
+	tables   *tableMetadataCache     // tables caches DescribeTable metadata used to resolve result columns, nil when disabled.
+	plans    *planCache              // plans caches the plans of the queries executed on the connection, see FeaturePlanCache.
+	caps     *capabilitiesCache      // caps holds the probed capabilities of the target.
+
+	info       hooks.ConnInfo // info identifies the connection in hooks, stats and errors.
+	statements atomic.Int64   // statements counts the statements executed on the connection.
+	lastUsed   atomic.Int64   // lastUsed is the Unix time in nanoseconds the last statement was submitted.
+	onClose    func()         // onClose is called once the connection is closed, nil when not needed.
+	batch      execBatch      // batch buffers Execs when micro-batching is enabled.
+	session    dataAPISession // session is the Data API session statements are pinned to, see PinSession.
+	database   string         // database replaces the database of the config, see UseDatabase.
 
 	inTx          bool                         // inTx is a flag that indicates whether the connection is in a transaction.
+	sessionTx     bool                         // sessionTx is set when the transaction runs in a Data API session, see TxModeSession.
 	txOpts        driver.TxOptions             // txOpts is a struct that holds the transaction options.
 	sqls          []string                     // sqls is a slice that holds the SQL statements executed in the transaction.
 	delayedResult []*redshiftDataDelayedResult // delayedResult is a slice that holds the delayed results of the SQL statements executed in the transaction.
 }
 
+// connSeq numbers the connections of the process.
+var connSeq atomic.Uint64
+
 // NewConnection returns a new redshiftDataConn instance with the provided RedshiftDataClient and RedshiftDataConfig.
 func NewConnection(client RedshiftDataClient, cfg *cfg.RedshiftDataConfig) *redshiftDataConn {
 	return &redshiftDataConn{
 		client:  client,
 		cfg:     cfg,
 		aliveCh: make(chan struct{}),
+		tables:  newTableMetadataCache(cfg.DescribeTableCacheTTL),
+		plans:   newPlanCache(planCacheSize),
+		caps:    &capabilitiesCache{},
+		info: hooks.ConnInfo{
+			ID:        fmt.Sprintf("conn-%d", connSeq.Add(1)),
+			CreatedAt: time.Now(),
+		},
 	}
 }
 
-// Prepares a SQL statement for execution.
-// This method returns an error indicating that prepared statements are not supported by this driver.
+// PrepareContext prepares a SQL statement for execution, on the client side: see redshiftDataStmt.
 func (conn *redshiftDataConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
-	return nil, fmt.Errorf("prepared statment %w", errors.ErrNotSupported)
+	if conn.isClosed {
+		return nil, driver.ErrBadConn
+	}
+	return &redshiftDataStmt{conn: conn, query: query, numInput: numInput(query, conn.cfg.Placeholders)}, nil
 }
 
 // Prepare  A convenience wrapper around PrepareContext, using context.Background() as the context.
-// It also indicates that prepared statements are not supported.
 func (conn *redshiftDataConn) Prepare(query string) (driver.Stmt, error) {
 	return conn.PrepareContext(context.Background(), query)
 }
@@ -57,18 +90,29 @@ func (conn *redshiftDataConn) Close() error {
 	if conn.isClosed {
 		return nil
 	}
+	flushErr := conn.flushBatch(context.Background())
 
 	conn.isClosed = true
 	close(conn.aliveCh)
-	return nil
+	if conn.onClose != nil {
+		conn.onClose()
+	}
+	return flushErr
 }
 
 func (conn *redshiftDataConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
 	if conn.inTx {
 		return nil, errors.ErrInTx
 	}
+	if err := conn.flushBatch(ctx); err != nil {
+		return nil, err
+	}
 	if opts.Isolation != driver.IsolationLevel(sql.LevelDefault) {
-		return nil, fmt.Errorf("isolation level %w", errors.ErrNotSupported)
+		return nil, errors.NewUnsupportedError(fmt.Sprintf("isolation level %s", sql.IsolationLevel(opts.Isolation)), errors.LimitationPlatform, "Redshift transactions are serializable")
+	}
+
+	if sessionTx(ctx, conn.cfg) {
+		return conn.beginSession(ctx, opts)
 	}
 
 	conn.inTx = true
@@ -80,15 +124,24 @@ func (conn *redshiftDataConn) BeginTx(ctx context.Context, opts driver.TxOptions
 		conn.delayedResult = nil
 		return nil
 	}
+	txEvent := func() *hooks.TxEvent {
+		return &hooks.TxEvent{
+			Statements:  append([]string(nil), conn.sqls...),
+			Conn:        conn.info,
+			Correlation: hooks.Correlate(ctx, conn.cfg.Hooks),
+		}
+	}
 	tx := &types.RedshiftDataTx{
 		OnRollback: func() error {
 			if !conn.inTx {
 				return errors.ErrNotInTx
 			}
+			event := txEvent()
 			err := cleanup()
 			if err != nil {
 				return fmt.Errorf("rollback error : %w", err)
 			}
+			hooks.AfterRollback(ctx, conn.cfg.Hooks, event)
 			return nil
 		},
 
@@ -96,173 +149,600 @@ func (conn *redshiftDataConn) BeginTx(ctx context.Context, opts driver.TxOptions
 			if !conn.inTx {
 				return errors.ErrNotInTx
 			}
-			if len(conn.sqls) == 0 {
-				return cleanup()
-			}
+			defer cleanup()
 			if len(conn.sqls) != len(conn.delayedResult) {
 				panic(fmt.Sprintf("unexpected length of sqls and delayedResult: %d != %d", len(conn.sqls), len(conn.delayedResult)))
 			}
-			if len(conn.sqls) == 1 {
-				res, err := conn.ExecContext(ctx, conn.sqls[0], []driver.NamedValue{})
-				if err != nil {
-					return fmt.Errorf("commit error: %w", err)
-				}
-				if conn.delayedResult[0] != nil {
-					conn.delayedResult[0].Result = res
-				}
-				return nil
+			event := txEvent()
+			if err := hooks.BeforeCommit(ctx, conn.cfg.Hooks, event); err != nil {
+				event.Err = err
+				hooks.AfterRollback(ctx, conn.cfg.Hooks, event)
+				return fmt.Errorf("commit error: %w", err)
 			}
-
-			input := &redshiftdata.BatchExecuteStatementInput{
-				Sqls: append(make([]string, 0, len(conn.sqls)), conn.sqls...),
+			start := time.Now()
+			if len(conn.sqls) > 0 {
+				event.StatementID, event.Err = conn.commitWithRetry(ctx)
 			}
-			_, desc, err := conn.BatchExecuteStatement(ctx, input)
-			if err != nil {
-				return err
+			event.Duration = time.Since(start)
+			if event.Err != nil {
+				hooks.AfterRollback(ctx, conn.cfg.Hooks, event)
+				return fmt.Errorf("commit error: %w", event.Err)
 			}
-			for i := range input.Sqls {
-				if i >= len(desc.SubStatements) {
-					return fmt.Errorf("sub statement not found: %d", i)
-				}
-				if conn.delayedResult[i] != nil {
-					conn.delayedResult[i].Result = NewResultWithSubStatementData(desc.SubStatements[i])
-				}
-			}
-			return cleanup()
+			hooks.AfterCommit(ctx, conn.cfg.Hooks, event)
+			return nil
 		},
 	}
 
 	return tx, nil
 }
 
+// commitWithRetry executes the buffered statements of the transaction and returns the statement id of the
+// last attempt. Transactions aborted by a serialization failure are executed again, as many times as
+// configured with SerializationRetries. When ctx has a deadline, it is shared between the attempts left so a
+// slow attempt does not consume the whole budget, see attemptContext, and attempts that ran out of their
+// share are retried as well. Errors of retried transactions are *errors.RetryError.
+func (conn *redshiftDataConn) commitWithRetry(ctx context.Context) (string, error) {
+	backoff := conn.cfg.GetSerializationRetryBackoff()
+	retries := conn.cfg.GetSerializationRetries()
+	for attempt := 0; ; attempt++ {
+		attemptCtx, cancel := attemptContext(ctx, retries-attempt+1, conn.cfg.GetRetryMinAttemptTimeout())
+		if attempt > 0 {
+			// A retried commit is a new transaction, it must not be matched with the one that failed.
+			attemptCtx = WithClientToken(attemptCtx, "")
+		}
+		id, err := conn.commit(attemptCtx)
+		cancel()
+		if err == nil {
+			return id, nil
+		}
+		timedOut := stderrors.Is(err, context.DeadlineExceeded) && ctx.Err() == nil
+		if attempt >= retries || !errors.IsSerializationFailure(err) && !timedOut {
+			return id, retryError(attempt+1, err)
+		}
+		select {
+		case <-ctx.Done():
+			return id, retryError(attempt+1, err)
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+// attemptContext returns the context of an attempt when attempts are left, including it. A deadline of ctx is
+// split evenly between them, each attempt getting at least minTimeout, the last one gets what is left.
+func attemptContext(ctx context.Context, attempts int, minTimeout time.Duration) (context.Context, context.CancelFunc) {
+	deadline, ok := ctx.Deadline()
+	if !ok || attempts <= 1 {
+		return ctx, func() {}
+	}
+	left := time.Until(deadline)
+	share := max(left/time.Duration(attempts), minTimeout)
+	if share >= left {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, share)
+}
+
+// retryError reports the number of attempts in the error of an operation attempted more than once.
+func retryError(attempts int, err error) error {
+	if attempts <= 1 {
+		return err
+	}
+	return errors.NewRetryError(attempts, err)
+}
+
+// commit executes the buffered statements, a single one as is and several ones as a batch, which
+// the Data API runs as a transaction, split when they exceed the limits of a batch.
+// It returns the statement id, if the statements were submitted.
+func (conn *redshiftDataConn) commit(ctx context.Context) (string, error) {
+	if len(conn.sqls) == 1 {
+		_, output, err := conn.runStatement(ctx, hooks.KindExec, conn.sqls[0], nil)
+		if err != nil {
+			var statementErr *errors.StatementError
+			if stderrors.As(err, &statementErr) {
+				return statementErr.StatementID, err
+			}
+			return "", err
+		}
+		if conn.delayedResult[0] != nil {
+			conn.delayedResult[0].Result = newResult(output)
+		}
+		return utils.Coalesce(output.Id), nil
+	}
+
+	if batches := splitBatches(conn.sqls); len(batches) > 1 {
+		return conn.commitSplit(ctx, batches)
+	}
+	input := &redshiftdata.BatchExecuteStatementInput{
+		Sqls: append(make([]string, 0, len(conn.sqls)), conn.sqls...),
+	}
+	batch, desc, err := conn.BatchExecuteStatement(ctx, input)
+	id := ""
+	if batch != nil {
+		id = utils.Coalesce(batch.Id)
+	}
+	if err != nil {
+		return id, err
+	}
+	for i := range input.Sqls {
+		if conn.delayedResult[i] != nil {
+			conn.delayedResult[i].Result = NewResultWithSubStatementData(desc.SubStatements[i])
+		}
+	}
+	return id, nil
+}
+
 func (conn *redshiftDataConn) Begin() (driver.Tx, error) {
 	return conn.BeginTx(context.Background(), driver.TxOptions{})
 }
 
 func (conn *redshiftDataConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
-	if conn.inTx {
+	if conn.inTx && !conn.sessionTx {
 		return nil, errors.ErrInTx
 	}
+	if conn.sessionTx {
+		conn.sqls = append(conn.sqls, query)
+	}
+	if err := conn.flushBatch(ctx); err != nil {
+		return nil, err
+	}
+	if rows, ok, err := conn.resumeRows(ctx); ok {
+		if err != nil {
+			return nil, err
+		}
+		return rows, nil
+	}
 
-	params := &redshiftdata.ExecuteStatementInput{
-		Sql:      utils.Nullif(rewriteQuery(query, len(args))),
-		Parameters: convertArgsToParameters(args),
+	if stmts := conn.multiStatements(query); stmts != nil {
+		return conn.queryMulti(ctx, stmts, args)
 	}
+	if conn.cfg.Dialect == dialect.Postgres {
+		return conn.queryTranslated(ctx, query, args)
+	}
+	if ttl := resultCacheTTL(ctx, conn.cfg); ttl > 0 {
+		return conn.cachedQuery(ctx, query, args, ttl)
+	}
+	return conn.uncachedQuery(ctx, query, args)
+}
 
-	p,output,err := conn.ExecuteStatement(ctx, params)
+// newRows returns the rows of a statement, applying the decoding settings of the connection.
+// Column metadata is resolved with the table metadata cache when it is enabled.
+func (conn *redshiftDataConn) newRows(ctx context.Context, id string, p *redshiftdata.GetStatementResultPaginator) (*redshiftDataRows, error) {
+	rows, err := newRows(ctx, id, p)
 	if err != nil {
 		return nil, err
 	}
-	rows := newRows(utils.Coalesce(output.ID),p)
-	return rows,nil
+	if conn.tables != nil {
+		conn.tables.resolveColumns(conn.withDatabase(ctx), conn.client, conn.cfg, rows.columns)
+	}
+	rows.hooks = conn.cfg.Hooks
+	rows.conn = conn.info
+	rows.decode = decodeOptions(ctx, conn.cfg)
+	overrideColumnTypes(rows.columns, rows.decode.ColumnTypes)
+	rows.converters = columnConverters(rows.columns)
+	if rows.decode.ParseTime {
+		rows.loc = rows.decode.TimeLocation()
+	}
+	if conn.tables != nil && conn.cfg.ColumnComments {
+		rows.comments = func(schema, table string) map[string]string {
+			return conn.tables.columnComments(ctx, conn, schema, table)
+		}
+	}
+	if rows.page != nil && rows.decode.Workers > 1 {
+		rows.decodePage()
+	}
+	buffer, decodeAhead := conn.cfg.PrefetchPages, conn.cfg.Features.Enabled(cfg.FeatureParallelFetch)
+	if decodeAhead && buffer <= 0 {
+		buffer = cfg.DefaultPrefetchPages
+	}
+	rows.startPrefetch(buffer, decodeAhead)
+	return rows, nil
+}
 
+// queryTranslated runs the statements the query is translated into and returns the rows of the one marked as Returns.
+func (conn *redshiftDataConn) queryTranslated(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	stmts, err := dialect.Translate(query)
+	if err != nil {
+		return nil, err
+	}
+	var rows driver.Rows
+	for i, stmt := range stmts {
+		kind := hooks.KindExec
+		if stmt.Returns || len(stmts) == 1 {
+			kind = hooks.KindQuery
+		}
+		p, output, err := conn.runStatement(translatedContext(ctx, i, len(stmts)), kind, stmt.SQL, statementArgs(stmt, args))
+		if err != nil {
+			return nil, err
+		}
+		if kind == hooks.KindQuery {
+			if rows, err = conn.queryRows(ctx, output, p); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if rows == nil {
+		return conn.newRows(ctx, "", nil)
+	}
+	return rows, nil
+}
+
+// translatedContext returns the context of the i-th of the n statements a query is translated into, carrying a
+// token derived from the one of the query when there are several, see WithClientToken.
+func translatedContext(ctx context.Context, i, n int) context.Context {
+	if n == 1 {
+		return ctx
+	}
+	return withStatementToken(ctx, i)
+}
+
+// statementArgs picks the arguments referenced by a translated statement.
+func statementArgs(stmt dialect.Statement, args []driver.NamedValue) []driver.NamedValue {
+	var picked []driver.NamedValue
+	for _, arg := range args {
+		if arg.Name != "" {
+			if strings.Contains(stmt.SQL, ":"+arg.Name) {
+				picked = append(picked, arg)
+			}
+			continue
+		}
+		if slices.Contains(stmt.Args, arg.Ordinal) {
+			picked = append(picked, arg)
+		}
+	}
+	return picked
 }
 
 func (conn *redshiftDataConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
-	if conn.inTx {
+	if conn.sessionTx {
+		conn.sqls = append(conn.sqls, query)
+	} else if conn.inTx {
 		if len(args) > 0 {
-			return nil, fmt.Errorf("exec with args in transaction: %w", errors.ErrNotSupported)
+			return nil, errors.NewUnsupportedError("arguments in transactions", errors.LimitationPlatform, "BatchExecuteStatement does not accept parameters")
 		}
 		if conn.txOpts.ReadOnly {
-			return nil, fmt.Errorf("exec in read only transaction: %w", errors.ErrNotSupported)
+			return nil, errors.NewUnsupportedError("exec in read only transaction", errors.LimitationDriver, "")
+		}
+		if conn.cfg.Dialect == dialect.Postgres {
+			stmts, err := dialect.Translate(query)
+			if err != nil {
+				return nil, err
+			}
+			var queries []string
+			for _, stmt := range stmts {
+				if !stmt.Returns {
+					queries = append(queries, stmt.SQL)
+				}
+			}
+			for _, q := range queries[:len(queries)-1] {
+				conn.sqls = append(conn.sqls, q)
+				conn.delayedResult = append(conn.delayedResult, nil)
+			}
+			query = queries[len(queries)-1]
+		}
+		if stmts := conn.multiStatements(query); stmts != nil {
+			// the statements are batched with the others of the transaction, the result is the one of the last
+			for _, stmt := range stmts[:len(stmts)-1] {
+				conn.sqls = append(conn.sqls, stmt)
+				conn.delayedResult = append(conn.delayedResult, nil)
+			}
+			query = stmts[len(stmts)-1]
 		}
 		conn.sqls = append(conn.sqls, query)
 		result := &redshiftDataDelayedResult{}
 		conn.delayedResult = append(conn.delayedResult, result)
 		// debugLogger.Printf("delayedResult[%d] creaed for %q", len(conn.delayedResult)-1, query)
-		return &redshiftDataDelayedResult{}, nil
+		return result, nil
 	}
 
-	params := &redshiftdata.ExecuteStatementInput{
-		Sql:        utils.Nullif(rewriteQuery(query, len(args))),
-		Parameters: convertArgsToParameters(args),
+	if stmts := conn.multiStatements(query); stmts != nil {
+		if err := conn.flushBatch(ctx); err != nil {
+			return nil, err
+		}
+		return conn.execMulti(ctx, stmts, args)
 	}
-
-	_, output, err := conn.executeStatement(ctx, params)
+	if conn.cfg.ExecBatchWindow > 0 && len(args) == 0 && conn.cfg.Dialect != dialect.Postgres && !conn.inTx {
+		return conn.batchExec(ctx, query)
+	}
+	if err := conn.flushBatch(ctx); err != nil {
+		return nil, err
+	}
+	if conn.cfg.Dialect == dialect.Postgres {
+		return conn.execTranslated(ctx, query, args)
+	}
+	h, err := conn.execute(ctx, hooks.KindExec, query, args)
 	if err != nil {
 		return nil, err
 	}
-	return newResult(output),nil
-	
+	return h.result(), nil
 }
 
-func rewriteQuery(query string, paramsCount int) string {
-	if paramsCount == 0 {
-		return query
+// execTranslated runs the statements the query is translated into, skipping the follow-up SELECTs of RETURNING clauses.
+func (conn *redshiftDataConn) execTranslated(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	stmts, err := dialect.Translate(query)
+	if err != nil {
+		return nil, err
 	}
-	runes := make([]rune, 0, len(query))
-	stack := make([]rune, 0)
-	var exclamationCount int
-	for _, r := range query {
-		if len(stack) > 0 {
-			if r == stack[len(stack)-1] {
-				stack = stack[:len(stack)-1]
-				runes = append(runes, r)
-				continue
-			}
-		} else {
-			switch r {
-			case '?':
-				exclamationCount++
-				runes = append(runes, []rune(fmt.Sprintf(":%d", exclamationCount))...)
-				continue
-			case '$':
-				runes = append(runes, ':')
-				continue
-			}
+	var result driver.Result
+	for i, stmt := range stmts {
+		if stmt.Returns {
+			continue
 		}
-		switch r {
-		case '"', '\'':
-			stack = append(stack, r)
+		_, output, err := conn.runStatement(translatedContext(ctx, i, len(stmts)), hooks.KindExec, stmt.SQL, statementArgs(stmt, args))
+		if err != nil {
+			return nil, err
 		}
-		runes = append(runes, r)
+		result = newResult(output)
+	}
+	return result, nil
+}
+
+// runStatement rewrites the query and its arguments into an ExecuteStatementInput and executes it,
+// invoking the configured hooks around the execution.
+func (conn *redshiftDataConn) runStatement(ctx context.Context, kind hooks.Kind, query string, args []driver.NamedValue) (*redshiftdata.GetStatementResultPaginator, *redshiftdata.DescribeStatementOutput, error) {
+	correlation := hooks.Correlate(ctx, conn.cfg.Hooks)
+	event := &hooks.StatementEvent{
+		Kind:        kind,
+		Query:       query,
+		Args:        args,
+		Start:       time.Now(),
+		Conn:        conn.info,
+		Correlation: correlation,
+	}
+	rewritten, bound, err := bindQuery(conn.queryPlan(query, len(args) > 0), args, conn.cfg)
+	if err != nil {
+		return nil, nil, correlateError(err, correlation)
+	}
+	rewritten, event.Label = labelQuery(ctx, conn.cfg, rewritten)
+	parameters, err := convertArgsToParameters(bound, conn.cfg)
+	if err != nil {
+		return nil, nil, correlateError(err, correlation)
 	}
-	return string(runes)
+	conn.statements.Add(1)
+	conn.lastUsed.Store(event.Start.UnixNano())
+	ctx = hooks.BeforeStatement(ctx, conn.cfg.Hooks, event)
+
+	params := &redshiftdata.ExecuteStatementInput{
+		Sql:        utils.Nullif(rewritten),
+		Parameters: parameters,
+	}
+	p, output, err := conn.executeStatement(ctx, params)
+	err = correlateError(err, correlation)
+
+	event.Duration = time.Since(event.Start)
+	event.Err = err
+	event.ClientToken = aws.ToString(params.ClientToken)
+	if output != nil {
+		event.StatementID = utils.Coalesce(output.Id)
+		event.HasResultSet = aws.ToBool(output.HasResultSet)
+		event.ResultRows = output.ResultRows
+		event.QueueDuration, event.ExecDuration, _ = warehouseWait(output)
+		conn.reportWarehouseWait(ctx, event, output)
+	}
+	hooks.AfterStatement(ctx, conn.cfg.Hooks, event)
+	return p, output, err
 }
 
-func convertArgsToParameters(args []driver.NamedValue) []awstypes.SqlParameter {
+// correlateError annotates err with the correlation ids of the statement, if there are any.
+func correlateError(err error, c hooks.Correlation) error {
+	if err == nil || c.IsZero() {
+		return err
+	}
+	return &errors.CorrelatedError{
+		Err:           err,
+		CorrelationID: c.ID,
+		TraceID:       c.TraceID,
+		SpanID:        c.SpanID,
+	}
+}
+
+func convertArgsToParameters(args []driver.NamedValue, config *cfg.RedshiftDataConfig) ([]awstypes.SqlParameter, error) {
 	if len(args) == 0 {
-		return nil
+		return nil, nil
 	}
 	params := make([]awstypes.SqlParameter, 0, len(args))
 	for _, arg := range args {
+		name := utils.Coalesce(utils.Nullif(arg.Name), aws.String(fmt.Sprintf("%d", arg.Ordinal)))
+		value, encoded, err := encodeParameter(config.ParameterEncoder, arg.Value)
+		if err != nil {
+			return nil, fmt.Errorf("argument %s: %w", name, err)
+		}
+		if !encoded {
+			if value, err = formatParameter(arg.Value, config); err != nil {
+				return nil, fmt.Errorf("argument %s: %w", name, err)
+			}
+		}
 		params = append(params, awstypes.SqlParameter{
-			Name:  aws.String(utils.Coalesce(utils.Nullif(arg.Name), aws.String(fmt.Sprintf("%d", arg.Ordinal)))),
-			Value: aws.String(fmt.Sprintf("%v", arg.Value)),
+			Name:  aws.String(name),
+			Value: aws.String(value),
 		})
 	}
-	return params
+	return params, nil
+}
+
+// formatParameter formats a driver.Value as the string value of a Data API parameter, in the syntax Redshift
+// parses for the type of the value. []byte values are hex-encoded, see bindTypedPlaceholders.
+func formatParameter(v any, config *cfg.RedshiftDataConfig) (string, error) {
+	switch v := v.(type) {
+	case nil:
+		// nil arguments are bound as NULL literals by bindTypedPlaceholders
+		return "", fmt.Errorf("null parameters are not supported by the Data API")
+	case string:
+		return v, nil
+	case []byte:
+		return hex.EncodeToString(v), nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case int64:
+		return strconv.FormatInt(v, 10), nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	case float32:
+		return strconv.FormatFloat(float64(v), 'f', -1, 32), nil
+	case time.Time:
+		return formatTimestamp(v, config.TimestampPrecision)
+	case listValue:
+		// lists are expanded into a parameter per element by expandListPlaceholders
+		return "", fmt.Errorf("list parameters are not supported with placeholders=%s", cfg.PlaceholdersNone)
+	}
+	return fmt.Sprintf("%v", v), nil
+}
+
+// encodeParameter encodes v with the ParameterEncoder of the config, if any, ok is false when v is left to
+// the default encoding.
+func encodeParameter(e cfg.ParameterEncoder, v any) (value string, ok bool, err error) {
+	if e == nil {
+		return "", false, nil
+	}
+	return e.EncodeParameter(v)
+}
+
+// CheckNamedValue validates and converts the arguments of a statement before it is executed, see checkNamedValue.
+func (conn *redshiftDataConn) CheckNamedValue(nv *driver.NamedValue) error {
+	return checkNamedValue(nv, conn.cfg.ParameterEncoder)
+}
+
+// checkNamedValue accepts the arguments the ParameterEncoder encodes as they are. The others are resolved with
+// driver.Valuer, whose result may be encoded too, then converted into a driver.Value, so unsupported types are
+// rejected before anything is sent. Slices are converted element by element, see expandListPlaceholders. Output parameters are rejected, the Data API has none, and names of
+// sql.Named arguments must be valid parameter names.
+func checkNamedValue(nv *driver.NamedValue, encoder cfg.ParameterEncoder) error {
+	if _, ok := nv.Value.(sql.Out); ok {
+		return errors.NewUnsupportedError("output parameters (sql.Out)", errors.LimitationPlatform, "return values with a SELECT instead")
+	}
+	name := strconv.Itoa(nv.Ordinal)
+	if nv.Name != "" {
+		name = nv.Name
+		if !isParameterName(nv.Name) {
+			return fmt.Errorf("argument %s: invalid parameter name, names are made of letters, digits and underscores and do not start with a digit", nv.Name)
+		}
+	}
+	if _, ok, err := encodeParameter(encoder, nv.Value); err != nil {
+		return fmt.Errorf("argument %s: %w", name, err)
+	} else if ok {
+		return nil
+	}
+	if valuer, ok := nv.Value.(driver.Valuer); ok {
+		v, err := callValuer(valuer)
+		if err != nil {
+			return fmt.Errorf("argument %s: %w", name, err)
+		}
+		nv.Value = v
+		if _, ok, err := encodeParameter(encoder, v); err != nil {
+			return fmt.Errorf("argument %s: %w", name, err)
+		} else if ok {
+			return nil
+		}
+	}
+	if list, ok, err := checkListValue(nv.Value, encoder); ok {
+		if err != nil {
+			return fmt.Errorf("argument %s: %w", name, err)
+		}
+		nv.Value = list
+		return nil
+	}
+	v, err := driver.DefaultParameterConverter.ConvertValue(nv.Value)
+	if err != nil {
+		return errors.NewUnsupportedError(fmt.Sprintf("argument %s of type %T", name, nv.Value), errors.LimitationDriver, "implement driver.Valuer or configure a ParameterEncoder")
+	}
+	nv.Value = v
+	return nil
+}
+
+// callValuer returns the value of v, nil for a nil pointer, as database/sql does.
+func callValuer(v driver.Valuer) (driver.Value, error) {
+	if rv := reflect.ValueOf(v); rv.Kind() == reflect.Pointer && rv.IsNil() {
+		return nil, nil
+	}
+	return v.Value()
 }
 
-func(conn *redshiftDataConn) executeStatement(ctx context.Context, params *redshiftdata.ExecuteStatementInput) (*redshiftdata.GetStatementResultPaginator, *redshiftdata.DescribeStatementOutput, error) {
+// isParameterName reports whether name can name a parameter of the Data API.
+func isParameterName(name string) bool {
+	for i, r := range name {
+		if !(r == '_' || unicode.IsLetter(r) || i > 0 && unicode.IsDigit(r)) {
+			return false
+		}
+	}
+	return name != ""
+}
+
+// routeTarget returns the database a statement runs against, as chosen by the Route hooks, and the credential
+// fields to send with it.
+func routeTarget(ctx context.Context, config *cfg.RedshiftDataConfig) (database, dbUser, secretArn *string, err error) {
+	target := &hooks.Target{
+		Database:  utils.Coalesce(config.Database),
+		SecretArn: utils.Coalesce(config.SecretsArn),
+	}
+	if database := contextDatabase(ctx); database != "" {
+		target.Database = database
+	}
+	if err := hooks.Route(ctx, config.Hooks, target); err != nil {
+		return nil, nil, nil, err
+	}
+	dbUser, secretArn, err = config.Credentials(utils.Nullif(target.SecretArn))
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return utils.Nullif(target.Database), dbUser, secretArn, nil
+}
+
+func (conn *redshiftDataConn) executeStatement(ctx context.Context, params *redshiftdata.ExecuteStatementInput) (*redshiftdata.GetStatementResultPaginator, *redshiftdata.DescribeStatementOutput, error) {
 	// debugLogger.Printf("query: %s", utils.Coalesce(params.Sql))
-	params.ClusterIdentifier = conn.cfg.ClusterIdentifier
-	params.Database = conn.cfg.Database
-	params.DbUser = conn.cfg.DBUser
-	params.SecretArn = conn.cfg.SecretsArn
-	params.WorkgroupName = conn.cfg.WorkgroupName
+	var inSession bool
+	params.SessionId, params.SessionKeepAliveSeconds, inSession = conn.sessionParams()
+	if params.StatementName == nil {
+		params.StatementName = contextStatementName(ctx)
+	}
+	if params.ClientToken == nil {
+		params.ClientToken = clientToken(ctx)
+	}
+	if !inSession {
+		database, dbUser, secretArn, err := routeTarget(conn.withDatabase(ctx), conn.cfg)
+		if err != nil {
+			return nil, nil, err
+		}
+		params.ClusterIdentifier = conn.cfg.ClusterIdentifier
+		params.Database = database
+		params.DbUser = dbUser
+		params.SecretArn = secretArn
+		params.WorkgroupName = conn.cfg.WorkgroupName
+	}
 
+	release, err := acquireQuota(ctx, conn.cfg)
+	if err != nil {
+		return nil, nil, err
+	}
 	executeOutput, err := conn.client.ExecuteStatement(ctx, params)
 	if err != nil {
-		return nil, nil, fmt.Errorf("execute statement error: %w", err)
+		release()
+		if inSession && conn.canRestoreSession(err) {
+			if err := conn.restoreSession(ctx); err != nil {
+				return nil, nil, err
+			}
+			// The statement was not submitted to the lost session, in the new one it is a new statement.
+			params.ClientToken = utils.Nullif(newUUID())
+			return conn.executeStatement(ctx, params)
+		}
+		return nil, nil, fmt.Errorf("execute statement error (%s): %w", conn.info.ID, classifyAPIError(err))
 	}
+	defer release()
+	conn.sessionStarted(executeOutput.SessionId)
+	reportSubmitted(ctx, executeOutput.Id)
 	queryStartTime := time.Now()
 	// debugLogger.Printf("[%s] success execute statement: %s", *executeOutput.Id, utils.Coalesce(params.Sql))
+	stopProgress := conn.trackProgress(ctx, utils.Coalesce(params.Sql), executeOutput.Id)
 	describeOutput, err := conn.waitWithCancel(ctx, executeOutput.Id, queryStartTime)
+	stopProgress()
 	if err != nil {
 		return nil, nil, err
 	}
-	if describeOutput.Status == awstypes.StatusStringAborted {
-		return nil, nil, fmt.Errorf("query aborted: %s", *describeOutput.Error)
-	}
-	if describeOutput.Status == awstypes.StatusStringFailed {
-		return nil, nil, fmt.Errorf("query failed: %s", *describeOutput.Error)
-	}
-	if describeOutput.Status != awstypes.StatusStringFinished {
-		return nil, nil, fmt.Errorf("query status is not finished: %s", describeOutput.Status)
+	if err := conn.checkStatus(describeOutput); err != nil {
+		return nil, nil, err
 	}
+	conn.recordSetting(params)
 	// debugLogger.Printf("[%s] success query: elapsed_time=%s", *executeOutput.Id, time.Since(queryStartTime))
-	if !*describeOutput.HasResultSet {
+	if !aws.ToBool(describeOutput.HasResultSet) || discardRows(ctx) {
 		return nil, describeOutput, nil
 	}
 	// debugLogger.Printf("[%s] query has result set: result_rows=%d", *executeOutput.Id, describeOutput.ResultRows)
@@ -272,8 +752,167 @@ func(conn *redshiftDataConn) executeStatement(ctx context.Context, params *redsh
 	return p, describeOutput, nil
 }
 
+// checkStatus returns the error of a statement that did not finish successfully.
+func (conn *redshiftDataConn) checkStatus(desc *redshiftdata.DescribeStatementOutput) error {
+	if desc.Status == awstypes.StatusStringAborted || desc.Status == awstypes.StatusStringFailed {
+		statementErr := errors.NewStatementError(utils.Coalesce(desc.Id), string(desc.Status), utils.Coalesce(desc.Error))
+		statementErr.ConnID = conn.info.ID
+		return statementErr
+	}
+	if desc.Status != awstypes.StatusStringFinished {
+		return fmt.Errorf("query status is not finished: %s", desc.Status)
+	}
+	return nil
+}
 
+// waitWithCancel polls DescribeStatement until the statement reaches a finished status.
+// If the connection is closed first, the statement is cancelled. If the context is done or the timeout elapses
+// first, the statement is cancelled unless the CancelMode of the config is CancelModeLeave, and the error, a
+// *errors.CancelError holding the statement id, wraps context.Canceled or context.DeadlineExceeded.
+func (conn *redshiftDataConn) waitWithCancel(ctx context.Context, id *string, queryStartTime time.Time) (*redshiftdata.DescribeStatementOutput, error) {
+	desc, err := conn.client.DescribeStatement(ctx, &redshiftdata.DescribeStatementInput{
+		Id: id,
+	})
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, conn.giveUpStatement(id, ctx.Err())
+		}
+		return nil, fmt.Errorf("describe statement error: %w", err)
+	}
+	if isFinishedStatus(desc.Status) {
+		return desc, nil
+	}
+	timeoutAfter := statementTimeout(ctx, conn.cfg)
+	polling := pollingStrategy(ctx, conn.cfg)
+	timeout := time.NewTimer(timeoutAfter - time.Since(queryStartTime))
+	defer timeout.Stop()
+	attempt := 1
+	delay := time.NewTimer(polling.Interval(attempt, time.Since(queryStartTime)))
+	defer delay.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, conn.giveUpStatement(id, ctx.Err())
+		case <-conn.aliveCh:
+			if err := conn.cancelStatement(id); err != nil {
+				return nil, err
+			}
+			return nil, errors.ErrConnClosed
+		case <-timeout.C:
+			return nil, conn.giveUpStatement(id, fmt.Errorf("query timeout: %s: %w", timeoutAfter, context.DeadlineExceeded))
+		case <-delay.C:
+		}
+
+		desc, err = conn.client.DescribeStatement(ctx, &redshiftdata.DescribeStatementInput{
+			Id: id,
+		})
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, conn.giveUpStatement(id, ctx.Err())
+			}
+			return nil, fmt.Errorf("describe statement error: %w", err)
+		}
+		if isFinishedStatus(desc.Status) {
+			return desc, nil
+		}
+		attempt++
+		delay.Reset(polling.Interval(attempt, time.Since(queryStartTime)))
+	}
+}
 
+// giveUpStatement stops waiting for the statement because of cause, cancelling it unless the CancelMode of the
+// config leaves it running.
+func (conn *redshiftDataConn) giveUpStatement(id *string, cause error) error {
+	if conn.cfg.GetCancelMode() == cfg.CancelModeLeave {
+		return errors.NewCancelError(aws.ToString(id), false, cause, nil)
+	}
+	if err := conn.cancelStatement(id); err != nil {
+		return errors.NewCancelError(aws.ToString(id), false, cause, err)
+	}
+	return errors.NewCancelError(aws.ToString(id), true, cause, nil)
+}
+
+// cancelStatement cancels the statement with a fresh context, since the caller's context is usually already done.
+func (conn *redshiftDataConn) cancelStatement(id *string) error {
+	_, err := conn.client.CancelStatement(context.Background(), &redshiftdata.CancelStatementInput{
+		Id: id,
+	})
+	if err != nil {
+		return fmt.Errorf("cancel statement error: %w", err)
+	}
+	// debugLogger.Printf("[%s] success cancel statement", *id)
+	return nil
+}
+
+func isFinishedStatus(status awstypes.StatusString) bool {
+	return status == awstypes.StatusStringFinished || status == awstypes.StatusStringFailed || status == awstypes.StatusStringAborted
+}
+
+// BatchExecuteStatement submits the statements of the input as a single transaction and waits for it to finish.
+// Once the batch was submitted its output is returned, even when it failed.
 func (conn *redshiftDataConn) BatchExecuteStatement(ctx context.Context, input *redshiftdata.BatchExecuteStatementInput) (*redshiftdata.BatchExecuteStatementOutput, *redshiftdata.DescribeStatementOutput, error) {
+	var inSession bool
+	input.SessionId, input.SessionKeepAliveSeconds, inSession = conn.sessionParams()
+	if input.StatementName == nil {
+		input.StatementName = contextStatementName(ctx)
+	}
+	if input.ClientToken == nil {
+		input.ClientToken = clientToken(ctx)
+	}
+	if !inSession {
+		database, dbUser, secretArn, err := routeTarget(conn.withDatabase(ctx), conn.cfg)
+		if err != nil {
+			return nil, nil, err
+		}
+		input.ClusterIdentifier = conn.cfg.ClusterIdentifier
+		input.Database = database
+		input.DbUser = dbUser
+		input.SecretArn = secretArn
+		input.WorkgroupName = conn.cfg.WorkgroupName
+	}
+
+	release, err := acquireQuota(ctx, conn.cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer release()
+	batchOutput, err := conn.client.BatchExecuteStatement(ctx, input)
+	if err != nil {
+		return nil, nil, fmt.Errorf("batch execute statement error (%s): %w", conn.info.ID, classifyAPIError(err))
+	}
+	conn.sessionStarted(batchOutput.SessionId)
+	describeOutput, err := conn.waitWithCancel(ctx, batchOutput.Id, time.Now())
+	if err != nil {
+		return batchOutput, nil, err
+	}
+	if err := conn.checkBatchStatus(describeOutput, len(input.Sqls)); err != nil {
+		return batchOutput, nil, err
+	}
+	return batchOutput, describeOutput, nil
+}
 
+// checkBatchStatus returns the error of a batch that did not finish successfully, pointing at the statement that
+// failed, and verifies that every statement of the batch is reported.
+func (conn *redshiftDataConn) checkBatchStatus(desc *redshiftdata.DescribeStatementOutput, statements int) error {
+	if err := conn.checkStatus(desc); err != nil {
+		var statementErr *errors.StatementError
+		if !stderrors.As(err, &statementErr) {
+			return err
+		}
+		for i, sub := range desc.SubStatements {
+			if sub.Status == awstypes.StatementStatusStringFailed || sub.Status == awstypes.StatementStatusStringAborted {
+				statementErr.SubStatement = i + 1
+				if statementErr.Message == "" {
+					statementErr.Message = utils.Coalesce(sub.Error)
+					statementErr.Code = errors.SQLState(statementErr.Message)
+				}
+				break
+			}
+		}
+		return statementErr
+	}
+	if len(desc.SubStatements) < statements {
+		return fmt.Errorf("sub statement not found: %d", len(desc.SubStatements))
+	}
+	return nil
 }
@@ -0,0 +1,125 @@
+package metasql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/redshiftdata"
+	awstypes "github.com/aws/aws-sdk-go-v2/service/redshiftdata/types"
+
+	cfg "github.com/adarsh-jaiss/metasql/config"
+)
+
+// describeClient is a RedshiftDataClient whose statements finish at once, described by describe, with the
+// result of result when it is set.
+type describeClient struct {
+	describe func(id string) *redshiftdata.DescribeStatementOutput
+	result   *redshiftdata.GetStatementResultOutput
+}
+
+func (c *describeClient) ExecuteStatement(context.Context, *redshiftdata.ExecuteStatementInput, ...func(*redshiftdata.Options)) (*redshiftdata.ExecuteStatementOutput, error) {
+	return &redshiftdata.ExecuteStatementOutput{Id: aws.String("statement-1")}, nil
+}
+
+func (c *describeClient) DescribeStatement(_ context.Context, params *redshiftdata.DescribeStatementInput, _ ...func(*redshiftdata.Options)) (*redshiftdata.DescribeStatementOutput, error) {
+	return c.describe(aws.ToString(params.Id)), nil
+}
+
+func (c *describeClient) CancelStatement(context.Context, *redshiftdata.CancelStatementInput, ...func(*redshiftdata.Options)) (*redshiftdata.CancelStatementOutput, error) {
+	return &redshiftdata.CancelStatementOutput{Status: aws.Bool(true)}, nil
+}
+
+func (c *describeClient) BatchExecuteStatement(context.Context, *redshiftdata.BatchExecuteStatementInput, ...func(*redshiftdata.Options)) (*redshiftdata.BatchExecuteStatementOutput, error) {
+	return nil, errors.New("unexpected BatchExecuteStatement")
+}
+
+func (c *describeClient) GetStatementResult(context.Context, *redshiftdata.GetStatementResultInput, ...func(*redshiftdata.Options)) (*redshiftdata.GetStatementResultOutput, error) {
+	if c.result == nil {
+		return nil, errors.New("unexpected GetStatementResult")
+	}
+	return c.result, nil
+}
+
+func openDescribeDB(t *testing.T, describe func(id string) *redshiftdata.DescribeStatementOutput) *sql.DB {
+	return openClientDB(t, "workgroup(wg)/db", &describeClient{describe: describe})
+}
+
+func openClientDB(t *testing.T, dsn string, client RedshiftDataClient) *sql.DB {
+	t.Helper()
+	config, err := cfg.ParseDSN(dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := sql.OpenDB(NewConnectorWithClient(client, config))
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestNilHasResultSet(t *testing.T) {
+	// DDL, and mocks of the Data API, describe statements without HasResultSet
+	db := openDescribeDB(t, func(id string) *redshiftdata.DescribeStatementOutput {
+		return &redshiftdata.DescribeStatementOutput{Id: aws.String(id), Status: awstypes.StatusStringFinished, ResultRows: 3}
+	})
+	res, err := db.Exec("CREATE TABLE t (a int)")
+	if err != nil {
+		t.Fatalf("Exec: %v", err)
+	}
+	if n, err := res.RowsAffected(); err != nil || n != 3 {
+		t.Errorf("RowsAffected = %d, %v, want 3", n, err)
+	}
+	rows, err := db.Query("CREATE TABLE t (a int)")
+	if err != nil {
+		t.Fatalf("Query: %v", err)
+	}
+	defer rows.Close()
+	if rows.Next() {
+		t.Error("Next = true, want no rows")
+	}
+	if err := rows.Err(); err != nil {
+		t.Errorf("Err: %v", err)
+	}
+}
+
+func TestFormatParameterFloat(t *testing.T) {
+	tests := []struct {
+		v    any
+		want string
+	}{
+		{1e6, "1000000"},
+		{1234567.0, "1234567"},
+		{1e21, "1000000000000000000000"},
+		{-0.5, "-0.5"},
+		{1.5e-7, "0.00000015"},
+		{float32(0.1), "0.1"},
+		{float32(1e6), "1000000"},
+	}
+	for _, tt := range tests {
+		got, err := formatParameter(tt.v, &cfg.RedshiftDataConfig{})
+		if err != nil || got != tt.want {
+			t.Errorf("formatParameter(%v) = %q, %v, want %q", tt.v, got, err, tt.want)
+		}
+	}
+}
+
+func TestByteaStrictTypes(t *testing.T) {
+	client := &describeClient{
+		describe: func(id string) *redshiftdata.DescribeStatementOutput {
+			return &redshiftdata.DescribeStatementOutput{Id: aws.String(id), Status: awstypes.StatusStringFinished, HasResultSet: aws.Bool(true)}
+		},
+		result: &redshiftdata.GetStatementResultOutput{
+			ColumnMetadata: []awstypes.ColumnMetadata{{Name: aws.String("b"), TypeName: aws.String("bytea")}},
+			Records:        [][]awstypes.Field{{&awstypes.FieldMemberStringValue{Value: `\xdead01`}}},
+		},
+	}
+	db := openClientDB(t, "workgroup(wg)/db?strict_types=true", client)
+	var b []byte
+	if err := db.QueryRow("SELECT b FROM t").Scan(&b); err != nil {
+		t.Fatalf("Scan: %v", err)
+	}
+	if string(b) != "\xde\xad\x01" {
+		t.Errorf("b = %x, want dead01", b)
+	}
+}
@@ -3,21 +3,89 @@ package metasql
 import (
 	"context"
 	"database/sql/driver"
+	"sort"
+	"sync"
+	"time"
 
 	"github.com/adarsh-jaiss/metasql/config"
 )
 
 type redshiftDataConnector struct {
-	d   *redshiftDataDriver
-	cfg *config.RedshiftDataConfig
+	d      *redshiftDataDriver
+	cfg    *config.RedshiftDataConfig
+	client RedshiftDataClient  // client is shared by the connections of the connector, NewRedshiftDataClient creates one per connection when nil.
+	tables *tableMetadataCache // tables is shared by the connections of the connector.
+	caps   *capabilitiesCache  // caps holds the capabilities of the target, shared by the connections of the connector.
+	conns  sync.Map            // conns holds the open connections by id.
+}
+
+// NewConnector returns a driver.Connector for the given RedshiftDataConfig, to be used with sql.OpenDB.
+func NewConnector(cfg *config.RedshiftDataConfig) *redshiftDataConnector {
+	return &redshiftDataConnector{
+		d:      &redshiftDataDriver{},
+		cfg:    cfg,
+		tables: newTableMetadataCache(cfg.DescribeTableCacheTTL),
+		caps:   &capabilitiesCache{},
+	}
+}
+
+// NewConnectorWithClient returns a driver.Connector whose connections all use the provided RedshiftDataClient,
+// such as a fake client in tests.
+func NewConnectorWithClient(client RedshiftDataClient, cfg *config.RedshiftDataConfig) *redshiftDataConnector {
+	c := NewConnector(cfg)
+	c.client = client
+	return c
 }
 
 func (c *redshiftDataConnector) Connect(ctx context.Context) (driver.Conn, error) {
-	client, err := NewRedshiftDataClient(ctx, c.cfg)
-	if err != nil {
-		return nil, err
+	client := c.client
+	if client == nil {
+		var err error
+		if client, err = NewRedshiftDataClient(ctx, c.cfg); err != nil {
+			return nil, err
+		}
 	}
-	return NewConnection(client, c.cfg), nil
+	conn := NewConnection(client, c.cfg)
+	conn.tables = c.tables
+	conn.caps = c.caps
+	c.conns.Store(conn.info.ID, conn)
+	conn.onClose = func() { c.conns.Delete(conn.info.ID) }
+	return conn, nil
+}
+
+// ConnStats describes an open connection of a connector.
+type ConnStats struct {
+	ID         string    // ID is the connection id, as reported to hooks and in errors.
+	CreatedAt  time.Time // CreatedAt is the time the connection was opened.
+	Statements int64     // Statements is the number of statements executed on the connection.
+	LastUsed   time.Time // LastUsed is the time the last statement was submitted, zero when none was.
+}
+
+// Stats returns the open connections of the connector, oldest first.
+func (c *redshiftDataConnector) Stats() []ConnStats {
+	var stats []ConnStats
+	c.conns.Range(func(_, v any) bool {
+		conn := v.(*redshiftDataConn)
+		s := ConnStats{
+			ID:         conn.info.ID,
+			CreatedAt:  conn.info.CreatedAt,
+			Statements: conn.statements.Load(),
+		}
+		if lastUsed := conn.lastUsed.Load(); lastUsed != 0 {
+			s.LastUsed = time.Unix(0, lastUsed)
+		}
+		stats = append(stats, s)
+		return true
+	})
+	sort.Slice(stats, func(i, j int) bool { return stats[i].CreatedAt.Before(stats[j].CreatedAt) })
+	return stats
+}
+
+// WithParameterEncoder sets the encoder rendering arguments of custom types into parameter values of the
+// statements of the connector, see config.ParameterEncoder.
+func (c *redshiftDataConnector) WithParameterEncoder(e config.ParameterEncoder) *redshiftDataConnector {
+	c.cfg.WithParameterEncoder(e)
+	return c
 }
 
 func (c *redshiftDataConnector) Driver() driver.Driver {
@@ -0,0 +1,49 @@
+package metasql
+
+import (
+	"database/sql/driver"
+	"strings"
+	"sync"
+
+	awstypes "github.com/aws/aws-sdk-go-v2/service/redshiftdata/types"
+)
+
+// ConverterFunc converts a Data API field of a column into the value Next returns for it.
+type ConverterFunc func(field awstypes.Field) (driver.Value, error)
+
+var (
+	convertersMu sync.RWMutex
+	converters   = map[string]ConverterFunc{}
+)
+
+// RegisterConverter registers fn to convert the values of the columns of the Redshift type dbType, such as
+// "uuid", "money" or the name of an enum-like domain, replacing the conversion of the driver. Type names are
+// matched case-insensitively against the type names of the column metadata. A nil fn removes the converter.
+//
+// Converters are looked up when a query returns its rows and are not called for NULL values. The values they
+// return are not checked against StrictTypes, and the scan type of their columns is any.
+func RegisterConverter(dbType string, fn ConverterFunc) {
+	convertersMu.Lock()
+	defer convertersMu.Unlock()
+	if fn == nil {
+		delete(converters, strings.ToLower(dbType))
+		return
+	}
+	converters[strings.ToLower(dbType)] = fn
+}
+
+// columnConverters returns the registered converter of each column, nil when none is registered at all.
+func columnConverters(columns []awstypes.ColumnMetadata) []ConverterFunc {
+	convertersMu.RLock()
+	defer convertersMu.RUnlock()
+	if len(converters) == 0 {
+		return nil
+	}
+	fns := make([]ConverterFunc, len(columns))
+	for i, c := range columns {
+		if c.TypeName != nil {
+			fns[i] = converters[strings.ToLower(*c.TypeName)]
+		}
+	}
+	return fns
+}
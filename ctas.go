@@ -0,0 +1,250 @@
+package metasql
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	stderrors "errors"
+	"fmt"
+	"strings"
+
+	"github.com/adarsh-jaiss/metasql/privileges"
+)
+
+// Suffixes of the tables CreateTableAs builds a replacement in and moves the replaced table to.
+const (
+	stagingSuffix  = "__metasql_new"
+	replacedSuffix = "__metasql_old"
+)
+
+// CTASOptions controls how CreateTableAs and CreateTableLike create a table.
+type CTASOptions struct {
+	Attributes string // Attributes are the table attributes of the new table, such as DISTKEY(id) SORTKEY(created_at).
+	Swap       bool   // Swap replaces an existing table of the same name, without it an existing table is an error.
+	CopyGrants bool   // CopyGrants grants the privileges held on the replaced table on the new table.
+	KeepOld    string // KeepOld renames the replaced table to this name, replacing a table of that name, instead of dropping it.
+}
+
+// CreateTableAs creates table, a name optionally qualified by its schema, from the result of query with
+// CREATE TABLE AS. A table that failed to build is dropped, so a failed run leaves nothing behind.
+//
+// With Swap, an existing table is rebuilt: the new table is built next to it, then swapped in with ALTER TABLE
+// RENAME as SwapTables does, so readers see either the old or the new table, never a missing or half built
+// one. The replaced table is dropped unless KeepOld is set, and stays in place when the swap fails.
+// Dropping it fails while views that are not late binding depend on it.
+func CreateTableAs(ctx context.Context, db *sql.DB, table, query string, opts CTASOptions) error {
+	return createTable(ctx, db, table, opts, func(target string) string {
+		return "CREATE TABLE " + target + attributes(opts) + " AS " + query
+	})
+}
+
+// CreateTableLike creates table with the columns, encodings, distribution and sort keys of source, with
+// CREATE TABLE LIKE, and replaces an existing table of the same name as CreateTableAs does. The new table is empty.
+func CreateTableLike(ctx context.Context, db *sql.DB, table, source string, opts CTASOptions) error {
+	like := qualifiedTableName(splitTableName(source))
+	return createTable(ctx, db, table, opts, func(target string) string {
+		return "CREATE TABLE " + target + " (LIKE " + like + " INCLUDING DEFAULTS)" + attributes(opts)
+	})
+}
+
+func attributes(opts CTASOptions) string {
+	if opts.Attributes == "" {
+		return ""
+	}
+	return " " + opts.Attributes
+}
+
+// createTable creates table with the statement returned by create for the quoted name of the table to build,
+// building it next to an existing table and swapping it in with Swap.
+func createTable(ctx context.Context, db *sql.DB, table string, opts CTASOptions, create func(target string) string) error {
+	ctx = withoutClientToken(ctx)
+	schema, name := splitTableName(table)
+	if schema == "" {
+		var err error
+		if schema, err = currentSchema(ctx, db); err != nil {
+			return err
+		}
+	}
+	exists, err := tableExists(ctx, db, schema, name)
+	if err != nil {
+		return err
+	}
+	if exists && !opts.Swap {
+		return fmt.Errorf("table %s already exists", qualifiedTableName(schema, name))
+	}
+
+	build := name
+	if exists {
+		build = name + stagingSuffix
+		if _, err := db.ExecContext(ctx, "DROP TABLE IF EXISTS "+qualifiedTableName(schema, build)); err != nil {
+			return fmt.Errorf("drop staging table: %w", err)
+		}
+	}
+	if _, err := db.ExecContext(ctx, create(qualifiedTableName(schema, build))); err != nil {
+		return dropBuiltTable(ctx, db, schema, build, fmt.Errorf("create table %s: %w", qualifiedTableName(schema, build), err))
+	}
+	if !exists {
+		return nil
+	}
+	if err := swapInTable(ctx, db, schema, build, name, opts); err != nil {
+		return dropBuiltTable(ctx, db, schema, build, err)
+	}
+	return nil
+}
+
+// SwapTables replaces target by staging, both names optionally qualified by their schema, as the last step of
+// a blue/green rebuild: staging is renamed to target and the previous target is dropped, or staging is merely
+// renamed when there is no target yet. The tables must be in the same schema.
+//
+// The swap runs in a transaction within a Data API session, so readers see either the old or the new table. The
+// rows of target are counted before the commit and the transaction is rolled back unless they match the rows of
+// staging, as it is when any statement of the swap fails: both tables are then left as they were.
+func SwapTables(ctx context.Context, db *sql.DB, staging, target string) error {
+	ctx = withoutClientToken(ctx)
+	stagingSchema, stagingName := splitTableName(staging)
+	schema, name := splitTableName(target)
+	if schema == "" || stagingSchema == "" {
+		current, err := currentSchema(ctx, db)
+		if err != nil {
+			return err
+		}
+		schema, stagingSchema = cmp.Or(schema, current), cmp.Or(stagingSchema, current)
+	}
+	if schema != stagingSchema {
+		return fmt.Errorf("swap table %s: staging table %s is in another schema", qualifiedTableName(schema, name), qualifiedTableName(stagingSchema, stagingName))
+	}
+	return swapInTable(ctx, db, schema, stagingName, name, CTASOptions{})
+}
+
+// swapInTable replaces table by build in one session transaction, copying the grants of table first when asked to.
+func swapInTable(ctx context.Context, db *sql.DB, schema, build, table string, opts CTASOptions) error {
+	var grants []string
+	if opts.CopyGrants {
+		current, err := privileges.Current(ctx, db, schema)
+		if err != nil {
+			return err
+		}
+		var copied []privileges.Grant
+		for _, g := range current {
+			if g.Schema == schema && g.Relation == table {
+				copied = append(copied, g)
+			}
+		}
+		grants = privileges.Statements(copied, nil)
+	}
+	replaced := table + replacedSuffix
+	if opts.KeepOld != "" {
+		replaced = opts.KeepOld
+	}
+	swapErr := func(err error) error {
+		return fmt.Errorf("swap table %s: %w", qualifiedTableName(schema, table), err)
+	}
+
+	tx, err := db.BeginTx(withSessionTx(ctx), nil)
+	if err != nil {
+		return swapErr(err)
+	}
+	defer tx.Rollback()
+	staged, err := countRows(ctx, tx, schema, build)
+	if err != nil {
+		return swapErr(err)
+	}
+	exists, err := tableExists(ctx, tx, schema, table)
+	if err != nil {
+		return swapErr(err)
+	}
+	var stmts []string
+	if exists {
+		stmts = append(stmts,
+			"DROP TABLE IF EXISTS "+qualifiedTableName(schema, replaced),
+			"ALTER TABLE "+qualifiedTableName(schema, table)+" RENAME TO "+quoteIdentifier(replaced))
+	}
+	stmts = append(stmts, "ALTER TABLE "+qualifiedTableName(schema, build)+" RENAME TO "+quoteIdentifier(table))
+	stmts = append(stmts, grants...)
+	if exists && opts.KeepOld == "" {
+		stmts = append(stmts, "DROP TABLE "+qualifiedTableName(schema, replaced))
+	}
+	for _, stmt := range stmts {
+		if _, err := tx.ExecContext(ctx, stmt); err != nil {
+			return swapErr(err)
+		}
+	}
+	swapped, err := countRows(ctx, tx, schema, table)
+	if err != nil {
+		return swapErr(err)
+	}
+	if swapped != staged {
+		return swapErr(fmt.Errorf("%d rows after the swap, %s had %d", swapped, qualifiedTableName(schema, build), staged))
+	}
+	if err := tx.Commit(); err != nil {
+		return swapErr(err)
+	}
+	return nil
+}
+
+// queryRower is a *sql.DB, *sql.Conn or *sql.Tx.
+type queryRower interface {
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// countRows returns the number of rows of the table.
+func countRows(ctx context.Context, q queryRower, schema, table string) (int64, error) {
+	var n int64
+	if err := q.QueryRowContext(ctx, "SELECT COUNT(*) FROM "+qualifiedTableName(schema, table)).Scan(&n); err != nil {
+		return 0, fmt.Errorf("count rows of %s: %w", qualifiedTableName(schema, table), err)
+	}
+	return n, nil
+}
+
+// currentSchema returns the first schema of the search path, where unqualified tables are created.
+func currentSchema(ctx context.Context, q queryRower) (string, error) {
+	var schema string
+	if err := q.QueryRowContext(ctx, "SELECT current_schema()").Scan(&schema); err != nil {
+		return "", fmt.Errorf("resolve current schema: %w", err)
+	}
+	return schema, nil
+}
+
+// dropBuiltTable drops the table that failed to build or to be swapped in, and returns err with the error of the
+// drop, if any.
+func dropBuiltTable(ctx context.Context, db *sql.DB, schema, build string, err error) error {
+	if _, dropErr := db.ExecContext(context.WithoutCancel(ctx), "DROP TABLE IF EXISTS "+qualifiedTableName(schema, build)); dropErr != nil {
+		return stderrors.Join(err, fmt.Errorf("drop table %s: %w", qualifiedTableName(schema, build), dropErr))
+	}
+	return err
+}
+
+// tableExists reports whether the schema holds a table named table.
+func tableExists(ctx context.Context, q queryRower, schema, table string) (bool, error) {
+	var n int64
+	err := q.QueryRowContext(ctx, `SELECT COUNT(*) FROM information_schema.tables WHERE table_schema = ? AND table_name = ?`, schema, table).Scan(&n)
+	if err != nil {
+		return false, fmt.Errorf("look up table %s: %w", qualifiedTableName(schema, table), err)
+	}
+	return n > 0, nil
+}
+
+// splitTableName splits a table name into its schema, empty when it is not qualified, and its name.
+// Double quotes around either part are removed, unquoted parts are folded to lower case as Redshift does.
+func splitTableName(table string) (schema, name string) {
+	schema, name, ok := strings.Cut(table, ".")
+	if !ok {
+		schema, name = "", table
+	}
+	return unquoteIdentifier(schema), unquoteIdentifier(name)
+}
+
+func unquoteIdentifier(name string) string {
+	if len(name) >= 2 && name[0] == '"' && name[len(name)-1] == '"' {
+		return strings.ReplaceAll(name[1:len(name)-1], `""`, `"`)
+	}
+	return strings.ToLower(name)
+}
+
+// qualifiedTableName quotes the name of a table and of its schema, if any.
+func qualifiedTableName(schema, table string) string {
+	if schema == "" {
+		return quoteIdentifier(table)
+	}
+	return quoteIdentifier(schema) + "." + quoteIdentifier(table)
+}
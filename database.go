@@ -0,0 +1,77 @@
+package metasql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/adarsh-jaiss/metasql/errors"
+	"github.com/aws/aws-sdk-go-v2/service/redshiftdata"
+)
+
+// UseDatabase switches the statements executed on c to database, for tools walking through the databases of
+// a cluster or workgroup on one connection. An empty database switches back to the database of the config.
+// The database is checked with the Data API ListDatabases operation when the client implements it.
+//
+// As with PinSession, the setting stays with the connection when c is closed and returns to the pool of
+// database/sql, switch back before closing c if the pool is shared. WithDatabase still takes precedence for
+// the statements of its context, and Route hooks still see, and may change, the database.
+func UseDatabase(ctx context.Context, c *sql.Conn, database string) error {
+	return rawConn(c, func(conn *redshiftDataConn) error {
+		if conn.inTx {
+			return errors.ErrInTx
+		}
+		if conn.session.pinned {
+			return errors.NewUnsupportedError("switching databases in a session", errors.LimitationPlatform, "a Data API session is bound to the database it was created on, call ReleaseSession first")
+		}
+		if database != "" {
+			if err := conn.checkDatabase(ctx, database); err != nil {
+				return err
+			}
+		}
+		if err := conn.flushBatch(ctx); err != nil {
+			return err
+		}
+		conn.database = database
+		return nil
+	})
+}
+
+// withDatabase returns ctx routing to the database set with UseDatabase, unless ctx already has one.
+func (conn *redshiftDataConn) withDatabase(ctx context.Context) context.Context {
+	if conn.database == "" || contextDatabase(ctx) != "" {
+		return ctx
+	}
+	return WithDatabase(ctx, conn.database)
+}
+
+// checkDatabase verifies with ListDatabases that database exists on the target of the connection.
+func (conn *redshiftDataConn) checkDatabase(ctx context.Context, database string) error {
+	lister, ok := conn.client.(redshiftdata.ListDatabasesAPIClient)
+	if !ok {
+		return nil
+	}
+	current, dbUser, secretArn, err := routeTarget(conn.withDatabase(ctx), conn.cfg)
+	if err != nil {
+		return err
+	}
+	p := redshiftdata.NewListDatabasesPaginator(lister, &redshiftdata.ListDatabasesInput{
+		ClusterIdentifier: conn.cfg.ClusterIdentifier,
+		Database:          current,
+		DbUser:            dbUser,
+		SecretArn:         secretArn,
+		WorkgroupName:     conn.cfg.WorkgroupName,
+	})
+	for p.HasMorePages() {
+		page, err := p.NextPage(ctx, conn.cfg.RedshiftDataOptFns...)
+		if err != nil {
+			return fmt.Errorf("list databases error: %w", classifyAPIError(err))
+		}
+		for _, name := range page.Databases {
+			if name == database {
+				return nil
+			}
+		}
+	}
+	return errors.NewConfigError(errors.ErrTargetUnreachable, fmt.Errorf("database %q does not exist", database))
+}
@@ -0,0 +1,110 @@
+package metasql
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// Decimal is an arbitrary-precision NUMERIC value, Unscaled * 10^-Scale, keeping the scale of the column so
+// 1.50 stays 1.50. The Data API returns NUMERIC values as strings, which are lossless: Decimal avoids going
+// through float64 when computing with them.
+//
+// Scan NUMERIC columns into a Decimal, or a *Decimal when they may be null. With the numeric=decimal DSN
+// parameter, rows return Decimal values themselves. Decimal arguments are sent as their exact text.
+type Decimal struct {
+	Unscaled *big.Int // Unscaled is the value without its decimal point, nil is zero.
+	Scale    int32    // Scale is the number of digits after the decimal point.
+}
+
+// maxDecimalExponent bounds the exponent ParseDecimal accepts, so 1e999999999 is not expanded into a billion
+// digits. It is the largest scale of a Postgres NUMERIC, far beyond the 38 digits of a Redshift one.
+const maxDecimalExponent = 16383
+
+// ParseDecimal parses a decimal number such as -12.345 or 1.5e-3. Exponents are limited to ±16383.
+func ParseDecimal(s string) (Decimal, error) {
+	mantissa, exponent := strings.TrimSpace(s), 0
+	if i := strings.IndexAny(mantissa, "eE"); i >= 0 {
+		var err error
+		if exponent, err = strconv.Atoi(mantissa[i+1:]); err != nil {
+			return Decimal{}, fmt.Errorf("invalid decimal %q", s)
+		}
+		if exponent > maxDecimalExponent || exponent < -maxDecimalExponent {
+			return Decimal{}, fmt.Errorf("invalid decimal %q: exponent out of range", s)
+		}
+		mantissa = mantissa[:i]
+	}
+	scale := 0
+	if i := strings.IndexByte(mantissa, '.'); i >= 0 {
+		scale = len(mantissa) - i - 1
+		mantissa = mantissa[:i] + mantissa[i+1:]
+	}
+	unscaled, ok := new(big.Int).SetString(mantissa, 10)
+	if !ok {
+		return Decimal{}, fmt.Errorf("invalid decimal %q", s)
+	}
+	scale -= exponent
+	if scale < 0 {
+		unscaled.Mul(unscaled, new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(-scale)), nil))
+		scale = 0
+	}
+	return Decimal{Unscaled: unscaled, Scale: int32(scale)}, nil
+}
+
+// String formats the decimal with Scale digits after the decimal point.
+func (d Decimal) String() string {
+	digits := "0"
+	if d.Unscaled != nil {
+		digits = new(big.Int).Abs(d.Unscaled).String()
+	}
+	sign := ""
+	if d.Unscaled != nil && d.Unscaled.Sign() < 0 {
+		sign = "-"
+	}
+	if d.Scale <= 0 {
+		return sign + digits + strings.Repeat("0", int(-d.Scale))
+	}
+	if pad := int(d.Scale) + 1 - len(digits); pad > 0 {
+		digits = strings.Repeat("0", pad) + digits
+	}
+	return sign + digits[:len(digits)-int(d.Scale)] + "." + digits[len(digits)-int(d.Scale):]
+}
+
+// Rat returns the decimal as a big.Rat.
+func (d Decimal) Rat() *big.Rat {
+	r, _ := new(big.Rat).SetString(d.String())
+	return r
+}
+
+// Scan implements sql.Scanner for NUMERIC columns and exact representations of numbers.
+func (d *Decimal) Scan(src any) error {
+	switch v := src.(type) {
+	case Decimal:
+		*d = v
+		return nil
+	case string:
+		parsed, err := ParseDecimal(v)
+		if err != nil {
+			return err
+		}
+		*d = parsed
+		return nil
+	case []byte:
+		return d.Scan(string(v))
+	case int64:
+		*d = Decimal{Unscaled: big.NewInt(v)}
+		return nil
+	case float64:
+		return d.Scan(strconv.FormatFloat(v, 'f', -1, 64))
+	case nil:
+		return fmt.Errorf("cannot scan NULL into Decimal, scan into a *Decimal")
+	}
+	return fmt.Errorf("cannot scan %T into Decimal", src)
+}
+
+// Value implements driver.Valuer, the decimal is sent as its exact text.
+func (d Decimal) Value() (driver.Value, error) {
+	return d.String(), nil
+}
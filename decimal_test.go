@@ -0,0 +1,122 @@
+package metasql
+
+import (
+	"math/big"
+	"strings"
+	"testing"
+)
+
+func TestParseDecimal(t *testing.T) {
+	tests := []struct {
+		in       string
+		want     string
+		unscaled string
+		scale    int32
+	}{
+		{"-0.05", "-0.05", "-5", 2},
+		{"1.50", "1.50", "150", 2},
+		{"1e3", "1000", "1000", 0},
+		{"1E+2", "100", "100", 0},
+		{"1.5e-3", "0.0015", "15", 4},
+		{"1.50e1", "15.0", "150", 1},
+		{"+1", "1", "1", 0},
+		{".5", "0.5", "5", 1},
+		{"-.5", "-0.5", "-5", 1},
+		{"1.", "1", "1", 0},
+		{"0", "0", "0", 0},
+		{"-0.00", "0.00", "0", 2},
+		{" 7 ", "7", "7", 0},
+		{"123456789012345678901234567890.123456789", "123456789012345678901234567890.123456789", "123456789012345678901234567890123456789", 9},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			d, err := ParseDecimal(tt.in)
+			if err != nil {
+				t.Fatalf("ParseDecimal(%q): %v", tt.in, err)
+			}
+			if got := d.String(); got != tt.want {
+				t.Errorf("String() = %q, want %q", got, tt.want)
+			}
+			if d.Unscaled.String() != tt.unscaled || d.Scale != tt.scale {
+				t.Errorf("Unscaled, Scale = %s, %d, want %s, %d", d.Unscaled, d.Scale, tt.unscaled, tt.scale)
+			}
+			again, err := ParseDecimal(d.String())
+			if err != nil || again.String() != d.String() {
+				t.Errorf("ParseDecimal(%q) = %v, %v, want it to round-trip", d.String(), again, err)
+			}
+		})
+	}
+}
+
+func TestParseDecimalErrors(t *testing.T) {
+	for _, in := range []string{"", ".", "-", "abc", "1e", "1e5x", "--1", "1.2.3", "0x10", "1_000", "1e999999999", "1e-999999999", "1e99999999999999999999"} {
+		if d, err := ParseDecimal(in); err == nil {
+			t.Errorf("ParseDecimal(%q) = %v, want an error", in, d)
+		}
+	}
+	if _, err := ParseDecimal("1e16383"); err != nil {
+		t.Errorf("ParseDecimal at the exponent limit: %v", err)
+	}
+}
+
+func TestDecimalString(t *testing.T) {
+	tests := []struct {
+		d    Decimal
+		want string
+	}{
+		{Decimal{}, "0"},
+		{Decimal{Scale: 2}, "0.00"},
+		{Decimal{Unscaled: big.NewInt(5), Scale: -2}, "500"},
+		{Decimal{Unscaled: big.NewInt(-123), Scale: 5}, "-0.00123"},
+	}
+	for _, tt := range tests {
+		if got := tt.d.String(); got != tt.want {
+			t.Errorf("%#v.String() = %q, want %q", tt.d, got, tt.want)
+		}
+	}
+}
+
+func TestDecimalScan(t *testing.T) {
+	parsed, _ := ParseDecimal("2.50")
+	tests := []struct {
+		src  any
+		want string
+	}{
+		{"-0.05", "-0.05"},
+		{[]byte("1.50"), "1.50"},
+		{int64(-42), "-42"},
+		{0.1, "0.1"},
+		{1e21, "1000000000000000000000"},
+		{parsed, "2.50"},
+	}
+	for _, tt := range tests {
+		var d Decimal
+		if err := d.Scan(tt.src); err != nil {
+			t.Errorf("Scan(%#v): %v", tt.src, err)
+			continue
+		}
+		if got := d.String(); got != tt.want {
+			t.Errorf("Scan(%#v) = %q, want %q", tt.src, got, tt.want)
+		}
+	}
+	for _, src := range []any{nil, true, "x"} {
+		var d Decimal
+		if err := d.Scan(src); err == nil {
+			t.Errorf("Scan(%#v) = %v, want an error", src, d)
+		}
+	}
+	var d Decimal
+	if err := d.Scan(nil); err == nil || !strings.Contains(err.Error(), "*Decimal") {
+		t.Errorf("Scan(nil) = %v, want a hint to scan into a *Decimal", err)
+	}
+}
+
+func TestDecimalValueAndRat(t *testing.T) {
+	d, _ := ParseDecimal("-1.50")
+	if v, err := d.Value(); err != nil || v != "-1.50" {
+		t.Errorf("Value() = %v, %v, want -1.50", v, err)
+	}
+	if got, want := d.Rat(), big.NewRat(-3, 2); got.Cmp(want) != 0 {
+		t.Errorf("Rat() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,70 @@
+package metasql
+
+import (
+	"context"
+	"strings"
+
+	cfg "github.com/adarsh-jaiss/metasql/config"
+	"github.com/adarsh-jaiss/metasql/utils"
+	awstypes "github.com/aws/aws-sdk-go-v2/service/redshiftdata/types"
+)
+
+type decodeOptionsKey struct{}
+
+// WithDecodeOptions returns a context decoding the result sets of the queries executed with it using
+// opts, which replace the DecodeOptions of the config as a whole.
+func WithDecodeOptions(ctx context.Context, opts cfg.DecodeOptions) context.Context {
+	return context.WithValue(ctx, decodeOptionsKey{}, opts)
+}
+
+// decodeOptions returns the DecodeOptions of the context, falling back to the ones of the config.
+func decodeOptions(ctx context.Context, config *cfg.RedshiftDataConfig) cfg.DecodeOptions {
+	if opts, ok := ctx.Value(decodeOptionsKey{}).(cfg.DecodeOptions); ok {
+		return opts
+	}
+	return config.Decode
+}
+
+// overrideColumnTypes replaces the type of the columns declared in the ColumnTypes of the decode options, matched
+// case-insensitively by table.column first, then by column name. Invalid types are ignored.
+func overrideColumnTypes(columns []awstypes.ColumnMetadata, types map[string]string) {
+	if len(types) == 0 {
+		return
+	}
+	overrides := make(map[string]string, len(types))
+	for column, spec := range types {
+		overrides[strings.ToLower(column)] = spec
+	}
+	for i, c := range columns {
+		name := strings.ToLower(utils.Coalesce(c.Name))
+		spec, ok := overrides[strings.ToLower(utils.Coalesce(c.TableName))+"."+name]
+		if !ok {
+			spec, ok = overrides[name]
+		}
+		if !ok {
+			continue
+		}
+		typeName, precision, scale, err := cfg.ParseColumnType(spec)
+		if err != nil {
+			continue
+		}
+		columns[i].TypeName = &typeName
+		if precision > 0 {
+			columns[i].Precision, columns[i].Length, columns[i].Scale = precision, precision, scale
+		}
+	}
+}
+
+type discardRowsKey struct{}
+
+// WithDiscardRows returns a context whose queries run for their side effects only: the result set is never
+// fetched and the rows are empty. The number of rows the query returned is still reported to the hooks.
+func WithDiscardRows(ctx context.Context) context.Context {
+	return context.WithValue(ctx, discardRowsKey{}, true)
+}
+
+// discardRows reports whether the result sets of the context are discarded.
+func discardRows(ctx context.Context) bool {
+	discard, _ := ctx.Value(discardRowsKey{}).(bool)
+	return discard
+}
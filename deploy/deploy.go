@@ -0,0 +1,245 @@
+// Package deploy creates a set of tables and views in dependency order.
+// Dependencies are found by looking for the names of the other objects of the set in each statement,
+// so views built on views of the same set are created after them. Objects that do not depend on each other
+// are created together, in one transaction per batch, which the driver submits as a single BatchExecuteStatement.
+package deploy
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Status tells what deploying a statement did to its object.
+type Status string
+
+const (
+	Created   Status = "created"   // Created is an object that did not exist before.
+	Replaced  Status = "replaced"  // Replaced is an existing object recreated by a CREATE OR REPLACE statement.
+	Unchanged Status = "unchanged" // Unchanged is an existing object left alone by a CREATE ... IF NOT EXISTS statement.
+)
+
+// Object is a table or view created by one statement.
+type Object struct {
+	Name        string   // Name is the schema qualified, lower-cased name of the object.
+	Kind        string   // Kind is TABLE, VIEW or MATERIALIZED VIEW.
+	SQL         string   // SQL is the CREATE statement.
+	OrReplace   bool     // OrReplace reports whether the statement is a CREATE OR REPLACE.
+	IfNotExists bool     // IfNotExists reports whether the statement is a CREATE ... IF NOT EXISTS.
+	DependsOn   []string // DependsOn are the names of the objects of the set the statement references.
+}
+
+// Result reports what happened to an object.
+type Result struct {
+	Object *Object
+	Status Status
+}
+
+// Options controls how objects are deployed.
+type Options struct {
+	Schema    string // Schema is the schema of unqualified names, public when empty.
+	BatchSize int    // BatchSize is the maximum number of statements per batch, 40 when 0, the Data API limit.
+	DryRun    bool   // DryRun resolves the plan and the status of each object without executing anything.
+}
+
+var createPattern = regexp.MustCompile(`(?is)^\s*CREATE\s+(OR\s+REPLACE\s+)?(?:(?:TEMP|TEMPORARY|LOCAL)\s+)*(TABLE|VIEW|MATERIALIZED\s+VIEW)\s+(IF\s+NOT\s+EXISTS\s+)?((?:"[^"]+"|[\w$]+)(?:\s*\.\s*(?:"[^"]+"|[\w$]+))?)`)
+
+var identifierPattern = regexp.MustCompile(`(?:"[^"]+"|[\w$]+)(?:\s*\.\s*(?:"[^"]+"|[\w$]+))?`)
+
+// Parse parses a CREATE TABLE, CREATE VIEW or CREATE MATERIALIZED VIEW statement.
+// Unqualified names are placed in schema.
+func Parse(stmt, schema string) (*Object, error) {
+	m := createPattern.FindStringSubmatch(stmt)
+	if m == nil {
+		return nil, fmt.Errorf("not a CREATE TABLE or CREATE VIEW statement: %.40q", stmt)
+	}
+	return &Object{
+		Name:        qualify(m[4], schema),
+		Kind:        strings.Join(strings.Fields(strings.ToUpper(m[2])), " "),
+		SQL:         stmt,
+		OrReplace:   m[1] != "",
+		IfNotExists: m[3] != "",
+	}, nil
+}
+
+// Plan parses the statements, resolves their dependencies and groups them into batches.
+// Every batch only depends on the batches before it.
+func Plan(stmts []string, opts Options) ([][]*Object, error) {
+	schema := opts.schema()
+	objects := make(map[string]*Object, len(stmts))
+	for _, stmt := range stmts {
+		o, err := Parse(stmt, schema)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := objects[o.Name]; ok {
+			return nil, fmt.Errorf("%s is created more than once", o.Name)
+		}
+		objects[o.Name] = o
+	}
+	for _, o := range objects {
+		body := createPattern.ReplaceAllString(o.SQL, "")
+		seen := map[string]bool{}
+		for _, ident := range identifierPattern.FindAllString(body, -1) {
+			name := qualify(ident, schema)
+			if _, ok := objects[name]; ok && name != o.Name && !seen[name] {
+				seen[name] = true
+				o.DependsOn = append(o.DependsOn, name)
+			}
+		}
+		sort.Strings(o.DependsOn)
+	}
+
+	batchSize := opts.BatchSize
+	if batchSize <= 0 {
+		batchSize = 40
+	}
+	var batches [][]*Object
+	done := make(map[string]bool, len(objects))
+	for len(done) < len(objects) {
+		var level []*Object
+		for _, o := range objects {
+			if done[o.Name] {
+				continue
+			}
+			ready := true
+			for _, dep := range o.DependsOn {
+				ready = ready && done[dep]
+			}
+			if ready {
+				level = append(level, o)
+			}
+		}
+		if len(level) == 0 {
+			var pending []string
+			for name := range objects {
+				if !done[name] {
+					pending = append(pending, name)
+				}
+			}
+			sort.Strings(pending)
+			return nil, fmt.Errorf("dependency cycle between %s", strings.Join(pending, ", "))
+		}
+		sort.Slice(level, func(i, j int) bool { return level[i].Name < level[j].Name })
+		for _, o := range level {
+			done[o.Name] = true
+		}
+		for len(level) > batchSize {
+			batches = append(batches, level[:batchSize])
+			level = level[batchSize:]
+		}
+		batches = append(batches, level)
+	}
+	return batches, nil
+}
+
+// Deploy creates the objects of the statements in dependency order and reports the status of each one,
+// in the order they were deployed. When a batch fails, the results of the batches deployed before it are
+// returned along with the error.
+func Deploy(ctx context.Context, db *sql.DB, stmts []string, opts Options) ([]Result, error) {
+	batches, err := Plan(stmts, opts)
+	if err != nil {
+		return nil, err
+	}
+	existing, err := existingObjects(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+	var results []Result
+	for _, batch := range batches {
+		if !opts.DryRun {
+			if err := execBatch(ctx, db, batch); err != nil {
+				return results, err
+			}
+		}
+		for _, o := range batch {
+			status := Created
+			if existing[o.Name] {
+				status = Unchanged
+				if o.OrReplace {
+					status = Replaced
+				}
+			}
+			results = append(results, Result{Object: o, Status: status})
+		}
+	}
+	return results, nil
+}
+
+// execBatch creates the objects of a batch in one transaction. Materialized views cannot be created in a
+// transaction block, they are created one by one once the transaction committed.
+func execBatch(ctx context.Context, db *sql.DB, batch []*Object) error {
+	var inTx, alone []*Object
+	for _, o := range batch {
+		if o.Kind == "MATERIALIZED VIEW" {
+			alone = append(alone, o)
+		} else {
+			inTx = append(inTx, o)
+		}
+	}
+	if len(inTx) > 0 {
+		tx, err := db.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+		for _, o := range inTx {
+			if _, err := tx.ExecContext(ctx, o.SQL); err != nil {
+				return fmt.Errorf("%s: %w", o.Name, err)
+			}
+		}
+		if err := tx.Commit(); err != nil {
+			names := make([]string, len(inTx))
+			for i, o := range inTx {
+				names[i] = o.Name
+			}
+			return fmt.Errorf("deploy %s: %w", strings.Join(names, ", "), err)
+		}
+	}
+	for _, o := range alone {
+		if _, err := db.ExecContext(ctx, o.SQL); err != nil {
+			return fmt.Errorf("deploy %s: %w", o.Name, err)
+		}
+	}
+	return nil
+}
+
+// existingObjects returns the qualified names of the tables and views of the database.
+func existingObjects(ctx context.Context, db *sql.DB) (map[string]bool, error) {
+	rows, err := db.QueryContext(ctx, `SELECT table_schema, table_name FROM information_schema.tables WHERE table_schema NOT IN ('pg_catalog', 'information_schema')`)
+	if err != nil {
+		return nil, fmt.Errorf("list existing objects: %w", err)
+	}
+	defer rows.Close()
+	existing := map[string]bool{}
+	for rows.Next() {
+		var schema, name string
+		if err := rows.Scan(&schema, &name); err != nil {
+			return nil, err
+		}
+		existing[strings.ToLower(schema+"."+name)] = true
+	}
+	return existing, rows.Err()
+}
+
+// qualify normalizes an identifier to a lower-cased schema qualified name.
+func qualify(ident, schema string) string {
+	parts := strings.Split(ident, ".")
+	for i, part := range parts {
+		parts[i] = strings.ToLower(strings.Trim(strings.TrimSpace(part), `"`))
+	}
+	if len(parts) == 1 {
+		return schema + "." + parts[0]
+	}
+	return parts[0] + "." + parts[1]
+}
+
+func (opts Options) schema() string {
+	if opts.Schema == "" {
+		return "public"
+	}
+	return strings.ToLower(opts.Schema)
+}
@@ -0,0 +1,344 @@
+// Package dialect translates common Postgres constructs that Redshift lacks into Redshift equivalents,
+// so code written against Postgres ports over with minimal changes:
+//
+//   - INSERT ... ON CONFLICT (keys) DO UPDATE SET ... becomes a MERGE over the inserted values.
+//   - INSERT ... ON CONFLICT (keys) DO NOTHING becomes an INSERT ... SELECT of the missing rows.
+//   - ... RETURNING columns becomes a follow-up SELECT (a preceding one for DELETE). This is best effort:
+//     rows are found again by their values or WHERE clause, and is only atomic inside a session.
+//     With ON CONFLICT DO NOTHING the rows that conflicted are returned too, Postgres only returns the inserted
+//     ones. UPDATE ... RETURNING is rejected when SET assigns a column the WHERE clause references, as the
+//     updated rows could not be found again.
+//   - SERIAL, BIGSERIAL and SMALLSERIAL columns become IDENTITY(1,1) columns.
+//
+// Translation is enabled per connector with the dialect=postgres DSN parameter.
+package dialect
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	Redshift = "redshift" // Redshift leaves queries untouched, it is the default.
+	Postgres = "postgres" // Postgres translates Postgres-isms.
+)
+
+// Statement is one of the statements a query is translated into.
+type Statement struct {
+	SQL     string // SQL is the statement, its placeholders are all of the $n form.
+	Args    []int  // Args are the 1-based ordinals of the original arguments the statement references.
+	Returns bool   // Returns marks the statement whose rows are the result of the original query.
+}
+
+// Translate translates the query. Queries without anything to translate are returned as a single statement.
+// Positional ? placeholders are numbered into $n placeholders so arguments can be shared across statements.
+func Translate(query string) ([]Statement, error) {
+	query = numberPlaceholders(query)
+	query = replaceSerial(query)
+
+	var stmts []Statement
+	base, returning := splitReturning(query)
+	if i := findKeyword(base, "ON CONFLICT", 0); i >= 0 && startsWith(base, "INSERT") {
+		ins, err := parseInsert(base[:i])
+		if err != nil {
+			return nil, err
+		}
+		merge, err := translateOnConflict(ins, base[afterKeyword(base, "ON CONFLICT", i):])
+		if err != nil {
+			return nil, err
+		}
+		stmts = append(stmts, Statement{SQL: merge})
+		if returning != "" {
+			stmts = append(stmts, Statement{SQL: ins.selectInserted(returning, ins.keys), Returns: true})
+		}
+	} else if returning != "" {
+		var err error
+		if stmts, err = translateReturning(base, returning); err != nil {
+			return nil, err
+		}
+	} else {
+		stmts = []Statement{{SQL: query}}
+	}
+	for i := range stmts {
+		stmts[i].Args = referencedArgs(stmts[i].SQL)
+	}
+	return stmts, nil
+}
+
+func startsWith(query, keyword string) bool {
+	return findKeyword(query, keyword, 0) == len(query)-len(strings.TrimLeft(query, " \t\r\n"))
+}
+
+// numberPlaceholders replaces top-level ? placeholders with $1, $2 and so on.
+func numberPlaceholders(query string) string {
+	var b strings.Builder
+	n, last := 0, 0
+	scanner{query}.walk(func(i, depth int) bool {
+		if query[i] == '?' {
+			n++
+			b.WriteString(query[last:i])
+			b.WriteString("$" + strconv.Itoa(n))
+			last = i + 1
+		}
+		return true
+	})
+	if n == 0 {
+		return query
+	}
+	b.WriteString(query[last:])
+	return b.String()
+}
+
+// referencedArgs returns the ordinals of the $n placeholders of the query, outside quotes and comments,
+// in the order they first appear.
+func referencedArgs(query string) []int {
+	seen := make(map[int]bool)
+	var args []int
+	scanner{query}.walk(func(i, depth int) bool {
+		if query[i] != '$' || (i > 0 && isWordByte(query[i-1])) {
+			return true
+		}
+		end := i + 1
+		for end < len(query) && query[end] >= '0' && query[end] <= '9' {
+			end++
+		}
+		if n, err := strconv.Atoi(query[i+1 : end]); err == nil && !seen[n] {
+			seen[n] = true
+			args = append(args, n)
+		}
+		return true
+	})
+	return args
+}
+
+var serialTypes = map[string]string{
+	"serial":      "INTEGER IDENTITY(1,1)",
+	"bigserial":   "BIGINT IDENTITY(1,1)",
+	"smallserial": "SMALLINT IDENTITY(1,1)",
+}
+
+// replaceSerial rewrites serial pseudo types of CREATE TABLE statements into identity columns.
+func replaceSerial(query string) string {
+	if !startsWith(query, "CREATE TABLE") && !startsWith(query, "CREATE TEMP TABLE") && !startsWith(query, "CREATE TEMPORARY TABLE") {
+		return query
+	}
+	var b strings.Builder
+	last := 0
+	scanner{query}.walk(func(i, depth int) bool {
+		if i < last || (i > 0 && isWordByte(query[i-1])) {
+			return true
+		}
+		for name, identity := range serialTypes {
+			if end := matchWords(query, i, []string{name}); end >= 0 {
+				b.WriteString(query[last:i])
+				b.WriteString(identity)
+				last = end
+				break
+			}
+		}
+		return true
+	})
+	b.WriteString(query[last:])
+	return b.String()
+}
+
+func splitReturning(query string) (string, string) {
+	i := findKeyword(query, "RETURNING", 0)
+	if i < 0 {
+		return query, ""
+	}
+	returning := strings.TrimSpace(strings.TrimRight(strings.TrimSpace(query[i+len("RETURNING"):]), ";"))
+	return strings.TrimSpace(query[:i]), returning
+}
+
+// insert is a parsed INSERT INTO table (columns) VALUES (...), ... statement.
+type insert struct {
+	table   string
+	columns []string
+	rows    [][]string
+	keys    []string
+}
+
+func parseInsert(query string) (*insert, error) {
+	into := findKeyword(query, "INTO", 0)
+	values := findKeyword(query, "VALUES", 0)
+	if into < 0 || values < 0 {
+		return nil, fmt.Errorf("dialect: only INSERT INTO table (columns) VALUES (...) can be translated")
+	}
+	target := query[afterKeyword(query, "INTO", into):values]
+	open := strings.IndexByte(target, '(')
+	if open < 0 {
+		return nil, fmt.Errorf("dialect: INSERT needs an explicit column list to be translated")
+	}
+	ins := &insert{table: strings.TrimSpace(target[:open])}
+	columns, _, ok := parenthesized(target, open)
+	if !ok {
+		return nil, fmt.Errorf("dialect: malformed INSERT column list")
+	}
+	ins.columns = splitTopLevel(columns, ',')
+	rest := query[afterKeyword(query, "VALUES", values):]
+	for _, tuple := range splitTopLevel(rest, ',') {
+		row, _, ok := parenthesized(tuple, 0)
+		if !ok {
+			return nil, fmt.Errorf("dialect: malformed VALUES tuple %q", tuple)
+		}
+		values := splitTopLevel(row, ',')
+		if len(values) != len(ins.columns) {
+			return nil, fmt.Errorf("dialect: VALUES tuple has %d values, expected %d", len(values), len(ins.columns))
+		}
+		ins.rows = append(ins.rows, values)
+	}
+	return ins, nil
+}
+
+// source renders the inserted rows as a derived table aliased excluded, like the Postgres pseudo table.
+func (ins *insert) source() string {
+	selects := make([]string, 0, len(ins.rows))
+	for _, row := range ins.rows {
+		items := make([]string, len(row))
+		for i, v := range row {
+			items[i] = v + " AS " + ins.columns[i]
+		}
+		selects = append(selects, "SELECT "+strings.Join(items, ", "))
+	}
+	return "(" + strings.Join(selects, " UNION ALL ") + ") AS excluded"
+}
+
+func (ins *insert) keyCondition() string {
+	conds := make([]string, len(ins.keys))
+	for i, k := range ins.keys {
+		conds[i] = fmt.Sprintf("%s.%s = excluded.%s", ins.table, k, k)
+	}
+	return strings.Join(conds, " AND ")
+}
+
+// selectInserted selects the returning columns of the inserted rows, identified by the given columns.
+func (ins *insert) selectInserted(returning string, by []string) string {
+	if len(by) == 0 {
+		by = ins.columns
+	}
+	alternatives := make([]string, 0, len(ins.rows))
+	for _, row := range ins.rows {
+		var conds []string
+		for _, c := range by {
+			for i, col := range ins.columns {
+				if strings.EqualFold(col, c) && !strings.EqualFold(row[i], "NULL") && !strings.EqualFold(row[i], "DEFAULT") {
+					conds = append(conds, fmt.Sprintf("%s = %s", c, row[i]))
+				}
+			}
+		}
+		if len(conds) > 0 {
+			alternatives = append(alternatives, "("+strings.Join(conds, " AND ")+")")
+		}
+	}
+	query := fmt.Sprintf("SELECT %s FROM %s", returning, ins.table)
+	if len(alternatives) > 0 {
+		query += " WHERE " + strings.Join(alternatives, " OR ")
+	}
+	return query
+}
+
+func translateOnConflict(ins *insert, clause string) (string, error) {
+	keys, end, ok := parenthesized(clause, 0)
+	if !ok {
+		return "", fmt.Errorf("dialect: ON CONFLICT needs a column list to be translated")
+	}
+	ins.keys = splitTopLevel(keys, ',')
+	action := strings.TrimSpace(strings.TrimRight(strings.TrimSpace(clause[end:]), ";"))
+
+	if i := findKeyword(action, "DO NOTHING", 0); i >= 0 {
+		// a RETURNING select finds the conflicting rows too, see the package documentation
+		items := make([]string, len(ins.columns))
+		for i, c := range ins.columns {
+			items[i] = "excluded." + c
+		}
+		return fmt.Sprintf("INSERT INTO %s (%s) SELECT %s FROM %s WHERE NOT EXISTS (SELECT 1 FROM %s WHERE %s)",
+			ins.table, strings.Join(ins.columns, ", "), strings.Join(items, ", "), ins.source(), ins.table, ins.keyCondition()), nil
+	}
+	i := findKeyword(action, "DO UPDATE SET", 0)
+	if i < 0 {
+		return "", fmt.Errorf("dialect: unsupported ON CONFLICT action %q", action)
+	}
+	assignments := action[afterKeyword(action, "DO UPDATE SET", i):]
+	if findKeyword(assignments, "WHERE", 0) >= 0 {
+		return "", fmt.Errorf("dialect: ON CONFLICT DO UPDATE ... WHERE can not be translated")
+	}
+	values := make([]string, len(ins.columns))
+	for i, c := range ins.columns {
+		values[i] = "excluded." + c
+	}
+	return fmt.Sprintf("MERGE INTO %s USING %s ON %s WHEN MATCHED THEN UPDATE SET %s WHEN NOT MATCHED THEN INSERT (%s) VALUES (%s)",
+		ins.table, ins.source(), ins.keyCondition(), strings.TrimSpace(assignments), strings.Join(ins.columns, ", "), strings.Join(values, ", ")), nil
+}
+
+func translateReturning(base, returning string) ([]Statement, error) {
+	switch {
+	case startsWith(base, "INSERT"):
+		ins, err := parseInsert(base)
+		if err != nil {
+			return nil, err
+		}
+		return []Statement{{SQL: base}, {SQL: ins.selectInserted(returning, nil), Returns: true}}, nil
+	case startsWith(base, "UPDATE"):
+		fields := strings.Fields(base)
+		if len(fields) < 2 {
+			return nil, fmt.Errorf("dialect: malformed UPDATE")
+		}
+		if column := reassignedFilter(base); column != "" {
+			return nil, fmt.Errorf("dialect: UPDATE ... RETURNING can not be translated, SET assigns %s which the WHERE clause references", column)
+		}
+		return []Statement{{SQL: base}, {SQL: selectWhere(base, returning, fields[1]), Returns: true}}, nil
+	case startsWith(base, "DELETE FROM"):
+		fields := strings.Fields(base)
+		if len(fields) < 3 {
+			return nil, fmt.Errorf("dialect: malformed DELETE")
+		}
+		return []Statement{{SQL: selectWhere(base, returning, fields[2]), Returns: true}, {SQL: base}}, nil
+	}
+	return nil, fmt.Errorf("dialect: RETURNING is only translated for INSERT, UPDATE and DELETE")
+}
+
+// reassignedFilter returns the first column the SET list of the UPDATE assigns and its WHERE clause references,
+// or an empty string. The rows selected again by the WHERE clause after such an update are not the updated ones.
+func reassignedFilter(update string) string {
+	where := findKeyword(update, "WHERE", 0)
+	set := findKeyword(update, "SET", 0)
+	if where < 0 || set < 0 || set > where {
+		return ""
+	}
+	end := where
+	if from := findKeyword(update, "FROM", set); from >= 0 && from < where {
+		end = from
+	}
+	filter := update[where:]
+	for _, assignment := range splitTopLevel(update[afterKeyword(update, "SET", set):end], ',') {
+		column, _, ok := strings.Cut(assignment, "=")
+		if column = strings.Trim(strings.TrimSpace(column), `"`); ok && references(filter, column) {
+			return column
+		}
+	}
+	return ""
+}
+
+// references reports whether the word appears in s, outside quotes and comments.
+func references(s, word string) bool {
+	found := false
+	scanner{s}.walk(func(i, depth int) bool {
+		if i > 0 && isWordByte(s[i-1]) {
+			return true
+		}
+		found = matchWords(s, i, []string{word}) >= 0
+		return !found
+	})
+	return found
+}
+
+// selectWhere selects the returning columns of table using the WHERE clause of the statement.
+func selectWhere(stmt, returning, table string) string {
+	query := fmt.Sprintf("SELECT %s FROM %s", returning, table)
+	if i := findKeyword(stmt, "WHERE", 0); i >= 0 {
+		query += " " + stmt[i:]
+	}
+	return query
+}
@@ -0,0 +1,162 @@
+package dialect
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestTranslate(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		want  []Statement
+	}{
+		{
+			"untouched",
+			"SELECT * FROM t WHERE a = $1",
+			[]Statement{{SQL: "SELECT * FROM t WHERE a = $1", Args: []int{1}}},
+		},
+		{
+			"on conflict do update",
+			"INSERT INTO t (id, name) VALUES (?, ?) ON CONFLICT (id) DO UPDATE SET name = excluded.name",
+			[]Statement{{
+				SQL: "MERGE INTO t USING (SELECT $1 AS id, $2 AS name) AS excluded ON t.id = excluded.id " +
+					"WHEN MATCHED THEN UPDATE SET name = excluded.name " +
+					"WHEN NOT MATCHED THEN INSERT (id, name) VALUES (excluded.id, excluded.name)",
+				Args: []int{1, 2},
+			}},
+		},
+		{
+			"on conflict do nothing",
+			"INSERT INTO t (id, name) VALUES ($1, $2), ($3, $4) ON CONFLICT (id) DO NOTHING",
+			[]Statement{{
+				SQL: "INSERT INTO t (id, name) SELECT excluded.id, excluded.name " +
+					"FROM (SELECT $1 AS id, $2 AS name UNION ALL SELECT $3 AS id, $4 AS name) AS excluded " +
+					"WHERE NOT EXISTS (SELECT 1 FROM t WHERE t.id = excluded.id)",
+				Args: []int{1, 2, 3, 4},
+			}},
+		},
+		{
+			"on conflict do nothing returning",
+			"INSERT INTO t (id, name) VALUES (1, 'a') ON CONFLICT (id) DO NOTHING RETURNING id",
+			[]Statement{
+				{SQL: "INSERT INTO t (id, name) SELECT excluded.id, excluded.name FROM (SELECT 1 AS id, 'a' AS name) AS excluded " +
+					"WHERE NOT EXISTS (SELECT 1 FROM t WHERE t.id = excluded.id)"},
+				{SQL: "SELECT id FROM t WHERE (id = 1)", Returns: true},
+			},
+		},
+		{
+			"on conflict composite key",
+			"INSERT INTO t (a, b, c) VALUES (?, ?, ?) ON CONFLICT (a, b) DO UPDATE SET c = excluded.c",
+			[]Statement{{
+				SQL: "MERGE INTO t USING (SELECT $1 AS a, $2 AS b, $3 AS c) AS excluded ON t.a = excluded.a AND t.b = excluded.b " +
+					"WHEN MATCHED THEN UPDATE SET c = excluded.c " +
+					"WHEN NOT MATCHED THEN INSERT (a, b, c) VALUES (excluded.a, excluded.b, excluded.c)",
+				Args: []int{1, 2, 3},
+			}},
+		},
+		{
+			"insert returning",
+			"INSERT INTO t (id, name) VALUES (?, ?) RETURNING id",
+			[]Statement{
+				{SQL: "INSERT INTO t (id, name) VALUES ($1, $2)", Args: []int{1, 2}},
+				{SQL: "SELECT id FROM t WHERE (id = $1 AND name = $2)", Args: []int{1, 2}, Returns: true},
+			},
+		},
+		{
+			"insert returning skips null and default",
+			"INSERT INTO t (id, name) VALUES (DEFAULT, NULL), (2, 'b') RETURNING id;",
+			[]Statement{
+				{SQL: "INSERT INTO t (id, name) VALUES (DEFAULT, NULL), (2, 'b')"},
+				{SQL: "SELECT id FROM t WHERE (id = 2 AND name = 'b')", Returns: true},
+			},
+		},
+		{
+			"update returning",
+			"UPDATE t SET name = ? WHERE id = ? RETURNING id, name",
+			[]Statement{
+				{SQL: "UPDATE t SET name = $1 WHERE id = $2", Args: []int{1, 2}},
+				{SQL: "SELECT id, name FROM t WHERE id = $2", Args: []int{2}, Returns: true},
+			},
+		},
+		{
+			"update returning assigning a column only named in a literal",
+			"UPDATE t SET status = 'done' WHERE id = 1 AND note <> 'status' RETURNING id",
+			[]Statement{
+				{SQL: "UPDATE t SET status = 'done' WHERE id = 1 AND note <> 'status'"},
+				{SQL: "SELECT id FROM t WHERE id = 1 AND note <> 'status'", Returns: true},
+			},
+		},
+		{
+			"delete returning",
+			"DELETE FROM t WHERE id = ? RETURNING name",
+			[]Statement{
+				{SQL: "SELECT name FROM t WHERE id = $1", Args: []int{1}, Returns: true},
+				{SQL: "DELETE FROM t WHERE id = $1", Args: []int{1}},
+			},
+		},
+		{
+			"serial",
+			"CREATE TABLE t (id serial, b bigserial, c smallserial, name text)",
+			[]Statement{{SQL: "CREATE TABLE t (id INTEGER IDENTITY(1,1), b BIGINT IDENTITY(1,1), c SMALLINT IDENTITY(1,1), name text)"}},
+		},
+		{
+			"serial only in create table",
+			"SELECT serial FROM t",
+			[]Statement{{SQL: "SELECT serial FROM t"}},
+		},
+		{
+			"placeholders in literals and comments",
+			"SELECT '?', ?, \"?\" FROM t WHERE a = 'it''s ?' /* ? */ AND b = ? -- ?",
+			[]Statement{{SQL: "SELECT '?', $1, \"?\" FROM t WHERE a = 'it''s ?' /* ? */ AND b = $2 -- ?", Args: []int{1, 2}}},
+		},
+		{
+			"dollar placeholders in literals",
+			"SELECT '$5', $1 FROM t",
+			[]Statement{{SQL: "SELECT '$5', $1 FROM t", Args: []int{1}}},
+		},
+		{
+			"keywords in literals",
+			"INSERT INTO t (a) VALUES ('ON CONFLICT (a) DO NOTHING RETURNING a')",
+			[]Statement{{SQL: "INSERT INTO t (a) VALUES ('ON CONFLICT (a) DO NOTHING RETURNING a')"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Translate(tt.query)
+			if err != nil {
+				t.Fatalf("Translate(%q): %v", tt.query, err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Translate(%q)\n got %#v\nwant %#v", tt.query, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTranslateErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		err   string
+	}{
+		{"do update where", "INSERT INTO t (id, name) VALUES (1, 'a') ON CONFLICT (id) DO UPDATE SET name = 'b' WHERE t.name <> 'b'", "DO UPDATE ... WHERE"},
+		{"on constraint", "INSERT INTO t (id) VALUES (1) ON CONFLICT ON CONSTRAINT t_pkey DO NOTHING", "needs a column list"},
+		{"unknown action", "INSERT INTO t (id) VALUES (1) ON CONFLICT (id) DO SOMETHING", "unsupported ON CONFLICT action"},
+		{"insert select", "INSERT INTO t SELECT * FROM u RETURNING id", "only INSERT INTO table (columns) VALUES"},
+		{"no column list", "INSERT INTO t VALUES (1) RETURNING id", "explicit column list"},
+		{"values count", "INSERT INTO t (a, b) VALUES (1) RETURNING a", "has 1 values, expected 2"},
+		{"returning select", "SELECT 1 RETURNING x", "only translated for INSERT, UPDATE and DELETE"},
+		{"update reassigns filter", "UPDATE t SET status = 'done' WHERE status = 'pending' RETURNING id", "SET assigns status"},
+		{"update reassigns qualified filter", "UPDATE t SET done = true, n = n + 1 WHERE NOT t.done RETURNING id", "SET assigns done"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stmts, err := Translate(tt.query)
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("Translate(%q) = %v, %v, want an error containing %q", tt.query, stmts, err, tt.err)
+			}
+		})
+	}
+}
@@ -0,0 +1,147 @@
+package dialect
+
+import (
+	"strings"
+)
+
+// scanner walks a query and reports which bytes are top-level SQL text:
+// outside quotes, comments and parentheses.
+type scanner struct {
+	query string
+}
+
+// walk calls fn with the offset and parenthesis depth of every byte that is not part of a quoted string,
+// quoted identifier or comment. Returning false stops the walk.
+func (s scanner) walk(fn func(i, depth int) bool) {
+	q := s.query
+	depth := 0
+	for i := 0; i < len(q); i++ {
+		switch c := q[i]; {
+		case c == '\'' || c == '"':
+			for i++; i < len(q); i++ {
+				if q[i] == c {
+					if i+1 < len(q) && q[i+1] == c {
+						i++
+						continue
+					}
+					break
+				}
+			}
+		case c == '-' && i+1 < len(q) && q[i+1] == '-':
+			for i < len(q) && q[i] != '\n' {
+				i++
+			}
+		case c == '/' && i+1 < len(q) && q[i+1] == '*':
+			end := strings.Index(q[i+2:], "*/")
+			if end < 0 {
+				return
+			}
+			i += end + 3
+		case c == '(':
+			if !fn(i, depth) {
+				return
+			}
+			depth++
+		case c == ')':
+			depth--
+			if !fn(i, depth) {
+				return
+			}
+		default:
+			if !fn(i, depth) {
+				return
+			}
+		}
+	}
+}
+
+func isWordByte(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
+
+// findKeyword returns the offset of the first top-level occurrence of the keyword at or after from, or -1.
+// The keyword may consist of several words separated by single spaces, which match any whitespace.
+func findKeyword(query, keyword string, from int) int {
+	words := strings.Fields(keyword)
+	found := -1
+	scanner{query}.walk(func(i, depth int) bool {
+		if i < from || depth != 0 || (i > 0 && isWordByte(query[i-1])) {
+			return true
+		}
+		if end := matchWords(query, i, words); end >= 0 {
+			found = i
+			return false
+		}
+		return true
+	})
+	return found
+}
+
+// matchWords matches the words case-insensitively at offset i and returns the offset after them, or -1.
+func matchWords(query string, i int, words []string) int {
+	for n, w := range words {
+		if n > 0 {
+			j := i
+			for j < len(query) && (query[j] == ' ' || query[j] == '\t' || query[j] == '\n' || query[j] == '\r') {
+				j++
+			}
+			if j == i {
+				return -1
+			}
+			i = j
+		}
+		if len(query) < i+len(w) || !strings.EqualFold(query[i:i+len(w)], w) {
+			return -1
+		}
+		i += len(w)
+		if i < len(query) && isWordByte(query[i]) {
+			return -1
+		}
+	}
+	return i
+}
+
+// afterKeyword returns the offset right after the keyword found at offset i.
+func afterKeyword(query, keyword string, i int) int {
+	return matchWords(query, i, strings.Fields(keyword))
+}
+
+// splitTopLevel splits s on sep bytes that are outside quotes, comments and parentheses.
+func splitTopLevel(s string, sep byte) []string {
+	var parts []string
+	last := 0
+	scanner{s}.walk(func(i, depth int) bool {
+		if depth == 0 && s[i] == sep {
+			parts = append(parts, strings.TrimSpace(s[last:i]))
+			last = i + 1
+		}
+		return true
+	})
+	return append(parts, strings.TrimSpace(s[last:]))
+}
+
+// parenthesized returns the content of the parenthesized group starting at the first '(' at or after from,
+// along with the offset after the closing parenthesis.
+func parenthesized(s string, from int) (string, int, bool) {
+	open, end := -1, -1
+	scanner{s}.walk(func(i, depth int) bool {
+		if i < from {
+			return true
+		}
+		if open < 0 {
+			if s[i] == '(' && depth == 0 {
+				open = i
+			}
+			return true
+		}
+		if s[i] == ')' && depth == 0 {
+			end = i
+			return false
+		}
+		return true
+	})
+	if open < 0 || end < 0 {
+		return "", -1, false
+	}
+	return s[open+1 : end], end + 1, true
+}
@@ -1,3 +1,41 @@
 package metasql
 
-type redshiftDataDriver struct{}
\ No newline at end of file
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+
+	"github.com/adarsh-jaiss/metasql/config"
+)
+
+// DriverName is the name the driver is registered with in database/sql:
+//
+//	db, err := sql.Open(metasql.DriverName, "workgroup(name)/database")
+const DriverName = "redshift-data"
+
+func init() {
+	sql.Register(DriverName, &redshiftDataDriver{})
+}
+
+type redshiftDataDriver struct{}
+
+// Open parses the DSN and opens a new connection with it.
+func (d *redshiftDataDriver) Open(dsn string) (driver.Conn, error) {
+	connector, err := d.OpenConnector(dsn)
+	if err != nil {
+		return nil, err
+	}
+	return connector.Connect(context.Background())
+}
+
+// OpenConnector parses the DSN once and returns a connector for it. database/sql uses it when the driver is
+// opened with sql.Open, so the connections of the pool share the caches of the connector as with NewConnector.
+func (d *redshiftDataDriver) OpenConnector(dsn string) (driver.Connector, error) {
+	cfg, err := config.ParseDSN(dsn)
+	if err != nil {
+		return nil, err
+	}
+	connector := NewConnector(cfg)
+	connector.d = d
+	return connector, nil
+}
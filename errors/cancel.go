@@ -0,0 +1,34 @@
+package errors
+
+import "fmt"
+
+// CancelError is returned when the driver stops waiting for a statement, because its context was done or the
+// statement timeout elapsed. Err is context.Canceled or context.DeadlineExceeded, so errors.Is still matches it.
+type CancelError struct {
+	StatementID string // StatementID is the id of the statement given up on.
+	Cancelled   bool   // Cancelled reports whether the statement was cancelled, it keeps running on the warehouse otherwise.
+	Err         error  // Err is the reason the statement was given up on.
+	CancelErr   error  // CancelErr is the error of CancelStatement, if it failed.
+}
+
+// NewCancelError returns a CancelError for the statement with the given id, cancelled unless cancelErr is set.
+func NewCancelError(id string, cancelled bool, err, cancelErr error) *CancelError {
+	return &CancelError{StatementID: id, Cancelled: cancelled, Err: err, CancelErr: cancelErr}
+}
+
+func (e *CancelError) Error() string {
+	switch {
+	case e.CancelErr != nil:
+		return fmt.Sprintf("statement %s: %v, %v", e.StatementID, e.Err, e.CancelErr)
+	case e.Cancelled:
+		return fmt.Sprintf("statement %s cancelled: %v", e.StatementID, e.Err)
+	}
+	return fmt.Sprintf("statement %s left running: %v", e.StatementID, e.Err)
+}
+
+func (e *CancelError) Unwrap() []error {
+	if e.CancelErr != nil {
+		return []error{e.Err, e.CancelErr}
+	}
+	return []error{e.Err}
+}
@@ -0,0 +1,23 @@
+package errors
+
+// ConfigError is a setup problem rather than a problem of a statement. It matches its Kind with errors.Is, one of
+// ErrConfigInvalid for DSNs and config fields, ErrCredentials for AWS authentication and ErrTargetUnreachable for
+// clusters and workgroups that can not be found or used, so callers can suggest the right remediation.
+type ConfigError struct {
+	Kind error // Kind is ErrConfigInvalid, ErrCredentials or ErrTargetUnreachable.
+	Err  error // Err is the underlying error.
+}
+
+// NewConfigError classifies err as a setup problem of the given kind.
+func NewConfigError(kind error, err error) *ConfigError {
+	return &ConfigError{Kind: kind, Err: err}
+}
+
+func (e *ConfigError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns both the kind and the underlying error, so errors.Is matches either of them.
+func (e *ConfigError) Unwrap() []error {
+	return []error{e.Kind, e.Err}
+}
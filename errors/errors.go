@@ -3,10 +3,21 @@ package errors
 import "errors"
 
 var (
-	ErrDSNEmpty     = errors.New("dsn is empty")
+	ErrDSNEmpty             = errors.New("dsn is empty")
 	ErrRedshiftDSNInvalid   = errors.New("dsn is invalid: workgroup(name)/database or username@cluster(name)/database or secrets_arn")
-	ErrNotSupported = errors.New("not supported")
-	ErrInTx         = errors.New("query in transaction")
-	ErrNotInTx      = errors.New("not in transaction")
-	
-)
\ No newline at end of file
+	ErrNotSupported         = errors.New("not supported")
+	ErrInTx                 = errors.New("query in transaction")
+	ErrNotInTx              = errors.New("not in transaction")
+	ErrConnClosed           = errors.New("connection closed")
+	ErrTenantMissing        = errors.New("no tenant in context")
+	ErrTenantUnknown        = errors.New("unknown tenant")
+	ErrRowsAffectedUnknown  = errors.New("rows affected is not reported for this statement")
+	ErrBatchPending         = errors.New("statement is pending in a micro-batch")
+	ErrAuthConflict         = errors.New("both secrets_arn and db_user are set: choose the one to send with auth_precedence=secret or auth_precedence=db_user")
+	ErrAuthDBUserServerless = errors.New("db_user is not supported with a workgroup: use secrets_arn or IAM credentials")
+	ErrUnboundParameter     = errors.New("unbound parameter")
+	ErrNoSession            = errors.New("connection is not pinned to a session: call PinSession first")
+	ErrConfigInvalid        = errors.New("configuration is invalid")
+	ErrCredentials          = errors.New("aws credentials are missing or rejected")
+	ErrTargetUnreachable    = errors.New("cluster or workgroup is unreachable")
+)
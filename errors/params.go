@@ -0,0 +1,63 @@
+package errors
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ParameterCountError is returned before a statement is executed when its positional placeholders and
+// arguments do not match.
+type ParameterCountError struct {
+	Expected int      // Expected is the number of distinct positional placeholders of the query.
+	Got      int      // Got is the number of positional arguments supplied.
+	Missing  []string // Missing lists the placeholders without an argument, such as ":3".
+	Extra    []string // Extra lists the arguments no placeholder refers to, such as ":3".
+}
+
+// NewParameterCountError returns a ParameterCountError.
+func NewParameterCountError(expected, got int, missing, extra []string) *ParameterCountError {
+	return &ParameterCountError{Expected: expected, Got: got, Missing: missing, Extra: extra}
+}
+
+func (e *ParameterCountError) Error() string {
+	msg := fmt.Sprintf("query expects %d parameters, %d supplied", e.Expected, e.Got)
+	if len(e.Missing) > 0 {
+		msg += ": missing " + strings.Join(e.Missing, ", ")
+	}
+	if len(e.Extra) > 0 {
+		sep := ": "
+		if len(e.Missing) > 0 {
+			sep = "; "
+		}
+		msg += sep + "extra " + strings.Join(e.Extra, ", ")
+	}
+	return msg
+}
+
+// UnboundParameterError is returned before a statement is executed when its :name placeholders and sql.Named
+// arguments do not match. It matches ErrUnboundParameter with errors.Is.
+type UnboundParameterError struct {
+	Unbound []string // Unbound lists the placeholders without a sql.Named argument.
+	Unused  []string // Unused lists the sql.Named arguments no placeholder refers to.
+}
+
+// NewUnboundParameterError returns an UnboundParameterError.
+func NewUnboundParameterError(unbound, unused []string) *UnboundParameterError {
+	return &UnboundParameterError{Unbound: unbound, Unused: unused}
+}
+
+func (e *UnboundParameterError) Error() string {
+	var parts []string
+	if len(e.Unbound) > 0 {
+		parts = append(parts, "no argument for :"+strings.Join(e.Unbound, ", :"))
+	}
+	if len(e.Unused) > 0 {
+		parts = append(parts, "unused arguments "+strings.Join(e.Unused, ", "))
+	}
+	return ErrUnboundParameter.Error() + ": " + strings.Join(parts, "; ")
+}
+
+// Is reports whether target is ErrUnboundParameter.
+func (e *UnboundParameterError) Is(target error) bool {
+	return target == ErrUnboundParameter
+}
@@ -0,0 +1,22 @@
+package errors
+
+import "fmt"
+
+// RetryError is the error of the last attempt of an operation that was retried.
+type RetryError struct {
+	Attempts int   // Attempts is the number of times the operation was attempted.
+	Err      error // Err is the error of the last attempt.
+}
+
+// NewRetryError returns a RetryError for err, the error of the last of attempts.
+func NewRetryError(attempts int, err error) *RetryError {
+	return &RetryError{Attempts: attempts, Err: err}
+}
+
+func (e *RetryError) Error() string {
+	return fmt.Sprintf("%v (after %d attempts)", e.Err, e.Attempts)
+}
+
+func (e *RetryError) Unwrap() error {
+	return e.Err
+}
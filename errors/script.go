@@ -0,0 +1,23 @@
+package errors
+
+import "fmt"
+
+// ScriptError is a statement of a script run with RunScript that failed.
+type ScriptError struct {
+	Statement int    // Statement is the 1-based position of the statement in the script.
+	SQL       string // SQL is the text of the statement.
+	Err       error  // Err is the error the statement failed with.
+}
+
+// NewScriptError returns a ScriptError for the statement at position n of a script.
+func NewScriptError(n int, sql string, err error) *ScriptError {
+	return &ScriptError{Statement: n, SQL: sql, Err: err}
+}
+
+func (e *ScriptError) Error() string {
+	return fmt.Sprintf("statement %d: %v", e.Statement, e.Err)
+}
+
+func (e *ScriptError) Unwrap() error {
+	return e.Err
+}
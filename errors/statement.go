@@ -0,0 +1,170 @@
+package errors
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/adarsh-jaiss/metasql/pqcompat"
+)
+
+// StatementError is returned when the Data API reports a statement as failed or aborted.
+type StatementError struct {
+	StatementID string // StatementID is the Data API statement id.
+	Status      string // Status is FAILED or ABORTED.
+	Message     string // Message is the error reported by Redshift.
+	Code        string // Code is the SQLSTATE derived from Message, XX000 when it is not recognized.
+	ConnID      string // ConnID is the id of the connection the statement was executed on, if known.
+	// SubStatement is the position, from 1, of the statement that failed within a batch, 0 for single statements
+	// and when the Data API did not report which one failed.
+	SubStatement int
+}
+
+// NewStatementError builds a StatementError and derives its SQLSTATE from the message.
+func NewStatementError(id string, status string, message string) *StatementError {
+	return &StatementError{
+		StatementID: id,
+		Status:      status,
+		Message:     message,
+		Code:        SQLState(message),
+	}
+}
+
+func (e *StatementError) Error() string {
+	msg := "query failed: " + e.Message
+	if e.Status == "ABORTED" {
+		msg = "query aborted: " + e.Message
+	}
+	if e.SubStatement > 0 {
+		msg += " (batch statement " + strconv.Itoa(e.SubStatement) + ")"
+	}
+	if e.ConnID != "" {
+		msg += " (" + e.ConnID + ")"
+	}
+	return msg
+}
+
+// As converts the error into a *pqcompat.Error, so code written against lib/pq can keep switching on error codes.
+func (e *StatementError) As(target any) bool {
+	t, ok := target.(**pqcompat.Error)
+	if !ok {
+		return false
+	}
+	message, detail, hint := splitMessage(e.Message)
+	*t = &pqcompat.Error{
+		Severity: "ERROR",
+		Code:     pqcompat.ErrorCode(e.Code),
+		Message:  message,
+		Detail:   detail,
+		Hint:     hint,
+	}
+	return true
+}
+
+// IsSerializationFailure reports whether err is a statement aborted by a serializable isolation violation,
+// in which case running the transaction again may succeed.
+func IsSerializationFailure(err error) bool {
+	var statementErr *StatementError
+	return errors.As(err, &statementErr) && statementErr.Code == "40001"
+}
+
+// splitMessage separates the DETAIL and HINT parts Redshift appends to error messages.
+func splitMessage(msg string) (message, detail, hint string) {
+	message = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(msg), "ERROR:"))
+	if i := strings.Index(message, "HINT:"); i >= 0 {
+		hint = strings.TrimSpace(message[i+len("HINT:"):])
+		message = strings.TrimSpace(message[:i])
+	}
+	if i := strings.Index(message, "DETAIL:"); i >= 0 {
+		detail = strings.TrimSpace(message[i+len("DETAIL:"):])
+		message = strings.TrimSpace(message[:i])
+	}
+	return message, detail, hint
+}
+
+// sqlStates maps fragments of Redshift error messages to their SQLSTATE, the first match wins.
+var sqlStates = []struct {
+	fragment string
+	code     string
+}{
+	{"serializable isolation violation", "40001"},
+	{"deadlock detected", "40P01"},
+	{"current transaction is aborted", "25P02"},
+	{"cancelled on user's request", "57014"},
+	{"canceling statement", "57014"},
+	{"query cancelled", "57014"},
+	{"wlm abort", "57014"},
+	{"permission denied", "42501"},
+	{"syntax error", "42601"},
+	{"division by zero", "22012"},
+	{"divide by zero", "22012"},
+	{"value too long", "22001"},
+	{"numeric field overflow", "22003"},
+	{"out of range", "22003"},
+	{"invalid input syntax", "22P02"},
+	{"invalid digit", "22P02"},
+	{"invalid timestamp format", "22007"},
+	{"invalid date format", "22007"},
+	{"violates not-null constraint", "23502"},
+	{"duplicate key", "23505"},
+	{"relation", "42P01"},
+	{"column", "42703"},
+	{"function", "42883"},
+	{"schema", "3F000"},
+	{"database", "3D000"},
+	{"type", "42704"},
+	{"disk full", "53100"},
+	{"out of memory", "53200"},
+	{"not supported", "0A000"},
+}
+
+// SQLState derives the SQLSTATE of a Redshift error message. Messages without a known pattern map to XX000.
+func SQLState(message string) string {
+	lower := strings.ToLower(message)
+	for _, s := range sqlStates {
+		if !strings.Contains(lower, s.fragment) {
+			continue
+		}
+		switch s.code {
+		case "42P01", "42703", "42883", "3F000", "3D000", "42704":
+			// object errors are only recognized together with the verb naming the problem
+			if strings.Contains(lower, "already exists") {
+				if s.code == "42P01" {
+					return "42P07"
+				}
+				return "42710"
+			}
+			if !strings.Contains(lower, "does not exist") {
+				continue
+			}
+		}
+		return s.code
+	}
+	return "XX000"
+}
+
+// CorrelatedError annotates an error with the correlation ids of the statement that failed.
+type CorrelatedError struct {
+	Err           error
+	CorrelationID string
+	TraceID       string
+	SpanID        string
+}
+
+func (e *CorrelatedError) Error() string {
+	var ids []string
+	if e.CorrelationID != "" {
+		ids = append(ids, "correlation_id="+e.CorrelationID)
+	}
+	if e.TraceID != "" {
+		ids = append(ids, "trace_id="+e.TraceID)
+	}
+	if e.SpanID != "" {
+		ids = append(ids, "span_id="+e.SpanID)
+	}
+	return e.Err.Error() + " [" + strings.Join(ids, " ") + "]"
+}
+
+func (e *CorrelatedError) Unwrap() error {
+	return e.Err
+}
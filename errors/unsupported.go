@@ -0,0 +1,35 @@
+package errors
+
+// Limitation tells whether an unsupported feature is missing from the driver or from the platform.
+type Limitation string
+
+const (
+	LimitationDriver   Limitation = "metasql"               // LimitationDriver is a feature metasql does not implement.
+	LimitationPlatform Limitation = "the Redshift Data API" // LimitationPlatform is a feature Redshift or its Data API does not offer.
+)
+
+// UnsupportedError reports a feature that can not be used, and whether the driver or the platform lacks it.
+// It matches ErrNotSupported with errors.Is.
+type UnsupportedError struct {
+	Feature    string     // Feature names the unsupported feature, such as "prepared statements".
+	Limitation Limitation // Limitation tells who lacks the feature.
+	Hint       string     // Hint suggests an alternative, if there is one.
+}
+
+// NewUnsupportedError returns an UnsupportedError for the feature.
+func NewUnsupportedError(feature string, limitation Limitation, hint string) *UnsupportedError {
+	return &UnsupportedError{Feature: feature, Limitation: limitation, Hint: hint}
+}
+
+func (e *UnsupportedError) Error() string {
+	msg := e.Feature + " not supported by " + string(e.Limitation)
+	if e.Hint != "" {
+		msg += ": " + e.Hint
+	}
+	return msg
+}
+
+// Is reports whether target is ErrNotSupported.
+func (e *UnsupportedError) Is(target error) bool {
+	return target == ErrNotSupported
+}
@@ -0,0 +1,151 @@
+package metasql
+
+import (
+	"context"
+	"database/sql/driver"
+	"io"
+	"time"
+
+	cfg "github.com/adarsh-jaiss/metasql/config"
+	"github.com/adarsh-jaiss/metasql/hooks"
+	"github.com/adarsh-jaiss/metasql/utils"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/redshiftdata"
+)
+
+// Executor executes statements on the Redshift Data API without going through database/sql, for callers wanting
+// the statement id and statistics or reading large results without the per-row overhead of sql.Rows.
+// Statements run exactly as the connections of the driver run them: placeholders, parameters, hooks, quotas,
+// polling and cancellation behave the same.
+type Executor struct {
+	conn *redshiftDataConn
+}
+
+// NewExecutor returns an Executor using the provided RedshiftDataClient and RedshiftDataConfig.
+func NewExecutor(client RedshiftDataClient, cfg *cfg.RedshiftDataConfig) *Executor {
+	return &Executor{conn: NewConnection(client, cfg)}
+}
+
+// Execute runs the statement and waits for it to finish.
+// Arguments follow the database/sql conventions, sql.Named values bind named parameters.
+func (e *Executor) Execute(ctx context.Context, query string, args ...any) (*StatementHandle, error) {
+	namedArgs, err := namedValues(args, e.conn.cfg.ParameterEncoder)
+	if err != nil {
+		return nil, err
+	}
+	return e.conn.execute(ctx, hooks.KindQuery, query, namedArgs)
+}
+
+// Close cancels the statements still running and releases the executor.
+func (e *Executor) Close() error {
+	return e.conn.Close()
+}
+
+// StatementHandle is a finished statement returned by Executor.Execute.
+type StatementHandle struct {
+	conn *redshiftDataConn
+	desc *redshiftdata.DescribeStatementOutput
+	p    *redshiftdata.GetStatementResultPaginator
+}
+
+// execute runs the statement and returns its handle, the driver builds its rows and results from it.
+func (conn *redshiftDataConn) execute(ctx context.Context, kind hooks.Kind, query string, args []driver.NamedValue) (*StatementHandle, error) {
+	p, desc, err := conn.runStatement(ctx, kind, query, args)
+	if err != nil {
+		return nil, err
+	}
+	return &StatementHandle{conn: conn, desc: desc, p: p}, nil
+}
+
+// ID returns the id of the statement.
+func (h *StatementHandle) ID() string {
+	return utils.Coalesce(h.desc.Id)
+}
+
+// Stats returns the status and statistics reported by DescribeStatement when the statement finished.
+func (h *StatementHandle) Stats() *StatementStatus {
+	return statementStatus(h.desc)
+}
+
+// Rows returns the result set of the statement, decoded with the settings of the config and of ctx.
+// A statement without a result set has no rows.
+func (h *StatementHandle) Rows(ctx context.Context) (*StatementRows, error) {
+	rows, err := h.rows(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &StatementRows{rows: rows, values: make([]driver.Value, len(rows.columns))}, nil
+}
+
+func (h *StatementHandle) rows(ctx context.Context) (*redshiftDataRows, error) {
+	return h.conn.queryRows(ctx, h.desc, h.p)
+}
+
+// result returns the driver.Result of the statement.
+func (h *StatementHandle) result() driver.Result {
+	return newResult(h.desc)
+}
+
+// StatementRows iterates over the records of a result set, fetching its pages as they are needed.
+type StatementRows struct {
+	rows   *redshiftDataRows
+	values []driver.Value
+	err    error
+}
+
+// Columns returns the names of the columns of the result set.
+func (r *StatementRows) Columns() []string {
+	return r.rows.Columns()
+}
+
+// Next advances to the next record, it returns false at the end of the result set or on error, see Err.
+func (r *StatementRows) Next() bool {
+	if r.err != nil {
+		return false
+	}
+	r.err = r.rows.Next(r.values)
+	return r.err == nil
+}
+
+// Values returns the values of the current record. The slice is reused by Next, callers keeping it must copy it.
+func (r *StatementRows) Values() []driver.Value {
+	return r.values
+}
+
+// Err returns the error that stopped Next, nil when the end of the result set was reached.
+func (r *StatementRows) Err() error {
+	if r.err == io.EOF {
+		return nil
+	}
+	return r.err
+}
+
+// Size returns the size of the result set reported when the statement finished.
+func (r *StatementRows) Size() ResultSize {
+	return r.rows.SizeHint()
+}
+
+// Close releases the current page.
+func (r *StatementRows) Close() error {
+	return r.rows.Close()
+}
+
+// statementStatus converts the output of DescribeStatement.
+func statementStatus(desc *redshiftdata.DescribeStatementOutput) *StatementStatus {
+	status := &StatementStatus{
+		ID:           utils.Coalesce(desc.Id),
+		Status:       string(desc.Status),
+		Error:        utils.Coalesce(desc.Error),
+		Query:        utils.Coalesce(desc.QueryString),
+		HasResultSet: aws.ToBool(desc.HasResultSet),
+		ResultRows:   desc.ResultRows,
+		ResultSize:   desc.ResultSize,
+		Duration:     time.Duration(desc.Duration),
+		CreatedAt:    aws.ToTime(desc.CreatedAt),
+		UpdatedAt:    aws.ToTime(desc.UpdatedAt),
+	}
+	if status.Finished() {
+		status.QueueDuration, status.ExecDuration, _ = warehouseWait(desc)
+	}
+	return status
+}
@@ -0,0 +1,113 @@
+package metasql
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// Nullability is the expected nullability of a column in a ColumnSpec.
+type Nullability int
+
+const (
+	NullableAny Nullability = iota // NullableAny accepts any nullability, including an unknown one.
+	Nullable                       // Nullable expects a column that may hold nulls.
+	NotNull                        // NotNull expects a column that can not hold nulls.
+)
+
+// ColumnSpec describes a column expected by ExpectColumns.
+type ColumnSpec struct {
+	Name     string      // Name is the column name, compared case-insensitively.
+	Type     string      // Type is the database type name, such as integer or varchar. Empty accepts any type.
+	Nullable Nullability // Nullable is the expected nullability.
+}
+
+// ColumnMismatchError is returned by ExpectColumns, it lists every difference found.
+type ColumnMismatchError struct {
+	Mismatches []string
+}
+
+func (e *ColumnMismatchError) Error() string {
+	return "result columns do not match: " + strings.Join(e.Mismatches, "; ")
+}
+
+// typeAliases maps type names to the names Redshift reports in result metadata.
+var typeAliases = map[string]string{
+	"smallint":                    "int2",
+	"integer":                     "int4",
+	"int":                         "int4",
+	"bigint":                      "int8",
+	"real":                        "float4",
+	"double precision":            "float8",
+	"float":                       "float8",
+	"decimal":                     "numeric",
+	"boolean":                     "bool",
+	"character varying":           "varchar",
+	"nvarchar":                    "varchar",
+	"text":                        "varchar",
+	"character":                   "bpchar",
+	"char":                        "bpchar",
+	"nchar":                       "bpchar",
+	"timestamp without time zone": "timestamp",
+	"timestamp with time zone":    "timestamptz",
+	"time without time zone":      "time",
+	"time with time zone":         "timetz",
+	"varbinary":                   "varbyte",
+	"binary varying":              "varbyte",
+}
+
+func normalizeType(name string) string {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if i := strings.IndexByte(name, '('); i >= 0 {
+		name = strings.TrimSpace(name[:i])
+	}
+	if alias, ok := typeAliases[name]; ok {
+		return alias
+	}
+	return name
+}
+
+// ExpectColumns validates the columns of a result set against the specs, in order, so pipelines can
+// fail fast when an upstream schema drifts. It returns a *ColumnMismatchError listing every
+// difference in names, types and nullability.
+func ExpectColumns(rows *sql.Rows, specs []ColumnSpec) error {
+	types, err := rows.ColumnTypes()
+	if err != nil {
+		return err
+	}
+	var mismatches []string
+	if len(types) != len(specs) {
+		mismatches = append(mismatches, fmt.Sprintf("got %d columns, expected %d", len(types), len(specs)))
+	}
+	for i := 0; i < min(len(types), len(specs)); i++ {
+		got, spec := types[i], specs[i]
+		if !strings.EqualFold(got.Name(), spec.Name) {
+			mismatches = append(mismatches, fmt.Sprintf("column %d is %q, expected %q", i+1, got.Name(), spec.Name))
+		}
+		if spec.Type != "" && normalizeType(got.DatabaseTypeName()) != normalizeType(spec.Type) {
+			mismatches = append(mismatches, fmt.Sprintf("column %q has type %s, expected %s", got.Name(), strings.ToLower(got.DatabaseTypeName()), spec.Type))
+		}
+		if spec.Nullable == NullableAny {
+			continue
+		}
+		nullable, ok := got.Nullable()
+		switch {
+		case !ok:
+			mismatches = append(mismatches, fmt.Sprintf("column %q has an unknown nullability", got.Name()))
+		case nullable && spec.Nullable == NotNull:
+			mismatches = append(mismatches, fmt.Sprintf("column %q is nullable, expected not null", got.Name()))
+		case !nullable && spec.Nullable == Nullable:
+			mismatches = append(mismatches, fmt.Sprintf("column %q is not null, expected nullable", got.Name()))
+		}
+	}
+	for i := len(specs); i < len(types); i++ {
+		mismatches = append(mismatches, fmt.Sprintf("unexpected column %q", types[i].Name()))
+	}
+	for i := len(types); i < len(specs); i++ {
+		mismatches = append(mismatches, fmt.Sprintf("missing column %q", specs[i].Name))
+	}
+	if len(mismatches) > 0 {
+		return &ColumnMismatchError{Mismatches: mismatches}
+	}
+	return nil
+}
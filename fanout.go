@@ -0,0 +1,274 @@
+package metasql
+
+import (
+	"context"
+	"database/sql/driver"
+	stderrors "errors"
+	"fmt"
+	"io"
+	"slices"
+	"sync"
+
+	cfg "github.com/adarsh-jaiss/metasql/config"
+	"github.com/adarsh-jaiss/metasql/errors"
+)
+
+// DefaultFanOutSourceColumn is the name of the column holding the target name in fan-out results.
+const DefaultFanOutSourceColumn = "source"
+
+// FanOutTarget is one of the clusters or workgroups a fan-out query is executed against.
+type FanOutTarget struct {
+	Name   string                  // Name is reported in the source column of every row coming from this target.
+	Config *cfg.RedshiftDataConfig // Config is the configuration used to connect to the target.
+}
+
+// A fanOutConnector opens connections that run every statement against all of its targets concurrently.
+// Query results are merged into a single row stream with a leading source column naming the target of each row.
+type fanOutConnector struct {
+	d            *redshiftDataDriver
+	targets      []FanOutTarget
+	connectors   []*redshiftDataConnector // connectors holds the connector of each target, in the same order as the targets.
+	sourceColumn string
+}
+
+// NewFanOutConnector returns a driver.Connector fanning out statements to the given targets, to be used with sql.OpenDB.
+// The connector of each target is created once, so its caches are shared by all the connections.
+func NewFanOutConnector(targets ...FanOutTarget) *fanOutConnector {
+	connectors := make([]*redshiftDataConnector, len(targets))
+	for i, target := range targets {
+		connectors[i] = NewConnector(target.Config)
+	}
+	return &fanOutConnector{
+		d:            &redshiftDataDriver{},
+		targets:      targets,
+		connectors:   connectors,
+		sourceColumn: DefaultFanOutSourceColumn,
+	}
+}
+
+// WithSourceColumn sets the name of the column holding the target name and returns the updated connector.
+func (c *fanOutConnector) WithSourceColumn(name string) *fanOutConnector {
+	c.sourceColumn = name
+	return c
+}
+
+// Connect opens one connection per target. If any of them fails, the already opened ones are closed again.
+func (c *fanOutConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	if len(c.targets) == 0 {
+		return nil, errors.NewUnsupportedError("fan-out without targets", errors.LimitationDriver, "")
+	}
+	conn := &fanOutConn{
+		targets:      c.targets,
+		conns:        make([]driver.Conn, 0, len(c.targets)),
+		sourceColumn: c.sourceColumn,
+	}
+	for i, target := range c.targets {
+		tc, err := c.connectors[i].Connect(ctx)
+		if err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("fan-out target %q: %w", target.Name, err)
+		}
+		conn.conns = append(conn.conns, tc)
+	}
+	return conn, nil
+}
+
+func (c *fanOutConnector) Driver() driver.Driver {
+	return c.d
+}
+
+// fanOutConn holds one connection per target, in the same order as the targets.
+type fanOutConn struct {
+	targets      []FanOutTarget
+	conns        []driver.Conn
+	sourceColumn string
+}
+
+func (conn *fanOutConn) Prepare(query string) (driver.Stmt, error) {
+	return nil, errors.NewUnsupportedError("prepared statements", errors.LimitationPlatform, "execute the query directly")
+}
+
+// Begin is not supported, a transaction can not span several clusters or workgroups.
+func (conn *fanOutConn) Begin() (driver.Tx, error) {
+	return nil, errors.NewUnsupportedError("fan-out transactions", errors.LimitationDriver, "a transaction can not span several targets")
+}
+
+func (conn *fanOutConn) Close() error {
+	var errs []error
+	for _, c := range conn.conns {
+		errs = append(errs, c.Close())
+	}
+	return stderrors.Join(errs...)
+}
+
+// each calls fn concurrently for every target connection and waits for all of them.
+// The returned error names the first target that failed.
+func (conn *fanOutConn) each(fn func(i int, c driver.Conn) error) error {
+	errs := make([]error, len(conn.conns))
+	var wg sync.WaitGroup
+	for i, c := range conn.conns {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = fn(i, c)
+		}()
+	}
+	wg.Wait()
+	for i, err := range errs {
+		if err != nil {
+			return fmt.Errorf("fan-out target %q: %w", conn.targets[i].Name, err)
+		}
+	}
+	return nil
+}
+
+// QueryContext runs the query on every target and merges the rows in the order they are received.
+// All targets must return the same columns.
+func (conn *fanOutConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	results := make([]driver.Rows, len(conn.conns))
+	err := conn.each(func(i int, c driver.Conn) error {
+		rows, err := c.(driver.QueryerContext).QueryContext(withStatementToken(ctx, i), query, args)
+		results[i] = rows
+		return err
+	})
+	if err == nil {
+		err = conn.checkColumns(results)
+	}
+	if err != nil {
+		for _, rows := range results {
+			if rows != nil {
+				rows.Close()
+			}
+		}
+		return nil, err
+	}
+	return newFanOutRows(conn.targets, results, conn.sourceColumn), nil
+}
+
+func (conn *fanOutConn) checkColumns(results []driver.Rows) error {
+	columns := results[0].Columns()
+	for i, rows := range results[1:] {
+		if !slices.Equal(columns, rows.Columns()) {
+			return fmt.Errorf("fan-out target %q: columns %v do not match %v", conn.targets[i+1].Name, rows.Columns(), columns)
+		}
+	}
+	return nil
+}
+
+// ExecContext runs the statement on every target, the affected rows are summed up.
+func (conn *fanOutConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	affected := make([]int64, len(conn.conns))
+	err := conn.each(func(i int, c driver.Conn) error {
+		res, err := c.(driver.ExecerContext).ExecContext(withStatementToken(ctx, i), query, args)
+		if err != nil {
+			return err
+		}
+		affected[i], err = res.RowsAffected()
+		if stderrors.Is(err, errors.ErrRowsAffectedUnknown) {
+			affected[i], err = -1, nil
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	var total int64
+	for _, n := range affected {
+		if n < 0 {
+			// the total is unknown as soon as one target can not report its count
+			total = -1
+			break
+		}
+		total += n
+	}
+	return &redshiftDataResult{affectedRows: total}, nil
+}
+
+type fanOutRecord struct {
+	source string
+	values []driver.Value
+	err    error
+}
+
+// fanOutRows reads every target's rows in its own goroutine and hands the records over through a channel.
+type fanOutRows struct {
+	columns   []string
+	results   []driver.Rows
+	records   chan fanOutRecord
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+func newFanOutRows(targets []FanOutTarget, results []driver.Rows, sourceColumn string) *fanOutRows {
+	rows := &fanOutRows{
+		columns: append([]string{sourceColumn}, results[0].Columns()...),
+		results: results,
+		records: make(chan fanOutRecord),
+		done:    make(chan struct{}),
+	}
+	var wg sync.WaitGroup
+	for i, r := range results {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			rows.read(targets[i].Name, r)
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(rows.records)
+	}()
+	return rows
+}
+
+func (rows *fanOutRows) read(source string, r driver.Rows) {
+	for {
+		values := make([]driver.Value, len(rows.columns)-1)
+		err := r.Next(values)
+		if err == io.EOF {
+			return
+		}
+		if err != nil {
+			err = fmt.Errorf("fan-out target %q: %w", source, err)
+		}
+		select {
+		case rows.records <- fanOutRecord{source: source, values: values, err: err}:
+		case <-rows.done:
+			return
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+func (rows *fanOutRows) Columns() []string {
+	return rows.columns
+}
+
+func (rows *fanOutRows) Next(dest []driver.Value) error {
+	record, ok := <-rows.records
+	if !ok {
+		return io.EOF
+	}
+	if record.err != nil {
+		return record.err
+	}
+	dest[0] = record.source
+	copy(dest[1:], record.values)
+	return nil
+}
+
+// Close stops the readers and waits for them before closing the underlying rows.
+func (rows *fanOutRows) Close() error {
+	rows.closeOnce.Do(func() {
+		close(rows.done)
+	})
+	for range rows.records {
+	}
+	var errs []error
+	for _, r := range rows.results {
+		errs = append(errs, r.Close())
+	}
+	return stderrors.Join(errs...)
+}
@@ -0,0 +1,278 @@
+// Package gateway exposes the metasql async API over a small authenticated HTTP/JSON interface,
+// so services that are not written in Go can submit statements, poll them and page through results.
+//
+// Routes:
+//
+//	POST   /v1/statements                 submit {"sql": "...", "args": [...]}, returns {"id": "..."}
+//	GET    /v1/statements/{id}            statement status
+//	DELETE /v1/statements/{id}            cancel the statement
+//	GET    /v1/statements/{id}/result     one result page, ?next_token=... and ?format=json|csv
+//
+// Statements are only visible to the client that submitted them, see Handler.Client: the other ones get 404.
+package gateway
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/adarsh-jaiss/metasql"
+)
+
+// maxRequestBody is the largest request body accepted, in bytes.
+const maxRequestBody = 1 << 20
+
+// ownershipTTL is how long the client of a statement is remembered, the Data API keeps results for 24 hours.
+const ownershipTTL = 24 * time.Hour
+
+// Handler serves the gateway routes on top of a metasql.Async.
+type Handler struct {
+	async     *metasql.Async
+	mux       *http.ServeMux
+	owners    owners
+	Token     string                       // Token is the bearer token clients must send, requests are not authenticated when it is empty.
+	Authorize func(r *http.Request) bool   // Authorize replaces the bearer token check when set.
+	Client    func(r *http.Request) string // Client identifies the client of an authorized request, by a hash of its Authorization header when nil.
+}
+
+// NewHandler returns a Handler submitting statements through async.
+func NewHandler(async *metasql.Async) *Handler {
+	h := &Handler{
+		async:  async,
+		mux:    http.NewServeMux(),
+		owners: owners{clients: map[string]string{}},
+	}
+	h.mux.HandleFunc("POST /v1/statements", h.submit)
+	h.mux.HandleFunc("GET /v1/statements/{id}", h.status)
+	h.mux.HandleFunc("DELETE /v1/statements/{id}", h.cancel)
+	h.mux.HandleFunc("GET /v1/statements/{id}/result", h.result)
+	return h
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !h.authorized(r) {
+		writeError(w, http.StatusUnauthorized, fmt.Errorf("unauthorized"))
+		return
+	}
+	h.mux.ServeHTTP(w, r)
+}
+
+func (h *Handler) authorized(r *http.Request) bool {
+	if h.Authorize != nil {
+		return h.Authorize(r)
+	}
+	if h.Token == "" {
+		return true
+	}
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return ok && subtle.ConstantTimeCompare([]byte(token), []byte(h.Token)) == 1
+}
+
+// client returns the identity of the client of the request.
+func (h *Handler) client(r *http.Request) string {
+	if h.Client != nil {
+		return h.Client(r)
+	}
+	sum := sha256.Sum256([]byte(r.Header.Get("Authorization")))
+	return string(sum[:])
+}
+
+// owners records the client that submitted each statement, forgetting them after ownershipTTL.
+type owners struct {
+	mu      sync.Mutex
+	clients map[string]string // clients holds the client of each statement id.
+	order   []ownedStatement  // order holds the statements in the order they were submitted.
+}
+
+type ownedStatement struct {
+	id        string
+	submitted time.Time
+}
+
+func (o *owners) add(id, client string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	now := time.Now()
+	expired := 0
+	for expired < len(o.order) && now.Sub(o.order[expired].submitted) > ownershipTTL {
+		delete(o.clients, o.order[expired].id)
+		expired++
+	}
+	o.order = append(o.order[expired:], ownedStatement{id: id, submitted: now})
+	o.clients[id] = client
+}
+
+func (o *owners) owns(id, client string) bool {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	owner, ok := o.clients[id]
+	return ok && subtle.ConstantTimeCompare([]byte(owner), []byte(client)) == 1
+}
+
+// owned answers 404 unless the statement of the request was submitted by its client.
+func (h *Handler) owned(w http.ResponseWriter, r *http.Request) (string, bool) {
+	id := r.PathValue("id")
+	if !h.owners.owns(id, h.client(r)) {
+		writeError(w, http.StatusNotFound, fmt.Errorf("statement %s not found", id))
+		return "", false
+	}
+	return id, true
+}
+
+// submitRequest is the body of POST /v1/statements.
+type submitRequest struct {
+	SQL  string `json:"sql"`
+	Args []any  `json:"args"`
+}
+
+func (h *Handler) submit(w http.ResponseWriter, r *http.Request) {
+	var req submitRequest
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestBody))
+	dec.UseNumber()
+	if err := dec.Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid request body: %w", err))
+		return
+	}
+	for i, arg := range req.Args {
+		req.Args[i] = numbersAsText(arg)
+	}
+	if strings.TrimSpace(req.SQL) == "" {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("sql is required"))
+		return
+	}
+	id, err := h.async.Submit(r.Context(), req.SQL, req.Args...)
+	if err != nil {
+		writeError(w, http.StatusBadGateway, err)
+		return
+	}
+	h.owners.add(id, h.client(r))
+	writeJSON(w, http.StatusAccepted, map[string]string{"id": id})
+}
+
+// numbersAsText replaces the JSON numbers of an argument with their text, so large integers and exact decimals
+// are sent as they were written instead of going through float64.
+func numbersAsText(v any) any {
+	switch v := v.(type) {
+	case json.Number:
+		return v.String()
+	case []any:
+		for i, e := range v {
+			v[i] = numbersAsText(e)
+		}
+	}
+	return v
+}
+
+func (h *Handler) status(w http.ResponseWriter, r *http.Request) {
+	id, ok := h.owned(w, r)
+	if !ok {
+		return
+	}
+	status, err := h.async.Status(r.Context(), id)
+	if err != nil {
+		writeError(w, http.StatusBadGateway, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, status)
+}
+
+func (h *Handler) cancel(w http.ResponseWriter, r *http.Request) {
+	id, ok := h.owned(w, r)
+	if !ok {
+		return
+	}
+	if err := h.async.Cancel(r.Context(), id); err != nil {
+		writeError(w, http.StatusBadGateway, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) result(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	id, ok := h.owned(w, r)
+	if !ok {
+		return
+	}
+	if ok := h.checkFinished(ctx, w, id); !ok {
+		return
+	}
+	page, err := h.async.Result(ctx, id, r.URL.Query().Get("next_token"))
+	if err != nil {
+		writeError(w, http.StatusBadGateway, err)
+		return
+	}
+	switch format := r.URL.Query().Get("format"); format {
+	case "", "json":
+		writeJSON(w, http.StatusOK, page)
+	case "csv":
+		writeCSV(w, page)
+	default:
+		writeError(w, http.StatusBadRequest, fmt.Errorf("unknown format %q", format))
+	}
+}
+
+// checkFinished answers 409 while the statement is still running and 422 when it failed, so clients get a clear signal.
+func (h *Handler) checkFinished(ctx context.Context, w http.ResponseWriter, id string) bool {
+	status, err := h.async.Status(ctx, id)
+	if err != nil {
+		writeError(w, http.StatusBadGateway, err)
+		return false
+	}
+	if !status.Finished() {
+		writeError(w, http.StatusConflict, fmt.Errorf("statement is %s", strings.ToLower(status.Status)))
+		return false
+	}
+	if status.Status != "FINISHED" {
+		writeError(w, http.StatusUnprocessableEntity, fmt.Errorf("statement %s: %s", strings.ToLower(status.Status), status.Error))
+		return false
+	}
+	if !status.HasResultSet {
+		writeError(w, http.StatusNotFound, fmt.Errorf("statement has no result set"))
+		return false
+	}
+	return true
+}
+
+func writeJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, code int, err error) {
+	writeJSON(w, code, map[string]string{"error": err.Error()})
+}
+
+// writeCSV writes the page with a header line, the next token is reported in the X-Next-Token header.
+func writeCSV(w http.ResponseWriter, page *metasql.ResultPage) {
+	w.Header().Set("Content-Type", "text/csv")
+	if page.NextToken != "" {
+		w.Header().Set("X-Next-Token", page.NextToken)
+	}
+	w.WriteHeader(http.StatusOK)
+	cw := csv.NewWriter(w)
+	cw.Write(page.Columns)
+	record := make([]string, len(page.Columns))
+	for _, row := range page.Rows {
+		for i, v := range row {
+			switch v := v.(type) {
+			case nil:
+				record[i] = ""
+			case []byte:
+				record[i] = string(v)
+			default:
+				record[i] = fmt.Sprint(v)
+			}
+		}
+		cw.Write(record)
+	}
+	cw.Flush()
+}
@@ -0,0 +1,242 @@
+// Package grpcserver implements the metasql.v1.QueryService gRPC service on top of a metasql *sql.DB.
+// Rows are streamed to the client while the result pages are fetched, and cancelling the call
+// cancels the running statement through the driver.
+package grpcserver
+
+//go:generate protoc -I querypb --go_out=querypb --go_opt=paths=source_relative --go-grpc_out=querypb --go-grpc_opt=paths=source_relative query.proto
+
+import (
+	"context"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/adarsh-jaiss/metasql"
+	metasqlerrors "github.com/adarsh-jaiss/metasql/errors"
+	"github.com/adarsh-jaiss/metasql/grpcserver/querypb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// QueryTagMetadataKey is the gRPC metadata key carrying the query tag of a call.
+const QueryTagMetadataKey = "metasql-query-tag"
+
+type queryTagKey struct{}
+
+// QueryTag returns the query tag of the call ctx belongs to, or an empty string.
+func QueryTag(ctx context.Context) string {
+	tag, _ := ctx.Value(queryTagKey{}).(string)
+	return tag
+}
+
+// Server implements querypb.QueryServiceServer.
+type Server struct {
+	querypb.UnimplementedQueryServiceServer
+
+	DB *sql.DB // DB is the metasql database the statements are executed on.
+
+	// WithTag, when set, is called with the query tag of every call so it can be attached to the statement context.
+	// NewServer sets it to TagStatement.
+	WithTag func(ctx context.Context, tag string) context.Context
+}
+
+// NewServer returns a Server executing statements on db, tagged with TagStatement.
+func NewServer(db *sql.DB) *Server {
+	return &Server{
+		DB:      db,
+		WithTag: TagStatement,
+	}
+}
+
+// TagStatement submits the statements of ctx with tag as their StatementName and query label, so they can be found
+// with ListStatements, metasql.CancelByTag and, when QueryLabels is set, in the query history.
+func TagStatement(ctx context.Context, tag string) context.Context {
+	return metasql.WithQueryLabel(metasql.WithStatementName(ctx, tag), tag)
+}
+
+// TokenAuth returns the server options requiring every call to send token as a bearer token, in its
+// authorization metadata as "Bearer <token>", as the HTTP gateway does. Other calls fail with Unauthenticated.
+func TokenAuth(token string) []grpc.ServerOption {
+	check := func(ctx context.Context) error {
+		md, _ := metadata.FromIncomingContext(ctx)
+		for _, value := range md.Get("authorization") {
+			got, ok := strings.CutPrefix(value, "Bearer ")
+			if ok && subtle.ConstantTimeCompare([]byte(got), []byte(token)) == 1 {
+				return nil
+			}
+		}
+		return status.Error(codes.Unauthenticated, "unauthenticated")
+	}
+	return []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(func(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+			if err := check(ctx); err != nil {
+				return nil, err
+			}
+			return handler(ctx, req)
+		}),
+		grpc.ChainStreamInterceptor(func(srv any, ss grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+			if err := check(ss.Context()); err != nil {
+				return err
+			}
+			return handler(srv, ss)
+		}),
+	}
+}
+
+// Register registers the service on s.
+func (srv *Server) Register(s *grpc.Server) {
+	querypb.RegisterQueryServiceServer(s, srv)
+}
+
+func (srv *Server) context(ctx context.Context) context.Context {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ctx
+	}
+	tags := md.Get(QueryTagMetadataKey)
+	if len(tags) == 0 {
+		return ctx
+	}
+	ctx = context.WithValue(ctx, queryTagKey{}, tags[0])
+	if srv.WithTag != nil {
+		ctx = srv.WithTag(ctx, tags[0])
+	}
+	return ctx
+}
+
+// Query runs the statement and streams a header followed by one response per row.
+func (srv *Server) Query(req *querypb.QueryRequest, stream querypb.QueryService_QueryServer) error {
+	ctx := srv.context(stream.Context())
+	rows, err := srv.DB.QueryContext(ctx, req.GetSql(), args(req.GetArgs())...)
+	if err != nil {
+		return toStatus(ctx, err)
+	}
+	defer rows.Close()
+
+	types, err := rows.ColumnTypes()
+	if err != nil {
+		return toStatus(ctx, err)
+	}
+	header := &querypb.Header{Columns: make([]*querypb.Column, 0, len(types))}
+	for _, t := range types {
+		header.Columns = append(header.Columns, &querypb.Column{
+			Name:         t.Name(),
+			DatabaseType: t.DatabaseTypeName(),
+		})
+	}
+	if err := stream.Send(&querypb.QueryResponse{Payload: &querypb.QueryResponse_Header{Header: header}}); err != nil {
+		return err
+	}
+
+	values := make([]any, len(types))
+	dest := make([]any, len(types))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	for rows.Next() {
+		if err := rows.Scan(dest...); err != nil {
+			return toStatus(ctx, err)
+		}
+		row := &querypb.Row{Values: make([]*structpb.Value, 0, len(values))}
+		for _, v := range values {
+			pv, err := toValue(v)
+			if err != nil {
+				return status.Error(codes.Internal, err.Error())
+			}
+			row.Values = append(row.Values, pv)
+		}
+		if err := stream.Send(&querypb.QueryResponse{Payload: &querypb.QueryResponse_Row{Row: row}}); err != nil {
+			return err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return toStatus(ctx, err)
+	}
+	return nil
+}
+
+// Exec runs the statement and reports the affected rows.
+func (srv *Server) Exec(ctx context.Context, req *querypb.QueryRequest) (*querypb.ExecResponse, error) {
+	ctx = srv.context(ctx)
+	res, err := srv.DB.ExecContext(ctx, req.GetSql(), args(req.GetArgs())...)
+	if err != nil {
+		return nil, toStatus(ctx, err)
+	}
+	n, err := res.RowsAffected()
+	if errors.Is(err, metasqlerrors.ErrRowsAffectedUnknown) {
+		n, err = -1, nil
+	}
+	if err != nil {
+		return nil, toStatus(ctx, err)
+	}
+	return &querypb.ExecResponse{RowsAffected: n}, nil
+}
+
+// maxExactInt is the largest integer a protobuf number, a float64, holds exactly.
+const maxExactInt = 1 << 53
+
+func args(values []*structpb.Value) []any {
+	if len(values) == 0 {
+		return nil
+	}
+	args := make([]any, 0, len(values))
+	for _, v := range values {
+		args = append(args, fromValue(v))
+	}
+	return args
+}
+
+// fromValue converts an argument, integral numbers are passed as int64 so they are not sent as floats.
+func fromValue(v *structpb.Value) any {
+	switch k := v.GetKind().(type) {
+	case *structpb.Value_NumberValue:
+		if n := k.NumberValue; n == math.Trunc(n) && math.Abs(n) <= maxExactInt {
+			return int64(n)
+		}
+		return k.NumberValue
+	case *structpb.Value_ListValue:
+		list := make([]any, 0, len(k.ListValue.GetValues()))
+		for _, e := range k.ListValue.GetValues() {
+			list = append(list, fromValue(e))
+		}
+		return list
+	}
+	return v.AsInterface()
+}
+
+// toValue converts a scanned value. The values a protobuf number can not hold exactly, NUMERIC decimals and
+// integers beyond 2^53, are sent as strings, like timestamps and SUPER documents.
+func toValue(v any) (*structpb.Value, error) {
+	switch v := v.(type) {
+	case time.Time:
+		return structpb.NewStringValue(v.Format(time.RFC3339Nano)), nil
+	case metasql.Decimal:
+		return structpb.NewStringValue(v.String()), nil
+	case json.RawMessage:
+		return structpb.NewStringValue(string(v)), nil
+	case int64:
+		if v > maxExactInt || v < -maxExactInt {
+			return structpb.NewStringValue(strconv.FormatInt(v, 10)), nil
+		}
+	}
+	return structpb.NewValue(v)
+}
+
+// toStatus maps context errors to their gRPC codes, every other error is reported as Unknown.
+func toStatus(ctx context.Context, err error) error {
+	switch ctx.Err() {
+	case context.Canceled:
+		return status.Error(codes.Canceled, err.Error())
+	case context.DeadlineExceeded:
+		return status.Error(codes.DeadlineExceeded, err.Error())
+	}
+	return status.Error(codes.Unknown, err.Error())
+}
@@ -0,0 +1,270 @@
+// Package hooks defines callbacks the driver invokes around the statements it executes.
+// Hooks are registered on the config with RedshiftDataConfig.WithHooks, every callback is optional.
+package hooks
+
+import (
+	"context"
+	"database/sql/driver"
+	"time"
+)
+
+// Kind tells which database/sql entry point a statement came from.
+type Kind string
+
+const (
+	KindQuery Kind = "query" // KindQuery is a statement executed through QueryContext.
+	KindExec  Kind = "exec"  // KindExec is a statement executed through ExecContext.
+	KindPing  Kind = "ping"  // KindPing is the statement executed by PingContext.
+)
+
+// ConnInfo identifies the pooled connection a statement was executed on.
+type ConnInfo struct {
+	ID        string    // ID is unique among the connections of the process.
+	CreatedAt time.Time // CreatedAt is the time the connection was opened.
+}
+
+// StatementEvent describes one statement executed through the Data API.
+// The fields after Start are filled in once the statement finished.
+type StatementEvent struct {
+	Kind  Kind                // Kind is the entry point the statement came from.
+	Query string              // Query is the SQL as given by the caller, before placeholders are rewritten.
+	Args  []driver.NamedValue // Args are the arguments bound to the query.
+	Start time.Time           // Start is the time the statement was submitted.
+	Conn  ConnInfo            // Conn is the connection executing the statement.
+
+	Correlation Correlation // Correlation ties the statement to the trace of the caller.
+	Label       string      // Label is the label injected into the statement, empty when labels are disabled.
+
+	StatementID   string        // StatementID is the Data API statement id, empty when the submission failed.
+	ClientToken   string        // ClientToken is the idempotency token the statement was submitted with, see metasql.WithClientToken.
+	Duration      time.Duration // Duration is the time from submission until the statement finished.
+	QueueDuration time.Duration // QueueDuration is the time the statement waited on the warehouse before it ran.
+	ExecDuration  time.Duration // ExecDuration is the execution time reported by the Data API.
+	HasResultSet  bool          // HasResultSet reports whether the statement returned rows.
+	ResultRows    int64         // ResultRows is the number of rows returned or affected.
+	Err           error         // Err is the error the statement failed with, if any.
+}
+
+// Correlation ties a statement to the trace of the caller.
+type Correlation struct {
+	ID      string // ID is the correlation id set with WithCorrelationID.
+	TraceID string // TraceID is the trace id of the caller, filled in by Correlate hooks.
+	SpanID  string // SpanID is the span id of the caller, filled in by Correlate hooks.
+}
+
+// IsZero reports whether the correlation holds no id at all.
+func (c Correlation) IsZero() bool {
+	return c == Correlation{}
+}
+
+type correlationIDKey struct{}
+
+// WithCorrelationID returns a context carrying a correlation id for the statements executed with it.
+func WithCorrelationID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, correlationIDKey{}, id)
+}
+
+// APICallEvent describes one Data API operation, including its retries.
+type APICallEvent struct {
+	Operation   string        // Operation is the API operation name, such as ExecuteStatement.
+	Start       time.Time     // Start is the time the operation was invoked.
+	Duration    time.Duration // Duration is the time the operation took, retries and backoff included.
+	Attempts    int           // Attempts is the number of requests sent, 1 when the first one succeeded.
+	RequestID   string        // RequestID is the AWS request id of the last attempt, if any.
+	Correlation Correlation   // Correlation ties the operation to the trace of the caller.
+	Err         error         // Err is the error the operation failed with, if any.
+}
+
+// WarehouseWaitEvent describes a statement that waited on the warehouse before it ran, as when a serverless
+// workgroup resumes from a cold start or a concurrency scaling cluster is added.
+type WarehouseWaitEvent struct {
+	Query       string        // Query is the SQL as given by the caller.
+	StatementID string        // StatementID is the Data API statement id.
+	Conn        ConnInfo      // Conn is the connection executing the statement.
+	Correlation Correlation   // Correlation ties the statement to the trace of the caller.
+	Serverless  bool          // Serverless reports whether the statement ran on a serverless workgroup.
+	Wait        time.Duration // Wait is the time the statement spent on the warehouse before it executed.
+	Execution   time.Duration // Execution is the time the statement took to execute once it started.
+}
+
+// ProgressEvent reports how far a running COPY or UNLOAD statement got.
+type ProgressEvent struct {
+	Operation   string      // Operation is COPY or UNLOAD.
+	Query       string      // Query is the SQL of the statement as submitted.
+	StatementID string      // StatementID is the Data API statement id.
+	Conn        ConnInfo    // Conn is the connection executing the statement.
+	Correlation Correlation // Correlation ties the statement to the trace of the caller.
+	Percent     float64     // Percent is the completion in percent, -1 when the warehouse does not report it.
+	Bytes       int64       // Bytes is the number of bytes loaded or unloaded so far.
+	TotalBytes  int64       // TotalBytes is the number of bytes to load, 0 when unknown.
+	Files       int64       // Files is the number of files written so far by an UNLOAD.
+}
+
+// CheckpointEvent marks a position in the result set of a query that fetching can be resumed from with
+// metasql.WithResume, for instance after an exporter crashed.
+type CheckpointEvent struct {
+	StatementID string   // StatementID is the Data API statement id of the query.
+	NextToken   string   // NextToken is the token of the page following the records returned so far.
+	Rows        int64    // Rows is the number of records returned so far by the rows, excluding resumed ones.
+	Conn        ConnInfo // Conn is the connection the query was executed on.
+}
+
+// TxEvent describes the commit or rollback of a transaction. The statements of a transaction are buffered
+// by the driver and submitted together when it commits, unless the transaction runs in a session.
+type TxEvent struct {
+	Statements  []string    // Statements are the statements of the transaction, in order.
+	Conn        ConnInfo    // Conn is the connection of the transaction.
+	Correlation Correlation // Correlation ties the transaction to the trace of the caller.
+
+	StatementID string        // StatementID is the Data API statement id of the commit, empty when nothing was submitted.
+	Duration    time.Duration // Duration is the time the commit took, serialization retries included.
+	Err         error         // Err is the error that rolled back a failed commit, nil for an explicit rollback.
+}
+
+// Target is the database a statement runs against, initialized from the config.
+type Target struct {
+	Database  string // Database is the name of the database.
+	SecretArn string // SecretArn is the ARN of the secret holding the credentials, empty when not used.
+}
+
+// Hooks is a set of callbacks on driver events. Any of them may be nil.
+// Callbacks run synchronously on the goroutine executing the statement and must be safe for concurrent use.
+type Hooks struct {
+	// BeforeStatement is called before a statement is submitted. The returned context is used for the statement.
+	BeforeStatement func(ctx context.Context, e *StatementEvent) context.Context
+	// AfterStatement is called once the statement finished, failed or was cancelled.
+	AfterStatement func(ctx context.Context, e *StatementEvent)
+	// Route may change the target of a statement, for instance to route tenants to their own database.
+	// Returning an error fails the statement without submitting it.
+	Route func(ctx context.Context, target *Target) error
+	// AfterAPICall is called after every Data API operation, when API metrics are enabled on the config.
+	AfterAPICall func(ctx context.Context, e *APICallEvent)
+	// Correlate fills in the trace and span ids of the caller found in the context, for instance the
+	// ones of the OpenTelemetry span context.
+	Correlate func(ctx context.Context, c *Correlation)
+	// WarehouseWait is called after a statement that waited on the warehouse for at least the configured
+	// warehouse wait threshold, before AfterStatement.
+	WarehouseWait func(ctx context.Context, e *WarehouseWaitEvent)
+	// Progress is called periodically while a COPY or UNLOAD statement runs, when a progress interval is
+	// configured. It runs on a separate goroutine.
+	Progress func(ctx context.Context, e *ProgressEvent)
+	// Checkpoint is called whenever every record of a fetched result page was returned, before the next page
+	// is fetched.
+	Checkpoint func(ctx context.Context, e *CheckpointEvent)
+	// BeforeCommit is called before the statements of a transaction are submitted. Returning an error fails
+	// the commit without submitting them.
+	BeforeCommit func(ctx context.Context, e *TxEvent) error
+	// AfterCommit is called once the statements of a transaction were committed.
+	AfterCommit func(ctx context.Context, e *TxEvent)
+	// AfterRollback is called after a transaction was rolled back, explicitly or because its commit failed.
+	AfterRollback func(ctx context.Context, e *TxEvent)
+}
+
+// BeforeStatement calls the BeforeStatement callback of every hook in order.
+func BeforeStatement(ctx context.Context, hooks []*Hooks, e *StatementEvent) context.Context {
+	for _, h := range hooks {
+		if h != nil && h.BeforeStatement != nil {
+			ctx = h.BeforeStatement(ctx, e)
+		}
+	}
+	return ctx
+}
+
+// AfterStatement calls the AfterStatement callback of every hook in order.
+func AfterStatement(ctx context.Context, hooks []*Hooks, e *StatementEvent) {
+	for _, h := range hooks {
+		if h != nil && h.AfterStatement != nil {
+			h.AfterStatement(ctx, e)
+		}
+	}
+}
+
+// Route calls the Route callback of every hook in order, stopping at the first error.
+func Route(ctx context.Context, hooks []*Hooks, target *Target) error {
+	for _, h := range hooks {
+		if h != nil && h.Route != nil {
+			if err := h.Route(ctx, target); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// AfterAPICall calls the AfterAPICall callback of every hook in order.
+func AfterAPICall(ctx context.Context, hooks []*Hooks, e *APICallEvent) {
+	for _, h := range hooks {
+		if h != nil && h.AfterAPICall != nil {
+			h.AfterAPICall(ctx, e)
+		}
+	}
+}
+
+// Correlate returns the correlation id of the context completed by the Correlate callback of every hook.
+func Correlate(ctx context.Context, hooks []*Hooks) Correlation {
+	var c Correlation
+	c.ID, _ = ctx.Value(correlationIDKey{}).(string)
+	for _, h := range hooks {
+		if h != nil && h.Correlate != nil {
+			h.Correlate(ctx, &c)
+		}
+	}
+	return c
+}
+
+// WarehouseWait calls the WarehouseWait callback of every hook in order.
+func WarehouseWait(ctx context.Context, hooks []*Hooks, e *WarehouseWaitEvent) {
+	for _, h := range hooks {
+		if h != nil && h.WarehouseWait != nil {
+			h.WarehouseWait(ctx, e)
+		}
+	}
+}
+
+// Progress calls the Progress callback of every hook in order.
+func Progress(ctx context.Context, hooks []*Hooks, e *ProgressEvent) {
+	for _, h := range hooks {
+		if h != nil && h.Progress != nil {
+			h.Progress(ctx, e)
+		}
+	}
+}
+
+// Checkpoint calls the Checkpoint callback of every hook in order.
+func Checkpoint(ctx context.Context, hooks []*Hooks, e *CheckpointEvent) {
+	for _, h := range hooks {
+		if h != nil && h.Checkpoint != nil {
+			h.Checkpoint(ctx, e)
+		}
+	}
+}
+
+// BeforeCommit calls the BeforeCommit callback of every hook in order, stopping at the first error.
+func BeforeCommit(ctx context.Context, hooks []*Hooks, e *TxEvent) error {
+	for _, h := range hooks {
+		if h != nil && h.BeforeCommit != nil {
+			if err := h.BeforeCommit(ctx, e); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// AfterCommit calls the AfterCommit callback of every hook in order.
+func AfterCommit(ctx context.Context, hooks []*Hooks, e *TxEvent) {
+	for _, h := range hooks {
+		if h != nil && h.AfterCommit != nil {
+			h.AfterCommit(ctx, e)
+		}
+	}
+}
+
+// AfterRollback calls the AfterRollback callback of every hook in order.
+func AfterRollback(ctx context.Context, hooks []*Hooks, e *TxEvent) {
+	for _, h := range hooks {
+		if h != nil && h.AfterRollback != nil {
+			h.AfterRollback(ctx, e)
+		}
+	}
+}
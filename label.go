@@ -0,0 +1,92 @@
+package metasql
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	cfg "github.com/adarsh-jaiss/metasql/config"
+)
+
+// labelPrefix starts the label comment injected into statements.
+const labelPrefix = "metasql:"
+
+type queryLabelKey struct{}
+
+// WithQueryLabel returns a context whose statements are labeled with label, such as the id of the
+// request being served, instead of a generated one. Labels are only injected when QueryLabels is set on the config.
+func WithQueryLabel(ctx context.Context, label string) context.Context {
+	return context.WithValue(ctx, queryLabelKey{}, label)
+}
+
+// labelQuery prefixes the query with its label comment when labels are enabled, it returns the label, empty otherwise.
+func labelQuery(ctx context.Context, config *cfg.RedshiftDataConfig, query string) (string, string) {
+	if !config.QueryLabels {
+		return query, ""
+	}
+	label, _ := ctx.Value(queryLabelKey{}).(string)
+	if label == "" {
+		label = newUUID()
+	}
+	label = strings.ReplaceAll(label, "*/", "")
+	return "/* " + labelPrefix + label + " */ " + query, label
+}
+
+// newUUID returns a random version 4 UUID.
+func newUUID() string {
+	var b [16]byte
+	rand.Read(b[:])
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	h := hex.EncodeToString(b[:])
+	return h[:8] + "-" + h[8:12] + "-" + h[12:16] + "-" + h[16:20] + "-" + h[20:]
+}
+
+// QueryHistory is an entry of sys_query_history.
+type QueryHistory struct {
+	QueryID   int64         // QueryID is the Redshift query id.
+	Status    string        // Status is the status of the query, such as success or failed.
+	StartTime time.Time     // StartTime is the time the query started.
+	EndTime   time.Time     // EndTime is the time the query ended, zero while it runs.
+	Elapsed   time.Duration // Elapsed is the time the query took.
+	Text      string        // Text is the first 4000 characters of the query.
+}
+
+// FindQueryByLabel looks up the queries labeled with label in sys_query_history, oldest first.
+// The history is only visible to the user who ran the queries, unless querying as a superuser.
+func FindQueryByLabel(ctx context.Context, db *sql.DB, label string) ([]QueryHistory, error) {
+	rows, err := db.QueryContext(withoutClientToken(ctx), `SELECT query_id, status, start_time, end_time, elapsed_time, query_text FROM sys_query_history WHERE query_text LIKE ? ORDER BY start_time`,
+		"%/* "+labelPrefix+escapeLike(label)+" */%")
+	if err != nil {
+		return nil, fmt.Errorf("find query by label: %w", err)
+	}
+	defer rows.Close()
+	var history []QueryHistory
+	for rows.Next() {
+		var h QueryHistory
+		var start, end sql.NullString
+		var elapsed sql.NullInt64
+		if err := rows.Scan(&h.QueryID, &h.Status, &start, &end, &elapsed, &h.Text); err != nil {
+			return nil, err
+		}
+		h.Status = strings.TrimSpace(h.Status)
+		if start.Valid {
+			h.StartTime, _ = time.Parse(timestampLayout, start.String)
+		}
+		if end.Valid {
+			h.EndTime, _ = time.Parse(timestampLayout, end.String)
+		}
+		h.Elapsed = time.Duration(elapsed.Int64) * time.Microsecond
+		history = append(history, h)
+	}
+	return history, rows.Err()
+}
+
+// escapeLike escapes the LIKE wildcards of s.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
@@ -0,0 +1,196 @@
+package metasql
+
+import (
+	"strconv"
+	"unicode"
+)
+
+// tokenKind is the kind of a token of a SQL statement.
+type tokenKind int
+
+const (
+	tokenSpace        tokenKind = iota // tokenSpace is a run of white space.
+	tokenComment                       // tokenComment is a -- line comment or a /* */ block comment.
+	tokenString                        // tokenString is a '' quoted string, E'' escaped strings included.
+	tokenDollarString                  // tokenDollarString is a $tag$ dollar-quoted string.
+	tokenIdentifier                    // tokenIdentifier is a "" quoted identifier.
+	tokenWord                          // tokenWord is a keyword or an unquoted identifier, which can contain $.
+	tokenNumber                        // tokenNumber is a numeric literal.
+	tokenCast                          // tokenCast is the :: cast operator.
+	tokenPlaceholder                   // tokenPlaceholder is a ?, $N, :N, $name or :name placeholder.
+	tokenOther                         // tokenOther is any other rune: operators and punctuation.
+)
+
+// sqlToken is a token of a SQL statement, runes[start:end] of the statement.
+type sqlToken struct {
+	kind       tokenKind
+	start, end int
+	n          int    // n is the number of a $N or :N placeholder, 0 for ? and named placeholders.
+	name       string // name is the name of a $name or :name placeholder.
+}
+
+// tokenize splits a SQL statement into tokens. Every rune of the statement belongs to exactly one token, so the
+// statement can be rebuilt from its tokens. Unterminated strings and comments run to the end of the statement.
+//
+// As in Redshift, a backslash escapes the next rune of a quoted string, two single quotes are a quote inside a
+// string and two double quotes a double quote inside a quoted identifier. Block comments nest as in Postgres.
+func tokenize(runes []rune) []sqlToken {
+	var tokens []sqlToken
+	for i := 0; i < len(runes); {
+		tok := sqlToken{kind: tokenOther, start: i}
+		r := runes[i]
+		next := rune(0)
+		if i+1 < len(runes) {
+			next = runes[i+1]
+		}
+		switch {
+		case unicode.IsSpace(r):
+			tok.kind = tokenSpace
+			tok.end = scanWhile(runes, i, unicode.IsSpace)
+		case r == '-' && next == '-':
+			tok.kind = tokenComment
+			tok.end = scanWhile(runes, i, func(r rune) bool { return r != '\n' })
+		case r == '/' && next == '*':
+			tok.kind = tokenComment
+			tok.end = scanBlockComment(runes, i)
+		case r == '\'':
+			tok.kind = tokenString
+			tok.end = scanQuoted(runes, i, '\'', true)
+		case (r == 'E' || r == 'e') && next == '\'':
+			tok.kind = tokenString
+			tok.end = scanQuoted(runes, i+1, '\'', true)
+		case r == '"':
+			tok.kind = tokenIdentifier
+			tok.end = scanQuoted(runes, i, '"', false)
+		case r == '_' || unicode.IsLetter(r):
+			tok.kind = tokenWord
+			tok.end = scanWhile(runes, i, isIdentifierRune)
+		case unicode.IsDigit(r) || r == '.' && unicode.IsDigit(next):
+			tok.kind = tokenNumber
+			tok.end = scanNumber(runes, i)
+		case r == ':' && next == ':':
+			tok.kind = tokenCast
+			tok.end = i + 2
+		case r == '?':
+			tok.kind = tokenPlaceholder
+			tok.end = i + 1
+		case r == '$':
+			if tag, ok := dollarQuoteTag(runes[i:]); ok {
+				tok.kind = tokenDollarString
+				tok.end = scanDollarQuoted(runes, i, tag)
+				break
+			}
+			tok = scanParameter(runes, i)
+		case r == ':':
+			tok = scanParameter(runes, i)
+		default:
+			tok.end = i + 1
+		}
+		tokens = append(tokens, tok)
+		i = tok.end
+	}
+	return tokens
+}
+
+// scanWhile returns the index of the first rune from i on not accepted by ok.
+func scanWhile(runes []rune, i int, ok func(rune) bool) int {
+	for i < len(runes) && ok(runes[i]) {
+		i++
+	}
+	return i
+}
+
+// scanBlockComment returns the index following the block comment opened at i.
+func scanBlockComment(runes []rune, i int) int {
+	depth := 0
+	for i < len(runes) {
+		switch {
+		case runes[i] == '/' && i+1 < len(runes) && runes[i+1] == '*':
+			depth++
+			i += 2
+		case runes[i] == '*' && i+1 < len(runes) && runes[i+1] == '/':
+			depth--
+			i += 2
+			if depth == 0 {
+				return i
+			}
+		default:
+			i++
+		}
+	}
+	return i
+}
+
+// scanQuoted returns the index following the string or identifier opened by quote at i. A doubled quote is a
+// quote of the string, backslashes escape the next rune when escapes is set.
+func scanQuoted(runes []rune, i int, quote rune, escapes bool) int {
+	for i++; i < len(runes); i++ {
+		switch {
+		case escapes && runes[i] == '\\':
+			i++
+		case runes[i] == quote && i+1 < len(runes) && runes[i+1] == quote:
+			i++
+		case runes[i] == quote:
+			return i + 1
+		}
+	}
+	return len(runes)
+}
+
+// scanNumber returns the index following the numeric literal starting at i, exponent included.
+func scanNumber(runes []rune, i int) int {
+	i = scanWhile(runes, i, func(r rune) bool { return unicode.IsDigit(r) || r == '.' })
+	if i+1 < len(runes) && (runes[i] == 'e' || runes[i] == 'E') {
+		j := i + 1
+		if runes[j] == '+' || runes[j] == '-' {
+			j++
+		}
+		if j < len(runes) && unicode.IsDigit(runes[j]) {
+			i = scanWhile(runes, j, unicode.IsDigit)
+		}
+	}
+	return i
+}
+
+// scanParameter scans the $N, :N, $name or :name placeholder at i. A $ or : followed by neither a number nor a
+// name is a single rune token.
+func scanParameter(runes []rune, i int) sqlToken {
+	if j := scanWhile(runes, i+1, unicode.IsDigit); j > i+1 {
+		n, _ := strconv.Atoi(string(runes[i+1 : j]))
+		return sqlToken{kind: tokenPlaceholder, start: i, end: j, n: n}
+	}
+	if i+1 < len(runes) && (runes[i+1] == '_' || unicode.IsLetter(runes[i+1])) {
+		j := scanWhile(runes, i+1, func(r rune) bool { return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r) })
+		return sqlToken{kind: tokenPlaceholder, start: i, end: j, name: string(runes[i+1 : j])}
+	}
+	return sqlToken{kind: tokenOther, start: i, end: i + 1}
+}
+
+func isIdentifierRune(r rune) bool {
+	return r == '_' || r == '$' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// dollarQuoteTag returns the opening $tag$ of a dollar-quoted string starting runes.
+func dollarQuoteTag(runes []rune) (string, bool) {
+	for j := 1; j < len(runes); j++ {
+		switch r := runes[j]; {
+		case r == '$':
+			return string(runes[:j+1]), true
+		case r == '_' || unicode.IsLetter(r) || j > 1 && unicode.IsDigit(r):
+		default:
+			return "", false
+		}
+	}
+	return "", false
+}
+
+// scanDollarQuoted returns the index following the dollar-quoted string opened by tag at i.
+func scanDollarQuoted(runes []rune, i int, tag string) int {
+	closing := []rune(tag)
+	for j := i + len(closing); j+len(closing) <= len(runes); j++ {
+		if string(runes[j:j+len(closing)]) == tag {
+			return j + len(closing)
+		}
+	}
+	return len(runes)
+}
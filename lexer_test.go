@@ -0,0 +1,95 @@
+package metasql
+
+import (
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+var tokenKindNames = map[tokenKind]string{
+	tokenSpace:        "space",
+	tokenComment:      "comment",
+	tokenString:       "string",
+	tokenDollarString: "dollar",
+	tokenIdentifier:   "ident",
+	tokenWord:         "word",
+	tokenNumber:       "number",
+	tokenCast:         "cast",
+	tokenPlaceholder:  "param",
+	tokenOther:        "other",
+}
+
+// describeTokens renders the tokens other than spaces as kind:text, placeholders with their number or name.
+func describeTokens(runes []rune, tokens []sqlToken) []string {
+	var got []string
+	for _, tok := range tokens {
+		if tok.kind == tokenSpace {
+			continue
+		}
+		s := tokenKindNames[tok.kind] + ":" + string(runes[tok.start:tok.end])
+		switch {
+		case tok.n > 0:
+			s += "#" + strconv.Itoa(tok.n)
+		case tok.name != "":
+			s += "#" + tok.name
+		}
+		got = append(got, s)
+	}
+	return got
+}
+
+func TestTokenize(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		want  []string
+	}{
+		{"placeholders", "a = ? AND b = $2 OR c = :3 OR d = :id OR e = $name", []string{
+			"word:a", "other:=", "param:?", "word:AND", "word:b", "other:=", "param:$2#2", "word:OR",
+			"word:c", "other:=", "param::3#3", "word:OR", "word:d", "other:=", "param::id#id", "word:OR",
+			"word:e", "other:=", "param:$name#name",
+		}},
+		{"dollar quoted", "$$ it's $1 $$", []string{"dollar:$$ it's $1 $$"}},
+		{"tagged dollar quoted", "$fn$ a $$ b $ c $fn$, $1", []string{"dollar:$fn$ a $$ b $ c $fn$", "other:,", "param:$1#1"}},
+		{"dollar quoted with digits", "$a1$x$a1$", []string{"dollar:$a1$x$a1$"}},
+		{"dollar digit is a placeholder", "$1$", []string{"param:$1#1", "other:$"}},
+		{"unterminated dollar quoted", "$q$ $1", []string{"dollar:$q$ $1"}},
+		{"line comment", "a -- b ?\nc", []string{"word:a", "comment:-- b ?", "word:c"}},
+		{"block comment", "a /* ? */ b", []string{"word:a", "comment:/* ? */", "word:b"}},
+		{"nested comments", "/* a /* b */ ? */ $1", []string{"comment:/* a /* b */ ? */", "param:$1#1"}},
+		{"unterminated comment", "/* a /* b */ $1", []string{"comment:/* a /* b */ $1"}},
+		{"cast", "$1::int", []string{"param:$1#1", "cast:::", "word:int"}},
+		{"cast of a column", "a::text", []string{"word:a", "cast:::", "word:text"}},
+		{"cast of a literal", "'1'::date::timestamp", []string{"string:'1'", "cast:::", "word:date", "cast:::", "word:timestamp"}},
+		{"lone colon", "a : b", []string{"word:a", "other::", "word:b"}},
+		{"doubled quote", "'it''s ?'", []string{"string:'it''s ?'"}},
+		{"backslash quote", `'it\'s ?', ?`, []string{`string:'it\'s ?'`, "other:,", "param:?"}},
+		{"escape string", `E'a\'b' e'\\'`, []string{`string:E'a\'b'`, `string:e'\\'`}},
+		{"quoted identifier", `"a""?" "b\"`, []string{`ident:"a""?"`, `ident:"b\"`}},
+		{"unterminated string", "'a ? $1", []string{"string:'a ? $1"}},
+		{"words with dollar", "a$1 b_2 café", []string{"word:a$1", "word:b_2", "word:café"}},
+		{"numbers", "1 1.5 .5 1e3 1.5e-3 2E+10 1e", []string{
+			"number:1", "number:1.5", "number:.5", "number:1e3", "number:1.5e-3", "number:2E+10", "number:1", "word:e",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			runes := []rune(tt.query)
+			tokens := tokenize(runes)
+			if got := describeTokens(runes, tokens); !slices.Equal(got, tt.want) {
+				t.Errorf("tokenize(%q)\n got %q\nwant %q", tt.query, got, tt.want)
+			}
+			var b strings.Builder
+			for i, tok := range tokens {
+				if i > 0 && tok.start != tokens[i-1].end {
+					t.Fatalf("token %d starts at %d, the previous one ends at %d", i, tok.start, tokens[i-1].end)
+				}
+				b.WriteString(string(runes[tok.start:tok.end]))
+			}
+			if b.String() != tt.query {
+				t.Errorf("tokens rebuild %q, want %q", b.String(), tt.query)
+			}
+		})
+	}
+}
@@ -0,0 +1,216 @@
+// Package meta reads the catalog of a database through the Data API ListSchemas, ListTables and DescribeTable
+// operations, which only require Data API permissions, no access to the system tables.
+package meta
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/adarsh-jaiss/metasql/config"
+	"github.com/adarsh-jaiss/metasql/hooks"
+	"github.com/adarsh-jaiss/metasql/utils"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/redshiftdata"
+)
+
+// Client is the subset of the Data API client used to read the catalog, *redshiftdata.Client implements it.
+type Client interface {
+	redshiftdata.ListSchemasAPIClient
+	redshiftdata.ListTablesAPIClient
+	redshiftdata.DescribeTableAPIClient
+}
+
+// Table is a table or view of the catalog.
+type Table struct {
+	Schema string // Schema is the schema of the table.
+	Name   string // Name is the name of the table.
+	Type   string // Type is the type of the table, such as TABLE or VIEW.
+}
+
+// Column is a column of a table.
+type Column struct {
+	Name      string // Name is the name of the column.
+	Type      string // Type is the type name of the column, such as int4 or varchar.
+	Length    int32  // Length is the maximum length of character columns.
+	Precision int32  // Precision is the precision of numeric columns.
+	Scale     int32  // Scale is the scale of numeric columns.
+	Nullable  bool   // Nullable reports whether the column may be null.
+	Default   string // Default is the default value expression, empty when there is none.
+}
+
+// Catalog reads the catalog of the database targeted by a config.
+type Catalog struct {
+	client Client
+	cfg    *config.RedshiftDataConfig
+}
+
+// NewCatalog returns a Catalog reading the database of cfg with client.
+func NewCatalog(client Client, cfg *config.RedshiftDataConfig) *Catalog {
+	return &Catalog{client: client, cfg: cfg}
+}
+
+// target returns the database and credentials to send, applying the Route hooks of the config.
+func (c *Catalog) target(ctx context.Context) (database, dbUser, secretArn *string, err error) {
+	target := &hooks.Target{
+		Database:  utils.Coalesce(c.cfg.Database),
+		SecretArn: utils.Coalesce(c.cfg.SecretsArn),
+	}
+	if err := hooks.Route(ctx, c.cfg.Hooks, target); err != nil {
+		return nil, nil, nil, err
+	}
+	dbUser, secretArn, err = c.cfg.Credentials(utils.Nullif(target.SecretArn))
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return utils.Nullif(target.Database), dbUser, secretArn, nil
+}
+
+// Schemas returns the schemas matching the LIKE pattern, all of them when it is empty.
+func (c *Catalog) Schemas(ctx context.Context, pattern string) ([]string, error) {
+	database, dbUser, secretArn, err := c.target(ctx)
+	if err != nil {
+		return nil, err
+	}
+	p := redshiftdata.NewListSchemasPaginator(c.client, &redshiftdata.ListSchemasInput{
+		ClusterIdentifier: c.cfg.ClusterIdentifier,
+		Database:          database,
+		DbUser:            dbUser,
+		SecretArn:         secretArn,
+		WorkgroupName:     c.cfg.WorkgroupName,
+		SchemaPattern:     utils.Nullif(pattern),
+	})
+	var schemas []string
+	for p.HasMorePages() {
+		page, err := p.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("list schemas error: %w", err)
+		}
+		schemas = append(schemas, page.Schemas...)
+	}
+	return schemas, nil
+}
+
+// Tables returns the tables whose schema and name match the LIKE patterns, empty patterns match everything.
+func (c *Catalog) Tables(ctx context.Context, schemaPattern, tablePattern string) ([]Table, error) {
+	database, dbUser, secretArn, err := c.target(ctx)
+	if err != nil {
+		return nil, err
+	}
+	p := redshiftdata.NewListTablesPaginator(c.client, &redshiftdata.ListTablesInput{
+		ClusterIdentifier: c.cfg.ClusterIdentifier,
+		Database:          database,
+		DbUser:            dbUser,
+		SecretArn:         secretArn,
+		WorkgroupName:     c.cfg.WorkgroupName,
+		SchemaPattern:     utils.Nullif(schemaPattern),
+		TablePattern:      utils.Nullif(tablePattern),
+	})
+	var tables []Table
+	for p.HasMorePages() {
+		page, err := p.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("list tables error: %w", err)
+		}
+		for _, t := range page.Tables {
+			tables = append(tables, Table{
+				Schema: utils.Coalesce(t.Schema),
+				Name:   utils.Coalesce(t.Name),
+				Type:   utils.Coalesce(t.Type),
+			})
+		}
+	}
+	return tables, nil
+}
+
+// Columns returns the columns of schema.table in their ordinal order. An empty schema is searched
+// in the search path.
+func (c *Catalog) Columns(ctx context.Context, schema, table string) ([]Column, error) {
+	database, dbUser, secretArn, err := c.target(ctx)
+	if err != nil {
+		return nil, err
+	}
+	p := redshiftdata.NewDescribeTablePaginator(c.client, &redshiftdata.DescribeTableInput{
+		ClusterIdentifier: c.cfg.ClusterIdentifier,
+		Database:          database,
+		DbUser:            dbUser,
+		SecretArn:         secretArn,
+		WorkgroupName:     c.cfg.WorkgroupName,
+		Schema:            utils.Nullif(schema),
+		Table:             aws.String(table),
+	})
+	var columns []Column
+	for p.HasMorePages() {
+		page, err := p.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("describe table error: %w", err)
+		}
+		for _, m := range page.ColumnList {
+			columns = append(columns, Column{
+				Name:      utils.Coalesce(m.Name),
+				Type:      utils.Coalesce(m.TypeName),
+				Length:    m.Length,
+				Precision: m.Precision,
+				Scale:     m.Scale,
+				Nullable:  m.Nullable != 0,
+				Default:   utils.Coalesce(m.ColumnDefault),
+			})
+		}
+	}
+	if len(columns) == 0 {
+		return nil, fmt.Errorf("table %s not found", qualifiedName(schema, table))
+	}
+	return columns, nil
+}
+
+// DDL returns a CREATE TABLE statement for schema.table, reconstructed from its columns.
+// Distribution and sort keys, encodings and constraints are not reported by the Data API and are left out.
+func (c *Catalog) DDL(ctx context.Context, schema, table string) (string, error) {
+	columns, err := c.Columns(ctx, schema, table)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "CREATE TABLE %s (\n", qualifiedName(schema, table))
+	for i, col := range columns {
+		fmt.Fprintf(&b, "    %s %s", quoteIdentifier(col.Name), col.TypeSQL())
+		if col.Default != "" {
+			fmt.Fprintf(&b, " DEFAULT %s", col.Default)
+		}
+		if !col.Nullable {
+			b.WriteString(" NOT NULL")
+		}
+		if i < len(columns)-1 {
+			b.WriteString(",")
+		}
+		b.WriteString("\n")
+	}
+	b.WriteString(");\n")
+	return b.String(), nil
+}
+
+// TypeSQL returns the type of the column as written in DDL, with its length or precision and scale.
+func (col Column) TypeSQL() string {
+	switch strings.ToLower(col.Type) {
+	case "varchar", "character varying", "char", "character", "bpchar", "varbyte":
+		if col.Length > 0 {
+			return fmt.Sprintf("%s(%d)", col.Type, col.Length)
+		}
+	case "numeric", "decimal":
+		if col.Precision > 0 {
+			return fmt.Sprintf("%s(%d,%d)", col.Type, col.Precision, col.Scale)
+		}
+	}
+	return col.Type
+}
+
+func qualifiedName(schema, table string) string {
+	if schema == "" {
+		return quoteIdentifier(table)
+	}
+	return quoteIdentifier(schema) + "." + quoteIdentifier(table)
+}
+
+func quoteIdentifier(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
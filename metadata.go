@@ -0,0 +1,78 @@
+package metasql
+
+import (
+	"context"
+	"strings"
+
+	"github.com/adarsh-jaiss/metasql/utils"
+)
+
+// ColumnMetadata describes a column of a result set, for services that render results, such as BI front ends.
+type ColumnMetadata struct {
+	Name          string // Name is the name of the column, as returned by Columns.
+	Label         string // Label is the label of the column, its name unless the query renamed it.
+	TypeName      string // TypeName is the upper-cased type name of the column, as returned by ColumnTypeDatabaseTypeName.
+	Schema        string // Schema is the schema of the table the column originates from, empty for computed columns.
+	Table         string // Table is the table the column originates from, empty for computed columns.
+	Nullable      bool   // Nullable reports whether the column may be null, false when NullableKnown is false.
+	NullableKnown bool   // NullableKnown reports whether the nullability of the column is known.
+	Length        int    // Length is the length of character columns.
+	Precision     int    // Precision is the precision of numeric columns.
+	Scale         int    // Scale is the scale of numeric columns.
+	// Comment is the comment of the column of the table, set with COMMENT ON COLUMN. Comments are only fetched
+	// when ColumnComments is enabled along with the table metadata cache.
+	Comment string
+}
+
+// RowsMetadataProvider is implemented by the rows of the driver, to describe the columns of a result set beyond
+// what database/sql.ColumnType exposes.
+type RowsMetadataProvider interface {
+	RowsMetadata() []ColumnMetadata
+}
+
+type rowsMetadataKey struct{}
+
+// WithRowsMetadata returns a context under which queries call fn with the metadata of their columns before
+// their rows are returned. An error returned by fn fails the query.
+func WithRowsMetadata(ctx context.Context, fn func([]ColumnMetadata) error) context.Context {
+	return context.WithValue(ctx, rowsMetadataKey{}, fn)
+}
+
+// reportRowsMetadata calls the function set with WithRowsMetadata, if any.
+func reportRowsMetadata(ctx context.Context, rows *redshiftDataRows) error {
+	if fn, ok := ctx.Value(rowsMetadataKey{}).(func([]ColumnMetadata) error); ok && fn != nil {
+		return fn(rows.RowsMetadata())
+	}
+	return nil
+}
+
+// RowsMetadata returns the metadata of the columns of the result set, in order.
+func (rows *redshiftDataRows) RowsMetadata() []ColumnMetadata {
+	names := rows.Columns()
+	metadata := make([]ColumnMetadata, len(rows.columns))
+	for i, c := range rows.columns {
+		metadata[i] = ColumnMetadata{
+			Name:      names[i],
+			Label:     utils.Coalesce(c.Label),
+			TypeName:  rows.ColumnTypeDatabaseTypeName(i),
+			Schema:    utils.Coalesce(c.SchemaName),
+			Table:     utils.Coalesce(c.TableName),
+			Length:    int(c.Length),
+			Precision: int(c.Precision),
+			Scale:     int(c.Scale),
+		}
+		metadata[i].Nullable, metadata[i].NullableKnown = rows.ColumnTypeNullable(i)
+		if length, ok := rows.ColumnTypeLength(i); ok {
+			metadata[i].Length = int(length)
+		}
+		if rows.comments != nil && metadata[i].Schema != "" && metadata[i].Table != "" {
+			metadata[i].Comment = rows.comments(metadata[i].Schema, metadata[i].Table)[strings.ToLower(utils.Coalesce(c.Name))]
+		}
+	}
+	return metadata
+}
+
+// RowsMetadata returns the metadata of the columns of the result set, in order.
+func (r *StatementRows) RowsMetadata() []ColumnMetadata {
+	return r.rows.RowsMetadata()
+}
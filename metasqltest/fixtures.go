@@ -0,0 +1,161 @@
+// Package metasqltest provides helpers for integration tests running against a development cluster or workgroup,
+// and a Mock Data API client for unit tests running without one.
+package metasqltest
+
+import (
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/adarsh-jaiss/metasql"
+	"gopkg.in/yaml.v3"
+)
+
+// Null is how NULL is written in CSV fixtures, as in the Postgres COPY text format.
+const Null = `\N`
+
+// Fixture is the content of a YAML fixture file.
+type Fixture struct {
+	Table   string           `yaml:"table"`   // Table is the (optionally schema qualified) table name, defaults to the file name.
+	Create  string           `yaml:"create"`  // Create is an optional statement creating the table.
+	Columns []string         `yaml:"columns"` // Columns fixes the column order, defaults to the sorted keys of the rows.
+	Rows    []map[string]any `yaml:"rows"`    // Rows are the rows to insert, keyed by column name.
+}
+
+// LoadFixtures loads every fixture of dir, in lexical file name order:
+//
+//   - *.sql files are executed as is, statement by statement, to create schemas and tables.
+//   - *.yaml and *.yml files hold a Fixture.
+//   - *.csv files hold a header line with the column names followed by the rows; the file name without
+//     extension is the table name, so public.users.csv loads into public.users.
+//
+// Rows are inserted with metasql.BulkInsert.
+func LoadFixtures(ctx context.Context, db *sql.DB, dir string) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return fmt.Errorf("read fixtures: %w", err)
+	}
+	names := make([]string, 0, len(entries))
+	for _, e := range entries {
+		if !e.IsDir() {
+			names = append(names, e.Name())
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		path := filepath.Join(dir, name)
+		var err error
+		switch filepath.Ext(name) {
+		case ".sql":
+			err = loadSQL(ctx, db, path)
+		case ".yaml", ".yml":
+			err = loadYAML(ctx, db, path)
+		case ".csv":
+			err = loadCSV(ctx, db, path)
+		default:
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("load fixture %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+func tableName(path string) string {
+	return strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+}
+
+func loadSQL(ctx context.Context, db *sql.DB, path string) error {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	for _, stmt := range strings.Split(string(b), ";") {
+		if strings.TrimSpace(stmt) == "" {
+			continue
+		}
+		if _, err := db.ExecContext(ctx, stmt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func loadYAML(ctx context.Context, db *sql.DB, path string) error {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var fixture Fixture
+	if err := yaml.Unmarshal(b, &fixture); err != nil {
+		return err
+	}
+	if fixture.Table == "" {
+		fixture.Table = tableName(path)
+	}
+	if fixture.Create != "" {
+		if _, err := db.ExecContext(ctx, fixture.Create); err != nil {
+			return err
+		}
+	}
+	columns := fixture.Columns
+	if len(columns) == 0 {
+		seen := make(map[string]bool)
+		for _, row := range fixture.Rows {
+			for c := range row {
+				if !seen[c] {
+					seen[c] = true
+					columns = append(columns, c)
+				}
+			}
+		}
+		sort.Strings(columns)
+	}
+	rows := make([][]any, 0, len(fixture.Rows))
+	for _, row := range fixture.Rows {
+		values := make([]any, len(columns))
+		for i, c := range columns {
+			values[i] = row[c]
+		}
+		rows = append(rows, values)
+	}
+	if len(rows) == 0 {
+		return nil
+	}
+	_, err = metasql.BulkInsert(ctx, db, fixture.Table, columns, rows)
+	return err
+}
+
+func loadCSV(ctx context.Context, db *sql.DB, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	records, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		return err
+	}
+	if len(records) < 2 {
+		return nil
+	}
+	rows := make([][]any, 0, len(records)-1)
+	for _, record := range records[1:] {
+		values := make([]any, len(record))
+		for i, v := range record {
+			if v != Null {
+				values[i] = v
+			}
+		}
+		rows = append(rows, values)
+	}
+	_, err = metasql.BulkInsert(ctx, db, tableName(path), records[0], rows)
+	return err
+}
@@ -0,0 +1,474 @@
+package metasqltest
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/adarsh-jaiss/metasql"
+	"github.com/adarsh-jaiss/metasql/config"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/redshiftdata"
+	awstypes "github.com/aws/aws-sdk-go-v2/service/redshiftdata/types"
+)
+
+// MockDSN is the DSN of the databases NewMock opens when none is given.
+const MockDSN = "workgroup(metasqltest)/dev"
+
+// AnyArg matches any value of the argument it is expected for, see Expectation.WithArgs.
+var AnyArg any = anyArg{}
+
+type anyArg struct{}
+
+// Mock is a fake Data API client answering the statements of a database with expectations, in the style of
+// sqlmock, so unit tests can assert on the statements a service issues without AWS.
+//
+// Expectations match the Data API calls the driver makes, in the order they were set: ExpectQuery and ExpectExec
+// an ExecuteStatement call, ExpectBatch a BatchExecuteStatement call. They see what the Data API receives, so
+// placeholders are already rewritten into :1 and :name parameters, labels prefix the queries, and the
+// statements of a transaction in the default batch mode arrive as one batch at commit, while in session mode
+// BEGIN, each statement and COMMIT are separate calls. Statements are polled with DescribeStatement until they
+// finish, see Expectation.WillRunFor.
+type Mock struct {
+	mu           sync.Mutex
+	expectations []*Expectation
+	matched      int                       // matched is the number of expectations matched so far.
+	statements   map[string]*mockStatement // statements holds the submitted statements by id.
+	sessions     int
+}
+
+// mockStatement is a statement submitted to a Mock.
+type mockStatement struct {
+	exp       *Expectation
+	sqls      []string
+	submitted time.Time
+}
+
+// NewMock returns a database whose connections use a new Mock, and the Mock. The database is configured by dsn,
+// MockDSN when it is empty. Once the test is done the database is closed and the test fails unless every
+// expectation was met.
+func NewMock(t testing.TB, dsn string) (*sql.DB, *Mock) {
+	t.Helper()
+	if dsn == "" {
+		dsn = MockDSN
+	}
+	cfg, err := config.ParseDSN(dsn)
+	if err != nil {
+		t.Fatalf("metasqltest: %v", err)
+	}
+	m := &Mock{statements: map[string]*mockStatement{}}
+	db := sql.OpenDB(metasql.NewConnectorWithClient(m, cfg))
+	t.Cleanup(func() {
+		db.Close()
+		if err := m.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+	})
+	return db, m
+}
+
+type expectKind int
+
+const (
+	expectQuery expectKind = iota
+	expectExec
+	expectBatch
+)
+
+// Expectation is a statement a Mock expects, set up with its With and Will methods.
+type Expectation struct {
+	kind      expectKind
+	patterns  []*regexp.Regexp
+	args      []any
+	rows      *Rows
+	affected  []int64
+	runFor    time.Duration
+	failure   string
+	err       error
+	cancelled bool
+}
+
+// ExpectQuery expects a statement returning rows whose SQL matches the regular expression pattern, use
+// regexp.QuoteMeta to match it literally. It returns the rows set with WillReturnRows, none by default.
+func (m *Mock) ExpectQuery(pattern string) *Expectation {
+	return m.expect(expectQuery, pattern)
+}
+
+// ExpectExec expects a statement without a result set whose SQL matches the regular expression pattern.
+func (m *Mock) ExpectExec(pattern string) *Expectation {
+	return m.expect(expectExec, pattern)
+}
+
+// ExpectBatch expects a batch of statements, such as a transaction committed in the batch mode or a
+// multi-statement query, whose SQL statements match the patterns one by one.
+func (m *Mock) ExpectBatch(patterns ...string) *Expectation {
+	return m.expect(expectBatch, patterns...)
+}
+
+func (m *Mock) expect(kind expectKind, patterns ...string) *Expectation {
+	e := &Expectation{kind: kind}
+	for _, p := range patterns {
+		e.patterns = append(e.patterns, regexp.MustCompile(p))
+	}
+	m.mu.Lock()
+	m.expectations = append(m.expectations, e)
+	m.mu.Unlock()
+	return e
+}
+
+// WithArgs expects the statement to be sent these arguments, compared with the text of the parameters the
+// statement is sent: positional arguments with the parameters :1, :2, ..., sql.Named arguments with the
+// parameter of their name. A nil argument expects no parameter, as NULL is sent as a literal, and AnyArg
+// matches any value. The statement must not be sent other parameters.
+func (e *Expectation) WithArgs(args ...any) *Expectation {
+	e.args = args
+	return e
+}
+
+// WillReturnRows sets the rows returned by a query.
+func (e *Expectation) WillReturnRows(rows *Rows) *Expectation {
+	e.rows = rows
+	return e
+}
+
+// WillReturnResult sets the number of rows affected by the statement, or by each statement of a batch.
+func (e *Expectation) WillReturnResult(rowsAffected ...int64) *Expectation {
+	e.affected = rowsAffected
+	return e
+}
+
+// WillRunFor keeps the statement running for d once submitted, so it is polled and can time out or be cancelled.
+func (e *Expectation) WillRunFor(d time.Duration) *Expectation {
+	e.runFor = d
+	return e
+}
+
+// WillFail makes the statement fail on the warehouse with the error message, such as "ERROR: relation does not
+// exist", reported by DescribeStatement. The last statement of a batch is the one failing.
+func (e *Expectation) WillFail(message string) *Expectation {
+	e.failure = message
+	return e
+}
+
+// WillReturnError makes the Data API call submitting the statement fail with err.
+func (e *Expectation) WillReturnError(err error) *Expectation {
+	e.err = err
+	return e
+}
+
+// Cancelled reports whether the statement was cancelled with CancelStatement.
+func (e *Expectation) Cancelled() bool {
+	return e.cancelled
+}
+
+func (e *Expectation) String() string {
+	var kind string
+	switch e.kind {
+	case expectQuery:
+		kind = "query"
+	case expectExec:
+		kind = "exec"
+	case expectBatch:
+		kind = "batch"
+	}
+	patterns := make([]string, len(e.patterns))
+	for i, p := range e.patterns {
+		patterns[i] = strconv.Quote(p.String())
+	}
+	return kind + " " + strings.Join(patterns, ", ")
+}
+
+// ExpectationsWereMet returns an error listing the expectations no statement matched.
+func (m *Mock) ExpectationsWereMet() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.matched == len(m.expectations) {
+		return nil
+	}
+	missing := make([]string, 0, len(m.expectations)-m.matched)
+	for _, e := range m.expectations[m.matched:] {
+		missing = append(missing, e.String())
+	}
+	return fmt.Errorf("metasqltest: expected statements were not issued: %s", strings.Join(missing, "; "))
+}
+
+// match returns the next expectation, provided the statements match it.
+func (m *Mock) match(batch bool, sqls []string, params []awstypes.SqlParameter) (*Expectation, error) {
+	if m.matched == len(m.expectations) {
+		return nil, fmt.Errorf("metasqltest: unexpected statement %q", strings.Join(sqls, "; "))
+	}
+	e := m.expectations[m.matched]
+	if (e.kind == expectBatch) != batch || len(e.patterns) != len(sqls) {
+		return nil, fmt.Errorf("metasqltest: statement %q does not match the expected %s", strings.Join(sqls, "; "), e)
+	}
+	for i, p := range e.patterns {
+		if !p.MatchString(sqls[i]) {
+			return nil, fmt.Errorf("metasqltest: statement %q does not match the expected %s", sqls[i], e)
+		}
+	}
+	if err := matchArgs(e.args, params); err != nil {
+		return nil, fmt.Errorf("metasqltest: statement %q: %w", strings.Join(sqls, "; "), err)
+	}
+	m.matched++
+	return e, nil
+}
+
+// matchArgs compares the expected arguments with the parameters sent, see Expectation.WithArgs.
+func matchArgs(args []any, params []awstypes.SqlParameter) error {
+	if args == nil {
+		return nil
+	}
+	sent := make(map[string]string, len(params))
+	for _, p := range params {
+		sent[aws.ToString(p.Name)] = aws.ToString(p.Value)
+	}
+	expected := 0
+	for i, arg := range args {
+		name := strconv.Itoa(i + 1)
+		if named, ok := arg.(sql.NamedArg); ok {
+			name, arg = named.Name, named.Value
+		}
+		value, ok := sent[name]
+		switch {
+		case arg == nil && ok:
+			return fmt.Errorf("argument %s is %q, expected NULL", name, value)
+		case arg == nil:
+			continue
+		case !ok:
+			return fmt.Errorf("argument %s is missing", name)
+		case arg != AnyArg && value != fmt.Sprint(arg):
+			return fmt.Errorf("argument %s is %q, expected %q", name, value, fmt.Sprint(arg))
+		}
+		expected++
+	}
+	if len(sent) != expected {
+		return fmt.Errorf("%d parameters sent, %d expected", len(sent), expected)
+	}
+	return nil
+}
+
+// submit matches the statements with the next expectation and records them under a new id.
+func (m *Mock) submit(batch bool, sqls []string, params []awstypes.SqlParameter) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	e, err := m.match(batch, sqls, params)
+	if err != nil {
+		return "", err
+	}
+	if e.err != nil {
+		return "", e.err
+	}
+	id := fmt.Sprintf("metasqltest-%d", len(m.statements)+1)
+	m.statements[id] = &mockStatement{exp: e, sqls: sqls, submitted: time.Now()}
+	return id, nil
+}
+
+// session returns the session a statement runs in, a new one when it asks for one.
+func (m *Mock) session(id *string, keepAlive *int32) *string {
+	if id != nil || keepAlive == nil {
+		return id
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sessions++
+	return aws.String(fmt.Sprintf("metasqltest-session-%d", m.sessions))
+}
+
+// ExecuteStatement submits a statement matching the next expectation.
+func (m *Mock) ExecuteStatement(ctx context.Context, params *redshiftdata.ExecuteStatementInput, optFns ...func(*redshiftdata.Options)) (*redshiftdata.ExecuteStatementOutput, error) {
+	id, err := m.submit(false, []string{aws.ToString(params.Sql)}, params.Parameters)
+	if err != nil {
+		return nil, err
+	}
+	return &redshiftdata.ExecuteStatementOutput{Id: aws.String(id), SessionId: m.session(params.SessionId, params.SessionKeepAliveSeconds)}, nil
+}
+
+// BatchExecuteStatement submits a batch matching the next expectation.
+func (m *Mock) BatchExecuteStatement(ctx context.Context, params *redshiftdata.BatchExecuteStatementInput, optFns ...func(*redshiftdata.Options)) (*redshiftdata.BatchExecuteStatementOutput, error) {
+	id, err := m.submit(true, params.Sqls, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &redshiftdata.BatchExecuteStatementOutput{Id: aws.String(id), SessionId: m.session(params.SessionId, params.SessionKeepAliveSeconds)}, nil
+}
+
+// DescribeStatement reports the status of a statement, running until its WillRunFor duration elapsed.
+func (m *Mock) DescribeStatement(ctx context.Context, params *redshiftdata.DescribeStatementInput, optFns ...func(*redshiftdata.Options)) (*redshiftdata.DescribeStatementOutput, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	stmt, ok := m.statements[aws.ToString(params.Id)]
+	if !ok {
+		return nil, fmt.Errorf("metasqltest: unknown statement %s", aws.ToString(params.Id))
+	}
+	e, now := stmt.exp, time.Now()
+	status := awstypes.StatusStringFinished
+	switch {
+	case e.cancelled:
+		status = awstypes.StatusStringAborted
+	case now.Sub(stmt.submitted) < e.runFor:
+		status = awstypes.StatusStringStarted
+	case e.failure != "":
+		status = awstypes.StatusStringFailed
+	}
+	out := &redshiftdata.DescribeStatementOutput{
+		Id:           params.Id,
+		Status:       status,
+		QueryString:  aws.String(strings.Join(stmt.sqls, "; ")),
+		CreatedAt:    aws.Time(stmt.submitted),
+		UpdatedAt:    aws.Time(now),
+		Duration:     int64(min(now.Sub(stmt.submitted), e.runFor)),
+		HasResultSet: aws.Bool(e.kind == expectQuery),
+		ResultRows:   e.resultRows(0),
+	}
+	if status == awstypes.StatusStringFailed && e.kind != expectBatch {
+		out.Error = aws.String(e.failure)
+	}
+	if e.kind == expectBatch {
+		out.ResultRows = 0
+		for i, sql := range stmt.sqls {
+			sub := awstypes.SubStatementData{
+				Id:           aws.String(fmt.Sprintf("%s:%d", aws.ToString(params.Id), i+1)),
+				QueryString:  aws.String(sql),
+				Status:       awstypes.StatementStatusString(status),
+				HasResultSet: aws.Bool(false),
+				ResultRows:   e.resultRows(i),
+			}
+			if status == awstypes.StatusStringFailed && i == len(stmt.sqls)-1 {
+				sub.Error = aws.String(e.failure)
+			} else if status == awstypes.StatusStringFailed {
+				sub.Status = awstypes.StatementStatusStringFinished
+			}
+			out.ResultRows += sub.ResultRows
+			out.SubStatements = append(out.SubStatements, sub)
+		}
+	}
+	return out, nil
+}
+
+// resultRows returns the number of rows the i-th statement of the expectation returns or affects.
+func (e *Expectation) resultRows(i int) int64 {
+	if e.kind == expectQuery {
+		if e.rows == nil {
+			return 0
+		}
+		return int64(len(e.rows.records))
+	}
+	if i < len(e.affected) {
+		return e.affected[i]
+	}
+	return 0
+}
+
+// CancelStatement cancels a running statement.
+func (m *Mock) CancelStatement(ctx context.Context, params *redshiftdata.CancelStatementInput, optFns ...func(*redshiftdata.Options)) (*redshiftdata.CancelStatementOutput, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	stmt, ok := m.statements[aws.ToString(params.Id)]
+	if !ok {
+		return nil, fmt.Errorf("metasqltest: unknown statement %s", aws.ToString(params.Id))
+	}
+	if time.Since(stmt.submitted) >= stmt.exp.runFor {
+		return nil, fmt.Errorf("metasqltest: statement %s is not running", aws.ToString(params.Id))
+	}
+	stmt.exp.cancelled = true
+	return &redshiftdata.CancelStatementOutput{Status: aws.Bool(true)}, nil
+}
+
+// GetStatementResult returns the rows of a query in a single page.
+func (m *Mock) GetStatementResult(ctx context.Context, params *redshiftdata.GetStatementResultInput, optFns ...func(*redshiftdata.Options)) (*redshiftdata.GetStatementResultOutput, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	stmt, ok := m.statements[aws.ToString(params.Id)]
+	if !ok || stmt.exp.kind != expectQuery {
+		return nil, fmt.Errorf("metasqltest: statement %s has no result set", aws.ToString(params.Id))
+	}
+	rows := stmt.exp.rows
+	if rows == nil {
+		rows = NewRows()
+	}
+	return &redshiftdata.GetStatementResultOutput{
+		ColumnMetadata: rows.metadata(),
+		Records:        rows.records,
+		TotalNumRows:   int64(len(rows.records)),
+	}, nil
+}
+
+// Rows are the rows a query expected by a Mock returns.
+type Rows struct {
+	columns []string
+	types   []string
+	records [][]awstypes.Field
+}
+
+// NewRows returns rows with the named columns. Their types are inferred from the values of their first row,
+// see WithTypes.
+func NewRows(columns ...string) *Rows {
+	return &Rows{columns: columns, types: make([]string, len(columns))}
+}
+
+// WithTypes sets the Redshift types of the columns, such as int4, numeric or timestamptz, as the Data API
+// reports them.
+func (r *Rows) WithTypes(types ...string) *Rows {
+	copy(r.types, types)
+	return r
+}
+
+// AddRow adds a row of nil, string, bool, integer, float, []byte or time.Time values, encoded into the fields
+// the Data API returns for them.
+func (r *Rows) AddRow(values ...any) *Rows {
+	if len(values) != len(r.columns) {
+		panic(fmt.Sprintf("metasqltest: %d values for %d columns", len(values), len(r.columns)))
+	}
+	record := make([]awstypes.Field, len(values))
+	for i, v := range values {
+		field, typeName := mockField(v)
+		if r.types[i] == "" {
+			r.types[i] = typeName
+		}
+		record[i] = field
+	}
+	r.records = append(r.records, record)
+	return r
+}
+
+func (r *Rows) metadata() []awstypes.ColumnMetadata {
+	metadata := make([]awstypes.ColumnMetadata, len(r.columns))
+	for i, c := range r.columns {
+		metadata[i] = awstypes.ColumnMetadata{Name: aws.String(c), Label: aws.String(c), TypeName: aws.String(cmp.Or(r.types[i], "varchar")), Nullable: 1}
+	}
+	return metadata
+}
+
+// mockField returns the field holding v and the type of the column it is inferred for, "" for NULL.
+func mockField(v any) (awstypes.Field, string) {
+	switch v := v.(type) {
+	case nil:
+		return &awstypes.FieldMemberIsNull{Value: true}, ""
+	case string:
+		return &awstypes.FieldMemberStringValue{Value: v}, "varchar"
+	case bool:
+		return &awstypes.FieldMemberBooleanValue{Value: v}, "bool"
+	case int:
+		return &awstypes.FieldMemberLongValue{Value: int64(v)}, "int8"
+	case int32:
+		return &awstypes.FieldMemberLongValue{Value: int64(v)}, "int4"
+	case int64:
+		return &awstypes.FieldMemberLongValue{Value: v}, "int8"
+	case float32:
+		return &awstypes.FieldMemberDoubleValue{Value: float64(v)}, "float4"
+	case float64:
+		return &awstypes.FieldMemberDoubleValue{Value: v}, "float8"
+	case []byte:
+		return &awstypes.FieldMemberBlobValue{Value: v}, "varbyte"
+	case time.Time:
+		return &awstypes.FieldMemberStringValue{Value: v.Format("2006-01-02 15:04:05.999999")}, "timestamp"
+	}
+	return &awstypes.FieldMemberStringValue{Value: fmt.Sprint(v)}, "varchar"
+}
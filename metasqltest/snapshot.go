@@ -0,0 +1,114 @@
+package metasqltest
+
+import (
+	"context"
+	"database/sql"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+	"testing"
+
+	"github.com/adarsh-jaiss/metasql/resultdiff"
+)
+
+// SnapshotDir is the directory snapshots are stored in, relative to the package of the test.
+var SnapshotDir = filepath.Join("testdata", "snapshots")
+
+func init() {
+	// Another package may own -update already, in which case its value is used.
+	if flag.Lookup("update") == nil {
+		flag.Bool("update", false, "rewrite the query snapshots of metasqltest instead of comparing with them")
+	}
+}
+
+// updating reports whether the tests run with -update.
+func updating() bool {
+	f := flag.Lookup("update")
+	if f == nil {
+		return false
+	}
+	getter, ok := f.Value.(flag.Getter)
+	if !ok {
+		return false
+	}
+	update, _ := getter.Get().(bool)
+	return update
+}
+
+var (
+	snapshotMu    sync.Mutex
+	snapshotCalls = map[string]int{}
+)
+
+var unsafeFileChars = regexp.MustCompile(`[^\w.-]+`)
+
+// snapshotPath returns the file of the next snapshot of the test, suffixed with a counter when the test
+// takes several.
+func snapshotPath(t testing.TB) string {
+	snapshotMu.Lock()
+	snapshotCalls[t.Name()]++
+	n := snapshotCalls[t.Name()]
+	snapshotMu.Unlock()
+	if n == 1 {
+		// forget the count once the test is done, so repeated runs with -count use the same files
+		t.Cleanup(func() {
+			snapshotMu.Lock()
+			delete(snapshotCalls, t.Name())
+			snapshotMu.Unlock()
+		})
+	}
+	name := unsafeFileChars.ReplaceAllString(t.Name(), "_")
+	if n > 1 {
+		name = fmt.Sprintf("%s_%d", name, n)
+	}
+	return filepath.Join(SnapshotDir, name+".csv")
+}
+
+// AssertQuerySnapshot runs the query and compares its result with the snapshot stored for the test, as a
+// multiset of rows normalized like resultdiff does. Running the tests with -update writes the snapshot instead.
+func AssertQuerySnapshot(t testing.TB, db *sql.DB, query string, args ...any) {
+	t.Helper()
+	path := snapshotPath(t)
+	opts := resultdiff.DefaultOptions
+	got, err := resultdiff.Query(context.Background(), db, opts, query, args...)
+	if err != nil {
+		t.Fatalf("snapshot %s: %v", path, err)
+	}
+	if updating() {
+		if err := writeSnapshot(path, got); err != nil {
+			t.Fatalf("snapshot %s: %v", path, err)
+		}
+		t.Logf("snapshot %s updated", path)
+		return
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("snapshot %s: %v (run the tests with -update to create it)", path, err)
+	}
+	defer f.Close()
+	want, err := resultdiff.ReadGolden(f)
+	if err != nil {
+		t.Fatalf("snapshot %s: %v", path, err)
+	}
+	if report := resultdiff.Compare(want, got, opts); !report.Equal() {
+		t.Errorf("snapshot %s differs from the result (- snapshot, + result), run the tests with -update to accept it:\n%s", path, report)
+	}
+}
+
+func writeSnapshot(path string, res *resultdiff.Result) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := resultdiff.WriteGolden(f, res); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,44 @@
+package metasql
+
+import (
+	"context"
+	"time"
+
+	cfg "github.com/adarsh-jaiss/metasql/config"
+	"github.com/adarsh-jaiss/metasql/hooks"
+	awsmiddleware "github.com/aws/aws-sdk-go-v2/aws/middleware"
+	"github.com/aws/aws-sdk-go-v2/aws/retry"
+	"github.com/aws/aws-sdk-go-v2/service/redshiftdata"
+	"github.com/aws/smithy-go/middleware"
+)
+
+// WithAPIMetrics returns a client option reporting every Data API operation to the AfterAPICall hooks of
+// the config. The default client constructor applies it when APIMetrics is enabled, custom constructors
+// can pass it to redshiftdata.NewFromConfig.
+func WithAPIMetrics(config *cfg.RedshiftDataConfig) func(*redshiftdata.Options) {
+	return func(o *redshiftdata.Options) {
+		o.APIOptions = append(o.APIOptions, func(stack *middleware.Stack) error {
+			return stack.Initialize.Add(apiMetricsMiddleware(config), middleware.After)
+		})
+	}
+}
+
+func apiMetricsMiddleware(config *cfg.RedshiftDataConfig) middleware.InitializeMiddleware {
+	return middleware.InitializeMiddlewareFunc("MetasqlAPIMetrics", func(ctx context.Context, in middleware.InitializeInput, next middleware.InitializeHandler) (middleware.InitializeOutput, middleware.Metadata, error) {
+		e := &hooks.APICallEvent{
+			Operation:   awsmiddleware.GetOperationName(ctx),
+			Start:       time.Now(),
+			Correlation: hooks.Correlate(ctx, config.Hooks),
+		}
+		out, metadata, err := next.HandleInitialize(ctx, in)
+		e.Duration = time.Since(e.Start)
+		e.Err = err
+		e.Attempts = 1
+		if results, ok := retry.GetAttemptResults(metadata); ok && len(results.Results) > 0 {
+			e.Attempts = len(results.Results)
+		}
+		e.RequestID, _ = awsmiddleware.GetRequestIDMetadata(metadata)
+		hooks.AfterAPICall(ctx, config.Hooks, e)
+		return out, metadata, err
+	})
+}
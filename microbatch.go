@@ -0,0 +1,117 @@
+package metasql
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/adarsh-jaiss/metasql/errors"
+	"github.com/adarsh-jaiss/metasql/hooks"
+	"github.com/aws/aws-sdk-go-v2/service/redshiftdata"
+)
+
+// maxBatchStatements is the number of statements BatchExecuteStatement accepts at most.
+const maxBatchStatements = 40
+
+// execBatch buffers the argument-less Execs of a connection when micro-batching is enabled with
+// ExecBatchWindow. The buffer is flushed as a single BatchExecuteStatement once the window since the
+// first buffered statement elapsed, once it is full, or before any other statement of the connection.
+//
+// Buffered statements run as one transaction: when one fails the whole batch is rolled back, and the
+// error is returned by the next call on the connection.
+type execBatch struct {
+	mu      sync.Mutex
+	sqls    []string
+	results []*redshiftDataDelayedResult
+	timer   *time.Timer
+	err     error // err is the error of a flush run by the timer, not reported yet.
+}
+
+// batchExec buffers the query and returns its result, which is filled in when the batch is flushed.
+func (conn *redshiftDataConn) batchExec(ctx context.Context, query string) (*redshiftDataDelayedResult, error) {
+	b := &conn.batch
+	b.mu.Lock()
+	if err := b.err; err != nil {
+		b.err = nil
+		b.mu.Unlock()
+		return nil, err
+	}
+	result := &redshiftDataDelayedResult{err: errors.ErrBatchPending}
+	b.sqls = append(b.sqls, query)
+	b.results = append(b.results, result)
+	full := len(b.sqls) >= maxBatchStatements
+	if !full && b.timer == nil {
+		b.timer = time.AfterFunc(conn.cfg.ExecBatchWindow, func() {
+			b.mu.Lock()
+			defer b.mu.Unlock()
+			if err := conn.flushLocked(context.Background()); err != nil && b.err == nil {
+				b.err = err
+			}
+		})
+	}
+	b.mu.Unlock()
+	if full {
+		if err := conn.flushBatch(ctx); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// flushBatch executes the buffered statements, and returns the error of a previous flush if any.
+func (conn *redshiftDataConn) flushBatch(ctx context.Context) error {
+	b := &conn.batch
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if err := b.err; err != nil {
+		b.err = nil
+		return err
+	}
+	return conn.flushLocked(ctx)
+}
+
+func (conn *redshiftDataConn) flushLocked(ctx context.Context) error {
+	b := &conn.batch
+	if b.timer != nil {
+		b.timer.Stop()
+		b.timer = nil
+	}
+	if len(b.sqls) == 0 {
+		return nil
+	}
+	sqls, results := b.sqls, b.results
+	b.sqls, b.results = nil, nil
+
+	// the batch holds the statements of several calls, none of their tokens applies to it
+	err := conn.runBatch(withoutClientToken(ctx), sqls, results)
+	if err != nil {
+		err = fmt.Errorf("micro-batch of %d statements: %w", len(sqls), err)
+		for _, r := range results {
+			r.err = err
+		}
+	}
+	return err
+}
+
+func (conn *redshiftDataConn) runBatch(ctx context.Context, sqls []string, results []*redshiftDataDelayedResult) error {
+	if len(sqls) == 1 {
+		_, output, err := conn.runStatement(ctx, hooks.KindExec, sqls[0], nil)
+		if err != nil {
+			return err
+		}
+		results[0].Result = newResult(output)
+		return nil
+	}
+	_, desc, err := conn.BatchExecuteStatement(ctx, &redshiftdata.BatchExecuteStatementInput{Sqls: sqls})
+	if err != nil {
+		return err
+	}
+	for i, r := range results {
+		if i >= len(desc.SubStatements) {
+			return fmt.Errorf("sub statement not found: %d", i)
+		}
+		r.Result = NewResultWithSubStatementData(desc.SubStatements[i])
+	}
+	return nil
+}
@@ -0,0 +1,119 @@
+package metasql
+
+import (
+	"context"
+	"database/sql/driver"
+	"io"
+	"strings"
+
+	"github.com/adarsh-jaiss/metasql/errors"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/redshiftdata"
+	awstypes "github.com/aws/aws-sdk-go-v2/service/redshiftdata/types"
+)
+
+// multiStatements returns the statements of a query holding several of them when MultiStatements is enabled,
+// nil for single statements, which are executed as usual.
+func (conn *redshiftDataConn) multiStatements(query string) []string {
+	if !conn.cfg.MultiStatements || !strings.Contains(query, ";") {
+		return nil
+	}
+	if stmts := splitStatements(query); len(stmts) > 1 {
+		return stmts
+	}
+	return nil
+}
+
+// execMulti executes the statements of a multi-statement query as one BatchExecuteStatement, which the Data API runs
+// as a transaction. The result reports the rows affected by all of them.
+func (conn *redshiftDataConn) execMulti(ctx context.Context, stmts []string, args []driver.NamedValue) (driver.Result, error) {
+	desc, err := conn.runMulti(ctx, stmts, args)
+	if err != nil {
+		return nil, err
+	}
+	total := &redshiftDataResult{}
+	for _, sub := range desc.SubStatements {
+		if n := NewResultWithSubStatementData(sub).affectedRows; n > 0 {
+			total.affectedRows += n
+		}
+	}
+	return total, nil
+}
+
+// queryMulti executes the statements of a multi-statement query as one BatchExecuteStatement and returns the result
+// sets of the statements returning rows, in order, see multiResultRows.
+func (conn *redshiftDataConn) queryMulti(ctx context.Context, stmts []string, args []driver.NamedValue) (driver.Rows, error) {
+	desc, err := conn.runMulti(ctx, stmts, args)
+	if err != nil {
+		return nil, err
+	}
+	rows := &multiResultRows{ctx: ctx, conn: conn}
+	for _, sub := range desc.SubStatements {
+		if aws.ToBool(sub.HasResultSet) {
+			rows.pending = append(rows.pending, sub)
+		}
+	}
+	if len(rows.pending) == 0 {
+		if rows.redshiftDataRows, err = conn.newRows(ctx, "", nil); err != nil {
+			return nil, err
+		}
+		return rows, nil
+	}
+	if err := rows.NextResultSet(); err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
+func (conn *redshiftDataConn) runMulti(ctx context.Context, stmts []string, args []driver.NamedValue) (*redshiftdata.DescribeStatementOutput, error) {
+	if len(args) > 0 {
+		return nil, errors.NewUnsupportedError("arguments in multi-statement queries", errors.LimitationPlatform, "BatchExecuteStatement does not accept parameters")
+	}
+	_, desc, err := conn.BatchExecuteStatement(ctx, &redshiftdata.BatchExecuteStatementInput{Sqls: stmts})
+	return desc, err
+}
+
+// multiResultRows are the rows of a multi-statement query: one result set for each statement returning rows,
+// moved through with NextResultSet. Statements that return no rows, such as the creation of a temporary table
+// the following SELECT reads, have no result set. The result set of each statement is fetched once reached.
+type multiResultRows struct {
+	*redshiftDataRows // redshiftDataRows is the current result set.
+
+	ctx     context.Context
+	conn    *redshiftDataConn
+	pending []awstypes.SubStatementData // pending are the statements whose result sets follow the current one.
+}
+
+// HasNextResultSet reports whether another statement of the query returned rows.
+func (r *multiResultRows) HasNextResultSet() bool {
+	return len(r.pending) > 0
+}
+
+// NextResultSet closes the current result set and fetches the rows of the next statement returning rows.
+func (r *multiResultRows) NextResultSet() error {
+	if len(r.pending) == 0 {
+		return io.EOF
+	}
+	sub := r.pending[0]
+	r.pending = r.pending[1:]
+	if r.redshiftDataRows != nil {
+		r.redshiftDataRows.Close()
+	}
+	desc := &redshiftdata.DescribeStatementOutput{
+		Id:           sub.Id,
+		HasResultSet: sub.HasResultSet,
+		QueryString:  sub.QueryString,
+		ResultRows:   sub.ResultRows,
+		ResultSize:   sub.ResultSize,
+	}
+	var p *redshiftdata.GetStatementResultPaginator
+	if !discardRows(r.ctx) {
+		p = redshiftdata.NewGetStatementResultPaginator(r.conn.client, &redshiftdata.GetStatementResultInput{Id: sub.Id})
+	}
+	rows, err := r.conn.queryRows(r.ctx, desc, p)
+	if err != nil {
+		return err
+	}
+	r.redshiftDataRows = rows
+	return nil
+}
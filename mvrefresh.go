@@ -0,0 +1,95 @@
+package metasql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RefreshOptions controls how RefreshMaterializedViews refreshes materialized views.
+type RefreshOptions struct {
+	Concurrency int  // Concurrency is the number of views refreshed at the same time, 1 when 0.
+	Incremental bool // Incremental only refreshes views that support incremental refresh, the others are reported as skipped.
+	All         bool // All refreshes every matching view, by default only stale views are refreshed.
+}
+
+// MaterializedViewRefresh reports the refresh of one materialized view.
+type MaterializedViewRefresh struct {
+	Schema      string        // Schema is the schema of the view.
+	Name        string        // Name is the name of the view.
+	Stale       bool          // Stale reports whether the view was stale before the refresh.
+	Incremental bool          // Incremental reports whether the view supports incremental refresh.
+	Refreshed   bool          // Refreshed reports whether the view was refreshed successfully.
+	Skipped     bool          // Skipped reports whether the view was left alone, because it was fresh or needs a full recompute.
+	Duration    time.Duration // Duration is the time the refresh took.
+	Err         error         // Err is the error the refresh failed with, if any.
+}
+
+// RefreshMaterializedViews refreshes the materialized views whose schema qualified name matches the LIKE pattern,
+// all of them when it is empty. Views are discovered from stv_mv_info. The returned error only covers the
+// discovery, the errors of the refreshes are reported per view.
+func RefreshMaterializedViews(ctx context.Context, db *sql.DB, pattern string, opts RefreshOptions) ([]MaterializedViewRefresh, error) {
+	if pattern == "" {
+		pattern = "%"
+	}
+	ctx = withoutClientToken(ctx)
+	rows, err := db.QueryContext(ctx, `SELECT TRIM(schema), TRIM(name), is_stale, state FROM stv_mv_info WHERE TRIM(schema) || '.' || TRIM(name) LIKE ? ORDER BY 1, 2`, pattern)
+	if err != nil {
+		return nil, fmt.Errorf("list materialized views: %w", err)
+	}
+	var views []MaterializedViewRefresh
+	for rows.Next() {
+		var v MaterializedViewRefresh
+		var stale string
+		var state int64
+		if err := rows.Scan(&v.Schema, &v.Name, &stale, &state); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		v.Stale = stale == "t"
+		v.Incremental = state == 1 // 0 requires a full recompute, other states cannot be refreshed.
+		v.Skipped = (!v.Stale && !opts.All) || (opts.Incremental && !v.Incremental)
+		views = append(views, v)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	concurrency := opts.Concurrency
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for i := range views {
+		if views[i].Skipped {
+			continue
+		}
+		wg.Add(1)
+		go func(v *MaterializedViewRefresh) {
+			defer wg.Done()
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+				v.Err = ctx.Err()
+				return
+			}
+			defer func() { <-sem }()
+			start := time.Now()
+			_, v.Err = db.ExecContext(ctx, "REFRESH MATERIALIZED VIEW "+quoteIdentifier(v.Schema)+"."+quoteIdentifier(v.Name))
+			v.Duration = time.Since(start)
+			v.Refreshed = v.Err == nil
+		}(&views[i])
+	}
+	wg.Wait()
+	return views, nil
+}
+
+// quoteIdentifier quotes a SQL identifier, doubling the quotes it contains.
+func quoteIdentifier(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
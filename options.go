@@ -0,0 +1,171 @@
+package metasql
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	cfg "github.com/adarsh-jaiss/metasql/config"
+	"github.com/adarsh-jaiss/metasql/hooks"
+	"github.com/adarsh-jaiss/metasql/utils"
+)
+
+type (
+	databaseKey struct{}
+	timeoutKey  struct{}
+	pollingKey  struct{}
+	nameKey     struct{}
+	tokenKey    struct{}
+)
+
+// maxStatementNameLength is the longest StatementName the Data API accepts.
+const maxStatementNameLength = 500
+
+// WithDatabase returns a context under which statements run on database instead of the database of the config.
+// Routing hooks still see, and may change, the database.
+func WithDatabase(ctx context.Context, database string) context.Context {
+	return context.WithValue(ctx, databaseKey{}, database)
+}
+
+// WithStatementTimeout returns a context under which statements are cancelled after d instead of the timeout of the config.
+func WithStatementTimeout(ctx context.Context, d time.Duration) context.Context {
+	return context.WithValue(ctx, timeoutKey{}, d)
+}
+
+// WithPolling returns a context under which the status of statements is polled every d instead of the polling interval of the config.
+func WithPolling(ctx context.Context, d time.Duration) context.Context {
+	return context.WithValue(ctx, pollingKey{}, d)
+}
+
+// WithStatementName returns a context under which statements are submitted with name as their Data API
+// StatementName, such as nightly-etl-step3, so they can be told apart in the Redshift console and found with
+// ListStatements. Names longer than the 500 characters the Data API accepts are truncated.
+func WithStatementName(ctx context.Context, name string) context.Context {
+	return context.WithValue(ctx, nameKey{}, name)
+}
+
+// contextStatementName returns the statement name set with WithStatementName, nil when there is none.
+func contextStatementName(ctx context.Context) *string {
+	name, _ := ctx.Value(nameKey{}).(string)
+	return utils.Nullif(truncateStatementName(name))
+}
+
+// truncateStatementName truncates name to the length of the statement names the Data API accepts.
+func truncateStatementName(name string) string {
+	if runes := []rune(name); len(runes) > maxStatementNameLength {
+		return string(runes[:maxStatementNameLength])
+	}
+	return name
+}
+
+// WithClientToken returns a context under which statements are submitted with token as their Data API
+// ClientToken, so submitting a statement again after a network error whose outcome is unknown does not run it twice:
+// the Data API returns the statement submitted first with the token instead. A token identifies one statement,
+// a context carrying one should only be used to execute a single statement and its resubmissions.
+// Without a token, or with an empty one, every statement is submitted with a generated token.
+//
+// The statements the driver issues on its own under the context, such as the BEGIN and END of transactions or the
+// settings replayed into a restored session, get tokens of their own. When the statement is run as several ones,
+// translated, split into batches or fanned out, each of them is submitted with the token followed by -N, N its index.
+// The helpers running several statements, such as Upsert and CreateTableAs, ignore the token, BulkInsert and
+// RunScript derive one per statement.
+func WithClientToken(ctx context.Context, token string) context.Context {
+	return context.WithValue(ctx, tokenKey{}, token)
+}
+
+// clientToken returns the token set with WithClientToken, or a new one.
+func clientToken(ctx context.Context) *string {
+	token, _ := ctx.Value(tokenKey{}).(string)
+	if token == "" {
+		token = newUUID()
+	}
+	return &token
+}
+
+// withoutClientToken returns ctx without the token set with WithClientToken, for the statements the driver issues
+// on its own, which must not be matched with the statement of the caller.
+func withoutClientToken(ctx context.Context) context.Context {
+	if token, _ := ctx.Value(tokenKey{}).(string); token == "" {
+		return ctx
+	}
+	return WithClientToken(ctx, "")
+}
+
+// withStatementToken returns ctx carrying the token of the i-th of the statements a statement is run as,
+// derived from the token set with WithClientToken so resubmitting the statement stays idempotent.
+func withStatementToken(ctx context.Context, i int) context.Context {
+	token, _ := ctx.Value(tokenKey{}).(string)
+	if token == "" {
+		return ctx
+	}
+	return WithClientToken(ctx, token+"-"+strconv.Itoa(i))
+}
+
+// contextDatabase returns the database set with WithDatabase, "" when there is none.
+func contextDatabase(ctx context.Context) string {
+	database, _ := ctx.Value(databaseKey{}).(string)
+	return database
+}
+
+// statementTimeout returns the timeout set with WithStatementTimeout, or the one of the config.
+func statementTimeout(ctx context.Context, config *cfg.RedshiftDataConfig) time.Duration {
+	if d, ok := ctx.Value(timeoutKey{}).(time.Duration); ok && d > 0 {
+		return d
+	}
+	return config.GetTimeout()
+}
+
+// pollingStrategy returns a fixed polling at the interval set with WithPolling, or the polling strategy of the config.
+func pollingStrategy(ctx context.Context, config *cfg.RedshiftDataConfig) cfg.PollingStrategy {
+	if d, ok := ctx.Value(pollingKey{}).(time.Duration); ok && d > 0 {
+		return cfg.FixedPolling(d)
+	}
+	return config.GetPollingStrategy()
+}
+
+// Options bundles the per-query settings carried by a context, so a service can define them once per class of
+// workload, such as dashboards or batch jobs, instead of chaining With* calls at every call site.
+// Zero fields leave the settings of the context and of the config as they are.
+type Options struct {
+	Database      string             // Database is set with WithDatabase.
+	Timeout       time.Duration      // Timeout is set with WithStatementTimeout.
+	Polling       time.Duration      // Polling is set with WithPolling.
+	Label         string             // Label is set with WithQueryLabel, it tags the statements in the query history.
+	StatementName string             // StatementName is set with WithStatementName.
+	CorrelationID string             // CorrelationID is set with hooks.WithCorrelationID.
+	Decode        *cfg.DecodeOptions // Decode is set with WithDecodeOptions.
+	DiscardRows   bool               // DiscardRows is set with WithDiscardRows.
+	ResultCache   time.Duration      // ResultCache is set with WithResultCache.
+}
+
+// Apply returns ctx carrying the non-zero settings of the options.
+func (o Options) Apply(ctx context.Context) context.Context {
+	if o.Database != "" {
+		ctx = WithDatabase(ctx, o.Database)
+	}
+	if o.Timeout > 0 {
+		ctx = WithStatementTimeout(ctx, o.Timeout)
+	}
+	if o.Polling > 0 {
+		ctx = WithPolling(ctx, o.Polling)
+	}
+	if o.Label != "" {
+		ctx = WithQueryLabel(ctx, o.Label)
+	}
+	if o.StatementName != "" {
+		ctx = WithStatementName(ctx, o.StatementName)
+	}
+	if o.CorrelationID != "" {
+		ctx = hooks.WithCorrelationID(ctx, o.CorrelationID)
+	}
+	if o.Decode != nil {
+		ctx = WithDecodeOptions(ctx, *o.Decode)
+	}
+	if o.DiscardRows {
+		ctx = WithDiscardRows(ctx)
+	}
+	if o.ResultCache != 0 {
+		ctx = WithResultCache(ctx, o.ResultCache)
+	}
+	return ctx
+}
@@ -0,0 +1,204 @@
+package pgwire
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// Frontend and backend message types of the Postgres protocol version 3 that the proxy understands.
+const (
+	msgQuery     = 'Q'
+	msgParse     = 'P'
+	msgBind      = 'B'
+	msgDescribe  = 'D'
+	msgExecute   = 'E'
+	msgSync      = 'S'
+	msgFlush     = 'H'
+	msgClose     = 'C'
+	msgTerminate = 'X'
+	msgPassword  = 'p'
+
+	msgAuthentication       = 'R'
+	msgParameterStatus      = 'S'
+	msgBackendKeyData       = 'K'
+	msgReadyForQuery        = 'Z'
+	msgRowDescription       = 'T'
+	msgDataRow              = 'D'
+	msgCommandComplete      = 'C'
+	msgEmptyQueryResponse   = 'I'
+	msgErrorResponse        = 'E'
+	msgParseComplete        = '1'
+	msgBindComplete         = '2'
+	msgCloseComplete        = '3'
+	msgNoData               = 'n'
+	msgParameterDescription = 't'
+)
+
+const (
+	protocolVersion3  = 196608
+	sslRequestCode    = 80877103
+	gssRequestCode    = 80877104
+	cancelRequestCode = 80877102
+
+	// maxMessageSize guards against garbage length prefixes allocating huge buffers.
+	maxMessageSize = 1 << 26
+)
+
+// readStartup reads an untyped startup packet, returning its code and remaining payload.
+func readStartup(r *bufio.Reader) (uint32, []byte, error) {
+	var header [8]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return 0, nil, err
+	}
+	length := binary.BigEndian.Uint32(header[:4])
+	if length < 8 || length > maxMessageSize {
+		return 0, nil, fmt.Errorf("invalid startup packet length: %d", length)
+	}
+	payload := make([]byte, length-8)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return 0, nil, err
+	}
+	return binary.BigEndian.Uint32(header[4:]), payload, nil
+}
+
+// readMessage reads a typed frontend message.
+func readMessage(r *bufio.Reader) (byte, []byte, error) {
+	var header [5]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return 0, nil, err
+	}
+	length := binary.BigEndian.Uint32(header[1:])
+	if length < 4 || length > maxMessageSize {
+		return 0, nil, fmt.Errorf("invalid message length: %d", length)
+	}
+	payload := make([]byte, length-4)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return 0, nil, err
+	}
+	return header[0], payload, nil
+}
+
+// reader decodes the fields of a message payload. The first decoding error sticks and is reported by err.
+type reader struct {
+	buf []byte
+	err error
+}
+
+func (r *reader) string() string {
+	if r.err != nil {
+		return ""
+	}
+	for i, b := range r.buf {
+		if b == 0 {
+			s := string(r.buf[:i])
+			r.buf = r.buf[i+1:]
+			return s
+		}
+	}
+	r.err = fmt.Errorf("unterminated string in message")
+	return ""
+}
+
+func (r *reader) byte() byte {
+	if r.err != nil {
+		return 0
+	}
+	if len(r.buf) < 1 {
+		r.err = io.ErrUnexpectedEOF
+		return 0
+	}
+	b := r.buf[0]
+	r.buf = r.buf[1:]
+	return b
+}
+
+func (r *reader) int16() int16 {
+	if r.err != nil {
+		return 0
+	}
+	if len(r.buf) < 2 {
+		r.err = io.ErrUnexpectedEOF
+		return 0
+	}
+	v := int16(binary.BigEndian.Uint16(r.buf))
+	r.buf = r.buf[2:]
+	return v
+}
+
+// count reads the int16 number of items that follow, negative counts are an error.
+func (r *reader) count() int {
+	n := r.int16()
+	if n < 0 && r.err == nil {
+		r.err = fmt.Errorf("invalid count %d", n)
+	}
+	return max(int(n), 0)
+}
+
+func (r *reader) int32() int32 {
+	if r.err != nil {
+		return 0
+	}
+	if len(r.buf) < 4 {
+		r.err = io.ErrUnexpectedEOF
+		return 0
+	}
+	v := int32(binary.BigEndian.Uint32(r.buf))
+	r.buf = r.buf[4:]
+	return v
+}
+
+func (r *reader) bytes(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if n < 0 || len(r.buf) < n {
+		r.err = io.ErrUnexpectedEOF
+		return nil
+	}
+	b := r.buf[:n]
+	r.buf = r.buf[n:]
+	return b
+}
+
+// writer builds a single backend message.
+type writer struct {
+	buf []byte
+}
+
+func newMessage(typ byte) *writer {
+	return &writer{buf: []byte{typ, 0, 0, 0, 0}}
+}
+
+func (w *writer) string(s string) *writer {
+	w.buf = append(w.buf, s...)
+	w.buf = append(w.buf, 0)
+	return w
+}
+
+func (w *writer) byte(b byte) *writer {
+	w.buf = append(w.buf, b)
+	return w
+}
+
+func (w *writer) int16(v int16) *writer {
+	w.buf = binary.BigEndian.AppendUint16(w.buf, uint16(v))
+	return w
+}
+
+func (w *writer) int32(v int32) *writer {
+	w.buf = binary.BigEndian.AppendUint32(w.buf, uint32(v))
+	return w
+}
+
+func (w *writer) bytes(b []byte) *writer {
+	w.buf = append(w.buf, b...)
+	return w
+}
+
+// finish fills in the length prefix and returns the encoded message.
+func (w *writer) finish() []byte {
+	binary.BigEndian.PutUint32(w.buf[1:5], uint32(len(w.buf)-1))
+	return w.buf
+}
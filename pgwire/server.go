@@ -0,0 +1,233 @@
+// Package pgwire implements a Postgres wire-protocol proxy in front of a metasql *sql.DB.
+// It lets Postgres clients such as psql, DBeaver or Metabase reach Redshift through the Data API,
+// so no JDBC driver or open 5439 port is needed, only IAM access from the host running the proxy.
+//
+// The simple query protocol is fully supported. The extended query protocol is supported with text
+// parameters; describing a statement before it is bound reports its parameters but not its columns.
+package pgwire
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"database/sql"
+	"fmt"
+	"log"
+	"net"
+	"runtime/debug"
+	"sync"
+	"sync/atomic"
+)
+
+// Server accepts Postgres protocol connections and executes their queries on DB.
+type Server struct {
+	DB           *sql.DB                          // DB is the metasql database the queries are executed on.
+	Authenticate func(user, password string) bool // Authenticate checks the cleartext password of a client, all clients are trusted when nil.
+	TLSConfig    *tls.Config                      // TLSConfig is used to answer SSL requests, they are declined when nil.
+	RequireTLS   bool                             // RequireTLS rejects the clients that did not request SSL before sending their password.
+	ErrorLog     *log.Logger                      // ErrorLog receives connection errors, the standard logger is used when nil.
+	Parameters   map[string]string                // Parameters are additional ParameterStatus values reported to clients.
+
+	mu        sync.Mutex
+	listeners map[net.Listener]struct{}
+	nextPID   atomic.Int32
+}
+
+// NewServer returns a Server executing queries on db.
+func NewServer(db *sql.DB) *Server {
+	return &Server{
+		DB: db,
+	}
+}
+
+// ListenAndServe listens on the TCP address addr and serves connections until the server is closed.
+func (s *Server) ListenAndServe(addr string) error {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return s.Serve(l)
+}
+
+// Serve accepts connections on l, each connection is served in its own goroutine.
+func (s *Server) Serve(l net.Listener) error {
+	s.mu.Lock()
+	if s.listeners == nil {
+		s.listeners = make(map[net.Listener]struct{})
+	}
+	s.listeners[l] = struct{}{}
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.listeners, l)
+		s.mu.Unlock()
+	}()
+
+	for {
+		nc, err := l.Accept()
+		if err != nil {
+			return err
+		}
+		go s.serveConn(nc)
+	}
+}
+
+// Close stops all listeners, connections being served are left to finish on their own.
+func (s *Server) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var err error
+	for l := range s.listeners {
+		if cerr := l.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+func (s *Server) logf(format string, args ...any) {
+	if s.ErrorLog != nil {
+		s.ErrorLog.Printf(format, args...)
+		return
+	}
+	log.Printf(format, args...)
+}
+
+func (s *Server) serveConn(nc net.Conn) {
+	defer nc.Close()
+	defer func() {
+		// a malformed message must only end its own connection
+		if err := recover(); err != nil {
+			s.logf("pgwire: connection %s: panic: %v\n%s", nc.RemoteAddr(), err, debug.Stack())
+		}
+	}()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	sess, err := s.startup(ctx, nc)
+	if err != nil {
+		s.logf("pgwire: startup %s: %v", nc.RemoteAddr(), err)
+		return
+	}
+	defer sess.close()
+	if err := sess.serve(ctx); err != nil {
+		s.logf("pgwire: connection %s: %v", nc.RemoteAddr(), err)
+	}
+}
+
+// startup negotiates SSL, reads the startup parameters and authenticates the client.
+func (s *Server) startup(ctx context.Context, nc net.Conn) (*session, error) {
+	r := bufio.NewReader(nc)
+	for {
+		code, payload, err := readStartup(r)
+		if err != nil {
+			return nil, err
+		}
+		switch code {
+		case sslRequestCode:
+			if s.TLSConfig == nil {
+				if _, err := nc.Write([]byte{'N'}); err != nil {
+					return nil, err
+				}
+				continue
+			}
+			if _, err := nc.Write([]byte{'S'}); err != nil {
+				return nil, err
+			}
+			tc := tls.Server(nc, s.TLSConfig)
+			if err := tc.HandshakeContext(ctx); err != nil {
+				return nil, fmt.Errorf("tls handshake: %w", err)
+			}
+			nc = tc
+			r = bufio.NewReader(nc)
+		case gssRequestCode:
+			if _, err := nc.Write([]byte{'N'}); err != nil {
+				return nil, err
+			}
+		case cancelRequestCode:
+			return nil, fmt.Errorf("cancel request is not supported")
+		case protocolVersion3:
+			return s.authenticate(ctx, nc, r, parseStartupParams(payload))
+		default:
+			return nil, fmt.Errorf("unsupported protocol version: %d", code)
+		}
+	}
+}
+
+func parseStartupParams(payload []byte) map[string]string {
+	params := make(map[string]string)
+	pr := &reader{buf: payload}
+	for {
+		key := pr.string()
+		if key == "" || pr.err != nil {
+			return params
+		}
+		params[key] = pr.string()
+	}
+}
+
+func (s *Server) authenticate(ctx context.Context, nc net.Conn, r *bufio.Reader, params map[string]string) (*session, error) {
+	w := bufio.NewWriter(nc)
+	sess := &session{
+		server:     s,
+		r:          r,
+		w:          w,
+		txStatus:   'I',
+		statements: make(map[string]*statement),
+		portals:    make(map[string]*portal),
+	}
+	if s.Authenticate != nil {
+		if _, ok := nc.(*tls.Conn); !ok && s.RequireTLS {
+			sess.sendError(fmt.Errorf("SSL is required to authenticate"), "28000")
+			w.Flush()
+			return nil, fmt.Errorf("client %q did not request SSL", params["user"])
+		}
+		sess.send(newMessage(msgAuthentication).int32(3))
+		if err := w.Flush(); err != nil {
+			return nil, err
+		}
+		typ, payload, err := readMessage(r)
+		if err != nil {
+			return nil, err
+		}
+		pr := &reader{buf: payload}
+		password := pr.string()
+		if typ != msgPassword || pr.err != nil || !s.Authenticate(params["user"], password) {
+			sess.sendError(fmt.Errorf("password authentication failed for user %q", params["user"]), "28P01")
+			w.Flush()
+			return nil, fmt.Errorf("authentication failed for user %q", params["user"])
+		}
+	}
+
+	conn, err := s.DB.Conn(ctx)
+	if err != nil {
+		sess.sendError(err, "08006")
+		w.Flush()
+		return nil, err
+	}
+	sess.conn = conn
+
+	sess.send(newMessage(msgAuthentication).int32(0))
+	status := map[string]string{
+		"server_version":              "8.0.2",
+		"server_encoding":             "UTF8",
+		"client_encoding":             "UTF8",
+		"DateStyle":                   "ISO, MDY",
+		"integer_datetimes":           "on",
+		"standard_conforming_strings": "on",
+		"application_name":            params["application_name"],
+	}
+	for k, v := range s.Parameters {
+		status[k] = v
+	}
+	for k, v := range status {
+		sess.send(newMessage(msgParameterStatus).string(k).string(v))
+	}
+	sess.send(newMessage(msgBackendKeyData).int32(s.nextPID.Add(1)).int32(0))
+	sess.sendReady()
+	if err := w.Flush(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return sess, nil
+}
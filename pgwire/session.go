@@ -0,0 +1,569 @@
+package pgwire
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// queryer is implemented by both *sql.Conn and *sql.Tx.
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// statement is a query prepared with a Parse message.
+type statement struct {
+	query      string
+	paramCount int
+}
+
+// portal is a statement bound to parameter values with a Bind message.
+// rows is set once the portal has been executed by a Describe, so Execute can stream them.
+type portal struct {
+	stmt  *statement
+	args  []any
+	rows  *sql.Rows
+	tag   string
+	ready bool
+}
+
+// errTransactionAborted is returned for the statements run in a transaction after one of them failed,
+// until the transaction is ended.
+var errTransactionAborted = errors.New("current transaction is aborted, commands ignored until end of transaction block")
+
+// session is the state of one client connection.
+type session struct {
+	server     *Server
+	r          *bufio.Reader
+	w          *bufio.Writer
+	conn       *sql.Conn
+	tx         *sql.Tx
+	txStatus   byte // txStatus is reported by ReadyForQuery: 'I' when idle, 'T' in a transaction, 'E' in a failed one.
+	statements map[string]*statement
+	portals    map[string]*portal
+	failed     bool // failed is set after an error in the extended protocol, messages are skipped until Sync.
+}
+
+func (sess *session) close() {
+	for _, p := range sess.portals {
+		if p.rows != nil {
+			p.rows.Close()
+		}
+	}
+	if sess.tx != nil {
+		sess.tx.Rollback()
+	}
+	sess.conn.Close()
+}
+
+func (sess *session) send(w *writer) {
+	sess.w.Write(w.finish())
+}
+
+func (sess *session) sendReady() {
+	sess.send(newMessage(msgReadyForQuery).byte(sess.txStatus))
+}
+
+func (sess *session) sendError(err error, code string) {
+	sess.send(newMessage(msgErrorResponse).
+		byte('S').string("ERROR").
+		byte('V').string("ERROR").
+		byte('C').string(code).
+		byte('M').string(err.Error()).
+		byte(0))
+}
+
+func (sess *session) serve(ctx context.Context) error {
+	for {
+		typ, payload, err := readMessage(sess.r)
+		if err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		if typ == msgTerminate {
+			return nil
+		}
+		if sess.failed && typ != msgSync {
+			continue
+		}
+		if err := sess.handle(ctx, typ, &reader{buf: payload}); err != nil {
+			code := "XX000"
+			if err == errTransactionAborted {
+				code = "25P02"
+			}
+			if sess.tx != nil {
+				sess.txStatus = 'E'
+			}
+			sess.sendError(err, code)
+			if typ == msgQuery {
+				sess.sendReady()
+			} else {
+				sess.failed = true
+			}
+		}
+		if typ == msgQuery || typ == msgSync || typ == msgFlush {
+			if err := sess.w.Flush(); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (sess *session) handle(ctx context.Context, typ byte, r *reader) error {
+	switch typ {
+	case msgQuery:
+		return sess.handleQuery(ctx, r)
+	case msgParse:
+		return sess.handleParse(r)
+	case msgBind:
+		return sess.handleBind(r)
+	case msgDescribe:
+		return sess.handleDescribe(ctx, r)
+	case msgExecute:
+		return sess.handleExecute(ctx, r)
+	case msgClose:
+		return sess.handleClose(r)
+	case msgSync:
+		sess.failed = false
+		sess.sendReady()
+		return nil
+	case msgFlush:
+		return nil
+	}
+	return fmt.Errorf("unsupported message type %q", typ)
+}
+
+func (sess *session) queryer() queryer {
+	if sess.tx != nil {
+		return sess.tx
+	}
+	return sess.conn
+}
+
+func (sess *session) handleQuery(ctx context.Context, r *reader) error {
+	query := r.string()
+	if r.err != nil {
+		return r.err
+	}
+	if strings.TrimSpace(query) == "" {
+		sess.send(newMessage(msgEmptyQueryResponse))
+		sess.sendReady()
+		return nil
+	}
+	p := &portal{stmt: &statement{query: query}}
+	if err := sess.execute(ctx, p); err != nil {
+		return err
+	}
+	if p.rows != nil {
+		if err := sess.sendRowDescription(p.rows); err != nil {
+			p.rows.Close()
+			return err
+		}
+	}
+	if err := sess.sendResult(p); err != nil {
+		return err
+	}
+	sess.sendReady()
+	return nil
+}
+
+var placeholderPattern = regexp.MustCompile(`\$([0-9]+)`)
+
+func (sess *session) handleParse(r *reader) error {
+	name := r.string()
+	query := r.string()
+	paramCount := r.count()
+	for i := 0; i < paramCount; i++ {
+		r.int32()
+	}
+	if r.err != nil {
+		return r.err
+	}
+	for _, m := range placeholderPattern.FindAllStringSubmatch(query, -1) {
+		if n, err := strconv.Atoi(m[1]); err == nil && n > paramCount {
+			paramCount = n
+		}
+	}
+	sess.statements[name] = &statement{query: query, paramCount: paramCount}
+	sess.send(newMessage(msgParseComplete))
+	return nil
+}
+
+func (sess *session) handleBind(r *reader) error {
+	portalName := r.string()
+	stmtName := r.string()
+	formats := make([]int16, r.count())
+	for i := range formats {
+		formats[i] = r.int16()
+	}
+	args := make([]any, r.count())
+	for i := range args {
+		n := r.int32()
+		if n < 0 {
+			args[i] = nil
+			continue
+		}
+		format := int16(0)
+		if len(formats) == 1 {
+			format = formats[0]
+		} else if i < len(formats) {
+			format = formats[i]
+		}
+		if format != 0 {
+			return fmt.Errorf("binary parameter format is not supported")
+		}
+		args[i] = string(r.bytes(int(n)))
+	}
+	for n := r.count(); n > 0; n-- {
+		if r.int16() != 0 {
+			return fmt.Errorf("binary result format is not supported")
+		}
+	}
+	if r.err != nil {
+		return r.err
+	}
+	stmt, ok := sess.statements[stmtName]
+	if !ok {
+		return fmt.Errorf("prepared statement %q does not exist", stmtName)
+	}
+	if old, ok := sess.portals[portalName]; ok && old.rows != nil {
+		old.rows.Close()
+	}
+	sess.portals[portalName] = &portal{stmt: stmt, args: args}
+	sess.send(newMessage(msgBindComplete))
+	return nil
+}
+
+func (sess *session) handleDescribe(ctx context.Context, r *reader) error {
+	kind := r.byte()
+	name := r.string()
+	if r.err != nil {
+		return r.err
+	}
+	if kind == 'S' {
+		stmt, ok := sess.statements[name]
+		if !ok {
+			return fmt.Errorf("prepared statement %q does not exist", name)
+		}
+		m := newMessage(msgParameterDescription).int16(int16(stmt.paramCount))
+		for i := 0; i < stmt.paramCount; i++ {
+			m.int32(oidText)
+		}
+		sess.send(m)
+		sess.send(newMessage(msgNoData))
+		return nil
+	}
+	p, ok := sess.portals[name]
+	if !ok {
+		return fmt.Errorf("portal %q does not exist", name)
+	}
+	if !p.ready {
+		if err := sess.execute(ctx, p); err != nil {
+			return err
+		}
+	}
+	if p.rows == nil {
+		sess.send(newMessage(msgNoData))
+		return nil
+	}
+	return sess.sendRowDescription(p.rows)
+}
+
+func (sess *session) handleExecute(ctx context.Context, r *reader) error {
+	name := r.string()
+	r.int32() // the row limit is ignored, portals are always run to completion
+	if r.err != nil {
+		return r.err
+	}
+	p, ok := sess.portals[name]
+	if !ok {
+		return fmt.Errorf("portal %q does not exist", name)
+	}
+	if !p.ready {
+		if err := sess.execute(ctx, p); err != nil {
+			return err
+		}
+	}
+	return sess.sendResult(p)
+}
+
+func (sess *session) handleClose(r *reader) error {
+	kind := r.byte()
+	name := r.string()
+	if r.err != nil {
+		return r.err
+	}
+	if kind == 'S' {
+		delete(sess.statements, name)
+	} else {
+		if p, ok := sess.portals[name]; ok && p.rows != nil {
+			p.rows.Close()
+		}
+		delete(sess.portals, name)
+	}
+	sess.send(newMessage(msgCloseComplete))
+	return nil
+}
+
+// execute runs the portal's statement. Queries leave their rows open on the portal, other statements only set the tag.
+// Transaction control statements are mapped onto database/sql transactions of the session's connection.
+// Once a statement failed in a transaction, the others are rejected until it is ended, and COMMIT rolls it back.
+func (sess *session) execute(ctx context.Context, p *portal) error {
+	query := p.stmt.query
+	keyword := firstKeyword(query)
+	if sess.txStatus == 'E' {
+		switch keyword {
+		case "COMMIT", "END", "ROLLBACK", "ABORT":
+			keyword = "ROLLBACK"
+		default:
+			return errTransactionAborted
+		}
+	}
+	switch keyword {
+	case "BEGIN", "START":
+		if sess.tx != nil {
+			return fmt.Errorf("there is already a transaction in progress")
+		}
+		tx, err := sess.conn.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		sess.tx = tx
+		sess.txStatus = 'T'
+		p.tag, p.ready = "BEGIN", true
+		return nil
+	case "COMMIT", "END", "ROLLBACK", "ABORT":
+		if sess.tx == nil {
+			p.tag, p.ready = keyword, true
+			return nil
+		}
+		var err error
+		if keyword == "COMMIT" || keyword == "END" {
+			err = sess.tx.Commit()
+			p.tag = "COMMIT"
+		} else {
+			err = sess.tx.Rollback()
+			p.tag = "ROLLBACK"
+		}
+		sess.tx = nil
+		sess.txStatus = 'I'
+		p.ready = true
+		return err
+	case "SELECT", "WITH", "SHOW", "VALUES", "EXPLAIN", "TABLE":
+		rows, err := sess.queryer().QueryContext(ctx, query, p.args...)
+		if err != nil {
+			return err
+		}
+		p.rows, p.ready = rows, true
+		return nil
+	}
+	res, err := sess.queryer().ExecContext(ctx, query, p.args...)
+	if err != nil {
+		return err
+	}
+	p.tag, p.ready = commandTag(query, keyword, res), true
+	return nil
+}
+
+func (sess *session) sendRowDescription(rows *sql.Rows) error {
+	types, err := rows.ColumnTypes()
+	if err != nil {
+		return err
+	}
+	m := newMessage(msgRowDescription).int16(int16(len(types)))
+	for _, t := range types {
+		m.string(t.Name()).
+			int32(0).          // table oid
+			int16(0).          // column attribute number
+			int32(typeOID(t)). // type oid
+			int16(-1).         // type size
+			int32(-1).         // type modifier
+			int16(0)           // text format
+	}
+	sess.send(m)
+	return nil
+}
+
+// sendResult streams the open rows of the portal, if any, followed by the command tag.
+func (sess *session) sendResult(p *portal) error {
+	p.ready = false
+	if p.rows == nil {
+		sess.send(newMessage(msgCommandComplete).string(p.tag))
+		return nil
+	}
+	rows := p.rows
+	p.rows = nil
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return err
+	}
+	values := make([]any, len(columns))
+	dest := make([]any, len(columns))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	var count int
+	for rows.Next() {
+		if err := rows.Scan(dest...); err != nil {
+			return err
+		}
+		m := newMessage(msgDataRow).int16(int16(len(values)))
+		for _, v := range values {
+			text, ok := formatText(v)
+			if !ok {
+				m.int32(-1)
+				continue
+			}
+			m.int32(int32(len(text))).bytes(text)
+		}
+		sess.send(m)
+		count++
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	sess.send(newMessage(msgCommandComplete).string(fmt.Sprintf("SELECT %d", count)))
+	return nil
+}
+
+// firstKeyword returns the upper-cased first word of the query, skipping comments and parentheses.
+func firstKeyword(query string) string {
+	for {
+		query = strings.TrimLeft(query, " \t\r\n(")
+		switch {
+		case strings.HasPrefix(query, "--"):
+			if i := strings.IndexByte(query, '\n'); i >= 0 {
+				query = query[i+1:]
+				continue
+			}
+			return ""
+		case strings.HasPrefix(query, "/*"):
+			if i := strings.Index(query, "*/"); i >= 0 {
+				query = query[i+2:]
+				continue
+			}
+			return ""
+		}
+		break
+	}
+	end := strings.IndexFunc(query, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z')
+	})
+	if end < 0 {
+		end = len(query)
+	}
+	return strings.ToUpper(query[:end])
+}
+
+func commandTag(query, keyword string, res sql.Result) string {
+	n, err := res.RowsAffected()
+	if err != nil {
+		n = 0
+	}
+	switch keyword {
+	case "INSERT":
+		return fmt.Sprintf("INSERT 0 %d", n)
+	case "UPDATE", "DELETE", "COPY", "MERGE":
+		return fmt.Sprintf("%s %d", keyword, n)
+	case "CREATE", "DROP", "ALTER":
+		fields := strings.Fields(strings.ToUpper(query))
+		if len(fields) >= 2 {
+			return fields[0] + " " + fields[1]
+		}
+	}
+	return keyword
+}
+
+// Type oids reported in row descriptions, everything unknown is reported as text.
+const (
+	oidBool        = 16
+	oidBytea       = 17
+	oidInt8        = 20
+	oidInt2        = 21
+	oidInt4        = 23
+	oidText        = 25
+	oidFloat4      = 700
+	oidFloat8      = 701
+	oidBpchar      = 1042
+	oidVarchar     = 1043
+	oidDate        = 1082
+	oidTime        = 1083
+	oidTimestamp   = 1114
+	oidTimestamptz = 1184
+	oidNumeric     = 1700
+)
+
+func typeOID(t *sql.ColumnType) int32 {
+	switch strings.ToLower(t.DatabaseTypeName()) {
+	case "bool", "boolean":
+		return oidBool
+	case "varbyte", "varbinary", "binary varying", "bytea":
+		return oidBytea
+	case "int8", "bigint":
+		return oidInt8
+	case "int2", "smallint":
+		return oidInt2
+	case "int4", "integer", "int":
+		return oidInt4
+	case "float4", "real":
+		return oidFloat4
+	case "float8", "float", "double precision":
+		return oidFloat8
+	case "bpchar", "char", "character":
+		return oidBpchar
+	case "varchar", "character varying":
+		return oidVarchar
+	case "date":
+		return oidDate
+	case "time":
+		return oidTime
+	case "timestamp":
+		return oidTimestamp
+	case "timestamptz":
+		return oidTimestamptz
+	case "numeric", "decimal":
+		return oidNumeric
+	}
+	return oidText
+}
+
+// formatText renders a scanned value in the Postgres text format, ok is false for NULL.
+func formatText(v any) ([]byte, bool) {
+	switch v := v.(type) {
+	case nil:
+		return nil, false
+	case []byte:
+		// binary values use the hex format of bytea
+		text := make([]byte, 2+hex.EncodedLen(len(v)))
+		copy(text, `\x`)
+		hex.Encode(text[2:], v)
+		return text, true
+	case string:
+		return []byte(v), true
+	case bool:
+		if v {
+			return []byte("t"), true
+		}
+		return []byte("f"), true
+	case int64:
+		return strconv.AppendInt(nil, v, 10), true
+	case float64:
+		return strconv.AppendFloat(nil, v, 'g', -1, 64), true
+	case time.Time:
+		return []byte(v.Format("2006-01-02 15:04:05.999999Z07:00")), true
+	}
+	return []byte(fmt.Sprint(v)), true
+}
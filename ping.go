@@ -0,0 +1,18 @@
+package metasql
+
+import (
+	"context"
+	"database/sql/driver"
+
+	"github.com/adarsh-jaiss/metasql/hooks"
+)
+
+// Ping runs SELECT 1 through the Data API, so db.PingContext checks the credentials, the target and that a
+// paused serverless workgroup can resume. A closed connection reports driver.ErrBadConn, so the pool drops it.
+func (conn *redshiftDataConn) Ping(ctx context.Context) error {
+	if conn.isClosed {
+		return driver.ErrBadConn
+	}
+	_, _, err := conn.runStatement(WithDiscardRows(withoutClientToken(ctx)), hooks.KindPing, "SELECT 1", nil)
+	return err
+}
@@ -0,0 +1,365 @@
+package metasql
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	cfg "github.com/adarsh-jaiss/metasql/config"
+	"github.com/adarsh-jaiss/metasql/errors"
+)
+
+// queryPlaceholders are the parameters a query refers to.
+type queryPlaceholders struct {
+	positional []int    // positional holds the distinct numbers of the ?, $N and :N placeholders, in order.
+	named      []string // named holds the distinct names of the :name placeholders, in order.
+}
+
+// scanPlaceholders finds the placeholders of query in the style of the config, see walkPlaceholders.
+// Each ? counts as the next number, as rewriteQuery numbers them.
+func scanPlaceholders(query string, style string) queryPlaceholders {
+	var ph queryPlaceholders
+	walkPlaceholders([]rune(query), style, func(_, _ int, n int, name string) {
+		if name == "" {
+			ph.addPositional(n)
+		} else if !slices.Contains(ph.named, name) {
+			ph.named = append(ph.named, name)
+		}
+	})
+	return ph
+}
+
+// rewriteQuery rewrites the ?, $N and $name placeholders of the query into the :N and :name placeholders of the Data API.
+// As in Postgres, the same $N used several times refers to a single parameter. Queries without arguments
+// are returned as they are, as are the placeholders the style of the config leaves alone.
+func rewriteQuery(query string, paramsCount int, style string) string {
+	if paramsCount == 0 {
+		return query
+	}
+	runes := []rune(query)
+	var b strings.Builder
+	last := 0
+	walkPlaceholders(runes, style, func(start, end int, n int, name string) {
+		if runes[start] == ':' {
+			return
+		}
+		b.WriteString(string(runes[last:start]))
+		if name != "" {
+			b.WriteString(":" + name)
+		} else {
+			b.WriteString(":" + strconv.Itoa(n))
+		}
+		last = end
+	})
+	b.WriteString(string(runes[last:]))
+	return b.String()
+}
+
+// walkPlaceholders calls fn with the bounds of every ?, $N, :N, $name and :name placeholder of the query, and either its
+// number or its name. Only placeholder tokens are reported, see tokenize: strings, quoted identifiers,
+// dollar-quoted strings, comments, :: casts and identifiers containing $ never hold placeholders. The $N of a
+// PREPARE statement are the parameters of the prepared statement, so PREPARE statements have no placeholders.
+// Placeholders of the styles style excludes are skipped, see isPlaceholder.
+func walkPlaceholders(runes []rune, style string, fn func(start, end int, n int, name string)) {
+	if isPrepare(runes) {
+		return
+	}
+	question := 0
+	for _, tok := range tokenize(runes) {
+		if tok.kind != tokenPlaceholder || !isPlaceholder(style, runes[tok.start]) {
+			continue
+		}
+		n := tok.n
+		if runes[tok.start] == '?' {
+			question++
+			n = question
+		}
+		fn(tok.start, tok.end, n, tok.name)
+	}
+}
+
+// isPlaceholder reports whether placeholders starting with r are placeholders in style, see config.Placeholders.
+// The :N and :name placeholders of the Data API are placeholders in every style but PlaceholdersNone.
+func isPlaceholder(style string, r rune) bool {
+	switch style {
+	case cfg.PlaceholdersQuestion:
+		return r == '?' || r == ':'
+	case cfg.PlaceholdersDollar:
+		return r == '$' || r == ':'
+	case cfg.PlaceholdersColon:
+		return r == ':'
+	case cfg.PlaceholdersNone:
+		return false
+	}
+	return true
+}
+
+// isPrepare reports whether the query is a PREPARE statement.
+func isPrepare(runes []rune) bool {
+	return leadingKeyword(string(runes)) == "PREPARE"
+}
+
+func (ph *queryPlaceholders) addPositional(n int) {
+	if !slices.Contains(ph.positional, n) {
+		ph.positional = append(ph.positional, n)
+	}
+}
+
+// checkParameters verifies that every placeholder of query has an argument and every argument is referred to,
+// so mismatches fail before the statement is submitted rather than binding NULL or failing in Redshift.
+// Queries are not checked in the PlaceholdersNone style.
+func checkParameters(query string, args []driver.NamedValue, style string) error {
+	if style == cfg.PlaceholdersNone {
+		return nil
+	}
+	return checkPlaceholders(scanPlaceholders(query, style), args, style)
+}
+
+// checkPlaceholders verifies the arguments against the placeholders of a query, see checkParameters.
+func checkPlaceholders(ph queryPlaceholders, args []driver.NamedValue, style string) error {
+	if style == cfg.PlaceholdersNone {
+		return nil
+	}
+	var ordinals []int
+	var names []string
+	for _, arg := range args {
+		if arg.Name == "" {
+			ordinals = append(ordinals, arg.Ordinal)
+		} else {
+			names = append(names, arg.Name)
+		}
+	}
+	if err := checkNamedParameters(ph.named, names); err != nil {
+		return err
+	}
+	var missing, extra []string
+	for _, n := range ph.positional {
+		if !slices.Contains(ordinals, n) {
+			missing = append(missing, ":"+strconv.Itoa(n))
+		}
+	}
+	for _, n := range ordinals {
+		if !slices.Contains(ph.positional, n) {
+			extra = append(extra, ":"+strconv.Itoa(n))
+		}
+	}
+	if len(missing) > 0 || len(extra) > 0 {
+		return errors.NewParameterCountError(len(ph.positional), len(ordinals), missing, extra)
+	}
+	return nil
+}
+
+// checkNamedParameters matches the :name placeholders of a query against the names of its sql.Named arguments.
+func checkNamedParameters(placeholders, names []string) error {
+	var unbound, unused []string
+	for _, name := range placeholders {
+		if !slices.Contains(names, name) {
+			unbound = append(unbound, name)
+		}
+	}
+	for _, name := range names {
+		if !slices.Contains(placeholders, name) {
+			unused = append(unused, name)
+		}
+	}
+	if len(unbound) > 0 || len(unused) > 0 {
+		return errors.NewUnboundParameterError(unbound, unused)
+	}
+	return nil
+}
+
+// castPlaceholders appends an explicit cast to the placeholders of the rewritten query whose argument has a
+// numeric, boolean or time Go type. Parameters travel as strings, so without a cast Redshift infers their type
+// from the context, which can compare numbers as text or keep an index from being used.
+// Placeholders already followed by a cast, and arguments of other types, are left as they are.
+func castPlaceholders(query string, args []driver.NamedValue, style string) string {
+	if len(args) == 0 {
+		return query
+	}
+	runes := []rune(query)
+	var b strings.Builder
+	last := 0
+	walkPlaceholders(runes, style, func(_, end int, n int, name string) {
+		if end+1 < len(runes) && runes[end] == ':' && runes[end+1] == ':' {
+			return
+		}
+		for _, arg := range args {
+			if name == "" && arg.Name == "" && arg.Ordinal == n || name != "" && arg.Name == name {
+				if cast := castType(arg.Value); cast != "" {
+					b.WriteString(string(runes[last:end]))
+					b.WriteString("::" + cast)
+					last = end
+				}
+				return
+			}
+		}
+	})
+	if last == 0 {
+		return query
+	}
+	b.WriteString(string(runes[last:]))
+	return b.String()
+}
+
+// castType returns the Redshift type a parameter of the Go type of v is cast to, "" when it is not cast.
+func castType(v any) string {
+	switch v.(type) {
+	case int, int8, int16, int32, int64, uint8, uint16, uint32:
+		return "BIGINT"
+	case uint, uint64:
+		return "DECIMAL(20,0)"
+	case float32, float64:
+		return "DOUBLE PRECISION"
+	case bool:
+		return "BOOLEAN"
+	case time.Time:
+		return "TIMESTAMP"
+	}
+	return ""
+}
+
+// withDefaultParameters appends the default parameters of the config among the named parameters of a query
+// the arguments do not bind already.
+func withDefaultParameters(named []string, args []driver.NamedValue, config *cfg.RedshiftDataConfig) []driver.NamedValue {
+	defaults := config.DefaultParameters()
+	if len(defaults) == 0 {
+		return args
+	}
+	for _, name := range named {
+		value, ok := defaults[name]
+		if !ok || slices.ContainsFunc(args, func(arg driver.NamedValue) bool { return arg.Name == name }) {
+			continue
+		}
+		args = append(slices.Clip(args), driver.NamedValue{Name: name, Ordinal: len(args) + 1, Value: value})
+	}
+	return args
+}
+
+// listValue is a slice argument, bound to one parameter per element by expandListPlaceholders.
+type listValue []driver.Value
+
+// checkListValue converts the elements of a slice or array argument, other than []byte, as checkNamedValue
+// converts arguments. ok is false for arguments of other types.
+func checkListValue(v any, encoder cfg.ParameterEncoder) (list listValue, ok bool, err error) {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array || rv.Type().Elem().Kind() == reflect.Uint8 {
+		return nil, false, nil
+	}
+	if rv.Len() == 0 {
+		return nil, true, fmt.Errorf("empty list, IN () is not valid SQL")
+	}
+	list = make(listValue, rv.Len())
+	for i := range list {
+		elem := driver.NamedValue{Ordinal: i + 1, Value: rv.Index(i).Interface()}
+		if err := checkNamedValue(&elem, encoder); err != nil {
+			return nil, true, fmt.Errorf("list element: %w", err)
+		}
+		if _, nested := elem.Value.(listValue); nested {
+			return nil, true, fmt.Errorf("list element %d: nested lists are not supported", i+1)
+		}
+		list[i] = elem.Value
+	}
+	return list, true, nil
+}
+
+// expandListPlaceholders expands the placeholders of the rewritten query bound to a slice argument into one
+// placeholder per element, so WHERE id IN (?) binds a slice of ids: :1 becomes :p1_1, :p1_2, ... and :ids
+// becomes :ids_1, :ids_2, ..., each element bound to its own named parameter. Queries are left as they are in
+// the PlaceholdersNone style, where slice arguments are rejected.
+func expandListPlaceholders(query string, args []driver.NamedValue, style string) (string, []driver.NamedValue) {
+	isList := func(arg driver.NamedValue) bool {
+		_, ok := arg.Value.(listValue)
+		return ok
+	}
+	if style == cfg.PlaceholdersNone || !slices.ContainsFunc(args, isList) {
+		return query, args
+	}
+	runes := []rune(query)
+	var b strings.Builder
+	last := 0
+	walkPlaceholders(runes, style, func(start, end int, n int, name string) {
+		for _, arg := range args {
+			if !(name == "" && arg.Name == "" && arg.Ordinal == n || name != "" && arg.Name == name) {
+				continue
+			}
+			if list, ok := arg.Value.(listValue); ok {
+				b.WriteString(string(runes[last:start]))
+				for i := range list {
+					if i > 0 {
+						b.WriteString(", ")
+					}
+					b.WriteString(":" + listElementName(arg, i))
+				}
+				last = end
+			}
+			return
+		}
+	})
+	b.WriteString(string(runes[last:]))
+	expanded := make([]driver.NamedValue, 0, len(args))
+	for _, arg := range args {
+		list, ok := arg.Value.(listValue)
+		if !ok {
+			expanded = append(expanded, arg)
+			continue
+		}
+		for i, v := range list {
+			expanded = append(expanded, driver.NamedValue{Name: listElementName(arg, i), Ordinal: arg.Ordinal, Value: v})
+		}
+	}
+	return b.String(), expanded
+}
+
+// listElementName returns the name of the parameter binding the i-th element of a slice argument.
+func listElementName(arg driver.NamedValue, i int) string {
+	if arg.Name != "" {
+		return fmt.Sprintf("%s_%d", arg.Name, i+1)
+	}
+	return fmt.Sprintf("p%d_%d", arg.Ordinal, i+1)
+}
+
+// bindTypedPlaceholders rewrites the placeholders of the rewritten query whose argument the Data API can not bind
+// as a plain string: nil arguments become NULL literals and are dropped, as parameters can not be null, and
+// []byte arguments, sent hex-encoded, are decoded into a VARBYTE with FROM_HEX. Arguments encoded by the
+// ParameterEncoder of the config are left as they are, as are all arguments in the PlaceholdersNone style.
+func bindTypedPlaceholders(query string, args []driver.NamedValue, config *cfg.RedshiftDataConfig) (string, []driver.NamedValue) {
+	typed := func(arg driver.NamedValue) bool {
+		if _, ok, _ := encodeParameter(config.ParameterEncoder, arg.Value); ok {
+			return false
+		}
+		_, isBytes := arg.Value.([]byte)
+		return arg.Value == nil || isBytes
+	}
+	if config.Placeholders == cfg.PlaceholdersNone || !slices.ContainsFunc(args, typed) {
+		return query, args
+	}
+	runes := []rune(query)
+	var b strings.Builder
+	last := 0
+	walkPlaceholders(runes, config.Placeholders, func(start, end int, n int, name string) {
+		for _, arg := range args {
+			if !(name == "" && arg.Name == "" && arg.Ordinal == n || name != "" && arg.Name == name) {
+				continue
+			}
+			if typed(arg) {
+				b.WriteString(string(runes[last:start]))
+				if arg.Value == nil {
+					b.WriteString("NULL")
+				} else {
+					b.WriteString("FROM_HEX(" + string(runes[start:end]) + ")")
+				}
+				last = end
+			}
+			return
+		}
+	})
+	b.WriteString(string(runes[last:]))
+	bound := slices.DeleteFunc(slices.Clone(args), func(arg driver.NamedValue) bool {
+		return arg.Value == nil && typed(arg)
+	})
+	return b.String(), bound
+}
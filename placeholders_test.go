@@ -0,0 +1,194 @@
+package metasql
+
+import (
+	"database/sql/driver"
+	stderrors "errors"
+	"slices"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+
+	cfg "github.com/adarsh-jaiss/metasql/config"
+	"github.com/adarsh-jaiss/metasql/errors"
+)
+
+func TestRewriteQuery(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		style string
+		want  string
+	}{
+		{"question", "SELECT * FROM t WHERE a = ? AND b = ?", "", "SELECT * FROM t WHERE a = :1 AND b = :2"},
+		{"dollar", "SELECT * FROM t WHERE a = $1 AND b = $2", "", "SELECT * FROM t WHERE a = :1 AND b = :2"},
+		{"dollar reused", "SELECT * FROM t WHERE a = $1 OR b = $1", "", "SELECT * FROM t WHERE a = :1 OR b = :1"},
+		{"dollar out of order", "SELECT * FROM t WHERE a = $2 AND b = $1", "", "SELECT * FROM t WHERE a = :2 AND b = :1"},
+		{"dollar named", "SELECT * FROM t WHERE a = $id", "", "SELECT * FROM t WHERE a = :id"},
+		{"colon kept", "SELECT * FROM t WHERE a = :1 AND b = :id", "", "SELECT * FROM t WHERE a = :1 AND b = :id"},
+		{"string literal", "SELECT '$1', 'it''s ?', $1", "", "SELECT '$1', 'it''s ?', :1"},
+		{"quoted identifier", `SELECT "$1", $1`, "", `SELECT "$1", :1`},
+		{"line comment", "-- where a = $2\nSELECT $1", "", "-- where a = $2\nSELECT :1"},
+		{"block comment", "SELECT /* ? $2 */ $1", "", "SELECT /* ? $2 */ :1"},
+		{"dollar quoted", "SELECT $$ $1 $$, $1", "", "SELECT $$ $1 $$, :1"},
+		{"tagged dollar quoted", "SELECT $fn$ ? $fn$, ?", "", "SELECT $fn$ ? $fn$, :1"},
+		{"cast", "SELECT $1::int, a::text FROM t WHERE b = $2::date", "", "SELECT :1::int, a::text FROM t WHERE b = :2::date"},
+		{"question cast", "SELECT ?::int", "", "SELECT :1::int"},
+		{"identifier with dollar", "SELECT a$1 FROM t WHERE b = $1", "", "SELECT a$1 FROM t WHERE b = :1"},
+		{"question style", "SELECT ?, $1", cfg.PlaceholdersQuestion, "SELECT :1, $1"},
+		{"dollar style", "SELECT ?, $1", cfg.PlaceholdersDollar, "SELECT ?, :1"},
+		{"colon style", "SELECT ?, $1, :2", cfg.PlaceholdersColon, "SELECT ?, $1, :2"},
+		{"prepare", "PREPARE p (int) AS SELECT * FROM t WHERE a = $1", "", "PREPARE p (int) AS SELECT * FROM t WHERE a = $1"},
+		{
+			// sqlc, for pgx, numbers the arguments of a query in order
+			"sqlc update",
+			"-- name: UpdateAuthor :one\nUPDATE authors SET name = $2, bio = $3\nWHERE id = $1\nRETURNING id, name, bio",
+			"",
+			"-- name: UpdateAuthor :one\nUPDATE authors SET name = :2, bio = :3\nWHERE id = :1\nRETURNING id, name, bio",
+		},
+		{
+			// sqlc.narg reuses the same parameter, with its cast, wherever it appears
+			"sqlc narg",
+			"-- name: ListAuthors :many\nSELECT id, name FROM authors\nWHERE ($1::text IS NULL OR name = $1::text)\nAND ($2::int IS NULL OR id > $2::int)\nORDER BY name",
+			"",
+			"-- name: ListAuthors :many\nSELECT id, name FROM authors\nWHERE (:1::text IS NULL OR name = :1::text)\nAND (:2::int IS NULL OR id > :2::int)\nORDER BY name",
+		},
+		{
+			"sqlc batch insert",
+			"INSERT INTO events (id, payload, created_at) VALUES ($1, $2, $3) ON CONFLICT DO NOTHING",
+			"",
+			"INSERT INTO events (id, payload, created_at) VALUES (:1, :2, :3) ON CONFLICT DO NOTHING",
+		},
+		{
+			// pgx named arguments are rewritten to $N before the query reaches the driver
+			"pgx rewritten named args",
+			"SELECT * FROM widgets WHERE name = $1 AND (owner = $2 OR $2 = '')",
+			"",
+			"SELECT * FROM widgets WHERE name = :1 AND (owner = :2 OR :2 = '')",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := rewriteQuery(tt.query, 1, tt.style); got != tt.want {
+				t.Errorf("rewriteQuery(%q) = %q, want %q", tt.query, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRewriteQueryWithoutArguments(t *testing.T) {
+	query := "SELECT * FROM t WHERE a = $1"
+	if got := rewriteQuery(query, 0, ""); got != query {
+		t.Errorf("rewriteQuery(%q, 0) = %q, want the query unchanged", query, got)
+	}
+}
+
+func TestScanPlaceholders(t *testing.T) {
+	tests := []struct {
+		name       string
+		query      string
+		positional []int
+		named      []string
+	}{
+		{"reused", "SELECT $1, $1, $1", []int{1}, nil},
+		{"out of order", "SELECT $2, $1, $2", []int{2, 1}, nil},
+		{"gap", "SELECT $1, $3", []int{1, 3}, nil},
+		{"question", "SELECT ?, ?", []int{1, 2}, nil},
+		{"named", "SELECT :id, $1, :id, :name", []int{1}, []string{"id", "name"}},
+		{"literals and comments", "SELECT '$2', $1 -- $3\n/* $4 */", []int{1}, nil},
+		{"casts", "SELECT $1::int, a::text", []int{1}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ph := scanPlaceholders(tt.query, "")
+			if !slices.Equal(ph.positional, tt.positional) {
+				t.Errorf("positional = %v, want %v", ph.positional, tt.positional)
+			}
+			if !slices.Equal(ph.named, tt.named) {
+				t.Errorf("named = %v, want %v", ph.named, tt.named)
+			}
+		})
+	}
+}
+
+func TestBindQueryPositionalReuse(t *testing.T) {
+	config := &cfg.RedshiftDataConfig{}
+	tests := []struct {
+		name   string
+		query  string
+		args   []driver.NamedValue
+		want   string
+		params []string
+	}{
+		{
+			"reused once bound",
+			"SELECT * FROM t WHERE a = $1 OR b = $1",
+			[]driver.NamedValue{{Ordinal: 1, Value: "x"}},
+			"SELECT * FROM t WHERE a = :1 OR b = :1",
+			[]string{"1=x"},
+		},
+		{
+			"out of order",
+			"UPDATE t SET a = $2 WHERE id = $1",
+			[]driver.NamedValue{{Ordinal: 1, Value: int64(7)}, {Ordinal: 2, Value: "y"}},
+			"UPDATE t SET a = :2 WHERE id = :1",
+			[]string{"1=7", "2=y"},
+		},
+		{
+			"sqlc narg",
+			"SELECT id FROM authors WHERE ($1::text IS NULL OR name = $1::text)",
+			[]driver.NamedValue{{Ordinal: 1, Value: "ann"}},
+			"SELECT id FROM authors WHERE (:1::text IS NULL OR name = :1::text)",
+			[]string{"1=ann"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plan := newQueryPlan(tt.query, true, config.Placeholders)
+			query, args, err := bindQuery(plan, tt.args, config)
+			if err != nil {
+				t.Fatalf("bindQuery: %v", err)
+			}
+			if query != tt.want {
+				t.Errorf("query = %q, want %q", query, tt.want)
+			}
+			parameters, err := convertArgsToParameters(args, config)
+			if err != nil {
+				t.Fatalf("convertArgsToParameters: %v", err)
+			}
+			var got []string
+			for _, p := range parameters {
+				got = append(got, aws.ToString(p.Name)+"="+aws.ToString(p.Value))
+			}
+			if !slices.Equal(got, tt.params) {
+				t.Errorf("parameters = %v, want %v", got, tt.params)
+			}
+		})
+	}
+}
+
+func TestBindQueryParameterMismatch(t *testing.T) {
+	config := &cfg.RedshiftDataConfig{}
+	tests := []struct {
+		name    string
+		query   string
+		args    []driver.NamedValue
+		missing []string
+		extra   []string
+	}{
+		{"reuse is one parameter", "SELECT $1, $1", []driver.NamedValue{{Ordinal: 1, Value: "a"}, {Ordinal: 2, Value: "b"}}, nil, []string{":2"}},
+		{"gap", "SELECT $1, $3", []driver.NamedValue{{Ordinal: 1, Value: "a"}, {Ordinal: 2, Value: "b"}}, []string{":3"}, []string{":2"}},
+		{"placeholder in literal", "SELECT '$2', $1", []driver.NamedValue{{Ordinal: 1, Value: "a"}, {Ordinal: 2, Value: "b"}}, nil, []string{":2"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := bindQuery(newQueryPlan(tt.query, true, config.Placeholders), tt.args, config)
+			var countErr *errors.ParameterCountError
+			if !stderrors.As(err, &countErr) {
+				t.Fatalf("bindQuery error = %v, want a *errors.ParameterCountError", err)
+			}
+			if !slices.Equal(countErr.Missing, tt.missing) || !slices.Equal(countErr.Extra, tt.extra) {
+				t.Errorf("missing %v, extra %v, want missing %v, extra %v", countErr.Missing, countErr.Extra, tt.missing, tt.extra)
+			}
+		})
+	}
+}
@@ -0,0 +1,142 @@
+package metasql
+
+import (
+	"container/list"
+	"database/sql/driver"
+	"hash/fnv"
+	"strconv"
+	"strings"
+	"sync"
+
+	cfg "github.com/adarsh-jaiss/metasql/config"
+)
+
+// planCacheSize is the number of query plans a connection keeps, see FeaturePlanCache.
+const planCacheSize = 256
+
+// queryPlan is what the driver derives from the text of a query before binding its arguments: the query with its
+// placeholders rewritten and the parameters it refers to. Plans are cached per connection, so hot query templates
+// are tokenized once rather than at every execution.
+type queryPlan struct {
+	query        string            // query is the text the plan was made for, compared on lookups as fingerprints can collide.
+	rewritten    string            // rewritten is the query with its placeholders rewritten, see rewriteQuery.
+	placeholders queryPlaceholders // placeholders are the parameters rewritten refers to.
+	accepted     sync.Map          // accepted holds the argument shapes checkParameters accepted, see argumentShape.
+}
+
+// newQueryPlan rewrites the placeholders of query, in the style of the config, and scans the parameters it refers to.
+func newQueryPlan(query string, hasArgs bool, style string) *queryPlan {
+	n := 0
+	if hasArgs {
+		n = 1
+	}
+	rewritten := rewriteQuery(query, n, style)
+	return &queryPlan{query: query, rewritten: rewritten, placeholders: scanPlaceholders(rewritten, style)}
+}
+
+// queryFingerprint hashes what the plan of a query depends on: its text, the placeholder style and whether it
+// has arguments.
+func queryFingerprint(query, style string, hasArgs bool) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(style))
+	h.Write([]byte{0, boolByte(hasArgs)})
+	h.Write([]byte(query))
+	return h.Sum64()
+}
+
+func boolByte(b bool) byte {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// planCache holds the plans of the queries last executed on a connection, keyed by their fingerprint and evicted
+// least recently used first.
+type planCache struct {
+	mu    sync.Mutex
+	size  int
+	plans map[uint64]*list.Element // plans holds the elements of lru by fingerprint.
+	lru   *list.List               // lru holds the *planEntry values, most recently used first.
+}
+
+type planEntry struct {
+	fingerprint uint64
+	plan        *queryPlan
+}
+
+func newPlanCache(size int) *planCache {
+	return &planCache{size: size, plans: make(map[uint64]*list.Element), lru: list.New()}
+}
+
+// plan returns the cached plan of the query, making it on a miss.
+func (c *planCache) plan(query string, hasArgs bool, style string) *queryPlan {
+	fingerprint := queryFingerprint(query, style, hasArgs)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.plans[fingerprint]; ok {
+		if entry := e.Value.(*planEntry); entry.plan.query == query {
+			c.lru.MoveToFront(e)
+			return entry.plan
+		}
+		c.lru.Remove(e)
+	}
+	plan := newQueryPlan(query, hasArgs, style)
+	c.plans[fingerprint] = c.lru.PushFront(&planEntry{fingerprint: fingerprint, plan: plan})
+	for c.lru.Len() > c.size {
+		oldest := c.lru.Back()
+		c.lru.Remove(oldest)
+		delete(c.plans, oldest.Value.(*planEntry).fingerprint)
+	}
+	return plan
+}
+
+// queryPlan returns the plan of the query, cached on the connection unless FeaturePlanCache is disabled.
+func (conn *redshiftDataConn) queryPlan(query string, hasArgs bool) *queryPlan {
+	if !conn.cfg.Features.Enabled(cfg.FeaturePlanCache) {
+		return newQueryPlan(query, hasArgs, conn.cfg.Placeholders)
+	}
+	return conn.plans.plan(query, hasArgs, conn.cfg.Placeholders)
+}
+
+// argumentShape describes what checkParameters looks at in the arguments of a statement: their names and numbers.
+func argumentShape(args []driver.NamedValue) string {
+	var b strings.Builder
+	for _, arg := range args {
+		if arg.Name != "" {
+			b.WriteString(":" + arg.Name)
+		} else {
+			b.WriteString(strconv.Itoa(arg.Ordinal))
+		}
+		b.WriteByte(',')
+	}
+	return b.String()
+}
+
+// bindQuery binds the arguments to the query of the plan: default parameters are added, slices expanded into
+// lists, the parameters checked against the placeholders, NULL and []byte arguments rewritten and, with
+// CastParameters, the placeholders cast. Argument shapes the plan already accepted are not checked again.
+func bindQuery(plan *queryPlan, args []driver.NamedValue, config *cfg.RedshiftDataConfig) (string, []driver.NamedValue, error) {
+	bound := withDefaultParameters(plan.placeholders.named, args, config)
+	rewritten, bound := expandListPlaceholders(plan.rewritten, bound, config.Placeholders)
+	if rewritten != plan.rewritten {
+		if err := checkParameters(rewritten, bound, config.Placeholders); err != nil {
+			return "", nil, err
+		}
+	} else if shape := argumentShape(bound); !plan.isAccepted(shape) {
+		if err := checkPlaceholders(plan.placeholders, bound, config.Placeholders); err != nil {
+			return "", nil, err
+		}
+		plan.accepted.Store(shape, true)
+	}
+	rewritten, bound = bindTypedPlaceholders(rewritten, bound, config)
+	if config.CastParameters {
+		rewritten = castPlaceholders(rewritten, bound, config.Placeholders)
+	}
+	return rewritten, bound, nil
+}
+
+func (plan *queryPlan) isAccepted(shape string) bool {
+	_, ok := plan.accepted.Load(shape)
+	return ok
+}
@@ -0,0 +1,95 @@
+// Package pqcompat mirrors the error types of github.com/lib/pq, so code that switches on pq.Error.Code
+// keeps working when moving from a direct Postgres connection to the Data API:
+//
+//	var pqErr *pqcompat.Error
+//	if errors.As(err, &pqErr) && pqErr.Code == "40001" {
+//		// retry
+//	}
+//
+// Statement errors of the driver convert themselves with errors.As, the SQLSTATE is derived from the
+// error message reported by Redshift since the Data API does not expose it.
+package pqcompat
+
+// ErrorCode is a five-character SQLSTATE error code.
+type ErrorCode string
+
+// Name returns a more human friendly rendering of the error code, namely the "condition name".
+func (ec ErrorCode) Name() string {
+	return errorCodeNames[ec]
+}
+
+// ErrorClass is only the class part of an error code.
+type ErrorClass string
+
+// Name returns the condition name of an error class. It is equivalent to the condition name of the "standard" error code.
+func (ec ErrorClass) Name() string {
+	return errorCodeNames[ErrorCode(ec+"000")]
+}
+
+// Class returns the error class, e.g. "28".
+func (ec ErrorCode) Class() ErrorClass {
+	return ErrorClass(ec[0:2])
+}
+
+// Error represents an error reported by the server, with the same fields as pq.Error.
+type Error struct {
+	Severity         string
+	Code             ErrorCode
+	Message          string
+	Detail           string
+	Hint             string
+	Position         string
+	InternalPosition string
+	InternalQuery    string
+	Where            string
+	Schema           string
+	Table            string
+	Column           string
+	DataTypeName     string
+	Constraint       string
+	File             string
+	Line             string
+	Routine          string
+}
+
+func (err *Error) Error() string {
+	return "pq: " + err.Message
+}
+
+// errorCodeNames covers the codes the driver derives from Redshift messages and their classes.
+var errorCodeNames = map[ErrorCode]string{
+	"0A000": "feature_not_supported",
+	"22000": "data_exception",
+	"22001": "string_data_right_truncation",
+	"22003": "numeric_value_out_of_range",
+	"22007": "invalid_datetime_format",
+	"22008": "datetime_field_overflow",
+	"22012": "division_by_zero",
+	"22P02": "invalid_text_representation",
+	"23000": "integrity_constraint_violation",
+	"23502": "not_null_violation",
+	"23505": "unique_violation",
+	"25000": "invalid_transaction_state",
+	"25P02": "in_failed_sql_transaction",
+	"28000": "invalid_authorization_specification",
+	"3D000": "invalid_catalog_name",
+	"3F000": "invalid_schema_name",
+	"40000": "transaction_rollback",
+	"40001": "serialization_failure",
+	"40P01": "deadlock_detected",
+	"42000": "syntax_error_or_access_rule_violation",
+	"42501": "insufficient_privilege",
+	"42601": "syntax_error",
+	"42703": "undefined_column",
+	"42704": "undefined_object",
+	"42710": "duplicate_object",
+	"42883": "undefined_function",
+	"42P01": "undefined_table",
+	"42P07": "duplicate_table",
+	"53000": "insufficient_resources",
+	"53100": "disk_full",
+	"53200": "out_of_memory",
+	"57000": "operator_intervention",
+	"57014": "query_canceled",
+	"XX000": "internal_error",
+}
@@ -0,0 +1,109 @@
+package metasql
+
+import (
+	"context"
+	"database/sql"
+	"strconv"
+	"strings"
+
+	"github.com/adarsh-jaiss/metasql/errors"
+)
+
+// ServerStatement is a statement prepared with PREPARE in the Data API session of a pinned connection. Redshift
+// plans it once, each execution only binds its parameters, which pays off for hot queries executed many times.
+type ServerStatement struct {
+	c        *sql.Conn
+	name     string
+	numInput int
+}
+
+// PrepareServerStatement prepares query as name in the session c is pinned to, see PinSession:
+//
+//	stmt, _ := metasql.PrepareServerStatement(ctx, c, "orders_by_customer", "SELECT * FROM orders WHERE customer_id = $1", "INT")
+//	defer stmt.Close(ctx)
+//	rows, _ := stmt.QueryContext(ctx, 42)
+//
+// Parameters are written $1, $2 and so on, paramTypes are their Redshift types, Redshift infers the types of
+// the parameters that have none.
+//
+// Preparing the same query with the same name and types again in the session reuses the prepared statement
+// instead of running PREPARE, so hot code paths can prepare their statements every time they run.
+func PrepareServerStatement(ctx context.Context, c *sql.Conn, name, query string, paramTypes ...string) (*ServerStatement, error) {
+	stmt := "PREPARE " + quoteIdentifier(name)
+	if len(paramTypes) > 0 {
+		stmt += " (" + strings.Join(paramTypes, ", ") + ")"
+	}
+	stmt += " AS " + query
+	fingerprint := queryFingerprint(stmt, "", false)
+	prepared := false
+	if err := rawConn(c, func(conn *redshiftDataConn) error {
+		if !conn.session.pinned {
+			return errors.ErrNoSession
+		}
+		p, ok := conn.session.prepared[name]
+		prepared = ok && p.session == conn.session.id && p.fingerprint == fingerprint
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	if !prepared {
+		if _, err := c.ExecContext(withoutClientToken(ctx), stmt); err != nil {
+			return nil, err
+		}
+		if err := rawConn(c, func(conn *redshiftDataConn) error {
+			if conn.session.prepared == nil {
+				conn.session.prepared = make(map[string]preparedStatement)
+			}
+			conn.session.prepared[name] = preparedStatement{session: conn.session.id, fingerprint: fingerprint}
+			return nil
+		}); err != nil {
+			return nil, err
+		}
+	}
+	numInput := 0
+	// the body of the PREPARE statement is scanned, the statement itself has no placeholders
+	for _, n := range scanPlaceholders(query, "").positional {
+		numInput = max(numInput, n)
+	}
+	return &ServerStatement{c: c, name: name, numInput: numInput}, nil
+}
+
+// NumInput returns the number of parameters of the statement.
+func (s *ServerStatement) NumInput() int {
+	return s.numInput
+}
+
+// ExecContext executes the prepared statement with args.
+func (s *ServerStatement) ExecContext(ctx context.Context, args ...any) (sql.Result, error) {
+	return s.c.ExecContext(ctx, s.execute(len(args)), args...)
+}
+
+// QueryContext executes the prepared statement with args and returns its rows.
+func (s *ServerStatement) QueryContext(ctx context.Context, args ...any) (*sql.Rows, error) {
+	return s.c.QueryContext(ctx, s.execute(len(args)), args...)
+}
+
+// Close deallocates the prepared statement. The session forgets it anyway once it expires.
+func (s *ServerStatement) Close(ctx context.Context) error {
+	if err := rawConn(s.c, func(conn *redshiftDataConn) error {
+		delete(conn.session.prepared, s.name)
+		return nil
+	}); err != nil {
+		return err
+	}
+	_, err := s.c.ExecContext(withoutClientToken(ctx), "DEALLOCATE "+quoteIdentifier(s.name))
+	return err
+}
+
+// execute returns the EXECUTE statement binding n arguments as parameters of the Data API. They are written :N,
+// which every placeholder style of the config binds.
+func (s *ServerStatement) execute(n int) string {
+	if n == 0 {
+		return "EXECUTE " + quoteIdentifier(s.name)
+	}
+	params := make([]string, n)
+	for i := range params {
+		params[i] = ":" + strconv.Itoa(i+1)
+	}
+	return "EXECUTE " + quoteIdentifier(s.name) + " (" + strings.Join(params, ", ") + ")"
+}
@@ -0,0 +1,197 @@
+// Package privileges compares the grants of a database with a declarative spec and applies the difference.
+// Current grants are read from svv_relation_privileges. Only the relations named in the spec are managed,
+// privileges on other relations are never revoked.
+package privileges
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Identity types, as reported by svv_relation_privileges.
+const (
+	User  = "user"
+	Group = "group"
+	Role  = "role"
+)
+
+// Grant is one privilege of an identity on a relation.
+type Grant struct {
+	Schema       string // Schema is the schema of the relation.
+	Relation     string // Relation is the name of the table or view.
+	Privilege    string // Privilege is the upper-cased privilege, such as SELECT or INSERT.
+	Identity     string // Identity is the name of the user, group or role holding the privilege.
+	IdentityType string // IdentityType is User, Group or Role.
+}
+
+func (g Grant) key() string {
+	return strings.ToLower(g.Schema + "." + g.Relation + "\x00" + g.IdentityType + "\x00" + g.Identity + "\x00" + g.Privilege)
+}
+
+func (g Grant) relation() string {
+	return strings.ToLower(g.Schema + "." + g.Relation)
+}
+
+// Rule grants privileges on relations of a schema to users, groups and roles.
+type Rule struct {
+	Schema     string   `yaml:"schema"`
+	Relations  []string `yaml:"relations"`
+	Privileges []string `yaml:"privileges"`
+	Users      []string `yaml:"users"`
+	Groups     []string `yaml:"groups"`
+	Roles      []string `yaml:"roles"`
+}
+
+// Spec is the desired set of grants.
+type Spec []Rule
+
+// LoadSpec reads a YAML list of rules.
+func LoadSpec(r io.Reader) (Spec, error) {
+	var spec Spec
+	if err := yaml.NewDecoder(r).Decode(&spec); err != nil {
+		return nil, fmt.Errorf("load privileges spec: %w", err)
+	}
+	return spec, nil
+}
+
+// Grants expands the rules of the spec into individual grants.
+func (s Spec) Grants() []Grant {
+	var grants []Grant
+	for _, rule := range s {
+		identities := map[string][]string{User: rule.Users, Group: rule.Groups, Role: rule.Roles}
+		for _, relation := range rule.Relations {
+			for _, privilege := range rule.Privileges {
+				for _, identityType := range []string{User, Group, Role} {
+					for _, identity := range identities[identityType] {
+						grants = append(grants, Grant{
+							Schema:       rule.Schema,
+							Relation:     relation,
+							Privilege:    strings.ToUpper(privilege),
+							Identity:     identity,
+							IdentityType: identityType,
+						})
+					}
+				}
+			}
+		}
+	}
+	return grants
+}
+
+// Current returns the grants on the relations of the schemas matching the LIKE pattern, all schemas when it is empty.
+func Current(ctx context.Context, db *sql.DB, schemaPattern string) ([]Grant, error) {
+	if schemaPattern == "" {
+		schemaPattern = "%"
+	}
+	rows, err := db.QueryContext(ctx, `SELECT namespace_name, relation_name, privilege_type, identity_name, identity_type FROM svv_relation_privileges WHERE namespace_name LIKE ? ORDER BY 1, 2, 5, 4, 3`, schemaPattern)
+	if err != nil {
+		return nil, fmt.Errorf("list privileges: %w", err)
+	}
+	defer rows.Close()
+	var grants []Grant
+	for rows.Next() {
+		var g Grant
+		if err := rows.Scan(&g.Schema, &g.Relation, &g.Privilege, &g.Identity, &g.IdentityType); err != nil {
+			return nil, err
+		}
+		g.Privilege = strings.ToUpper(g.Privilege)
+		g.IdentityType = strings.ToLower(g.IdentityType)
+		grants = append(grants, g)
+	}
+	return grants, rows.Err()
+}
+
+// Diff returns the grants missing from current and the grants of current to revoke, restricted to
+// the relations named in desired.
+func Diff(current, desired []Grant) (grant, revoke []Grant) {
+	have := make(map[string]bool, len(current))
+	for _, g := range current {
+		have[g.key()] = true
+	}
+	want := make(map[string]bool, len(desired))
+	managed := map[string]bool{}
+	for _, g := range desired {
+		managed[g.relation()] = true
+		if !want[g.key()] && !have[g.key()] {
+			grant = append(grant, g)
+		}
+		want[g.key()] = true
+	}
+	for _, g := range current {
+		if managed[g.relation()] && !want[g.key()] {
+			revoke = append(revoke, g)
+		}
+	}
+	sortGrants(grant)
+	sortGrants(revoke)
+	return grant, revoke
+}
+
+// Statements returns the GRANT and REVOKE statements applying the difference, revokes first.
+func Statements(grant, revoke []Grant) []string {
+	stmts := make([]string, 0, len(grant)+len(revoke))
+	for _, g := range revoke {
+		stmts = append(stmts, fmt.Sprintf("REVOKE %s ON %s FROM %s", g.Privilege, relationName(g), identityName(g)))
+	}
+	for _, g := range grant {
+		stmts = append(stmts, fmt.Sprintf("GRANT %s ON %s TO %s", g.Privilege, relationName(g), identityName(g)))
+	}
+	return stmts
+}
+
+// Apply brings the grants on the relations of the spec in line with it and returns the statements
+// it executed. With dryRun the statements are returned without being executed.
+func Apply(ctx context.Context, db *sql.DB, spec Spec, dryRun bool) ([]string, error) {
+	desired := spec.Grants()
+	schemas := map[string]bool{}
+	var current []Grant
+	for _, g := range desired {
+		if schemas[g.Schema] {
+			continue
+		}
+		schemas[g.Schema] = true
+		grants, err := Current(ctx, db, g.Schema)
+		if err != nil {
+			return nil, err
+		}
+		current = append(current, grants...)
+	}
+	stmts := Statements(Diff(current, desired))
+	if dryRun {
+		return stmts, nil
+	}
+	for i, stmt := range stmts {
+		if _, err := db.ExecContext(ctx, stmt); err != nil {
+			return stmts[:i], fmt.Errorf("%s: %w", stmt, err)
+		}
+	}
+	return stmts, nil
+}
+
+func relationName(g Grant) string {
+	return quote(g.Schema) + "." + quote(g.Relation)
+}
+
+func identityName(g Grant) string {
+	switch g.IdentityType {
+	case Group:
+		return "GROUP " + quote(g.Identity)
+	case Role:
+		return "ROLE " + quote(g.Identity)
+	}
+	return quote(g.Identity)
+}
+
+func quote(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+func sortGrants(grants []Grant) {
+	sort.Slice(grants, func(i, j int) bool { return grants[i].key() < grants[j].key() })
+}
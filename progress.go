@@ -0,0 +1,120 @@
+package metasql
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/adarsh-jaiss/metasql/hooks"
+	"github.com/adarsh-jaiss/metasql/utils"
+	"github.com/aws/aws-sdk-go-v2/service/redshiftdata"
+)
+
+// progressQueries are the system table queries reporting the progress of a statement by its Redshift query id.
+// stv_load_state and stl_unload_log are only available on provisioned clusters, on serverless workgroups the
+// queries fail and no progress is reported.
+var progressQueries = map[string]string{
+	"COPY":   "SELECT COALESCE(SUM(bytes_loaded), 0), COALESCE(SUM(bytes_to_load), 0) FROM stv_load_state WHERE query = %d",
+	"UNLOAD": "SELECT COALESCE(SUM(transfer_size), 0), COUNT(*) FROM stl_unload_log WHERE query = %d",
+}
+
+// trackProgress reports the progress of a COPY or UNLOAD statement to the Progress hooks until the returned
+// function is called. Progress is best effort: tracking stops at the first failing system table query.
+func (conn *redshiftDataConn) trackProgress(ctx context.Context, query string, id *string) (stop func()) {
+	operation := leadingKeyword(query)
+	if conn.cfg.ProgressInterval <= 0 || progressQueries[operation] == "" {
+		return func() {}
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(conn.cfg.ProgressInterval)
+		defer ticker.Stop()
+		event := &hooks.ProgressEvent{
+			Operation:   operation,
+			Query:       query,
+			StatementID: utils.Coalesce(id),
+			Conn:        conn.info,
+			Correlation: hooks.Correlate(ctx, conn.cfg.Hooks),
+		}
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			if err := conn.progress(ctx, id, event); err != nil {
+				return
+			}
+		}
+	}()
+	return func() {
+		cancel()
+		wg.Wait()
+	}
+}
+
+// progress fills in the event from the system tables and reports it, it does nothing before the statement started.
+func (conn *redshiftDataConn) progress(ctx context.Context, id *string, event *hooks.ProgressEvent) error {
+	desc, err := conn.client.DescribeStatement(ctx, &redshiftdata.DescribeStatementInput{
+		Id: id,
+	})
+	if err != nil {
+		return fmt.Errorf("describe statement error: %w", err)
+	}
+	if desc.RedshiftQueryId == 0 || isFinishedStatus(desc.Status) {
+		return nil
+	}
+	// the progress query is not the statement of the caller, it must not share its token
+	exec := NewExecutor(conn.client, conn.cfg)
+	defer exec.Close()
+	h, err := exec.Execute(withoutClientToken(ctx), fmt.Sprintf(progressQueries[event.Operation], desc.RedshiftQueryId))
+	if err != nil {
+		return err
+	}
+	rows, err := h.Rows(ctx)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		return fmt.Errorf("progress query returned no rows")
+	}
+	if len(rows.Values()) != 2 {
+		return fmt.Errorf("progress query returned %d columns", len(rows.Values()))
+	}
+	first, second := progressValue(rows.Values()[0]), progressValue(rows.Values()[1])
+	event.Bytes = first
+	event.Percent = -1
+	switch event.Operation {
+	case "COPY":
+		event.TotalBytes = second
+		if second > 0 {
+			event.Percent = float64(first) * 100 / float64(second)
+		}
+	case "UNLOAD":
+		event.Files = second
+	}
+	hooks.Progress(ctx, conn.cfg.Hooks, event)
+	return nil
+}
+
+// progressValue converts an aggregate returned by a progress query, SUM returns NUMERIC as a string.
+func progressValue(v driver.Value) int64 {
+	switch v := v.(type) {
+	case int64:
+		return v
+	case string:
+		n, _ := strconv.ParseInt(v, 10, 64)
+		return n
+	}
+	return 0
+}
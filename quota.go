@@ -0,0 +1,26 @@
+package metasql
+
+import (
+	"context"
+	"fmt"
+
+	cfg "github.com/adarsh-jaiss/metasql/config"
+)
+
+// acquireQuota waits for the coordinator of the config, if any, to allow one more statement.
+// The returned function gives the active statement slot back once the statement finished.
+func acquireQuota(ctx context.Context, config *cfg.RedshiftDataConfig) (release func(), err error) {
+	c := config.Coordinator
+	if c == nil {
+		return func() {}, nil
+	}
+	// Connectors are told apart by their config, so the connections of one pool share a fair share.
+	if release, err = c.Acquire(ctx, config); err != nil {
+		return nil, fmt.Errorf("quota: %w", err)
+	}
+	if err = c.Wait(ctx); err != nil {
+		release()
+		return nil, fmt.Errorf("quota: %w", err)
+	}
+	return release, nil
+}
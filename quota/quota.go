@@ -0,0 +1,197 @@
+// Package quota coordinates the Data API usage of several connectors sharing an AWS account.
+// A Coordinator rate limits statement submissions and bounds the number of active statements, giving each
+// connector a fair share of the slots while several of them compete, so one busy pool can not starve the others.
+// Connectors opt in with RedshiftDataConfig.Coordinator or the quota=global DSN parameter.
+package quota
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultRate      = 20  // DefaultRate is the number of submissions per second of the global coordinator.
+	DefaultBurst     = 20  // DefaultBurst is the number of submissions the global coordinator allows at once.
+	DefaultMaxActive = 200 // DefaultMaxActive is the number of active statements of the global coordinator.
+)
+
+// Coordinator shares a submission rate and an active statement budget between connectors.
+type Coordinator struct {
+	mu        sync.Mutex
+	rate      float64 // rate is the number of submissions per second, unlimited when 0.
+	burst     float64 // burst is the size of the token bucket.
+	maxActive int     // maxActive is the number of statements active at once, unlimited when 0.
+	tokens    float64
+	last      time.Time
+	active    int
+	members   map[any]*member
+	changed   chan struct{} // changed is closed and replaced whenever slots are released.
+}
+
+// member tracks the statements of one connector.
+type member struct {
+	active  int
+	waiting int
+}
+
+// NewCoordinator returns a Coordinator allowing rate submissions per second with bursts of burst, and maxActive
+// statements at once. A zero rate or maxActive disables the corresponding limit.
+func NewCoordinator(rate float64, burst, maxActive int) *Coordinator {
+	if burst < 1 {
+		burst = 1
+	}
+	return &Coordinator{
+		rate:      rate,
+		burst:     float64(burst),
+		maxActive: maxActive,
+		tokens:    float64(burst),
+		last:      time.Now(),
+		members:   map[any]*member{},
+		changed:   make(chan struct{}),
+	}
+}
+
+// Limits are the limits of a Coordinator, see NewCoordinator.
+type Limits struct {
+	Rate      float64 `yaml:"rate"`       // Rate is the number of submissions per second, unlimited when 0.
+	Burst     int     `yaml:"burst"`      // Burst is the number of submissions allowed at once.
+	MaxActive int     `yaml:"max_active"` // MaxActive is the number of statements active at once, unlimited when 0.
+}
+
+// Limits returns the current limits of the coordinator.
+func (c *Coordinator) Limits() Limits {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return Limits{Rate: c.rate, Burst: int(c.burst), MaxActive: c.maxActive}
+}
+
+// SetLimits changes the limits of the coordinator. The goroutines waiting in Acquire are woken up to check them,
+// the ones waiting in Wait apply them once their current delay elapsed. Statements active already keep their
+// slots, also beyond a lowered MaxActive.
+func (c *Coordinator) SetLimits(l Limits) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.rate = l.Rate
+	c.burst = float64(max(l.Burst, 1))
+	c.tokens = min(c.tokens, c.burst)
+	c.maxActive = l.MaxActive
+	c.broadcast()
+}
+
+var global = NewCoordinator(DefaultRate, DefaultBurst, DefaultMaxActive)
+
+// Global returns the process-wide coordinator used by the quota=global DSN parameter.
+func Global() *Coordinator {
+	return global
+}
+
+// Wait blocks until the rate limit allows one more submission or ctx is done.
+func (c *Coordinator) Wait(ctx context.Context) error {
+	for {
+		c.mu.Lock()
+		if c.rate <= 0 {
+			c.mu.Unlock()
+			return nil
+		}
+		now := time.Now()
+		c.tokens = min(c.burst, c.tokens+now.Sub(c.last).Seconds()*c.rate)
+		c.last = now
+		if c.tokens >= 1 {
+			c.tokens--
+			c.mu.Unlock()
+			return nil
+		}
+		wait := time.Duration((1 - c.tokens) / c.rate * float64(time.Second))
+		c.mu.Unlock()
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// Acquire blocks until a statement of the connector identified by key may become active, or ctx is done.
+// The returned function releases the slot.
+func (c *Coordinator) Acquire(ctx context.Context, key any) (release func(), err error) {
+	c.mu.Lock()
+	if c.maxActive <= 0 {
+		c.mu.Unlock()
+		return func() {}, nil
+	}
+	m, ok := c.members[key]
+	if !ok {
+		m = &member{}
+		c.members[key] = m
+	}
+	m.waiting++
+	for c.maxActive > 0 && (c.active >= c.maxActive || m.active >= c.share()) {
+		changed := c.changed
+		c.mu.Unlock()
+		select {
+		case <-ctx.Done():
+			c.mu.Lock()
+			m.waiting--
+			c.forget(key, m)
+			c.broadcast()
+			c.mu.Unlock()
+			return nil, ctx.Err()
+		case <-changed:
+		}
+		c.mu.Lock()
+	}
+	m.waiting--
+	m.active++
+	c.active++
+	c.mu.Unlock()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			c.mu.Lock()
+			m.active--
+			c.active--
+			c.forget(key, m)
+			c.broadcast()
+			c.mu.Unlock()
+		})
+	}, nil
+}
+
+// share returns the number of slots each connector with statements active or waiting may hold.
+func (c *Coordinator) share() int {
+	busy := 0
+	for _, m := range c.members {
+		if m.active > 0 || m.waiting > 0 {
+			busy++
+		}
+	}
+	if busy <= 1 {
+		return c.maxActive
+	}
+	return max(1, (c.maxActive+busy-1)/busy)
+}
+
+// forget drops a connector with no statements left.
+func (c *Coordinator) forget(key any, m *member) {
+	if m.active == 0 && m.waiting == 0 {
+		delete(c.members, key)
+	}
+}
+
+// broadcast wakes up the goroutines waiting in Acquire.
+func (c *Coordinator) broadcast() {
+	close(c.changed)
+	c.changed = make(chan struct{})
+}
+
+// Active returns the number of active statements.
+func (c *Coordinator) Active() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.active
+}
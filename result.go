@@ -2,18 +2,127 @@ package metasql
 
 import (
 	"database/sql/driver"
+	"strings"
 
-	"github.com/adarsh-jaiss/metasql/types"
+	"github.com/adarsh-jaiss/metasql/errors"
+	"github.com/adarsh-jaiss/metasql/utils"
+	"github.com/aws/aws-sdk-go-v2/service/redshiftdata"
+	awstypes "github.com/aws/aws-sdk-go-v2/service/redshiftdata/types"
 )
 
+// redshiftDataResult implements driver.Result using the row count reported by DescribeStatement.
+// affectedRows is negative when the Data API could not report a count.
+type redshiftDataResult struct {
+	affectedRows int64
+}
+
+// redshiftDataDelayedResult is returned by ExecContext inside a transaction or a micro-batch.
+// Its Result is filled in once the buffered statements are executed at commit or flush time.
 type redshiftDataDelayedResult struct {
 	driver.Result
+	err error // err is returned while Result is nil, the transaction errors are used when it is nil too.
 }
 
+func newResult(output *redshiftdata.DescribeStatementOutput) *redshiftDataResult {
+	// debugLogger.Printf("[%s] create result", coalesce(output.Id))
+	return &redshiftDataResult{
+		affectedRows: affectedRows(utils.Coalesce(output.QueryString), output.ResultRows),
+	}
+}
 
-func NewResultWithSubStatementData(st types.SubStatementData) *redshiftDataResult {
+func NewResultWithSubStatementData(st awstypes.SubStatementData) *redshiftDataResult {
 	// debugLogger.Printf("[%s] create result", coalesce(st.Id))
 	return &redshiftDataResult{
-		affectedRows: st.ResultRows,
+		affectedRows: affectedRows(utils.Coalesce(st.QueryString), st.ResultRows),
+	}
+}
+
+// noRowCount lists the statements that never affect rows, the Data API reports either 0 or -1 for them.
+var noRowCount = map[string]bool{
+	"CREATE": true, "ALTER": true, "DROP": true, "TRUNCATE": true, "GRANT": true, "REVOKE": true,
+	"COMMENT": true, "VACUUM": true, "ANALYZE": true, "SET": true, "RESET": true, "CALL": true,
+	"BEGIN": true, "START": true, "COMMIT": true, "END": true, "ROLLBACK": true, "ABORT": true, "LOCK": true,
+}
+
+// affectedRows maps the ResultRows of a statement to its number of affected rows. ResultRows holds
+// the affected rows of INSERT, UPDATE, DELETE, MERGE and COPY, the returned rows of SELECT and -1 when
+// the count is null. Statements that do not affect rows, such as DDL, report 0.
+func affectedRows(query string, resultRows int64) int64 {
+	if noRowCount[leadingKeyword(query)] {
+		return 0
+	}
+	if resultRows < 0 {
+		return -1
+	}
+	return resultRows
+}
+
+// leadingKeyword returns the upper-cased first keyword of the query, skipping comments and parentheses.
+func leadingKeyword(query string) string {
+	query = trimLeadingComments(query)
+	end := strings.IndexFunc(query, func(r rune) bool {
+		return !(r == '_' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z')
+	})
+	if end < 0 {
+		end = len(query)
 	}
-}
\ No newline at end of file
+	return strings.ToUpper(query[:end])
+}
+
+// trimLeadingComments removes the whitespace, comments and parentheses preceding the first keyword of the query.
+func trimLeadingComments(query string) string {
+	for {
+		query = strings.TrimLeft(query, " \t\r\n(")
+		switch {
+		case strings.HasPrefix(query, "--"):
+			if i := strings.IndexByte(query, '\n'); i >= 0 {
+				query = query[i+1:]
+				continue
+			}
+			return ""
+		case strings.HasPrefix(query, "/*"):
+			if i := strings.Index(query, "*/"); i >= 0 {
+				query = query[i+2:]
+				continue
+			}
+			return ""
+		}
+		return query
+	}
+}
+
+// LastInsertId is not supported, Redshift has no notion of an auto generated row id.
+func (r *redshiftDataResult) LastInsertId() (int64, error) {
+	return 0, errors.NewUnsupportedError("LastInsertId", errors.LimitationPlatform, "Redshift has no auto generated row ids, use an IDENTITY column and query it")
+}
+
+// RowsAffected returns the number of rows reported by the Data API for the statement,
+// or errors.ErrRowsAffectedUnknown when the Data API did not report one.
+func (r *redshiftDataResult) RowsAffected() (int64, error) {
+	if r.affectedRows < 0 {
+		return 0, errors.ErrRowsAffectedUnknown
+	}
+	return r.affectedRows, nil
+}
+
+// LastInsertId returns an error until the transaction has been committed or the batch flushed.
+func (r *redshiftDataDelayedResult) LastInsertId() (int64, error) {
+	if r.Result != nil {
+		return r.Result.LastInsertId()
+	}
+	if r.err != nil {
+		return 0, r.err
+	}
+	return 0, errors.ErrNotInTx
+}
+
+// RowsAffected returns an error until the transaction has been committed or the batch flushed.
+func (r *redshiftDataDelayedResult) RowsAffected() (int64, error) {
+	if r.Result != nil {
+		return r.Result.RowsAffected()
+	}
+	if r.err != nil {
+		return 0, r.err
+	}
+	return 0, errors.ErrInTx
+}
@@ -0,0 +1,225 @@
+package metasql
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/gob"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	cfg "github.com/adarsh-jaiss/metasql/config"
+	"github.com/adarsh-jaiss/metasql/hooks"
+	"github.com/adarsh-jaiss/metasql/utils"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/redshiftdata"
+	awstypes "github.com/aws/aws-sdk-go-v2/service/redshiftdata/types"
+)
+
+type resultCacheTTLKey struct{}
+
+// WithResultCache returns a context under which query results are cached for ttl instead of the ResultCacheTTL of
+// the config. A negative ttl bypasses the cache, the query is executed and its result is not stored.
+func WithResultCache(ctx context.Context, ttl time.Duration) context.Context {
+	return context.WithValue(ctx, resultCacheTTLKey{}, ttl)
+}
+
+// resultCacheTTL returns how long the result of a query is cached, 0 when it is not or the result cache
+// feature is disabled.
+func resultCacheTTL(ctx context.Context, config *cfg.RedshiftDataConfig) time.Duration {
+	if !config.Features.Enabled(cfg.FeatureResultCache) {
+		return 0
+	}
+	if ttl, ok := ctx.Value(resultCacheTTLKey{}).(time.Duration); ok && ttl != 0 {
+		return max(ttl, 0)
+	}
+	return config.ResultCacheTTL
+}
+
+// InvalidateResult removes the cached result of query with args from the result cache of db, so the next
+// execution reads fresh data, typically after the tables it reads were written.
+func InvalidateResult(ctx context.Context, db *sql.DB, query string, args ...any) error {
+	c, err := db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	return rawConn(c, func(conn *redshiftDataConn) error {
+		namedArgs, err := namedValues(args, conn.cfg.ParameterEncoder)
+		if err != nil {
+			return err
+		}
+		key, err := resultKey(conn.withDatabase(ctx), conn.cfg, query, namedArgs)
+		if err != nil {
+			return err
+		}
+		return conn.cfg.GetResultCache().Invalidate(ctx, key)
+	})
+}
+
+// cachedQuery serves the query from the result cache, executing it and storing its result on a miss.
+// Results served from the cache run no statement, so no statement hooks are called.
+// Queries of transactions and pinned sessions are not cached, they may read data only they can see.
+func (conn *redshiftDataConn) cachedQuery(ctx context.Context, query string, args []driver.NamedValue, ttl time.Duration) (driver.Rows, error) {
+	if conn.inTx || conn.session.pinned {
+		return conn.uncachedQuery(ctx, query, args)
+	}
+	store := conn.cfg.GetResultCache()
+	key, err := resultKey(conn.withDatabase(ctx), conn.cfg, query, args)
+	if err != nil {
+		return nil, err
+	}
+	if data, ok, err := store.Get(ctx, key); err == nil && ok {
+		var result cachedResult
+		if gob.NewDecoder(bytes.NewReader(data)).Decode(&result) == nil {
+			return conn.queryRows(ctx, result.describe(), result.paginator())
+		}
+	}
+
+	h, err := conn.execute(ctx, hooks.KindQuery, query, args)
+	if err != nil {
+		return nil, err
+	}
+	if h.p == nil {
+		return h.rows(ctx)
+	}
+	result := &cachedResult{ID: h.ID()}
+	for h.p.HasMorePages() {
+		page, err := h.p.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("get statement result error: %w", err)
+		}
+		if result.Columns == nil {
+			result.Columns = page.ColumnMetadata
+		}
+		for _, record := range page.Records {
+			result.Records = append(result.Records, cachedRecord(record))
+		}
+	}
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(result); err == nil {
+		store.Set(ctx, key, buf.Bytes(), ttl)
+	}
+	return conn.queryRows(ctx, result.describe(), result.paginator())
+}
+
+func (conn *redshiftDataConn) uncachedQuery(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	h, err := conn.execute(ctx, hooks.KindQuery, query, args)
+	if err != nil {
+		return nil, err
+	}
+	return h.rows(ctx)
+}
+
+// resultKey returns the cache key of a query: the hash of its target, text and arguments.
+func resultKey(ctx context.Context, config *cfg.RedshiftDataConfig, query string, args []driver.NamedValue) (string, error) {
+	database, dbUser, secretArn, err := routeTarget(ctx, config)
+	if err != nil {
+		return "", err
+	}
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\x00%s\x00%s\x00%s\x00%s\x00%s\x00", utils.Coalesce(config.ClusterIdentifier), utils.Coalesce(config.WorkgroupName),
+		utils.Coalesce(database), utils.Coalesce(dbUser), utils.Coalesce(secretArn), query)
+	for _, arg := range args {
+		fmt.Fprintf(h, "%s\x00%d\x00%T\x00%v\x00", arg.Name, arg.Ordinal, arg.Value, arg.Value)
+	}
+	return "metasql:" + hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// cachedResult is the encoded form of a result set in the result cache.
+type cachedResult struct {
+	ID      string                    // ID is the id of the statement the result was read from.
+	Columns []awstypes.ColumnMetadata // Columns is the column metadata of the result set.
+	Records [][]cachedField           // Records are the records of all the pages of the result set.
+}
+
+// cachedField is a Data API field. Gob does not transmit zero values, so the Kind tells empty strings,
+// zeros and false apart from nulls.
+type cachedField struct {
+	Kind   fieldKind
+	Bool   bool
+	Long   int64
+	Double float64
+	String string
+	Blob   []byte
+}
+
+type fieldKind uint8
+
+const (
+	fieldNull fieldKind = iota
+	fieldBool
+	fieldLong
+	fieldDouble
+	fieldString
+	fieldBlob
+)
+
+func cachedRecord(record []awstypes.Field) []cachedField {
+	fields := make([]cachedField, len(record))
+	for i, field := range record {
+		switch f := field.(type) {
+		case *awstypes.FieldMemberBooleanValue:
+			fields[i] = cachedField{Kind: fieldBool, Bool: f.Value}
+		case *awstypes.FieldMemberLongValue:
+			fields[i] = cachedField{Kind: fieldLong, Long: f.Value}
+		case *awstypes.FieldMemberDoubleValue:
+			fields[i] = cachedField{Kind: fieldDouble, Double: f.Value}
+		case *awstypes.FieldMemberStringValue:
+			fields[i] = cachedField{Kind: fieldString, String: f.Value}
+		case *awstypes.FieldMemberBlobValue:
+			fields[i] = cachedField{Kind: fieldBlob, Blob: f.Value}
+		}
+	}
+	return fields
+}
+
+func (f cachedField) field() awstypes.Field {
+	switch f.Kind {
+	case fieldBool:
+		return &awstypes.FieldMemberBooleanValue{Value: f.Bool}
+	case fieldLong:
+		return &awstypes.FieldMemberLongValue{Value: f.Long}
+	case fieldDouble:
+		return &awstypes.FieldMemberDoubleValue{Value: f.Double}
+	case fieldString:
+		return &awstypes.FieldMemberStringValue{Value: f.String}
+	case fieldBlob:
+		return &awstypes.FieldMemberBlobValue{Value: append([]byte{}, f.Blob...)}
+	}
+	return &awstypes.FieldMemberIsNull{Value: true}
+}
+
+// describe returns the DescribeStatement output the rows of the result are built from.
+func (r *cachedResult) describe() *redshiftdata.DescribeStatementOutput {
+	return &redshiftdata.DescribeStatementOutput{
+		Id:           aws.String(r.ID),
+		HasResultSet: aws.Bool(true),
+		ResultRows:   int64(len(r.Records)),
+		ResultSize:   -1,
+	}
+}
+
+// paginator returns a paginator serving the records of the result as a single page.
+func (r *cachedResult) paginator() *redshiftdata.GetStatementResultPaginator {
+	return redshiftdata.NewGetStatementResultPaginator(r, &redshiftdata.GetStatementResultInput{Id: aws.String(r.ID)})
+}
+
+// GetStatementResult implements redshiftdata.GetStatementResultAPIClient for paginator.
+func (r *cachedResult) GetStatementResult(context.Context, *redshiftdata.GetStatementResultInput, ...func(*redshiftdata.Options)) (*redshiftdata.GetStatementResultOutput, error) {
+	records := make([][]awstypes.Field, len(r.Records))
+	for i, record := range r.Records {
+		records[i] = make([]awstypes.Field, len(record))
+		for j, f := range record {
+			records[i][j] = f.field()
+		}
+	}
+	return &redshiftdata.GetStatementResultOutput{
+		ColumnMetadata: r.Columns,
+		Records:        records,
+		TotalNumRows:   int64(len(records)),
+	}, nil
+}
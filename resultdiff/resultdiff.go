@@ -0,0 +1,300 @@
+// Package resultdiff compares query results, either between two targets or against a golden file.
+// Values are normalized to canonical strings first, so the same data read through different types
+// (for example int64 and float64, or times in different locations) compares equal.
+package resultdiff
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/binary"
+	"encoding/csv"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"math/bits"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Null is the canonical representation of SQL NULL, as in the Postgres COPY text format.
+const Null = `\N`
+
+// Result is a normalized result set.
+type Result struct {
+	Columns []string
+	Rows    [][]string
+}
+
+// Options controls how results are compared.
+type Options struct {
+	Ordered        bool // Ordered compares rows by position, otherwise results are compared as multisets.
+	FloatPrecision int  // FloatPrecision is the number of decimals floats are rounded to, 0 keeps the shortest exact form.
+}
+
+// DefaultOptions compares unordered results with floats rounded to 9 decimals.
+var DefaultOptions = Options{FloatPrecision: 9}
+
+// Query runs the query on db and returns its normalized result.
+func Query(ctx context.Context, db *sql.DB, opts Options, query string, args ...any) (*Result, error) {
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return FromRows(rows, opts)
+}
+
+// FromRows reads all remaining rows and normalizes them.
+func FromRows(rows *sql.Rows, opts Options) (*Result, error) {
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	res := &Result{Columns: columns}
+	values := make([]any, len(columns))
+	dest := make([]any, len(columns))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	for rows.Next() {
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+		row := make([]string, len(values))
+		for i, v := range values {
+			row[i] = Normalize(v, opts.FloatPrecision)
+		}
+		res.Rows = append(res.Rows, row)
+	}
+	return res, rows.Err()
+}
+
+// Normalize renders a scanned value as a canonical string.
+func Normalize(v any, floatPrecision int) string {
+	switch v := v.(type) {
+	case nil:
+		return Null
+	case []byte:
+		return string(v)
+	case string:
+		return v
+	case bool:
+		return strconv.FormatBool(v)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case float64:
+		if v == float64(int64(v)) {
+			return strconv.FormatInt(int64(v), 10)
+		}
+		if floatPrecision <= 0 {
+			floatPrecision = -1
+		}
+		return strconv.FormatFloat(v, 'f', floatPrecision, 64)
+	case time.Time:
+		return v.UTC().Format(time.RFC3339Nano)
+	}
+	return fmt.Sprint(v)
+}
+
+// ReadGolden reads a result written by WriteGolden: CSV with a header line.
+func ReadGolden(r io.Reader) (*Result, error) {
+	records, err := csv.NewReader(r).ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("read golden file: %w", err)
+	}
+	if len(records) == 0 {
+		return nil, fmt.Errorf("read golden file: missing header")
+	}
+	return &Result{Columns: records[0], Rows: records[1:]}, nil
+}
+
+// WriteGolden writes the result as CSV with a header line.
+func WriteGolden(w io.Writer, res *Result) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(res.Columns); err != nil {
+		return err
+	}
+	if err := cw.WriteAll(res.Rows); err != nil {
+		return err
+	}
+	return cw.Error()
+}
+
+// Report lists the differences between two results, called left and right.
+type Report struct {
+	Columns      []string   // Columns are the columns present on both sides, rows are compared on these.
+	LeftColumns  []string   // LeftColumns are the columns only present on the left.
+	RightColumns []string   // RightColumns are the columns only present on the right.
+	LeftRows     [][]string // LeftRows are the rows only present on the left.
+	RightRows    [][]string // RightRows are the rows only present on the right.
+}
+
+// Equal reports whether both results have the same columns and rows.
+func (r *Report) Equal() bool {
+	return len(r.LeftColumns) == 0 && len(r.RightColumns) == 0 && len(r.LeftRows) == 0 && len(r.RightRows) == 0
+}
+
+// String renders the report in a unified-diff like format.
+func (r *Report) String() string {
+	if r.Equal() {
+		return "results are equal\n"
+	}
+	var b strings.Builder
+	for _, c := range r.LeftColumns {
+		fmt.Fprintf(&b, "- column %s\n", c)
+	}
+	for _, c := range r.RightColumns {
+		fmt.Fprintf(&b, "+ column %s\n", c)
+	}
+	if len(r.LeftRows) > 0 || len(r.RightRows) > 0 {
+		fmt.Fprintf(&b, "  %s\n", strings.Join(r.Columns, " | "))
+	}
+	for _, row := range r.LeftRows {
+		fmt.Fprintf(&b, "- %s\n", strings.Join(row, " | "))
+	}
+	for _, row := range r.RightRows {
+		fmt.Fprintf(&b, "+ %s\n", strings.Join(row, " | "))
+	}
+	return b.String()
+}
+
+// Compare compares left and right. Rows are compared on the columns both results have in common.
+func Compare(left, right *Result, opts Options) *Report {
+	report := &Report{}
+	var leftIdx, rightIdx []int
+	for i, c := range left.Columns {
+		if j := slices.Index(right.Columns, c); j >= 0 {
+			report.Columns = append(report.Columns, c)
+			leftIdx = append(leftIdx, i)
+			rightIdx = append(rightIdx, j)
+			continue
+		}
+		report.LeftColumns = append(report.LeftColumns, c)
+	}
+	for _, c := range right.Columns {
+		if !slices.Contains(left.Columns, c) {
+			report.RightColumns = append(report.RightColumns, c)
+		}
+	}
+
+	leftRows := project(left.Rows, leftIdx)
+	rightRows := project(right.Rows, rightIdx)
+	if opts.Ordered {
+		for i := 0; i < max(len(leftRows), len(rightRows)); i++ {
+			switch {
+			case i >= len(leftRows):
+				report.RightRows = append(report.RightRows, rightRows[i])
+			case i >= len(rightRows):
+				report.LeftRows = append(report.LeftRows, leftRows[i])
+			case !slices.Equal(leftRows[i], rightRows[i]):
+				report.LeftRows = append(report.LeftRows, leftRows[i])
+				report.RightRows = append(report.RightRows, rightRows[i])
+			}
+		}
+		return report
+	}
+
+	counts := make(map[string]int, len(rightRows))
+	for _, row := range rightRows {
+		counts[rowKey(row)]++
+	}
+	for _, row := range leftRows {
+		key := rowKey(row)
+		if counts[key] > 0 {
+			counts[key]--
+			continue
+		}
+		report.LeftRows = append(report.LeftRows, row)
+	}
+	for _, row := range rightRows {
+		key := rowKey(row)
+		if counts[key] > 0 {
+			counts[key]--
+			report.RightRows = append(report.RightRows, row)
+		}
+	}
+	return report
+}
+
+func project(rows [][]string, idx []int) [][]string {
+	projected := make([][]string, 0, len(rows))
+	for _, row := range rows {
+		p := make([]string, len(idx))
+		for i, j := range idx {
+			if j < len(row) {
+				p[i] = row[j]
+			}
+		}
+		projected = append(projected, p)
+	}
+	return projected
+}
+
+func rowKey(row []string) string {
+	return strings.Join(row, "\x00")
+}
+
+// Checksum reads all remaining rows and returns an order-insensitive hash of the normalized result set,
+// so two results can be compared without holding them in memory. Duplicate rows count, and the column
+// names are part of the hash.
+func Checksum(rows *sql.Rows, opts Options) (string, error) {
+	columns, err := rows.Columns()
+	if err != nil {
+		return "", err
+	}
+	var sum checksum
+	values := make([]any, len(columns))
+	dest := make([]any, len(columns))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	row := make([]string, len(columns))
+	for rows.Next() {
+		if err := rows.Scan(dest...); err != nil {
+			return "", err
+		}
+		for i, v := range values {
+			row[i] = Normalize(v, opts.FloatPrecision)
+		}
+		sum.add(row)
+	}
+	if err := rows.Err(); err != nil {
+		return "", err
+	}
+	return sum.String(columns), nil
+}
+
+// Checksum returns the hash Checksum computes for the rows the result was read from.
+func (r *Result) Checksum() string {
+	var sum checksum
+	for _, row := range r.Rows {
+		sum.add(row)
+	}
+	return sum.String(r.Columns)
+}
+
+// checksum adds up the hashes of rows, which does not depend on their order.
+type checksum struct {
+	hi, lo uint64
+	rows   uint64
+}
+
+func (c *checksum) add(row []string) {
+	h := sha256.Sum256([]byte(rowKey(row)))
+	lo := binary.BigEndian.Uint64(h[8:16])
+	var carry uint64
+	c.lo, carry = bits.Add64(c.lo, lo, 0)
+	c.hi, _ = bits.Add64(c.hi, binary.BigEndian.Uint64(h[:8]), carry)
+	c.rows++
+}
+
+// String returns the hex encoded hash of the sum, the row count and the columns.
+func (c *checksum) String(columns []string) string {
+	h := sha256.New()
+	binary.Write(h, binary.BigEndian, [3]uint64{c.hi, c.lo, c.rows})
+	h.Write([]byte(rowKey(columns)))
+	return hex.EncodeToString(h.Sum(nil)[:16])
+}
@@ -0,0 +1,502 @@
+package metasql
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	cfg "github.com/adarsh-jaiss/metasql/config"
+	"github.com/adarsh-jaiss/metasql/hooks"
+	"github.com/adarsh-jaiss/metasql/utils"
+	"github.com/aws/aws-sdk-go-v2/service/redshiftdata"
+	awstypes "github.com/aws/aws-sdk-go-v2/service/redshiftdata/types"
+)
+
+// redshiftDataRows implements driver.Rows on top of the GetStatementResult paginator.
+// Pages are fetched lazily as the caller iterates, the first page is fetched eagerly to know the columns.
+// Statements without a result set have no paginator, their rows have no columns and no records.
+type redshiftDataRows struct {
+	ctx        context.Context                              // ctx is the context of the query, used to fetch the following pages.
+	id         string                                       // id is the statement id the rows belong to.
+	p          *redshiftdata.GetStatementResultPaginator    // p is the paginator used to fetch result pages, nil without a result set.
+	columns    []awstypes.ColumnMetadata                    // columns is the column metadata of the result set.
+	page       *redshiftdata.GetStatementResultOutput       // page is the current result page.
+	receivedAt int                                          // receivedAt is the index of the next record in the current page.
+	fetched    int64                                        // fetched is the number of records of the pages before the current one.
+	size       ResultSize                                   // size is the size of the result set reported by DescribeStatement.
+	comments   func(schema, table string) map[string]string // comments returns the column comments of a table, nil when they are not fetched.
+
+	hooks []*hooks.Hooks // hooks are notified of checkpoints between pages.
+	conn  hooks.ConnInfo // conn is the connection the rows were queried on.
+
+	prefetch     <-chan fetchedPage // prefetch receives the pages fetched ahead in the background, nil when pages are fetched on demand.
+	stopPrefetch func()             // stopPrefetch stops fetching pages ahead.
+
+	decode     cfg.DecodeOptions // decode controls how values are decoded.
+	loc        *time.Location    // loc is the location of parsed timestamps, resolved from decode.
+	converters []ConverterFunc   // converters holds the registered converter of each column, nil when there are none.
+	decoded    [][]driver.Value  // decoded holds the records of the current page decoded ahead by workers, nil when decoding inline.
+	decodeErrs []error           // decodeErrs holds the decoding error of each record of decoded.
+}
+
+func newRows(ctx context.Context, id string, p *redshiftdata.GetStatementResultPaginator) (*redshiftDataRows, error) {
+	rows := &redshiftDataRows{
+		ctx:  ctx,
+		id:   id,
+		p:    p,
+		size: ResultSize{Rows: -1, Bytes: -1},
+	}
+	if err := rows.nextPage(); err != nil && err != io.EOF {
+		return nil, err
+	}
+	return rows, nil
+}
+
+func (rows *redshiftDataRows) nextPage() error {
+	if rows.prefetch == nil && (rows.p == nil || !rows.p.HasMorePages()) {
+		return io.EOF
+	}
+	if rows.prefetch != nil && utils.Coalesce(rows.page.NextToken) == "" {
+		return io.EOF
+	}
+	if rows.page != nil {
+		rows.fetched += int64(len(rows.page.Records))
+		if len(rows.hooks) > 0 {
+			hooks.Checkpoint(rows.ctx, rows.hooks, &hooks.CheckpointEvent{
+				StatementID: rows.id,
+				NextToken:   utils.Coalesce(rows.page.NextToken),
+				Rows:        rows.fetched,
+				Conn:        rows.conn,
+			})
+		}
+	}
+	page, err := rows.fetchPage()
+	if err != nil {
+		return err
+	}
+	// debugLogger.Printf("[%s] fetch page: records=%d", rows.id, len(page.Records))
+	if rows.columns == nil {
+		rows.columns = page.ColumnMetadata
+	}
+	rows.page = page
+	rows.receivedAt = 0
+	return nil
+}
+
+// fetchPage returns the next page, fetched ahead when prefetching.
+func (rows *redshiftDataRows) fetchPage() (*redshiftdata.GetStatementResultOutput, error) {
+	if rows.prefetch == nil {
+		rows.decoded, rows.decodeErrs = nil, nil
+		page, err := rows.p.NextPage(rows.ctx)
+		if err != nil {
+			return nil, fmt.Errorf("get statement result error: %w", err)
+		}
+		return page, nil
+	}
+	fetched, ok := <-rows.prefetch
+	if !ok {
+		return nil, io.EOF
+	}
+	if fetched.err != nil {
+		return nil, fmt.Errorf("get statement result error: %w", fetched.err)
+	}
+	rows.decoded, rows.decodeErrs = fetched.decoded, fetched.decodeErrs
+	return fetched.page, nil
+}
+
+// fetchedPage is a page fetched ahead, or the error fetching it.
+type fetchedPage struct {
+	page       *redshiftdata.GetStatementResultOutput
+	decoded    [][]driver.Value // decoded holds the records of the page decoded ahead, nil when they are decoded by Next.
+	decodeErrs []error          // decodeErrs holds the decoding error of each record of decoded.
+	err        error
+}
+
+// startPrefetch fetches the pages following the current one in a goroutine, up to buffer pages ahead of the
+// caller scanning the rows, hiding the latency of GetStatementResult on large result sets. The goroutine owns
+// the paginator from then on, it stops at the last page, on the first error or when the rows are closed.
+//
+// With decodeAhead, each page is also decoded in the background, by a goroutine per page running alongside the
+// fetch of the following pages, and the decoded pages are handed to Next in order. Pages are still requested one
+// after the other, as the NextToken of a page is only known from the previous one.
+func (rows *redshiftDataRows) startPrefetch(buffer int, decodeAhead bool) {
+	if buffer <= 0 || rows.p == nil || rows.page == nil || !rows.p.HasMorePages() {
+		return
+	}
+	ctx, cancel := context.WithCancel(rows.ctx)
+	pages := make(chan fetchedPage, buffer)
+	// ahead holds the pages being decoded, in order: its buffer bounds how many pages are held in memory.
+	ahead := make(chan chan fetchedPage, buffer)
+	go func(p *redshiftdata.GetStatementResultPaginator) {
+		defer close(ahead)
+		for p.HasMorePages() {
+			page, err := p.NextPage(ctx)
+			result := make(chan fetchedPage, 1)
+			if err == nil && decodeAhead {
+				go func() {
+					decoded, errs := rows.decodeRecords(page.Records, rows.decode.Workers)
+					result <- fetchedPage{page: page, decoded: decoded, decodeErrs: errs}
+				}()
+			} else {
+				result <- fetchedPage{page: page, err: err}
+			}
+			select {
+			case ahead <- result:
+			case <-ctx.Done():
+				return
+			}
+			if err != nil {
+				return
+			}
+		}
+	}(rows.p)
+	go func() {
+		defer close(pages)
+		for result := range ahead {
+			select {
+			case pages <- <-result:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	rows.prefetch = pages
+	rows.stopPrefetch = cancel
+}
+
+// Columns returns the names of the columns of the result set.
+func (rows *redshiftDataRows) Columns() []string {
+	columns := make([]string, 0, len(rows.columns))
+	for _, c := range rows.columns {
+		switch rows.decode.ColumnCase {
+		case cfg.ColumnCaseLower:
+			columns = append(columns, strings.ToLower(*c.Name))
+		case cfg.ColumnCaseUpper:
+			columns = append(columns, strings.ToUpper(*c.Name))
+		default:
+			columns = append(columns, *c.Name)
+		}
+	}
+	return columns
+}
+
+// Close releases the current page and stops fetching pages ahead, the Data API has nothing to release on the server side.
+func (rows *redshiftDataRows) Close() error {
+	if rows.stopPrefetch != nil {
+		rows.stopPrefetch()
+	}
+	rows.page = nil
+	rows.decoded = nil
+	rows.decodeErrs = nil
+	return nil
+}
+
+// Next populates dest with the next record, fetching the next page when the current one is exhausted.
+func (rows *redshiftDataRows) Next(dest []driver.Value) error {
+	for rows.page == nil || rows.receivedAt >= len(rows.page.Records) {
+		if err := rows.nextPage(); err != nil {
+			return err
+		}
+		if rows.decoded == nil && rows.decode.Workers > 1 {
+			rows.decodePage()
+		}
+	}
+	i := rows.receivedAt
+	rows.receivedAt++
+	if rows.decoded != nil {
+		copy(dest, rows.decoded[i])
+		return rows.decodeErrs[i]
+	}
+	return rows.decodeRecord(rows.page.Records[i], dest)
+}
+
+// decodeRecord converts the fields of a record into dest.
+func (rows *redshiftDataRows) decodeRecord(record []awstypes.Field, dest []driver.Value) error {
+	for i := range dest {
+		if i >= len(record) {
+			break
+		}
+		if fn := rows.converter(i); fn != nil {
+			if _, null := record[i].(*awstypes.FieldMemberIsNull); !null {
+				var err error
+				if dest[i], err = fn(record[i]); err != nil {
+					return fmt.Errorf("column %s: %w", utils.Coalesce(rows.columns[i].Name), err)
+				}
+				continue
+			}
+		}
+		dest[i] = convertField(record[i])
+		if v, ok := dest[i].(int64); ok && rows.decode.IntegerWidth == cfg.IntegerWidthExact {
+			var err error
+			if dest[i], err = narrowInteger(v, rows.typeName(i)); err != nil {
+				return fmt.Errorf("column %s: %w", utils.Coalesce(rows.columns[i].Name), err)
+			}
+		}
+		if v, ok := dest[i].(string); ok && isHexEncoded(rows.typeName(i)) {
+			b, err := hex.DecodeString(strings.TrimPrefix(v, `\x`))
+			if err != nil {
+				return fmt.Errorf("column %s: %s value is not hex-encoded: %w", utils.Coalesce(rows.columns[i].Name), rows.typeName(i), err)
+			}
+			dest[i] = b
+		}
+		if v, ok := dest[i].(string); ok && rows.decimal(i) {
+			var err error
+			if dest[i], err = ParseDecimal(v); err != nil {
+				return fmt.Errorf("column %s: %w", utils.Coalesce(rows.columns[i].Name), err)
+			}
+		}
+		if v, ok := dest[i].(string); ok && rows.decode.ParseTime {
+			if timestamp, withZone := isTimestamp(rows.typeName(i)); timestamp {
+				var err error
+				if dest[i], err = parseTimestamp(v, withZone, rows.loc); err != nil {
+					return fmt.Errorf("column %s: %w", utils.Coalesce(rows.columns[i].Name), err)
+				}
+			}
+		}
+		if v, ok := dest[i].(string); ok && rows.superMode(i) != "" {
+			var err error
+			if dest[i], err = decodeSuper(v, rows.superMode(i)); err != nil {
+				return fmt.Errorf("column %s: %w", utils.Coalesce(rows.columns[i].Name), err)
+			}
+		}
+		if rows.decode.StrictTypes && dest[i] != nil {
+			if scanType := rows.ColumnTypeScanType(i); scanType != scanTypeAny && reflect.TypeOf(dest[i]) != scanType {
+				return fmt.Errorf("column %s: %T value does not match column type %s", utils.Coalesce(rows.columns[i].Name), dest[i], rows.typeName(i))
+			}
+		}
+	}
+	return nil
+}
+
+// converter returns the registered converter of the column, nil when there is none.
+func (rows *redshiftDataRows) converter(index int) ConverterFunc {
+	if index < len(rows.converters) {
+		return rows.converters[index]
+	}
+	return nil
+}
+
+// isHexEncoded reports whether the Data API returns the values of columns of the type hex-encoded: VARBYTE and
+// BYTEA values, the latter with the \x prefix of the bytea format, and the EWKB of GEOMETRY and GEOGRAPHY values,
+// which are decoded into []byte.
+func isHexEncoded(typeName string) bool {
+	switch typeName {
+	case "varbyte", "varbinary", "binary varying", "bytea", "geometry", "geography":
+		return true
+	}
+	return false
+}
+
+// decimal reports whether the values of the column are returned as Decimal.
+func (rows *redshiftDataRows) decimal(index int) bool {
+	switch rows.typeName(index) {
+	case "numeric", "decimal":
+		return rows.decode.Numeric == cfg.NumericDecimal
+	}
+	return false
+}
+
+// superMode returns the SuperJSON or SuperValue decoding of the column, "" when it is not a SUPER column
+// or its values are returned as strings.
+func (rows *redshiftDataRows) superMode(index int) string {
+	if rows.decode.Super == "" || rows.decode.Super == cfg.SuperString || rows.typeName(index) != "super" {
+		return ""
+	}
+	return rows.decode.Super
+}
+
+// decodeSuper converts the JSON text of a SUPER value as selected by mode.
+func decodeSuper(v string, mode string) (driver.Value, error) {
+	if mode == cfg.SuperJSON {
+		if !json.Valid([]byte(v)) {
+			return nil, fmt.Errorf("SUPER value is not valid JSON: %.40q", v)
+		}
+		return json.RawMessage(v), nil
+	}
+	var value any
+	if err := json.Unmarshal([]byte(v), &value); err != nil {
+		return nil, fmt.Errorf("decode SUPER value: %w", err)
+	}
+	return value, nil
+}
+
+// decodePage decodes the records of the current page with the configured number of workers, see decodeRecords.
+func (rows *redshiftDataRows) decodePage() {
+	rows.decoded, rows.decodeErrs = rows.decodeRecords(rows.page.Records, rows.decode.Workers)
+}
+
+// decodeRecords decodes records with workers goroutines, each one decoding a contiguous range of records, so rows
+// are returned in order.
+func (rows *redshiftDataRows) decodeRecords(records [][]awstypes.Field, workers int) ([][]driver.Value, []error) {
+	decoded := make([][]driver.Value, len(records))
+	errs := make([]error, len(records))
+	chunk := max((len(records)+workers-1)/max(workers, 1), 1)
+	var wg sync.WaitGroup
+	for start := 0; start < len(records); start += chunk {
+		end := min(start+chunk, len(records))
+		wg.Add(1)
+		go func(start, end int) {
+			defer wg.Done()
+			for i := start; i < end; i++ {
+				decoded[i] = make([]driver.Value, len(rows.columns))
+				errs[i] = rows.decodeRecord(records[i], decoded[i])
+			}
+		}(start, end)
+	}
+	wg.Wait()
+	return decoded, errs
+}
+
+func (rows *redshiftDataRows) typeName(index int) string {
+	return strings.ToLower(utils.Coalesce(rows.columns[index].TypeName))
+}
+
+// narrowInteger converts the value of a SMALLINT or INTEGER column into an int16 or int32,
+// reporting values that do not fit the type of the column.
+func narrowInteger(v int64, typeName string) (driver.Value, error) {
+	switch typeName {
+	case "int2", "smallint":
+		if v < math.MinInt16 || v > math.MaxInt16 {
+			return nil, fmt.Errorf("value %d overflows %s", v, typeName)
+		}
+		return int16(v), nil
+	case "int4", "integer", "int":
+		if v < math.MinInt32 || v > math.MaxInt32 {
+			return nil, fmt.Errorf("value %d overflows %s", v, typeName)
+		}
+		return int32(v), nil
+	}
+	return v, nil
+}
+
+var (
+	scanTypeBool    = reflect.TypeOf(false)
+	scanTypeInt16   = reflect.TypeOf(int16(0))
+	scanTypeInt32   = reflect.TypeOf(int32(0))
+	scanTypeInt64   = reflect.TypeOf(int64(0))
+	scanTypeFloat64 = reflect.TypeOf(float64(0))
+	scanTypeString  = reflect.TypeOf("")
+	scanTypeBytes   = reflect.TypeOf([]byte(nil))
+	scanTypeAny     = reflect.TypeOf((*any)(nil)).Elem()
+	scanTypeJSON    = reflect.TypeOf(json.RawMessage(nil))
+	scanTypeDecimal = reflect.TypeOf(Decimal{})
+	scanTypeTime    = reflect.TypeOf(time.Time{})
+)
+
+// ColumnTypeScanType returns the Go type of the values Next returns for the column, derived from its type name.
+func (rows *redshiftDataRows) ColumnTypeScanType(index int) reflect.Type {
+	if rows.converter(index) != nil {
+		return scanTypeAny
+	}
+	switch rows.typeName(index) {
+	case "bool", "boolean":
+		return scanTypeBool
+	case "int2", "smallint":
+		if rows.decode.IntegerWidth == cfg.IntegerWidthExact {
+			return scanTypeInt16
+		}
+		return scanTypeInt64
+	case "int4", "integer", "int":
+		if rows.decode.IntegerWidth == cfg.IntegerWidthExact {
+			return scanTypeInt32
+		}
+		return scanTypeInt64
+	case "int8", "bigint", "oid":
+		return scanTypeInt64
+	case "float4", "float8", "real", "float", "double precision":
+		return scanTypeFloat64
+	case "varbyte", "varbinary", "binary varying", "bytea", "geometry", "geography":
+		// GEOMETRY and GEOGRAPHY values are returned as EWKB, select ST_AsText to read them as WKT
+		return scanTypeBytes
+	case "numeric", "decimal":
+		if rows.decimal(index) {
+			return scanTypeDecimal
+		}
+	case "timestamp", "timestamp without time zone", "timestamptz", "timestamp with time zone":
+		if rows.decode.ParseTime {
+			return scanTypeTime
+		}
+	case "hllsketch":
+		// HLLSKETCH values are returned in their text form, JSON for sparse sketches and Base64 for dense ones
+		return scanTypeString
+	case "super":
+		switch rows.superMode(index) {
+		case cfg.SuperJSON:
+			return scanTypeJSON
+		case cfg.SuperValue:
+			return scanTypeAny
+		}
+	case "":
+		return scanTypeAny
+	}
+	// numeric, character, date and time, SUPER and other types are all returned as strings by the Data API.
+	return scanTypeString
+}
+
+// ColumnTypeDatabaseTypeName returns the upper-cased type name of the column, such as INT4 or VARCHAR.
+func (rows *redshiftDataRows) ColumnTypeDatabaseTypeName(index int) string {
+	return strings.ToUpper(rows.typeName(index))
+}
+
+// ColumnTypeNullable reports whether the column may be null, ok is false when the nullability is unknown.
+func (rows *redshiftDataRows) ColumnTypeNullable(index int) (nullable, ok bool) {
+	switch rows.columns[index].Nullable {
+	case 0:
+		return false, true
+	case 1:
+		return true, true
+	}
+	return false, false
+}
+
+// ColumnTypeLength returns the declared length of CHAR, VARCHAR and VARBYTE columns, ok is false for the other types.
+// The Data API reports the declared length of character columns as their precision.
+func (rows *redshiftDataRows) ColumnTypeLength(index int) (length int64, ok bool) {
+	switch rows.typeName(index) {
+	case "char", "bpchar", "character", "nchar", "varchar", "character varying", "nvarchar", "text",
+		"varbyte", "varbinary", "binary varying":
+		c := rows.columns[index]
+		if c.Length > 0 {
+			return int64(c.Length), true
+		}
+		return int64(c.Precision), true
+	}
+	return 0, false
+}
+
+// ColumnTypePrecisionScale returns the precision and scale of NUMERIC and DECIMAL columns, ok is false for the other types.
+func (rows *redshiftDataRows) ColumnTypePrecisionScale(index int) (precision, scale int64, ok bool) {
+	switch rows.typeName(index) {
+	case "numeric", "decimal":
+		c := rows.columns[index]
+		return int64(c.Precision), int64(c.Scale), true
+	}
+	return 0, 0, false
+}
+
+// convertField converts a Data API field into the corresponding driver.Value.
+func convertField(field awstypes.Field) driver.Value {
+	switch f := field.(type) {
+	case *awstypes.FieldMemberIsNull:
+		return nil
+	case *awstypes.FieldMemberBooleanValue:
+		return f.Value
+	case *awstypes.FieldMemberLongValue:
+		return f.Value
+	case *awstypes.FieldMemberDoubleValue:
+		return f.Value
+	case *awstypes.FieldMemberStringValue:
+		return f.Value
+	case *awstypes.FieldMemberBlobValue:
+		return f.Value
+	}
+	return nil
+}
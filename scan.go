@@ -0,0 +1,58 @@
+package metasql
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// ScanSlice scans the current row of rows into a slice holding one value per column, as returned by the driver.
+func ScanSlice(rows *sql.Rows) ([]any, error) {
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	values := make([]any, len(columns))
+	dest := make([]any, len(columns))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	if err := rows.Scan(dest...); err != nil {
+		return nil, err
+	}
+	return values, nil
+}
+
+// ScanMap scans the current row of rows into a map keyed by column name.
+// It fails when several columns have the same name, since their values would overwrite each other.
+func ScanMap(rows *sql.Rows) (map[string]any, error) {
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	values, err := ScanSlice(rows)
+	if err != nil {
+		return nil, err
+	}
+	row := make(map[string]any, len(columns))
+	for i, c := range columns {
+		if _, ok := row[c]; ok {
+			return nil, fmt.Errorf("duplicate column %q", c)
+		}
+		row[c] = values[i]
+	}
+	return row, nil
+}
+
+// CollectMaps reads all remaining rows into maps keyed by column name and closes rows.
+func CollectMaps(rows *sql.Rows) ([]map[string]any, error) {
+	defer rows.Close()
+	var result []map[string]any
+	for rows.Next() {
+		row, err := ScanMap(rows)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, row)
+	}
+	return result, rows.Err()
+}
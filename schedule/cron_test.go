@@ -0,0 +1,157 @@
+package schedule
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// runs returns the first n times matching expr after from.
+func runs(t *testing.T, expr string, from time.Time, n int) []time.Time {
+	t.Helper()
+	c, err := ParseCron(expr)
+	if err != nil {
+		t.Fatalf("ParseCron(%q): %v", expr, err)
+	}
+	var times []time.Time
+	for i := 0; i < n; i++ {
+		from = c.Next(from)
+		times = append(times, from)
+	}
+	return times
+}
+
+func TestCronNext(t *testing.T) {
+	// 2024-01-01 is a Monday
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name string
+		expr string
+		want []string
+	}{
+		{"every minute", "* * * * *", []string{"2024-01-01 00:01", "2024-01-01 00:02"}},
+		{"minute step", "*/20 * * * *", []string{"2024-01-01 00:20", "2024-01-01 00:40", "2024-01-01 01:00"}},
+		{"step from a value", "5/20 * * * *", []string{"2024-01-01 00:05", "2024-01-01 00:25", "2024-01-01 00:45", "2024-01-01 01:05"}},
+		{"step on a range", "0 8-18/4 * * *", []string{"2024-01-01 08:00", "2024-01-01 12:00", "2024-01-01 16:00", "2024-01-02 08:00"}},
+		{"list", "15,45 9 * * *", []string{"2024-01-01 09:15", "2024-01-01 09:45", "2024-01-02 09:15"}},
+		{"day of month", "0 0 13 * *", []string{"2024-01-13 00:00", "2024-02-13 00:00"}},
+		{"day of week", "0 0 * * fri", []string{"2024-01-05 00:00", "2024-01-12 00:00"}},
+		{"day of month or week", "0 0 13 * fri", []string{"2024-01-05 00:00", "2024-01-12 00:00", "2024-01-13 00:00", "2024-01-19 00:00"}},
+		{"day of month step is restricted", "0 0 */10 * mon", []string{"2024-01-08 00:00", "2024-01-11 00:00", "2024-01-15 00:00"}},
+		{"sunday as 7", "0 0 * * 7", []string{"2024-01-07 00:00", "2024-01-14 00:00"}},
+		{"range to sunday", "0 0 * * 6-7", []string{"2024-01-06 00:00", "2024-01-07 00:00", "2024-01-13 00:00"}},
+		{"day names", "0 9 * * MON-wed", []string{"2024-01-01 09:00", "2024-01-02 09:00", "2024-01-03 09:00", "2024-01-08 09:00"}},
+		{"month names", "0 0 1 mar,Sep *", []string{"2024-03-01 00:00", "2024-09-01 00:00", "2025-03-01 00:00"}},
+		{"month range", "0 0 1 nov-dec *", []string{"2024-11-01 00:00", "2024-12-01 00:00", "2025-11-01 00:00"}},
+		{"leap day", "0 0 29 2 *", []string{"2024-02-29 00:00", "2028-02-29 00:00"}},
+		{"31st", "0 0 31 * *", []string{"2024-01-31 00:00", "2024-03-31 00:00"}},
+		{"weekly", "@weekly", []string{"2024-01-07 00:00", "2024-01-14 00:00"}},
+		{"yearly", "@Yearly", []string{"2025-01-01 00:00"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, next := range runs(t, tt.expr, from, len(tt.want)) {
+				got = append(got, next.Format("2006-01-02 15:04"))
+			}
+			if strings.Join(got, ", ") != strings.Join(tt.want, ", ") {
+				t.Errorf("%q after %s\n got %v\nwant %v", tt.expr, from.Format(time.DateTime), got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCronNextTruncatesToTheMinute(t *testing.T) {
+	from := time.Date(2024, 1, 1, 9, 59, 30, 0, time.UTC)
+	if got := runs(t, "0 10 * * *", from, 1)[0]; !got.Equal(time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)) {
+		t.Errorf("Next = %v, want 10:00", got)
+	}
+	from = time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	if got := runs(t, "0 10 * * *", from, 1)[0]; !got.Equal(time.Date(2024, 1, 2, 10, 0, 0, 0, time.UTC)) {
+		t.Errorf("Next = %v, want the next day, Next is strictly after t", got)
+	}
+}
+
+func TestCronNextDST(t *testing.T) {
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip(err)
+	}
+	// 2024-03-10 02:00 to 03:00 does not exist in New York, the run of that day is skipped
+	from := time.Date(2024, 3, 10, 0, 0, 0, 0, loc)
+	if got, want := runs(t, "30 2 * * *", from, 1)[0], time.Date(2024, 3, 11, 2, 30, 0, 0, loc); !got.Equal(want) {
+		t.Errorf("Next in the DST gap = %v, want %v", got, want)
+	}
+	if got, want := runs(t, "0 3 * * *", from, 1)[0], time.Date(2024, 3, 10, 3, 0, 0, 0, loc); !got.Equal(want) {
+		t.Errorf("Next after the DST gap = %v, want %v", got, want)
+	}
+	// 2024-11-03 01:00 to 02:00 happens twice, it runs once
+	from = time.Date(2024, 11, 3, 0, 0, 0, 0, loc)
+	got := runs(t, "30 1 * * *", from, 2)
+	if want := time.Date(2024, 11, 4, 1, 30, 0, 0, loc); !got[1].Equal(want) {
+		t.Errorf("Next after the repeated hour = %v, want %v", got[1], want)
+	}
+	if want := time.Date(2024, 11, 3, 5, 30, 0, 0, time.UTC); !got[0].Equal(want) {
+		t.Errorf("Next in the repeated hour = %v, want %v, the first 01:30", got[0], want)
+	}
+	if got[0].Location() != loc {
+		t.Errorf("Next location = %v, want %v", got[0].Location(), loc)
+	}
+
+	// 2018-11-04 00:00 to 01:00 did not exist in São Paulo, the day started at 01:00
+	loc, err = time.LoadLocation("America/Sao_Paulo")
+	if err != nil {
+		t.Skip(err)
+	}
+	from = time.Date(2018, 11, 3, 12, 0, 0, 0, loc)
+	if got, want := runs(t, "0 * 4 * *", from, 1)[0], time.Date(2018, 11, 4, 1, 0, 0, 0, loc); !got.Equal(want) {
+		t.Errorf("Next after a skipped midnight = %v, want %v", got, want)
+	}
+}
+
+func TestCronNextFiveYearCutoff(t *testing.T) {
+	tests := []struct {
+		name string
+		expr string
+		from time.Time
+	}{
+		{"never", "0 0 30 2 *", time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)},
+		// 2100 is not a leap year, the next 29th of February is in 2104
+		{"beyond five years", "0 0 29 2 *", time.Date(2097, 3, 1, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := runs(t, tt.expr, tt.from, 1)[0]; !got.IsZero() {
+				t.Errorf("Next = %v, want the zero time", got)
+			}
+		})
+	}
+}
+
+func TestParseCronErrors(t *testing.T) {
+	tests := []struct {
+		expr string
+		err  string
+	}{
+		{"* * * *", "expected 5 fields, got 4"},
+		{"* * * * * *", "expected 5 fields, got 6"},
+		{"60 * * * *", "minute: invalid value \"60\""},
+		{"* 24 * * *", "hour: invalid value \"24\""},
+		{"* * 0 * *", "day of month: invalid value \"0\""},
+		{"* * * 13 *", "month: invalid value \"13\""},
+		{"* * * * 8", "day of week: invalid value \"8\""},
+		{"* * * foo *", "month: invalid value \"foo\""},
+		{"*/0 * * * *", "invalid step \"0\""},
+		{"*/x * * * *", "invalid step \"x\""},
+		{"30-10 * * * *", "invalid range \"30-10\""},
+		{"@reboot", "expected 5 fields"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			_, err := ParseCron(tt.expr)
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("ParseCron(%q) = %v, want an error containing %q", tt.expr, err, tt.err)
+			}
+		})
+	}
+}
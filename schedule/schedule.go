@@ -0,0 +1,299 @@
+// Package schedule submits recurring statements on cron schedules through metasql.Async, for the few
+// periodic Redshift jobs that do not warrant a workflow orchestrator. Runs of a job never overlap, failed
+// runs are retried according to the job's policy and the outcome of every run is kept for inspection.
+package schedule
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/adarsh-jaiss/metasql"
+)
+
+const (
+	DefaultPoll    = 5 * time.Second // DefaultPoll is the interval the status of a running statement is polled at.
+	DefaultHistory = 20              // DefaultHistory is the number of runs kept per job.
+)
+
+// Status is the outcome of a run.
+type Status string
+
+const (
+	Succeeded Status = "succeeded" // Succeeded is a run whose statement finished.
+	Failed    Status = "failed"    // Failed is a run whose statement still failed after the retries.
+	Skipped   Status = "skipped"   // Skipped is a run not started because the previous run of the job was still running.
+)
+
+// RetryPolicy controls how failed runs are retried.
+type RetryPolicy struct {
+	Attempts int           // Attempts is the number of times a failed statement is submitted again.
+	Backoff  time.Duration // Backoff is the delay before the first retry, doubled after each one. Defaults to 30 seconds.
+}
+
+// Job is a statement submitted on a schedule.
+type Job struct {
+	Name     string        // Name identifies the job, it must be unique within a Scheduler.
+	Schedule string        // Schedule is a cron expression, see ParseCron.
+	Query    string        // Query is the statement to submit.
+	Args     []any         // Args are the arguments of the statement.
+	Timeout  time.Duration // Timeout, when set, cancels an attempt running for longer.
+	Retry    RetryPolicy
+
+	cron *Cron
+}
+
+// Run is the outcome of one scheduled run of a job.
+type Run struct {
+	Job         string
+	Scheduled   time.Time // Scheduled is the time the run was due.
+	Start       time.Time
+	End         time.Time
+	Status      Status
+	Attempts    int    // Attempts is the number of times the statement was submitted.
+	StatementID string // StatementID is the id of the statement of the last attempt.
+	ResultRows  int64  // ResultRows is the number of rows returned or affected by the statement, -1 when unknown.
+	Err         error  // Err is the error of the last attempt of a failed run.
+}
+
+// JobStatus is a snapshot of a job.
+type JobStatus struct {
+	Job     string
+	Next    time.Time // Next is the next time the job is due.
+	Running bool      // Running reports whether a run of the job is in progress.
+	Last    *Run      // Last is the last finished run, nil before the first one.
+}
+
+// Scheduler submits the statements of its jobs when they are due.
+type Scheduler struct {
+	async    *metasql.Async
+	location *time.Location
+	poll     time.Duration
+	history  int
+	onRun    func(Run)
+
+	mu      sync.Mutex
+	jobs    map[string]*Job
+	running map[string]bool
+	runs    map[string][]Run
+	next    map[string]time.Time
+	wg      sync.WaitGroup
+}
+
+// New returns a Scheduler submitting statements through async, evaluating schedules in the local time zone.
+func New(async *metasql.Async) *Scheduler {
+	return &Scheduler{
+		async:    async,
+		location: time.Local,
+		poll:     DefaultPoll,
+		history:  DefaultHistory,
+		jobs:     map[string]*Job{},
+		running:  map[string]bool{},
+		runs:     map[string][]Run{},
+		next:     map[string]time.Time{},
+	}
+}
+
+// WithLocation sets the time zone schedules are evaluated in and returns the scheduler.
+func (s *Scheduler) WithLocation(loc *time.Location) *Scheduler {
+	s.location = loc
+	return s
+}
+
+// WithPoll sets the interval the status of running statements is polled at and returns the scheduler.
+func (s *Scheduler) WithPoll(d time.Duration) *Scheduler {
+	s.poll = d
+	return s
+}
+
+// WithHistory sets the number of runs kept per job and returns the scheduler.
+func (s *Scheduler) WithHistory(n int) *Scheduler {
+	s.history = n
+	return s
+}
+
+// OnRun registers a function called with the outcome of every run, for example to log it or record it in a table.
+func (s *Scheduler) OnRun(fn func(Run)) *Scheduler {
+	s.onRun = fn
+	return s
+}
+
+// Add registers a job. Jobs can be added while the scheduler runs.
+func (s *Scheduler) Add(job Job) error {
+	cron, err := ParseCron(job.Schedule)
+	if err != nil {
+		return fmt.Errorf("job %s: %w", job.Name, err)
+	}
+	job.cron = cron
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.jobs[job.Name]; ok {
+		return fmt.Errorf("job %s is already scheduled", job.Name)
+	}
+	s.jobs[job.Name] = &job
+	s.next[job.Name] = cron.Next(time.Now().In(s.location))
+	return nil
+}
+
+// Remove unregisters a job, a run in progress is finished.
+func (s *Scheduler) Remove(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.jobs, name)
+	delete(s.next, name)
+}
+
+// Run starts the jobs when they are due until ctx is done, then waits for the runs in progress to finish.
+// Cancelling ctx cancels their statements too.
+func (s *Scheduler) Run(ctx context.Context) error {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			s.wg.Wait()
+			return ctx.Err()
+		case now := <-ticker.C:
+			s.startDue(ctx, now.In(s.location))
+		}
+	}
+}
+
+// startDue starts the runs of the jobs due at now.
+func (s *Scheduler) startDue(ctx context.Context, now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for name, job := range s.jobs {
+		due := s.next[name]
+		if due.IsZero() || now.Before(due) {
+			continue
+		}
+		s.next[name] = job.cron.Next(now)
+		if s.running[name] {
+			s.record(Run{Job: name, Scheduled: due, Start: now, End: now, Status: Skipped, ResultRows: -1})
+			continue
+		}
+		s.running[name] = true
+		s.wg.Add(1)
+		go func(job *Job) {
+			defer s.wg.Done()
+			run := s.execute(ctx, job, due)
+			s.mu.Lock()
+			s.running[job.Name] = false
+			s.record(run)
+			s.mu.Unlock()
+		}(job)
+	}
+}
+
+// record keeps the run in the history of its job and reports it, s.mu must be held.
+func (s *Scheduler) record(run Run) {
+	runs := append(s.runs[run.Job], run)
+	if len(runs) > s.history {
+		runs = runs[len(runs)-s.history:]
+	}
+	s.runs[run.Job] = runs
+	if s.onRun != nil {
+		s.onRun(run)
+	}
+}
+
+// execute runs the statement of the job, retrying it according to the job's policy.
+func (s *Scheduler) execute(ctx context.Context, job *Job, due time.Time) Run {
+	run := Run{Job: job.Name, Scheduled: due, Start: time.Now(), ResultRows: -1}
+	backoff := job.Retry.Backoff
+	if backoff <= 0 {
+		backoff = 30 * time.Second
+	}
+	for {
+		run.Attempts++
+		status, err := s.attempt(ctx, job, &run)
+		if err == nil {
+			run.Status = Succeeded
+			run.ResultRows = status.ResultRows
+			run.Err = nil
+			break
+		}
+		run.Err = err
+		if run.Attempts > job.Retry.Attempts || ctx.Err() != nil {
+			run.Status = Failed
+			break
+		}
+		select {
+		case <-ctx.Done():
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+	run.End = time.Now()
+	return run
+}
+
+// attempt submits the statement once and polls it until it finished.
+func (s *Scheduler) attempt(ctx context.Context, job *Job, run *Run) (*metasql.StatementStatus, error) {
+	if job.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, job.Timeout)
+		defer cancel()
+	}
+	id, err := s.async.Submit(ctx, job.Query, job.Args...)
+	if err != nil {
+		return nil, err
+	}
+	run.StatementID = id
+	ticker := time.NewTicker(s.poll)
+	defer ticker.Stop()
+	for {
+		status, err := s.async.Status(ctx, id)
+		if err == nil && status.Finished() {
+			if status.Status != "FINISHED" {
+				return nil, fmt.Errorf("statement %s %s: %s", id, status.Status, status.Error)
+			}
+			return status, nil
+		}
+		select {
+		case <-ctx.Done():
+			// the statement outlives ctx on the Data API side unless it is cancelled
+			s.async.Cancel(context.WithoutCancel(ctx), id)
+			return nil, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// LastRun returns the last finished run of the job.
+func (s *Scheduler) LastRun(name string) (Run, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	runs := s.runs[name]
+	if len(runs) == 0 {
+		return Run{}, false
+	}
+	return runs[len(runs)-1], true
+}
+
+// History returns the runs kept for the job, oldest first.
+func (s *Scheduler) History(name string) []Run {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Run(nil), s.runs[name]...)
+}
+
+// Status returns a snapshot of every job, sorted by name.
+func (s *Scheduler) Status() []JobStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	statuses := make([]JobStatus, 0, len(s.jobs))
+	for name := range s.jobs {
+		status := JobStatus{Job: name, Next: s.next[name], Running: s.running[name]}
+		if runs := s.runs[name]; len(runs) > 0 {
+			last := runs[len(runs)-1]
+			status.Last = &last
+		}
+		statuses = append(statuses, status)
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Job < statuses[j].Job })
+	return statuses
+}
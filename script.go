@@ -0,0 +1,110 @@
+package metasql
+
+import (
+	"context"
+	"database/sql"
+	stderrors "errors"
+	"io"
+	"strings"
+
+	"github.com/adarsh-jaiss/metasql/errors"
+)
+
+// ScriptOptions controls how RunScript executes a script.
+type ScriptOptions struct {
+	StopOnError bool                            // StopOnError stops at the first failing statement instead of running the rest of the script.
+	Batch       bool                            // Batch runs the script in one transaction, submitted as a batch at commit unless config.TxMode says otherwise.
+	Progress    func(i, total int, stmt string) // Progress is called before each statement is executed, i is 1-based.
+}
+
+// RunScript executes the statements of a SQL script, such as a migration or a .sql file of a deploy, one after the
+// other. Statements are separated by semicolons, the ones of comments, quoted strings and dollar-quoted
+// bodies of functions and procedures excluded, see SplitScript.
+//
+// Failed statements are returned as *errors.ScriptError, joined when StopOnError is not set. A batched script
+// runs in a transaction, so it stops at the first failing statement whatever StopOnError: nothing is applied and
+// the error reports the statement the batch failed on.
+func RunScript(ctx context.Context, db *sql.DB, r io.Reader, opts ScriptOptions) error {
+	stmts, err := SplitScript(r)
+	if err != nil {
+		return err
+	}
+	if opts.Batch {
+		return runScriptBatch(ctx, db, stmts, opts)
+	}
+	var errs []error
+	for i, stmt := range stmts {
+		opts.progress(i+1, len(stmts), stmt)
+		if _, err := db.ExecContext(withStatementToken(ctx, i), stmt); err != nil {
+			errs = append(errs, errors.NewScriptError(i+1, stmt, err))
+			if opts.StopOnError || ctx.Err() != nil {
+				break
+			}
+		}
+	}
+	return stderrors.Join(errs...)
+}
+
+// runScriptBatch executes the statements in one transaction.
+func runScriptBatch(ctx context.Context, db *sql.DB, stmts []string, opts ScriptOptions) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for i, stmt := range stmts {
+		opts.progress(i+1, len(stmts), stmt)
+		if _, err := tx.ExecContext(ctx, stmt); err != nil {
+			return errors.NewScriptError(i+1, stmt, err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		var stmtErr *errors.StatementError
+		if stderrors.As(err, &stmtErr) && stmtErr.SubStatement > 0 && stmtErr.SubStatement <= len(stmts) {
+			return errors.NewScriptError(stmtErr.SubStatement, stmts[stmtErr.SubStatement-1], err)
+		}
+		return err
+	}
+	return nil
+}
+
+func (opts ScriptOptions) progress(i, total int, stmt string) {
+	if opts.Progress != nil {
+		opts.Progress(i, total, stmt)
+	}
+}
+
+// SplitScript reads a SQL script and splits it into its statements. Semicolons end statements unless they are
+// part of a comment, a quoted string or identifier, or a dollar-quoted string. Statements are trimmed, and
+// the ones holding nothing but comments are dropped.
+func SplitScript(r io.Reader) ([]string, error) {
+	script, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	return splitStatements(string(script)), nil
+}
+
+// splitStatements splits script into its statements, see SplitScript.
+func splitStatements(script string) []string {
+	runes := []rune(script)
+	var stmts []string
+	start, code := 0, false
+	flush := func(end int) {
+		if stmt := strings.TrimSpace(string(runes[start:end])); code && stmt != "" {
+			stmts = append(stmts, stmt)
+		}
+		code = false
+	}
+	for _, tok := range tokenize(runes) {
+		switch {
+		case tok.kind == tokenOther && runes[tok.start] == ';':
+			flush(tok.start)
+			start = tok.end
+		case tok.kind != tokenSpace && tok.kind != tokenComment:
+			code = true
+		}
+	}
+	flush(len(runes))
+	return stmts
+}
@@ -0,0 +1,210 @@
+package metasql
+
+import (
+	"context"
+	"database/sql"
+	stderrors "errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/adarsh-jaiss/metasql/errors"
+	"github.com/adarsh-jaiss/metasql/hooks"
+	"github.com/adarsh-jaiss/metasql/utils"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/redshiftdata"
+	awstypes "github.com/aws/aws-sdk-go-v2/service/redshiftdata/types"
+	"github.com/aws/smithy-go"
+)
+
+// DefaultSessionKeepAlive is how long a pinned session is kept alive after each statement when
+// PinSession is called without a keep-alive.
+const DefaultSessionKeepAlive = 10 * time.Minute
+
+// dataAPISession is the Data API session statements of a connection are pinned to.
+type dataAPISession struct {
+	pinned    bool   // pinned is set between PinSession and ReleaseSession.
+	id        string // id is the session id returned by the first statement, empty until then.
+	keepAlive int32  // keepAlive is the number of seconds the session is kept alive after each statement.
+
+	settings []sessionSetting                            // settings are the SET statements run in the session, reapplied when it expired.
+	prepared map[string]preparedStatement                // prepared holds the statements prepared with PrepareServerStatement by name.
+	restore  func(ctx context.Context, s *Session) error // restore re-creates the state of an expired session, see OnSessionRestore.
+}
+
+// preparedStatement is a statement prepared in a session, see PrepareServerStatement.
+type preparedStatement struct {
+	session     string // session is the id of the session the statement was prepared in.
+	fingerprint uint64 // fingerprint is the fingerprint of the PREPARE statement.
+}
+
+// sessionSetting is a SET statement run in a session.
+type sessionSetting struct {
+	name       string // name is the lower-cased name of the setting.
+	sql        string
+	parameters []awstypes.SqlParameter
+}
+
+// PinSession pins the statements executed on c to a single Data API session until ReleaseSession, so
+// session state such as temporary tables outlives a statement without opening a transaction:
+//
+//	c, _ := db.Conn(ctx)
+//	defer c.Close()
+//	metasql.PinSession(ctx, c, time.Minute)
+//	defer metasql.ReleaseSession(c)
+//	c.ExecContext(ctx, "CREATE TEMP TABLE t AS SELECT ...")
+//	c.QueryContext(ctx, "SELECT * FROM t")
+//
+// The session is created by the next statement and kept alive for keepAlive after every statement,
+// up to the 24 hours limit of the Data API. While pinned, statements are not routed by Route hooks.
+func PinSession(ctx context.Context, c *sql.Conn, keepAlive time.Duration) error {
+	if keepAlive <= 0 {
+		keepAlive = DefaultSessionKeepAlive
+	}
+	return rawConn(c, func(conn *redshiftDataConn) error {
+		if err := conn.sessionsEnabled(); err != nil {
+			return err
+		}
+		if caps := conn.caps.peek(); caps != nil && !caps.Sessions {
+			return errors.NewUnsupportedError("sessions", errors.LimitationPlatform, "the target did not create a session when probed")
+		}
+		if err := conn.flushBatch(ctx); err != nil {
+			return err
+		}
+		conn.session = dataAPISession{
+			pinned:    true,
+			keepAlive: int32(keepAlive / time.Second),
+			restore:   conn.session.restore,
+		}
+		return nil
+	})
+}
+
+// ReleaseSession stops pinning the statements of c to a session. The session itself expires once its
+// keep-alive elapses.
+func ReleaseSession(c *sql.Conn) error {
+	return rawConn(c, func(conn *redshiftDataConn) error {
+		err := conn.flushBatch(context.Background())
+		conn.session = dataAPISession{}
+		return err
+	})
+}
+
+// SessionID returns the id of the session the statements of c are pinned to, empty when the session
+// was not created yet or c is not pinned.
+func SessionID(c *sql.Conn) (string, error) {
+	var id string
+	err := rawConn(c, func(conn *redshiftDataConn) error {
+		id = conn.session.id
+		return nil
+	})
+	return id, err
+}
+
+// rawConn calls fn with the driver connection of c.
+func rawConn(c *sql.Conn, fn func(conn *redshiftDataConn) error) error {
+	return c.Raw(func(driverConn any) error {
+		conn, ok := driverConn.(*redshiftDataConn)
+		if !ok {
+			return errors.NewUnsupportedError(fmt.Sprintf("connection %T", driverConn), errors.LimitationDriver, "open the database with a metasql connector")
+		}
+		return fn(conn)
+	})
+}
+
+// sessionParams returns the session id and keep-alive to send with a statement, along with whether
+// the statement targets the session rather than the configured database.
+func (conn *redshiftDataConn) sessionParams() (id *string, keepAlive *int32, inSession bool) {
+	if !conn.session.pinned {
+		return nil, nil, false
+	}
+	return utils.Nullif(conn.session.id), aws.Int32(conn.session.keepAlive), conn.session.id != ""
+}
+
+// sessionStarted records the session id returned by a statement of a pinned connection.
+func (conn *redshiftDataConn) sessionStarted(id *string) {
+	if conn.session.pinned && conn.session.id == "" && id != nil {
+		conn.session.id = *id
+	}
+}
+
+// Session runs statements in the session of a pinned connection while its state is restored, see OnSessionRestore.
+type Session struct {
+	conn *redshiftDataConn
+}
+
+// ExecContext executes a statement in the new session.
+func (s *Session) ExecContext(ctx context.Context, query string, args ...any) error {
+	namedArgs, err := namedValues(args, s.conn.cfg.ParameterEncoder)
+	if err != nil {
+		return err
+	}
+	_, _, err = s.conn.runStatement(ctx, hooks.KindExec, query, namedArgs)
+	return err
+}
+
+// OnSessionRestore registers fn to re-create the state of the session c is pinned to, such as temporary tables,
+// when the session expired. The SET statements run in the session are reapplied first, then fn is called on the
+// new session, and the statement that found the session expired is executed again, so long-lived workers
+// survive the expiry of their sessions. Sessions expiring within a transaction are not restored, the transaction
+// is lost with them. fn stays registered until the session is released, nil unregisters it.
+func OnSessionRestore(c *sql.Conn, fn func(ctx context.Context, s *Session) error) error {
+	return rawConn(c, func(conn *redshiftDataConn) error {
+		conn.session.restore = fn
+		return nil
+	})
+}
+
+// recordSetting records the SET and RESET statements executed in the session, to reapply them when it expired.
+func (conn *redshiftDataConn) recordSetting(params *redshiftdata.ExecuteStatementInput) {
+	query := utils.Coalesce(params.Sql)
+	keyword := leadingKeyword(query)
+	if !conn.session.pinned || keyword != "SET" && keyword != "RESET" {
+		return
+	}
+	fields := strings.Fields(trimLeadingComments(query))
+	if len(fields) < 2 {
+		return
+	}
+	name := strings.ToLower(strings.TrimRight(fields[1], ";"))
+	if name == "session" && len(fields) > 2 {
+		// SET SESSION name TO value
+		name = strings.ToLower(fields[2])
+	}
+	name, _, _ = strings.Cut(name, "=")
+	conn.session.settings = slices.DeleteFunc(conn.session.settings, func(s sessionSetting) bool {
+		return s.name == name || keyword == "RESET" && name == "all"
+	})
+	if keyword == "SET" {
+		conn.session.settings = append(conn.session.settings, sessionSetting{name: name, sql: query, parameters: params.Parameters})
+	}
+}
+
+// canRestoreSession reports whether err is the expiry of the pinned session of the connection, outside of a transaction.
+func (conn *redshiftDataConn) canRestoreSession(err error) bool {
+	if !conn.session.pinned || conn.session.id == "" || conn.inTx {
+		return false
+	}
+	var apiErr smithy.APIError
+	return stderrors.As(err, &apiErr) && strings.Contains(strings.ToLower(apiErr.ErrorMessage()), "session")
+}
+
+// restoreSession forgets the expired session, so the next statement starts a new one, and restores its state.
+func (conn *redshiftDataConn) restoreSession(ctx context.Context) error {
+	ctx = withoutClientToken(ctx)
+	conn.session.id = ""
+	settings := conn.session.settings
+	conn.session.settings = nil
+	for _, setting := range settings {
+		if _, _, err := conn.executeStatement(ctx, &redshiftdata.ExecuteStatementInput{Sql: aws.String(setting.sql), Parameters: setting.parameters}); err != nil {
+			return fmt.Errorf("restore session: %w", err)
+		}
+	}
+	if conn.session.restore != nil {
+		if err := conn.session.restore(ctx, &Session{conn: conn}); err != nil {
+			return fmt.Errorf("restore session: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,152 @@
+// Package shadow mirrors a fraction of read queries to a second target, for example a new serverless
+// workgroup, and reports latency and result divergences without affecting the primary workload.
+//
+// Mirroring is driven by hooks: register Shadow.Hooks on the primary config, and every sampled read
+// query that succeeded on the primary is replayed asynchronously on the shadow target.
+package shadow
+
+import (
+	"context"
+	"database/sql"
+	"math/rand/v2"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/adarsh-jaiss/metasql/hooks"
+	"github.com/adarsh-jaiss/metasql/resultdiff"
+)
+
+// Options configures a Shadow.
+type Options struct {
+	Target      *sql.DB            // Target is the database queries are mirrored to.
+	Primary     *sql.DB            // Primary, when set, is queried again to compare results, otherwise only latency and errors are compared.
+	Fraction    float64            // Fraction is the share of read queries that are mirrored, between 0 and 1.
+	Timeout     time.Duration      // Timeout bounds every mirrored query, defaults to one minute.
+	MaxInFlight int                // MaxInFlight bounds the mirrored queries running at once, further samples are dropped. Defaults to 4.
+	Diff        resultdiff.Options // Diff controls how results are compared.
+	Report      func(*Report)      // Report receives the outcome of every mirrored query.
+}
+
+// Report is the outcome of one mirrored query.
+type Report struct {
+	Query           string
+	PrimaryDuration time.Duration      // PrimaryDuration is the latency of the original execution.
+	ShadowDuration  time.Duration      // ShadowDuration is the latency of the mirrored execution.
+	ShadowErr       error              // ShadowErr is the error of the mirrored execution, if any.
+	Diff            *resultdiff.Report // Diff is set when results were compared.
+}
+
+// Diverged reports whether the shadow failed or returned different results.
+func (r *Report) Diverged() bool {
+	return r.ShadowErr != nil || (r.Diff != nil && !r.Diff.Equal())
+}
+
+// Shadow mirrors queries according to its Options.
+type Shadow struct {
+	opts     Options
+	inFlight chan struct{}
+	wg       sync.WaitGroup
+}
+
+type mirroredKey struct{}
+
+// New returns a Shadow for the given options.
+func New(opts Options) *Shadow {
+	if opts.Timeout <= 0 {
+		opts.Timeout = time.Minute
+	}
+	if opts.MaxInFlight <= 0 {
+		opts.MaxInFlight = 4
+	}
+	return &Shadow{
+		opts:     opts,
+		inFlight: make(chan struct{}, opts.MaxInFlight),
+	}
+}
+
+// Hooks returns the hooks to register on the primary config.
+func (s *Shadow) Hooks() *hooks.Hooks {
+	return &hooks.Hooks{
+		AfterStatement: s.afterStatement,
+	}
+}
+
+// Wait blocks until all mirrored queries started so far have been reported.
+func (s *Shadow) Wait() {
+	s.wg.Wait()
+}
+
+func (s *Shadow) afterStatement(ctx context.Context, e *hooks.StatementEvent) {
+	if e.Err != nil || e.Kind != hooks.KindQuery || !isRead(e.Query) {
+		return
+	}
+	if ctx.Value(mirroredKey{}) != nil {
+		return
+	}
+	if s.opts.Fraction <= 0 || rand.Float64() >= s.opts.Fraction {
+		return
+	}
+	select {
+	case s.inFlight <- struct{}{}:
+	default:
+		return
+	}
+	args := make([]any, 0, len(e.Args))
+	for _, arg := range e.Args {
+		if arg.Name != "" {
+			args = append(args, sql.Named(arg.Name, arg.Value))
+			continue
+		}
+		args = append(args, arg.Value)
+	}
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		defer func() { <-s.inFlight }()
+		report := s.mirror(e.Query, args)
+		report.PrimaryDuration = e.Duration
+		if s.opts.Report != nil {
+			s.opts.Report(report)
+		}
+	}()
+}
+
+func (s *Shadow) mirror(query string, args []any) *Report {
+	ctx, cancel := context.WithTimeout(context.Background(), s.opts.Timeout)
+	defer cancel()
+	ctx = context.WithValue(ctx, mirroredKey{}, true)
+
+	report := &Report{Query: query}
+	start := time.Now()
+	shadowResult, err := resultdiff.Query(ctx, s.opts.Target, s.opts.Diff, query, args...)
+	report.ShadowDuration = time.Since(start)
+	if err != nil {
+		report.ShadowErr = err
+		return report
+	}
+	if s.opts.Primary == nil {
+		return report
+	}
+	primaryResult, err := resultdiff.Query(ctx, s.opts.Primary, s.opts.Diff, query, args...)
+	if err != nil {
+		// the primary succeeded a moment ago, a failure now says nothing about the shadow
+		return report
+	}
+	report.Diff = resultdiff.Compare(primaryResult, shadowResult, s.opts.Diff)
+	return report
+}
+
+// isRead reports whether the query is a plain read that is safe to replay.
+func isRead(query string) bool {
+	fields := strings.Fields(strings.TrimLeft(query, " \t\r\n("))
+	if len(fields) == 0 {
+		return false
+	}
+	switch strings.ToUpper(fields[0]) {
+	case "SELECT", "WITH":
+		upper := strings.ToUpper(query)
+		return !strings.Contains(upper, " INTO ") && !strings.Contains(upper, "INSERT ") && !strings.Contains(upper, "DELETE ") && !strings.Contains(upper, "UPDATE ")
+	}
+	return false
+}
@@ -0,0 +1,52 @@
+package metasql
+
+import (
+	"context"
+
+	"github.com/adarsh-jaiss/metasql/utils"
+	"github.com/aws/aws-sdk-go-v2/service/redshiftdata"
+)
+
+// ResultSize is the size of a result set, as reported by DescribeStatement once the statement finished.
+type ResultSize struct {
+	Rows  int64 // Rows is the number of rows of the result set, -1 when unknown.
+	Bytes int64 // Bytes is the size of the result set in bytes, -1 when unknown.
+}
+
+// SizeHinter is implemented by the rows of the driver, to tell the size of a result set before it is read.
+type SizeHinter interface {
+	SizeHint() ResultSize
+}
+
+type resultSizeKey struct{}
+
+// WithResultSize returns a context under which queries call fn with the size of their result set before its
+// first page is fetched. An error returned by fn fails the query, a UI can for example refuse huge results
+// and offer an UNLOAD instead.
+func WithResultSize(ctx context.Context, fn func(ResultSize) error) context.Context {
+	return context.WithValue(ctx, resultSizeKey{}, fn)
+}
+
+// queryRows reports the size of the result of a finished statement and returns its rows.
+func (conn *redshiftDataConn) queryRows(ctx context.Context, desc *redshiftdata.DescribeStatementOutput, p *redshiftdata.GetStatementResultPaginator) (*redshiftDataRows, error) {
+	size := ResultSize{Rows: desc.ResultRows, Bytes: desc.ResultSize}
+	if fn, ok := ctx.Value(resultSizeKey{}).(func(ResultSize) error); ok && fn != nil {
+		if err := fn(size); err != nil {
+			return nil, err
+		}
+	}
+	rows, err := conn.newRows(ctx, utils.Coalesce(desc.Id), p)
+	if err != nil {
+		return nil, err
+	}
+	rows.size = size
+	if err := reportRowsMetadata(ctx, rows); err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
+// SizeHint returns the size of the result set reported when the statement finished.
+func (rows *redshiftDataRows) SizeHint() ResultSize {
+	return rows.size
+}
@@ -0,0 +1,61 @@
+package metasql
+
+import (
+	"context"
+	"database/sql/driver"
+
+	cfg "github.com/adarsh-jaiss/metasql/config"
+)
+
+// redshiftDataStmt implements driver.Stmt on the client side, the Data API has no server-side prepare outside
+// sessions, see PrepareServerStatement. Preparing only counts the placeholders of the query, the query is rewritten
+// and its arguments are bound as Data API parameters every time it is executed, as for unprepared statements.
+type redshiftDataStmt struct {
+	conn     *redshiftDataConn
+	query    string
+	numInput int
+}
+
+// numInput returns the number of arguments the query expects, -1 when it has named placeholders, whose arguments
+// database/sql can not count, including the ones bound by default parameters. Arguments are not counted in the
+// PlaceholdersNone style either.
+func numInput(query string, style string) int {
+	ph := scanPlaceholders(query, style)
+	if len(ph.named) > 0 || style == cfg.PlaceholdersNone {
+		return -1
+	}
+	return len(ph.positional)
+}
+
+func (stmt *redshiftDataStmt) Close() error {
+	return nil
+}
+
+func (stmt *redshiftDataStmt) NumInput() int {
+	return stmt.numInput
+}
+
+func (stmt *redshiftDataStmt) Exec(args []driver.Value) (driver.Result, error) {
+	return stmt.ExecContext(context.Background(), namedValuesOf(args))
+}
+
+func (stmt *redshiftDataStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	return stmt.conn.ExecContext(ctx, stmt.query, args)
+}
+
+func (stmt *redshiftDataStmt) Query(args []driver.Value) (driver.Rows, error) {
+	return stmt.QueryContext(context.Background(), namedValuesOf(args))
+}
+
+func (stmt *redshiftDataStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	return stmt.conn.QueryContext(ctx, stmt.query, args)
+}
+
+// namedValuesOf numbers the arguments of the legacy driver.Stmt methods.
+func namedValuesOf(args []driver.Value) []driver.NamedValue {
+	values := make([]driver.NamedValue, len(args))
+	for i, v := range args {
+		values[i] = driver.NamedValue{Ordinal: i + 1, Value: v}
+	}
+	return values
+}
@@ -0,0 +1,164 @@
+package metasql
+
+import (
+	"context"
+	"database/sql/driver"
+	"strings"
+	"sync"
+	"time"
+
+	cfg "github.com/adarsh-jaiss/metasql/config"
+	"github.com/adarsh-jaiss/metasql/hooks"
+	"github.com/adarsh-jaiss/metasql/utils"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/redshiftdata"
+	awstypes "github.com/aws/aws-sdk-go-v2/service/redshiftdata/types"
+)
+
+// tableMetadataCache caches the column metadata returned by DescribeTable, which is more precise than the
+// metadata of result sets: it knows the declared type and nullability of every column of a table.
+// Entries expire after ttl, failed lookups are cached as well so a missing permission costs a single call.
+type tableMetadataCache struct {
+	ttl      time.Duration
+	mu       sync.Mutex
+	entries  map[string]*tableMetadataEntry
+	comments map[string]*tableCommentsEntry
+}
+
+type tableMetadataEntry struct {
+	columns map[string]awstypes.ColumnMetadata // columns is keyed by lower-cased column name.
+	expires time.Time
+}
+
+type tableCommentsEntry struct {
+	comments map[string]string // comments is keyed by lower-cased column name.
+	expires  time.Time
+}
+
+// newTableMetadataCache returns a cache with the given ttl, or nil when ttl disables caching.
+func newTableMetadataCache(ttl time.Duration) *tableMetadataCache {
+	if ttl <= 0 {
+		return nil
+	}
+	return &tableMetadataCache{
+		ttl:      ttl,
+		entries:  make(map[string]*tableMetadataEntry),
+		comments: make(map[string]*tableCommentsEntry),
+	}
+}
+
+// lookup returns the metadata of the columns of schema.table, describing the table when it is not cached.
+// It returns nil when the client can not describe tables or the table could not be described.
+func (c *tableMetadataCache) lookup(ctx context.Context, client RedshiftDataClient, config *cfg.RedshiftDataConfig, schema, table string) map[string]awstypes.ColumnMetadata {
+	database, dbUser, secretArn, err := routeTarget(ctx, config)
+	if err != nil {
+		return nil
+	}
+	key := utils.Coalesce(database) + "." + schema + "." + table
+	c.mu.Lock()
+	entry, ok := c.entries[key]
+	c.mu.Unlock()
+	if ok && time.Now().Before(entry.expires) {
+		return entry.columns
+	}
+
+	columns := describeTable(ctx, client, config, database, dbUser, secretArn, schema, table)
+	c.mu.Lock()
+	c.entries[key] = &tableMetadataEntry{columns: columns, expires: time.Now().Add(c.ttl)}
+	c.mu.Unlock()
+	return columns
+}
+
+func describeTable(ctx context.Context, client RedshiftDataClient, config *cfg.RedshiftDataConfig, database, dbUser, secretArn *string, schema, table string) map[string]awstypes.ColumnMetadata {
+	describer, ok := client.(redshiftdata.DescribeTableAPIClient)
+	if !ok {
+		return nil
+	}
+	p := redshiftdata.NewDescribeTablePaginator(describer, &redshiftdata.DescribeTableInput{
+		ClusterIdentifier: config.ClusterIdentifier,
+		Database:          database,
+		DbUser:            dbUser,
+		WorkgroupName:     config.WorkgroupName,
+		SecretArn:         secretArn,
+		Schema:            utils.Nullif(schema),
+		Table:             aws.String(table),
+	})
+	columns := make(map[string]awstypes.ColumnMetadata)
+	for p.HasMorePages() {
+		page, err := p.NextPage(ctx, config.RedshiftDataOptFns...)
+		if err != nil {
+			return nil
+		}
+		for _, col := range page.ColumnList {
+			if !strings.EqualFold(utils.Coalesce(col.TableName), table) {
+				continue
+			}
+			columns[strings.ToLower(utils.Coalesce(col.Name))] = col
+		}
+	}
+	return columns
+}
+
+// resolveColumns replaces the type, nullability and size of result columns originating from a table with
+// the ones declared by the table.
+func (c *tableMetadataCache) resolveColumns(ctx context.Context, client RedshiftDataClient, config *cfg.RedshiftDataConfig, columns []awstypes.ColumnMetadata) {
+	for i, col := range columns {
+		table := utils.Coalesce(col.TableName)
+		if table == "" {
+			continue
+		}
+		declared, ok := c.lookup(ctx, client, config, utils.Coalesce(col.SchemaName), table)[strings.ToLower(utils.Coalesce(col.Name))]
+		if !ok {
+			continue
+		}
+		columns[i].TypeName = declared.TypeName
+		columns[i].Nullable = declared.Nullable
+		columns[i].Length = declared.Length
+		columns[i].Precision = declared.Precision
+		columns[i].Scale = declared.Scale
+	}
+}
+
+// columnComments returns the comments of the columns of schema.table, querying SVV_COLUMNS on conn when they
+// are not cached. It returns nil when the comments could not be queried.
+func (c *tableMetadataCache) columnComments(ctx context.Context, conn *redshiftDataConn, schema, table string) map[string]string {
+	database, _, _, err := routeTarget(conn.withDatabase(ctx), conn.cfg)
+	if err != nil {
+		return nil
+	}
+	key := utils.Coalesce(database) + "." + schema + "." + table
+	c.mu.Lock()
+	entry, ok := c.comments[key]
+	c.mu.Unlock()
+	if ok && time.Now().Before(entry.expires) {
+		return entry.comments
+	}
+
+	comments := queryColumnComments(ctx, conn, schema, table)
+	c.mu.Lock()
+	c.comments[key] = &tableCommentsEntry{comments: comments, expires: time.Now().Add(c.ttl)}
+	c.mu.Unlock()
+	return comments
+}
+
+func queryColumnComments(ctx context.Context, conn *redshiftDataConn, schema, table string) map[string]string {
+	p, _, err := conn.runStatement(withoutClientToken(ctx), hooks.KindQuery, "SELECT column_name, remarks FROM svv_columns WHERE table_schema = :schema AND table_name = :table AND remarks IS NOT NULL", []driver.NamedValue{
+		{Name: "schema", Ordinal: 1, Value: schema},
+		{Name: "table", Ordinal: 2, Value: table},
+	})
+	if err != nil {
+		return nil
+	}
+	rows, err := newRows(ctx, "", p)
+	if err != nil {
+		return nil
+	}
+	comments := make(map[string]string)
+	dest := make([]driver.Value, 2)
+	for rows.Next(dest) == nil {
+		name, _ := dest[0].(string)
+		comment, _ := dest[1].(string)
+		comments[strings.ToLower(name)] = comment
+	}
+	return comments
+}
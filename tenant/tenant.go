@@ -0,0 +1,75 @@
+// Package tenant routes statements to a database per tenant, for database-per-tenant architectures
+// sharing a single sql.DB:
+//
+//	router := &tenant.Router{Resolve: tenant.Databases(map[string]string{"acme": "acme_db"})}
+//	cfg.WithHooks(router.Hooks())
+//	rows, err := db.QueryContext(tenant.WithTenant(ctx, "acme"), "SELECT ...")
+package tenant
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/adarsh-jaiss/metasql/errors"
+	"github.com/adarsh-jaiss/metasql/hooks"
+)
+
+type tenantKey struct{}
+
+// WithTenant returns a context carrying the tenant key.
+func WithTenant(ctx context.Context, tenant string) context.Context {
+	return context.WithValue(ctx, tenantKey{}, tenant)
+}
+
+// FromContext returns the tenant key carried by the context.
+func FromContext(ctx context.Context) (string, bool) {
+	tenant, ok := ctx.Value(tenantKey{}).(string)
+	return tenant, ok && tenant != ""
+}
+
+// Resolver returns the database of a tenant, along with the ARN of the secret to connect with.
+// An empty secret ARN keeps the one of the config.
+type Resolver func(ctx context.Context, tenant string) (database, secretArn string, err error)
+
+// Databases returns a Resolver for a static mapping of tenants to databases.
+func Databases(databases map[string]string) Resolver {
+	return func(ctx context.Context, tenant string) (string, string, error) {
+		database, ok := databases[tenant]
+		if !ok {
+			return "", "", fmt.Errorf("%w: %q", errors.ErrTenantUnknown, tenant)
+		}
+		return database, "", nil
+	}
+}
+
+// Router switches the database of every statement to the one of the tenant in its context.
+type Router struct {
+	Resolve  Resolver // Resolve maps tenants to databases.
+	Required bool     // Required fails statements without tenant, instead of running them against the configured database.
+}
+
+// Hooks returns the hooks to register on the config with RedshiftDataConfig.WithHooks.
+func (r *Router) Hooks() *hooks.Hooks {
+	return &hooks.Hooks{
+		Route: r.route,
+	}
+}
+
+func (r *Router) route(ctx context.Context, target *hooks.Target) error {
+	tenant, ok := FromContext(ctx)
+	if !ok {
+		if r.Required {
+			return errors.ErrTenantMissing
+		}
+		return nil
+	}
+	database, secretArn, err := r.Resolve(ctx, tenant)
+	if err != nil {
+		return err
+	}
+	target.Database = database
+	if secretArn != "" {
+		target.SecretArn = secretArn
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+package metasql
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	cfg "github.com/adarsh-jaiss/metasql/config"
+)
+
+// timestampLayout is the layout of Redshift TIMESTAMP literals, which have microsecond precision.
+const timestampLayout = "2006-01-02 15:04:05.999999"
+
+// formatTimestamp formats t as a Redshift TIMESTAMP literal in UTC. Sub-microsecond precision is truncated,
+// or reported as an error when the precision mode is cfg.TimestampPrecisionError.
+func formatTimestamp(t time.Time, precision string) (string, error) {
+	truncated := t.Truncate(time.Microsecond)
+	if precision == cfg.TimestampPrecisionError && !truncated.Equal(t) {
+		return "", fmt.Errorf("timestamp %s has sub-microsecond precision which Redshift can not store", t.Format(time.RFC3339Nano))
+	}
+	return truncated.UTC().Format(timestampLayout), nil
+}
+
+// timestamptzLayouts are the layouts of the TIMESTAMPTZ values returned by the Data API, whose offsets are
+// written +HH unless the time zone has minutes.
+var timestamptzLayouts = []string{timestampLayout + "-07", timestampLayout + "-07:00"}
+
+// isTimestamp reports whether the column type is TIMESTAMP or TIMESTAMPTZ, and whether it has a time zone.
+func isTimestamp(typeName string) (timestamp, withZone bool) {
+	switch typeName {
+	case "timestamp", "timestamp without time zone":
+		return true, false
+	case "timestamptz", "timestamp with time zone":
+		return true, true
+	}
+	return false, false
+}
+
+// parseTimestamp parses a TIMESTAMP or TIMESTAMPTZ value. Zone-less values are read in loc, values with an offset
+// are converted to loc so all the values of a result set share a location.
+func parseTimestamp(v string, withZone bool, loc *time.Location) (time.Time, error) {
+	if strings.HasSuffix(v, " BC") {
+		return time.Time{}, fmt.Errorf("timestamp %q is before year 1, which time.Time can not represent", v)
+	}
+	if !withZone {
+		t, err := time.ParseInLocation(timestampLayout, v, loc)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid timestamp %q", v)
+		}
+		return t, nil
+	}
+	for _, layout := range timestamptzLayouts {
+		if t, err := time.Parse(layout, v); err == nil {
+			return t.In(loc), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid timestamptz %q", v)
+}
@@ -0,0 +1,127 @@
+package metasql
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"time"
+
+	cfg "github.com/adarsh-jaiss/metasql/config"
+	"github.com/adarsh-jaiss/metasql/errors"
+	"github.com/adarsh-jaiss/metasql/hooks"
+	"github.com/adarsh-jaiss/metasql/types"
+	"github.com/adarsh-jaiss/metasql/utils"
+)
+
+// sessionsEnabled returns an unsupported error when the sessions feature is disabled on the config.
+func (conn *redshiftDataConn) sessionsEnabled() error {
+	if !conn.cfg.Features.Enabled(cfg.FeatureSessions) {
+		return errors.NewUnsupportedError("sessions", errors.LimitationDriver, "the sessions feature is disabled")
+	}
+	return nil
+}
+
+type sessionTxKey struct{}
+
+// withSessionTx returns a context whose transactions run in a Data API session whatever the TxMode of the config,
+// for helpers reading within their own transaction.
+func withSessionTx(ctx context.Context) context.Context {
+	return context.WithValue(ctx, sessionTxKey{}, true)
+}
+
+// sessionTx reports whether transactions begun with the context run in a Data API session.
+func sessionTx(ctx context.Context, config *cfg.RedshiftDataConfig) bool {
+	inSession, _ := ctx.Value(sessionTxKey{}).(bool)
+	return inSession || config.TxMode == cfg.TxModeSession
+}
+
+// beginSession starts a transaction running in a Data API session: BEGIN, the statements of the transaction and
+// COMMIT or ROLLBACK are executed as they come, so queries, arguments and reads of the transaction's own writes
+// work as outside transactions. Connections not pinned to a session are pinned until the transaction ends.
+func (conn *redshiftDataConn) beginSession(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if err := conn.sessionsEnabled(); err != nil {
+		return nil, err
+	}
+	pinned := conn.session.pinned
+	if !pinned {
+		conn.session = dataAPISession{pinned: true, keepAlive: int32(DefaultSessionKeepAlive / time.Second)}
+	}
+	begin := "BEGIN"
+	if opts.ReadOnly {
+		begin = "BEGIN READ ONLY"
+	}
+	if _, _, err := conn.runStatement(withoutClientToken(ctx), hooks.KindExec, begin, nil); err != nil {
+		if !pinned {
+			conn.session = dataAPISession{}
+		}
+		return nil, fmt.Errorf("begin error: %w", err)
+	}
+
+	conn.inTx = true
+	conn.sessionTx = true
+	conn.txOpts = opts
+	cleanup := func() {
+		conn.inTx = false
+		conn.sessionTx = false
+		conn.txOpts = driver.TxOptions{}
+		conn.sqls = nil
+		if !pinned {
+			conn.session = dataAPISession{}
+		}
+	}
+	txEvent := func() *hooks.TxEvent {
+		return &hooks.TxEvent{
+			Statements:  append([]string(nil), conn.sqls...),
+			Conn:        conn.info,
+			Correlation: hooks.Correlate(ctx, conn.cfg.Hooks),
+		}
+	}
+	end := func(ctx context.Context, statement string) (string, error) {
+		_, output, err := conn.runStatement(withoutClientToken(ctx), hooks.KindExec, statement, nil)
+		if output != nil {
+			return utils.Coalesce(output.Id), err
+		}
+		return "", err
+	}
+
+	return &types.RedshiftDataTx{
+		OnRollback: func() error {
+			if !conn.inTx {
+				return errors.ErrNotInTx
+			}
+			defer cleanup()
+			event := txEvent()
+			// database/sql rolls back when the context of the transaction is done, ROLLBACK must still be sent
+			event.StatementID, event.Err = end(context.WithoutCancel(ctx), "ROLLBACK")
+			hooks.AfterRollback(ctx, conn.cfg.Hooks, event)
+			if event.Err != nil {
+				return fmt.Errorf("rollback error: %w", event.Err)
+			}
+			return nil
+		},
+
+		OnCommit: func() error {
+			if !conn.inTx {
+				return errors.ErrNotInTx
+			}
+			defer cleanup()
+			event := txEvent()
+			if err := hooks.BeforeCommit(ctx, conn.cfg.Hooks, event); err != nil {
+				end(context.WithoutCancel(ctx), "ROLLBACK")
+				event.Err = err
+				hooks.AfterRollback(ctx, conn.cfg.Hooks, event)
+				return fmt.Errorf("commit error: %w", err)
+			}
+			start := time.Now()
+			event.StatementID, event.Err = end(ctx, "COMMIT")
+			event.Duration = time.Since(start)
+			if event.Err != nil {
+				end(context.WithoutCancel(ctx), "ROLLBACK")
+				hooks.AfterRollback(ctx, conn.cfg.Hooks, event)
+				return fmt.Errorf("commit error: %w", event.Err)
+			}
+			hooks.AfterCommit(ctx, conn.cfg.Hooks, event)
+			return nil
+		},
+	}, nil
+}
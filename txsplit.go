@@ -0,0 +1,79 @@
+package metasql
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+
+	"github.com/adarsh-jaiss/metasql/errors"
+	"github.com/adarsh-jaiss/metasql/hooks"
+	"github.com/adarsh-jaiss/metasql/utils"
+	"github.com/aws/aws-sdk-go-v2/service/redshiftdata"
+)
+
+// maxBatchBytes is the total size of the statements of one BatchExecuteStatement request the Data API accepts.
+const maxBatchBytes = 100 * 1024
+
+// splitKeepAlive is the keep-alive, in seconds, of the session a split transaction runs in.
+const splitKeepAlive = 60
+
+// splitBatches groups the statements into batches within the statement count and size limits of
+// BatchExecuteStatement, keeping their order. A statement larger than the size limit gets a batch of its own.
+func splitBatches(sqls []string) [][]string {
+	var batches [][]string
+	var batch []string
+	size := 0
+	for _, sql := range sqls {
+		if len(batch) > 0 && (len(batch) == maxBatchStatements || size+len(sql) > maxBatchBytes) {
+			batches = append(batches, batch)
+			batch, size = nil, 0
+		}
+		batch = append(batch, sql)
+		size += len(sql)
+	}
+	if len(batch) > 0 {
+		batches = append(batches, batch)
+	}
+	return batches
+}
+
+// commitSplit executes a transaction too large for one BatchExecuteStatement as several batches. They run in
+// one Data API session between an explicit BEGIN and END, so the transaction stays atomic: when a batch fails
+// the transaction is rolled back. BEGIN and END are submitted without the token of the caller, the batches with
+// tokens derived from it, see WithClientToken. Connections not pinned to a session are pinned for the duration of the commit.
+// It returns the statement id of the last batch submitted.
+func (conn *redshiftDataConn) commitSplit(ctx context.Context, batches [][]string) (string, error) {
+	if !conn.session.pinned {
+		conn.session = dataAPISession{pinned: true, keepAlive: splitKeepAlive}
+		defer func() { conn.session = dataAPISession{} }()
+	}
+	if _, _, err := conn.runStatement(withoutClientToken(ctx), hooks.KindExec, "BEGIN", nil); err != nil {
+		return "", fmt.Errorf("begin split transaction: %w", err)
+	}
+	id := ""
+	offset := 0
+	for i, batch := range batches {
+		output, desc, err := conn.BatchExecuteStatement(withStatementToken(ctx, i), &redshiftdata.BatchExecuteStatementInput{Sqls: batch})
+		if output != nil {
+			id = utils.Coalesce(output.Id)
+		}
+		if err != nil {
+			var statementErr *errors.StatementError
+			if stderrors.As(err, &statementErr) && statementErr.SubStatement > 0 {
+				statementErr.SubStatement += offset
+			}
+			conn.runStatement(withoutClientToken(context.WithoutCancel(ctx)), hooks.KindExec, "ROLLBACK", nil)
+			return id, err
+		}
+		for i := range batch {
+			if r := conn.delayedResult[offset+i]; r != nil {
+				r.Result = NewResultWithSubStatementData(desc.SubStatements[i])
+			}
+		}
+		offset += len(batch)
+	}
+	if _, _, err := conn.runStatement(withoutClientToken(ctx), hooks.KindExec, "END", nil); err != nil {
+		return id, fmt.Errorf("end split transaction: %w", err)
+	}
+	return id, nil
+}
@@ -0,0 +1,147 @@
+package metasql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// upsertStagingTable is the temporary table Upsert loads the rows into before merging them.
+const upsertStagingTable = "metasql_upsert_staging"
+
+// UpsertOptions controls how Upsert merges rows into a table.
+type UpsertOptions struct {
+	Keys          []string // Keys are the columns identifying a row, they must be among the columns of the rows.
+	UpdateColumns []string // UpdateColumns are the columns updated on rows already in the table, all the columns but the keys when empty.
+	DeleteInsert  bool     // DeleteInsert merges with UPDATE or DELETE and INSERT statements, for clusters predating MERGE.
+}
+
+// Upsert inserts rows into table, updating the rows of the table whose key columns match instead of inserting them,
+// the equivalent of INSERT ... ON CONFLICT (keys) DO UPDATE. It returns the number of rows updated or inserted.
+//
+// The rows are loaded into a temporary staging table with multi-row INSERT statements, as BulkInsert does, then
+// merged into table with MERGE. With DeleteInsert the matching rows are deleted and all the staged rows inserted,
+// or the UpdateColumns of the matching rows are updated when only some columns are updated, and the other rows
+// inserted. Everything runs in one transaction within a Data API session, so a failure leaves the table unchanged.
+func Upsert(ctx context.Context, db *sql.DB, table string, columns []string, rows [][]any, opts UpsertOptions) (int64, error) {
+	updates, err := opts.updateColumns(columns)
+	if err != nil {
+		return 0, fmt.Errorf("upsert into %s: %w", table, err)
+	}
+	if len(rows) == 0 {
+		return 0, nil
+	}
+	ctx = withoutClientToken(ctx)
+	tx, err := db.BeginTx(withSessionTx(ctx), nil)
+	if err != nil {
+		return 0, fmt.Errorf("upsert into %s: %w", table, err)
+	}
+	defer tx.Rollback()
+	stage := fmt.Sprintf("CREATE TEMP TABLE %s AS SELECT %s FROM %s WHERE FALSE", upsertStagingTable, strings.Join(columns, ", "), table)
+	if _, err := tx.ExecContext(ctx, stage); err != nil {
+		return 0, fmt.Errorf("upsert into %s: create staging table: %w", table, err)
+	}
+	if _, err := bulkInsert(ctx, tx, upsertStagingTable, columns, rows); err != nil {
+		return 0, fmt.Errorf("upsert into %s: %w", table, err)
+	}
+	var total int64
+	for _, query := range upsertStatements(table, columns, updates, opts) {
+		res, err := tx.ExecContext(ctx, query)
+		if err != nil {
+			return 0, fmt.Errorf("upsert into %s: %w", table, err)
+		}
+		if n, err := res.RowsAffected(); err == nil && !strings.HasPrefix(query, "DELETE") {
+			total += n
+		}
+	}
+	if _, err := tx.ExecContext(ctx, "DROP TABLE "+upsertStagingTable); err != nil {
+		return 0, fmt.Errorf("upsert into %s: drop staging table: %w", table, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("upsert into %s: %w", table, err)
+	}
+	return total, nil
+}
+
+// updateColumns checks the keys and update columns of the options against the columns of the rows and returns
+// the columns to update.
+func (opts UpsertOptions) updateColumns(columns []string) ([]string, error) {
+	if len(opts.Keys) == 0 {
+		return nil, fmt.Errorf("no key columns")
+	}
+	for _, key := range opts.Keys {
+		if !slices.Contains(columns, key) {
+			return nil, fmt.Errorf("key column %s is not among the columns", key)
+		}
+	}
+	if len(opts.UpdateColumns) == 0 {
+		var updates []string
+		for _, c := range columns {
+			if !slices.Contains(opts.Keys, c) {
+				updates = append(updates, c)
+			}
+		}
+		return updates, nil
+	}
+	for _, c := range opts.UpdateColumns {
+		if !slices.Contains(columns, c) {
+			return nil, fmt.Errorf("update column %s is not among the columns", c)
+		}
+		if slices.Contains(opts.Keys, c) {
+			return nil, fmt.Errorf("update column %s is a key column", c)
+		}
+	}
+	return opts.UpdateColumns, nil
+}
+
+// upsertStatements returns the statements merging the staging table into table.
+func upsertStatements(table string, columns, updates []string, opts UpsertOptions) []string {
+	list := strings.Join(columns, ", ")
+	staged := prefixColumns("s.", columns)
+	if !opts.DeleteInsert {
+		query := fmt.Sprintf("MERGE INTO %s AS t USING %s AS s ON %s", table, upsertStagingTable, keyCondition("t.", opts.Keys))
+		if len(updates) > 0 {
+			query += " WHEN MATCHED THEN UPDATE SET " + strings.Join(assignments(updates), ", ")
+		}
+		return []string{query + fmt.Sprintf(" WHEN NOT MATCHED THEN INSERT (%s) VALUES (%s)", list, strings.Join(staged, ", "))}
+	}
+	matching := keyCondition(table+".", opts.Keys)
+	if len(updates) == len(columns)-len(opts.Keys) {
+		return []string{
+			fmt.Sprintf("DELETE FROM %s USING %s AS s WHERE %s", table, upsertStagingTable, matching),
+			fmt.Sprintf("INSERT INTO %s (%s) SELECT %s FROM %s AS s", table, list, strings.Join(staged, ", "), upsertStagingTable),
+		}
+	}
+	return []string{
+		fmt.Sprintf("UPDATE %s SET %s FROM %s AS s WHERE %s", table, strings.Join(assignments(updates), ", "), upsertStagingTable, matching),
+		fmt.Sprintf("INSERT INTO %s (%s) SELECT %s FROM %s AS s WHERE NOT EXISTS (SELECT 1 FROM %s WHERE %s)",
+			table, list, strings.Join(staged, ", "), upsertStagingTable, table, matching),
+	}
+}
+
+// keyCondition matches the key columns of the rows of the table referred to by prefix with the staged rows.
+func keyCondition(prefix string, keys []string) string {
+	conds := make([]string, len(keys))
+	for i, key := range keys {
+		conds[i] = prefix + key + " = s." + key
+	}
+	return strings.Join(conds, " AND ")
+}
+
+func assignments(columns []string) []string {
+	set := make([]string, len(columns))
+	for i, c := range columns {
+		set[i] = c + " = s." + c
+	}
+	return set
+}
+
+func prefixColumns(prefix string, columns []string) []string {
+	prefixed := make([]string, len(columns))
+	for i, c := range columns {
+		prefixed[i] = prefix + c
+	}
+	return prefixed
+}
@@ -0,0 +1,45 @@
+package metasql
+
+import (
+	"context"
+	"time"
+
+	"github.com/adarsh-jaiss/metasql/hooks"
+	"github.com/aws/aws-sdk-go-v2/service/redshiftdata"
+)
+
+// warehouseWait splits the lifetime of a finished statement, as reported by DescribeStatement, into the time it
+// waited on the warehouse and the time it executed. The wait covers queueing, serverless cold starts and
+// concurrency scaling, none of which is spent in the driver.
+func warehouseWait(desc *redshiftdata.DescribeStatementOutput) (wait, execution time.Duration, ok bool) {
+	if desc.CreatedAt == nil || desc.UpdatedAt == nil {
+		return 0, 0, false
+	}
+	execution = time.Duration(desc.Duration)
+	if execution < 0 {
+		execution = 0
+	}
+	wait = desc.UpdatedAt.Sub(*desc.CreatedAt) - execution
+	if wait < 0 {
+		wait = 0
+	}
+	return wait, execution, true
+}
+
+// reportWarehouseWait calls the WarehouseWait hooks when the statement waited on the warehouse for at least the
+// configured threshold.
+func (conn *redshiftDataConn) reportWarehouseWait(ctx context.Context, e *hooks.StatementEvent, desc *redshiftdata.DescribeStatementOutput) {
+	wait, execution, ok := warehouseWait(desc)
+	if !ok || wait < conn.cfg.GetWarehouseWaitThreshold() {
+		return
+	}
+	hooks.WarehouseWait(ctx, conn.cfg.Hooks, &hooks.WarehouseWaitEvent{
+		Query:       e.Query,
+		StatementID: e.StatementID,
+		Conn:        e.Conn,
+		Correlation: e.Correlation,
+		Serverless:  desc.WorkgroupName != nil || conn.cfg.WorkgroupName != nil,
+		Wait:        wait,
+		Execution:   execution,
+	})
+}
@@ -0,0 +1,65 @@
+package metasql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// Warmup opens n connections of db so the first requests of a service do not pay for creating Data API clients,
+// and runs a trivial query to wake a paused serverless workgroup. The metadata of the tables, given as
+// [schema.]table with unqualified names in public, is loaded into the DescribeTable cache when the connector
+// has one. The connections are returned to the pool, which only keeps them when db.SetMaxIdleConns allows n idle
+// connections.
+func Warmup(ctx context.Context, db *sql.DB, n int, tables ...string) error {
+	if n < 1 {
+		n = 1
+	}
+	conns := make([]*sql.Conn, n)
+	errs := make([]error, n)
+	var wg sync.WaitGroup
+	for i := range conns {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			conns[i], errs[i] = db.Conn(ctx)
+		}(i)
+	}
+	wg.Wait()
+	defer func() {
+		for _, c := range conns {
+			if c != nil {
+				c.Close()
+			}
+		}
+	}()
+	for _, err := range errs {
+		if err != nil {
+			return fmt.Errorf("warmup: %w", err)
+		}
+	}
+
+	if _, err := conns[0].ExecContext(withoutClientToken(ctx), "SELECT 1"); err != nil {
+		return fmt.Errorf("warmup: %w", err)
+	}
+	if len(tables) == 0 {
+		return nil
+	}
+	return rawConn(conns[0], func(conn *redshiftDataConn) error {
+		if conn.tables == nil {
+			return nil
+		}
+		for _, t := range tables {
+			schema, table, ok := strings.Cut(t, ".")
+			if !ok {
+				schema, table = "public", t
+			}
+			if conn.tables.lookup(conn.withDatabase(ctx), conn.client, conn.cfg, schema, table) == nil {
+				return fmt.Errorf("warmup: table %s could not be described", t)
+			}
+		}
+		return nil
+	})
+}